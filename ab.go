@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"app/engine"
+)
+
+// copyGrids makes the layers of b start from the cells and turmites of the
+// corresponding layers of a, so that an a/b comparison only differs in how
+// the two evolve.
+func copyGrids(a, b []*layer) error {
+	if len(a) != len(b) {
+		return fmt.Errorf("panes have %d and %d layers", len(a), len(b))
+	}
+	for i := range a {
+		if err := b[i].e.Restore(a[i].e.Snapshot()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fork makes the layers of b a copy of those of a, as copyGrids does, with
+// both paused meanwhile so that the copy is exact, then runs the command
+// cmd of commander, if any, on b, so that the two differ in that alone.
+func fork(a, b []*layer, cmd string) error {
+	for _, l := range slices.Concat(a, b) {
+		if !l.e.Paused() {
+			l.e.Pause()
+			defer l.e.Resume()
+		}
+	}
+	if err := copyGrids(a, b); err != nil {
+		return err
+	}
+	if cmd == "" {
+		return nil
+	}
+	if err := (&commander{b}).run(cmd); err != nil {
+		return fmt.Errorf("fork: %w", err)
+	}
+	return nil
+}
+
+// divergence returns the Hamming distance between a and b, which must be
+// the same size: the number of cells whose species differ.
+func divergence(a, b *engine.Engine) int {
+	differ := 0
+	for i := 0; i < a.Rows(); i++ {
+		for j := 0; j < a.Cols(); j++ {
+			if a.Cell(i, j).Species != b.Cell(i, j).Species {
+				differ++
+			}
+		}
+	}
+	return differ
+}
+
+// trackDivergence starts the status line of b with how far the layer it shows
+// has diverged from the same layer of a, refreshed every statsInterval.
+func trackDivergence(a, b *display) {
+	var differ atomic.Int64
+	differ.Store(-1) // until measured
+	go b.every(statsInterval, func(time.Time) {
+		k := b.current.Load()
+		differ.Store(int64(divergence(a.layers[k].e, b.layers[k].e)))
+	})
+	b.status = append([]func() string{func() string {
+		n := differ.Load()
+		if n < 0 {
+			return ""
+		}
+		e := b.layer().e
+		return fmt.Sprintf("diverged %d cells, %.1f%%, from the left", n, 100*float64(n)/float64(e.Rows()*e.Cols()))
+	}}, b.status...)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/gob"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// runAttach implements the "attach" subcommand: a viewer of a simulation
+// run by "daemon", which keeps running when the viewer detaches. The mouse
+// paints cells of the brush species on the shown layer, as in edit mode.
+func runAttach(args []string) {
+	fs := flag.NewFlagSet("attach", flag.ExitOnError)
+	socket := fs.String("socket", socketPath, "Unix socket of the daemon")
+	fs.Parse(args)
+	conn, err := net.Dial("unix", *socket)
+	if err != nil {
+		log.Fatalf("attaching: %v", err)
+	}
+	defer conn.Close()
+	v := &viewer{enc: gob.NewEncoder(conn), brush: 1}
+	dec := gob.NewDecoder(conn)
+	if err := dec.Decode(&v.hello); err != nil {
+		log.Fatalf("attaching: %v", err)
+	}
+
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		log.Fatalf("creating screen: %v", err)
+	}
+	if err = screen.Init(); err != nil {
+		log.Fatalf("initializing screen: %v", err)
+	}
+	defer screen.Fini()
+	screen.EnableMouse()
+	go v.receive(dec)
+	go func() {
+		for {
+			v.draw(screen)
+			screen.Show()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+
+	last := [2]int{-1, -1}
+	for {
+		switch ev := screen.PollEvent().(type) {
+		case *tcell.EventMouse:
+			species := 0
+			switch {
+			case ev.Buttons()&tcell.ButtonPrimary != 0:
+				v.mu.Lock()
+				species = v.brush
+				v.mu.Unlock()
+			case ev.Buttons()&tcell.ButtonSecondary == 0:
+				last = [2]int{-1, -1}
+				continue
+			}
+			x, y := ev.Position()
+			if cell := [2]int{y, x / 2}; cell != last {
+				last = cell
+				v.send(remoteCommand{Op: "paint", Layer: v.shown(), Row: y, Col: x / 2, Species: species})
+			}
+		case *tcell.EventKey:
+			switch {
+			case ev.Key() == tcell.KeyEscape || ev.Key() == tcell.KeyCtrlC || ev.Rune() == 'q':
+				return
+			case ev.Rune() == 'Q':
+				v.send(remoteCommand{Op: "stop"})
+				return
+			case ev.Rune() == ' ':
+				v.send(remoteCommand{Op: "pause"})
+			case ev.Key() == tcell.KeyTab:
+				v.send(remoteCommand{Op: "show", Layer: (v.shown() + 1) % len(v.hello.Layers)})
+			case ev.Rune() >= '1' && ev.Rune() <= '9':
+				v.mu.Lock()
+				if id := int(ev.Rune() - '0'); id < len(v.hello.Species[v.frame.Layer]) {
+					v.brush = id
+				}
+				v.mu.Unlock()
+			}
+		}
+	}
+}
+
+// viewer is the state of an attached viewer.
+type viewer struct {
+	hello remoteHello
+
+	sendMu sync.Mutex
+	enc    *gob.Encoder
+
+	mu    sync.Mutex
+	frame remoteFrame // the latest one
+	brush int         // species painted with the primary button
+	err   error       // once the daemon has gone away
+}
+
+// receive keeps the latest frame until the connection drops.
+func (v *viewer) receive(dec *gob.Decoder) {
+	for {
+		var f remoteFrame
+		err := dec.Decode(&f)
+		v.mu.Lock()
+		if err != nil {
+			v.err = err
+		} else {
+			v.frame = f
+		}
+		v.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// send sends c to the daemon, which ignores it if it is gone.
+func (v *viewer) send(c remoteCommand) {
+	v.sendMu.Lock()
+	defer v.sendMu.Unlock()
+	v.enc.Encode(c)
+}
+
+// shown returns the layer shown.
+func (v *viewer) shown() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.frame.Layer
+}
+
+// draw draws the latest frame with a status line below it.
+func (v *viewer) draw(screen tcell.Screen) {
+	v.mu.Lock()
+	f, brush, err := v.frame, v.brush, v.err
+	v.mu.Unlock()
+	h := v.hello
+	screen.Clear()
+	for i, rgb := range f.Colors {
+		x, y := i/h.Cols, i%h.Cols
+		style := tcell.StyleDefault
+		if rgb >= 0 {
+			style = style.Background(tcell.NewHexColor(rgb))
+		}
+		screen.SetContent(2*y, x, ' ', nil, style)
+		screen.SetContent(2*y+1, x, ' ', nil, style)
+	}
+
+	names := h.Species[f.Layer]
+	var status []string
+	if len(h.Layers) > 1 {
+		status = append(status, fmt.Sprintf("layer %s [tab]", h.Layers[f.Layer]))
+	}
+	status = append(status, fmt.Sprintf("tick %d", f.Tick))
+	if f.Paused {
+		status = append(status, "paused")
+	}
+	for id := 1; id < min(len(f.Population), len(names)); id++ { // hybrids bred since the hello are left out
+		status = append(status, fmt.Sprintf("%s %d", names[id], f.Population[id]))
+	}
+	status = append(status, fmt.Sprintf("brush %s [1-9]", names[min(brush, len(names)-1)]),
+		fmt.Sprintf("%d attached", f.Viewers))
+	if err != nil {
+		status = append(status, "daemon gone [q]")
+	} else {
+		status = append(status, "detach [q]  stop [Q]")
+	}
+	x := 0
+	for _, r := range strings.Join(status, "  ") {
+		screen.SetContent(x, h.Rows, r, nil, tcell.StyleDefault)
+		x++
+	}
+}
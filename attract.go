@@ -0,0 +1,166 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+)
+
+// attractRules are the rules -attract deals out to the species, each
+// known to keep a grid busy for a while.
+var attractRules = []string{
+	"B3/S23",         // Life
+	"B36/S23",        // HighLife
+	"B3678/S34678",   // Day & Night
+	"B368/S245",      // Morley
+	"B35678/S5678",   // Diamoeba
+	"B4678/S35678",   // Anneal
+	"B357/S1358",     // Amoeba
+	"B3/S45678",      // Coral
+	"B36/S125",       // 2x2
+	"B3/S12345",      // Maze
+	"B1357/S1357",    // Replicator
+	"B34/S34",        // 34 Life
+	"B345/S5",        // Long Life
+	"B378/S235678",   // Coagulations
+	"B3/S238",        // Pseudo Life
+	"B35678/S4678",   // Vote 4/5
+	"B3678/S235678",  // Stains
+	"B34678/S345678", // Holstein
+}
+
+// attractFade is how long -attract takes to fade out, and again in.
+const attractFade = 1500 * time.Millisecond
+
+// startAttract runs the attract mode of -attract on panes until stop is
+// closed: every cfg.Attract it fades them out, deals every species of
+// every layer a new rule, the species new colors and the grids new cells
+// from a random preset, and fades them back in, so that a wall display
+// keeps changing without anyone at the keyboard. A scene whose grids all
+// die out is moved on from early. Modes with rules of their own keep them.
+func startAttract(cfg *Config, panes []*display, stop <-chan struct{}) {
+	var presets []string
+	for name := range initPresets {
+		presets = append(presets, name)
+	}
+	slices.Sort(presets)
+	var layers []*layer
+	for _, d := range panes {
+		layers = append(layers, d.layers...)
+	}
+	next := time.Now().Add(cfg.Attract)
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-t.C:
+			if now.Before(next) && !extinct(layers) {
+				continue
+			}
+		}
+		if !fade(panes, 0, 1, stop) {
+			return
+		}
+		sc := cfg.clone()
+		sc.Init = presets[rand.Intn(len(presets))]
+		sc.Density = 0.15 + 0.35*rand.Float64()
+		sc.Densities = nil
+		palette := randomPalette(len(layers[0].e.Species()))
+		for _, l := range layers {
+			for id := 1; id < len(l.e.Species()); id++ {
+				rule := engine.MustParseRule(attractRules[rand.Intn(len(attractRules))])
+				l.e.SetRule(id, rule) // refused by modes with rules of their own
+			}
+			if seed := modes[sc.Mode].seed; seed != nil {
+				seed(l.e)
+			} else {
+				sc.seedPreset(l.e)
+			}
+		}
+		for _, d := range panes {
+			d.recolor.Store(&palette)
+		}
+		if !fade(panes, 1, 0, stop) {
+			return
+		}
+		next = time.Now().Add(cfg.Attract)
+	}
+}
+
+// extinct reports whether no layer has any live cell left.
+func extinct(layers []*layer) bool {
+	for _, l := range layers {
+		pop := l.trend.latest()
+		if len(pop) == 0 {
+			return false
+		}
+		for _, n := range pop[1:] {
+			if n > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// fade dims panes from one fraction to another over attractFade, and
+// reports whether it got there before stop was closed.
+func fade(panes []*display, from, to float64, stop <-chan struct{}) bool {
+	const steps = 30
+	for i := 1; i <= steps; i++ {
+		select {
+		case <-stop:
+			return false
+		case <-time.After(attractFade / steps):
+		}
+		f := from + (to-from)*float64(i)/steps
+		for _, d := range panes {
+			d.dim.Store(math.Float64bits(f))
+			d.wake.signal()
+		}
+	}
+	return true
+}
+
+// randomPalette returns n colors, indexed like a layer's palette, the live
+// ones bright and spread evenly around the color wheel from a random hue.
+func randomPalette(n int) []tcell.Color {
+	palette := make([]tcell.Color, n)
+	palette[engine.Dead] = tcell.ColorBlack
+	start := rand.Float64()
+	for id := 1; id < n; id++ {
+		palette[id] = hsv(start+float64(id-1)/float64(max(n-1, 1)), 0.55+0.35*rand.Float64(), 1)
+	}
+	return palette
+}
+
+// hsv returns the color of hue h, in turns, saturation s and value v.
+func hsv(h, s, v float64) tcell.Color {
+	h = (h - math.Floor(h)) * 6
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g = c, x
+	case 1:
+		r, g = x, c
+	case 2:
+		g, b = c, x
+	case 3:
+		g, b = x, c
+	case 4:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := v - c
+	return tcell.NewRGBColor(int32(255*(r+m)), int32(255*(g+m)), int32(255*(b+m)))
+}
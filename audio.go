@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"app/engine"
+	"app/sound"
+)
+
+// startSound plays e as audio as cfg.Sound and cfg.SoundOut describe, until
+// stop is called. stop returns the error that ended playback early, if any.
+func startSound(cfg *Config, e *engine.Engine) (stop func() error, err error) {
+	mode, err := sound.ParseMode(cfg.Sound)
+	if err != nil {
+		return nil, err
+	}
+	s := sound.New(mode, len(e.Species())-1)
+	e.OnCellChanged(func(x, y int, old, new engine.State) {
+		if old.Alive() {
+			s.Death(old.Species)
+		}
+		if new.Alive() {
+			s.Birth(new.Species)
+		}
+	})
+	if mode == sound.Population {
+		e.OnTick(func(st engine.Stats) { s.Populations(st.Population) })
+	}
+
+	var w io.Writer
+	var finish func() error
+	if strings.EqualFold(filepath.Ext(cfg.SoundOut), ".wav") {
+		f, err := os.Create(cfg.SoundOut)
+		if err != nil {
+			return nil, err
+		}
+		if err := sound.WriteWAVHeader(f, math.MaxUint32); err != nil {
+			f.Close()
+			return nil, err
+		}
+		cw := &countingWriter{w: f}
+		w = cw
+		finish = func() error {
+			// Now that the length is known, fix it in the header.
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				f.Close()
+				return err
+			}
+			if err := sound.WriteWAVHeader(f, uint32(min(cw.n, math.MaxUint32))); err != nil {
+				f.Close()
+				return err
+			}
+			return f.Close()
+		}
+	} else {
+		cmd := exec.Command("sh", "-c", cfg.SoundOut)
+		in, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("starting %q: %w", cfg.SoundOut, err)
+		}
+		w = in
+		finish = func() error {
+			in.Close()
+			return cmd.Wait()
+		}
+	}
+
+	done, played := make(chan struct{}), make(chan error, 1)
+	go func() { played <- s.Play(w, done) }()
+	return func() error {
+		close(done)
+		err := <-played
+		if ferr := finish(); err == nil {
+			err = ferr
+		}
+		return err
+	}, nil
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
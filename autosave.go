@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"app/engine"
+)
+
+// autosaveDir is where running simulations save their state, in the
+// user's cache directory, or the temporary one if there is none.
+func autosaveDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "nnca")
+}
+
+// autosavePath is where the process of pid saves the state of its
+// simulation, so that runs at once each keep their own. It is removed on a
+// clean exit, so finding it once the process is gone means that run
+// crashed or lost its terminal.
+func autosavePath(pid int) string {
+	return filepath.Join(autosaveDir(), fmt.Sprintf("autosave-%d.gob", pid))
+}
+
+// processAlive reports whether the process of pid is still running.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return !errors.Is(p.Signal(syscall.Signal(0)), os.ErrProcessDone)
+}
+
+// savedState is the content of the autosave file.
+type savedState struct {
+	Saved  time.Time
+	Layers []engine.Snapshot // bottom first
+	// Config is the configuration of the run that saved it, as a TOML
+	// config file, or empty if unknown.
+	Config string
+}
+
+// startAutosave writes the state of every layer, with config, to the
+// autosavePath of this process every interval until ctx is done or stop is
+// called. Each save replaces the previous one atomically. stop waits for a
+// save under way and removes the file, as the run is ending cleanly.
+func startAutosave(ctx context.Context, layers []*layer, interval time.Duration, config string) (stop func()) {
+	path := autosavePath(os.Getpid())
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			s := savedState{Saved: time.Now(), Config: config}
+			for _, l := range layers {
+				s.Layers = append(s.Layers, l.e.Snapshot())
+			}
+			writeSaved(path, s) // a failed save is retried at the next interval
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+		os.Remove(path)
+	}
+}
+
+func writeSaved(path string, s savedState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(s); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// offerRecovery asks on the terminal whether to resume from the latest
+// autosave left by a run that is gone, if there is one that fits layers,
+// and restores it if the answer is yes. Either way it is not offered again.
+func offerRecovery(layers []*layer) error {
+	paths, err := filepath.Glob(filepath.Join(autosaveDir(), "autosave-*.gob"))
+	if err != nil {
+		return err
+	}
+	var s savedState
+	var found string
+	for _, path := range paths {
+		var pid int
+		if _, err := fmt.Sscanf(filepath.Base(path), "autosave-%d.gob", &pid); err != nil || pid == os.Getpid() || processAlive(pid) {
+			continue // not an autosave, or one of a run under way
+		}
+		saved, err := readSaved(path)
+		if err != nil {
+			continue
+		}
+		if fits(saved, layers) && (found == "" || saved.Saved.After(s.Saved)) {
+			s, found = saved, path
+		}
+	}
+	if found == "" {
+		return nil
+	}
+	defer os.Remove(found)
+
+	fmt.Fprintf(os.Stderr, "The previous run did not exit cleanly. Resume from its autosave of %s? [Y/n] ",
+		s.Saved.Format(time.DateTime))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if a := strings.ToLower(strings.TrimSpace(answer)); a != "" && a != "y" && a != "yes" {
+		return nil
+	}
+	for i, l := range layers {
+		if err := l.e.Restore(s.Layers[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fits reports whether s holds a grid of the size of every layer.
+func fits(s savedState, layers []*layer) bool {
+	if len(s.Layers) != len(layers) {
+		return false
+	}
+	for i, l := range layers {
+		if len(s.Layers[i].Cells) != l.e.Rows() || len(s.Layers[i].Cells[0]) != l.e.Cols() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"app/engine"
+)
+
+// runBench implements the "bench" subcommand: it drives the engine headless
+// for a fixed number of ticks per grid size and concurrency model and
+// reports throughput and allocations.
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	ticks := fs.Int("ticks", 100, "updates per cell for each run")
+	sizes := fs.String("sizes", "50,100,200", "comma-separated square grid sizes")
+	models := fs.String("models", "goroutines,sequential,pool", "comma-separated concurrency models")
+	density := fs.Float64("density", 0.3, "initial live-cell probability")
+	fs.Parse(args)
+
+	var ns []int
+	for _, s := range strings.Split(*sizes, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n <= 0 {
+			log.Fatalf("bad grid size %q", s)
+		}
+		ns = append(ns, n)
+	}
+	var ms []engine.Model
+	for _, s := range strings.Split(*models, ",") {
+		m, err := engine.ParseModel(strings.TrimSpace(s))
+		if err != nil {
+			log.Fatalf("parsing models: %v", err)
+		}
+		ms = append(ms, m)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "size\tmodel\tticks\telapsed\tupdates/s\tallocs/tick\tbytes/tick\t")
+	for _, n := range ns {
+		for _, m := range ms {
+			p := engine.DefaultParams()
+			p.Rows, p.Cols = n, n
+			e, err := engine.New(p)
+			if err != nil {
+				log.Fatalf("creating engine: %v", err)
+			}
+			e.Seed(float32(*density))
+
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			start := time.Now()
+			e.RunTicks(m, *ticks)
+			elapsed := time.Since(start)
+			runtime.ReadMemStats(&after)
+
+			updates := float64(e.Updates())
+			fmt.Fprintf(tw, "%dx%d\t%s\t%d\t%s\t%.0f\t%d\t%d\t\n",
+				n, n, m, *ticks, elapsed.Round(time.Millisecond),
+				updates/elapsed.Seconds(),
+				(after.Mallocs-before.Mallocs)/uint64(*ticks),
+				(after.TotalAlloc-before.TotalAlloc)/uint64(*ticks))
+		}
+	}
+	tw.Flush()
+}
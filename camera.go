@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A grid larger than the screen is shown through a viewport, a window of
+// it as large as fits, whose top left corner is the display's origin. The
+// camera moves the origin: towards the busiest part of the shown layer or
+// along with a cluster of live cells.
+
+// cameraInterval is how often the camera moves, and cameraMargin how much
+// busier another part of the grid must be, as a factor, for the camera to
+// head there rather than stay on the one it is heading to.
+const (
+	cameraInterval = 150 * time.Millisecond
+	cameraMargin   = 1.2
+)
+
+// clusterReach is how many cells apart live cells may be and still count
+// as the same cluster, such as the cells of a glider between phases.
+const clusterReach = 2
+
+// region is a rectangle of grid cells, its corners included.
+type region struct {
+	top, left, bottom, right int
+}
+
+// camera keeps the viewport of a display on the most active region of its
+// shown layer or, if follow is set, on the cluster of live cells it bounds.
+type camera struct {
+	on atomic.Bool
+
+	mu     sync.Mutex
+	follow *region
+	target [2]int // the origin heading for the most active region
+}
+
+// window returns the part of a grid of rows by cols cells shown: its top
+// left cell and its size, as many cells as fit the display above the
+// status line.
+func (d *display) window(rows, cols int) (top, left, shownRows, shownCols int) {
+	_, h := d.screen.Size()
+	shownRows = min(rows, max(h-1, 1))
+	shownCols = min(cols, max((d.right()-d.left)/2, 1))
+	top = min(max(int(d.originRow.Load()), 0), rows-shownRows)
+	left = min(max(int(d.originCol.Load()), 0), cols-shownCols)
+	return top, left, shownRows, shownCols
+}
+
+// cellAt returns the grid cell shown at screen column x and row y.
+func (d *display) cellAt(x, y int) (row, col int) {
+	e := d.layer().e
+	top, left, _, _ := d.window(e.Rows(), e.Cols())
+	return top + y, left + (x-d.left)/2 // cells are two characters wide
+}
+
+// toggleCamera turns the camera on or off, leaving the viewport where it
+// is. Turned on with sel set, it follows the live cells within sel.
+func (d *display) toggleCamera(sel *region) {
+	c := &d.camera
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.on.Load() {
+		c.on.Store(false)
+		d.flash("camera off")
+		return
+	}
+	c.follow = sel
+	c.target = [2]int{int(d.originRow.Load()), int(d.originCol.Load())}
+	c.on.Store(true)
+	if sel != nil {
+		d.flash("camera following the selected cluster")
+	} else {
+		d.flash("camera following activity")
+	}
+}
+
+// viewStatus describes the viewport, if the grid does not fit the screen,
+// and what the camera follows.
+func (d *display) viewStatus() string {
+	e := d.layer().e
+	top, left, rows, cols := d.window(e.Rows(), e.Cols())
+	if rows == e.Rows() && cols == e.Cols() {
+		return ""
+	}
+	s := fmt.Sprintf("view %d,%d of %dx%d", top, left, e.Rows(), e.Cols())
+	c := &d.camera
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case !c.on.Load():
+		return s + " [z]"
+	case c.follow != nil:
+		return s + " following a cluster [z]"
+	}
+	return s + " following activity [z]"
+}
+
+// aim moves the viewport a step towards what the camera follows.
+func (d *display) aim() {
+	c := &d.camera
+	if !c.on.Load() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l := d.layer()
+	rows, cols := l.e.Rows(), l.e.Cols()
+	top, left, shownRows, shownCols := d.window(rows, cols)
+	if shownRows == rows && shownCols == cols {
+		return // all of it shows
+	}
+	if c.follow != nil {
+		next, ok := trackCluster(l, *c.follow)
+		if !ok {
+			c.follow = nil
+			d.flash("camera lost the cluster, following activity")
+		} else {
+			c.follow = &next
+			c.target = [2]int{(next.top+next.bottom)/2 - shownRows/2, (next.left+next.right)/2 - shownCols/2}
+		}
+	}
+	if c.follow == nil {
+		c.target = busiest(l, shownRows, shownCols, c.target)
+	}
+	step := func(from, to, most int) int {
+		to = min(max(to, 0), most)
+		switch delta := (to - from) / 4; {
+		case delta != 0:
+			return from + delta
+		case to > from:
+			return from + 1
+		case to < from:
+			return from - 1
+		}
+		return from
+	}
+	d.originRow.Store(int32(step(top, c.target[0], rows-shownRows)))
+	d.originCol.Store(int32(step(left, c.target[1], cols-shownCols)))
+	d.wake.signal()
+}
+
+// busiest returns the top left cell of the rows by cols window of l whose
+// cells changed the most lately, or current, if no window beats it by
+// cameraMargin.
+func busiest(l *layer, rows, cols int, current [2]int) [2]int {
+	R, C := l.e.Rows(), l.e.Cols()
+	// sums[i*(C+1)+j] is the activity of the cells above i and left of j
+	sums := make([]int, (R+1)*(C+1))
+	for i := range R {
+		for j := range C {
+			sums[(i+1)*(C+1)+j+1] = l.activity.changes(i, j) + sums[i*(C+1)+j+1] + sums[(i+1)*(C+1)+j] - sums[i*(C+1)+j]
+		}
+	}
+	within := func(top, left int) int {
+		bottom, right := top+rows, left+cols
+		return sums[bottom*(C+1)+right] - sums[top*(C+1)+right] - sums[bottom*(C+1)+left] + sums[top*(C+1)+left]
+	}
+	best, most := current, 0
+	for top := range R - rows + 1 {
+		for left := range C - cols + 1 {
+			if n := within(top, left); n > most {
+				best, most = [2]int{top, left}, n
+			}
+		}
+	}
+	current = [2]int{min(max(current[0], 0), R-rows), min(max(current[1], 0), C-cols)}
+	if float64(most) > cameraMargin*float64(within(current[0], current[1])) {
+		return best
+	}
+	return current
+}
+
+// trackCluster returns the bounds of the live cells of l connected, within
+// clusterReach of each other, to those within r, looking no further than
+// clusterReach beyond r, so that a cluster moving a cell or two a step is
+// followed. It reports false if none are left.
+func trackCluster(l *layer, r region) (region, bool) {
+	e := l.e
+	grown := region{
+		max(r.top-clusterReach, 0), max(r.left-clusterReach, 0),
+		min(r.bottom+clusterReach, e.Rows()-1), min(r.right+clusterReach, e.Cols()-1),
+	}
+	w := grown.right - grown.left + 1
+	seen := make([]bool, (grown.bottom-grown.top+1)*w)
+	var queue [][2]int
+	for x := max(r.top, 0); x <= min(r.bottom, e.Rows()-1); x++ {
+		for y := max(r.left, 0); y <= min(r.right, e.Cols()-1); y++ {
+			if s := e.Cell(x, y); s.Alive() && !s.Wall {
+				seen[(x-grown.top)*w+y-grown.left] = true
+				queue = append(queue, [2]int{x, y})
+			}
+		}
+	}
+	if len(queue) == 0 {
+		return r, false
+	}
+	next := region{queue[0][0], queue[0][1], queue[0][0], queue[0][1]}
+	for len(queue) > 0 {
+		x, y := queue[0][0], queue[0][1]
+		queue = queue[1:]
+		next = region{min(next.top, x), min(next.left, y), max(next.bottom, x), max(next.right, y)}
+		for i := max(x-clusterReach, grown.top); i <= min(x+clusterReach, grown.bottom); i++ {
+			for j := max(y-clusterReach, grown.left); j <= min(y+clusterReach, grown.right); j++ {
+				k := (i-grown.top)*w + j - grown.left
+				if s := e.Cell(i, j); !seen[k] && s.Alive() && !s.Wall {
+					seen[k] = true
+					queue = append(queue, [2]int{i, j})
+				}
+			}
+		}
+	}
+	return next, true
+}
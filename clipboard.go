@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+	"app/pattern"
+)
+
+// selection is a rectangle of cells of a display's shown layer, corners
+// included, in the order they were dragged.
+type selection struct {
+	d              *display
+	x0, y0, x1, y1 int
+}
+
+// bounds returns the top left and bottom right corners of s.
+func (s *selection) bounds() (top, left, bottom, right int) {
+	return min(s.x0, s.x1), min(s.y0, s.y1), max(s.x0, s.x1), max(s.y0, s.y1)
+}
+
+// selected returns the display the selection is on and its cells, or nil
+// if nothing is selected.
+func (ed *editor) selected() (*display, *region) {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	if ed.sel == nil || !ed.on.Load() {
+		return nil, nil
+	}
+	top, left, bottom, right := ed.sel.bounds()
+	return ed.sel.d, &region{top, left, bottom, right}
+}
+
+// toggleSelect turns select mode on or off.
+func (ed *editor) toggleSelect() {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	if !ed.on.Load() {
+		return
+	}
+	ed.selecting, ed.pasting = !ed.selecting, false
+	if !ed.selecting {
+		ed.sel = nil
+	}
+}
+
+// pointer handles a mouse event at row, col of d in select mode or while a
+// paste is armed: dragging with the primary button selects a rectangle, and
+// a click pastes.
+func (ed *editor) pointer(d *display, ev *tcell.EventMouse, row, col int) {
+	l := d.layer()
+	if ev.Buttons()&tcell.ButtonPrimary == 0 {
+		ed.stroking = false
+		return
+	}
+	row, col = min(max(row, 0), l.e.Rows()-1), min(max(col, 0), l.e.Cols()-1)
+	switch {
+	case ed.pasting && !ed.stroking:
+		ed.paste(l, row, col)
+		ed.pasting = false
+	case ed.selecting && !ed.stroking:
+		ed.sel = &selection{d, row, col, row, col}
+	case ed.selecting && ed.sel != nil && ed.sel.d == d:
+		ed.sel.x1, ed.sel.y1 = row, col
+	}
+	ed.stroking = true
+}
+
+// copySelection copies the selected cells to the clipboard as a pattern,
+// and to the terminal's clipboard as RLE, and kills them if cut is set.
+func (ed *editor) copySelection(cut bool) {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	if ed.sel == nil || ed.stroking {
+		return
+	}
+	l := ed.sel.d.layer()
+	top, left, bottom, right := ed.sel.bounds()
+	p := &pattern.Pattern{Width: right - left + 1, Height: bottom - top + 1}
+	var changes []change
+	for x := top; x <= bottom; x++ {
+		row := make([]int, p.Width)
+		for y := left; y <= right; y++ {
+			s := l.e.Cell(x, y)
+			switch {
+			case s.Wall:
+				row[y-left] = pattern.Wall
+			default:
+				row[y-left] = s.Species
+			}
+			if cut && (s.Alive() || s.Wall) {
+				l.e.SetCell(x, y, engine.State{})
+				changes = append(changes, change{l, x, y, s, engine.State{}})
+			}
+		}
+		p.Cells = append(p.Cells, row)
+	}
+	ed.clip = p
+	var rle bytes.Buffer
+	if err := pattern.WriteRLE(&rle, p); err == nil {
+		ed.sel.d.screen.SetClipboard(rle.Bytes())
+	}
+	if len(changes) > 0 {
+		ed.push(edit{changes, fmt.Sprintf("cut %dx%d cells", p.Width, p.Height)})
+	}
+}
+
+// receive takes a pattern pasted into the terminal or read from its
+// clipboard as the clipboard, in any format pattern.Read knows, and arms a
+// paste of it, turning edit mode on if need be. d reports the outcome.
+func (ed *editor) receive(d *display, text string) {
+	p, err := pattern.Read(strings.NewReader(text))
+	switch {
+	case err != nil:
+		d.flash(fmt.Sprintf("paste: not a pattern: %v", err))
+		return
+	case p.Width == 0 || p.Height == 0:
+		d.flash("paste: empty pattern")
+		return
+	}
+	if !ed.on.Load() {
+		ed.toggle(d.screen)
+	}
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	ed.clip, ed.selecting, ed.sel, ed.pasting = p, false, nil, true
+	d.flash(fmt.Sprintf("click to paste the %dx%d pattern", p.Width, p.Height))
+}
+
+// armPaste makes the next click paste the clipboard there.
+func (ed *editor) armPaste() {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	if ed.on.Load() && ed.clip != nil {
+		ed.pasting = true
+	}
+}
+
+// paste writes the clipboard to l with its top left corner at row, col,
+// cutting it short at the edges. Dead cells of the clipboard overwrite
+// live ones, and species l lacks are pasted as dead.
+func (ed *editor) paste(l *layer, row, col int) {
+	p := ed.clip
+	var changes []change
+	for i, cells := range p.Cells {
+		for j, v := range cells {
+			x, y := row+i, col+j
+			if x >= l.e.Rows() || y >= l.e.Cols() {
+				continue
+			}
+			var s engine.State
+			switch {
+			case v == pattern.Wall:
+				s.Wall = true
+			case v < len(l.e.Species()):
+				s.Species = v
+			}
+			old := l.e.Cell(x, y)
+			if old.Species == s.Species && old.Wall == s.Wall {
+				continue
+			}
+			l.e.SetCell(x, y, s)
+			changes = append(changes, change{l, x, y, old, s})
+		}
+	}
+	if len(changes) > 0 {
+		ed.push(edit{changes, fmt.Sprintf("pasted %dx%d cells", p.Width, p.Height)})
+	}
+}
+
+// drawSelection outlines the selection, if any, over its display.
+func (ed *editor) drawSelection() {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	if ed.sel == nil || !ed.on.Load() {
+		return
+	}
+	d := ed.sel.d
+	d.repaint()
+	top, left, bottom, right := ed.sel.bounds()
+	e := d.layer().e
+	row0, col0, rows, cols := d.window(e.Rows(), e.Cols())
+	screen := d.screen
+	mark := func(x, y int, r rune) { // in screen columns of the grid and rows
+		if x < 2*col0 || x >= 2*(col0+cols) || y < row0 || y >= row0+rows {
+			return // outside the viewport
+		}
+		x, y = d.left+x-2*col0, y-row0
+		_, _, style, _ := screen.GetContent(x, y)
+		screen.SetContent(x, y, r, nil, style.Foreground(tcell.ColorWhite).Bold(true))
+	}
+	for y := 2 * left; y <= 2*right+1; y++ {
+		mark(y, top, '▔')
+		if bottom != top {
+			mark(y, bottom, '▁')
+		}
+	}
+	for x := top; x <= bottom; x++ {
+		mark(2*left, x, '▏')
+		mark(2*right+1, x, '▕')
+	}
+}
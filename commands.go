@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"app/engine"
+	"app/pattern"
+)
+
+// commander runs the commands scripts send a live simulation, one per line:
+//
+//	set cell ROW COL SPECIES          make a cell SPECIES, dead or wall
+//	set tau SPECIES DURATION          change the reaction time of SPECIES
+//	set noise P                       change the probability of spontaneous death
+//	set rule SPECIES RULE             change the B/S rule of SPECIES
+//	set speed SCALE                   run SCALE times as fast as real time
+//	stamp PATTERN ROW COL [SPECIES]   write a pattern with its top left corner there
+//	kill species SPECIES              kill every cell of SPECIES
+//	convert P SPECIES to SPECIES      turn a fraction P of the cells of a species into another
+//	inject SPECIES P ROW COL HxW      make a fraction P of an H by W region SPECIES
+//	fastforward GENERATIONS           move the grid on that many generations at once
+//	pause
+//	resume
+//
+// A position may also be written "at ROW,COL", and a fraction as a
+// percentage such as 30%. PATTERN is the name of a
+// builtin pattern, such as glider or gun, or a pattern file. A stamp
+// overwrites the cells under it, cut short at the edges, with its live
+// cells as SPECIES if given. Cells change on the bottom layer of the first
+// pane, reaction times, rules and the noise on every layer, and pause and
+// resume pause and resume them all. fastforward pauses the bottom layer of
+// the first pane while HashLife moves it on; see engine.Engine.FastForward.
+type commander struct {
+	layers []*layer // of every pane, the bottom layer of the first one first
+}
+
+// run runs the command of line; blank lines and lines starting with # do
+// nothing.
+func (cm *commander) run(line string) error {
+	f := strings.Fields(line)
+	if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+		return nil
+	}
+	if i := slices.Index(f, "at"); i > 0 && i+1 < len(f) {
+		f = slices.Concat(f[:i], strings.SplitN(f[i+1], ",", 2), f[i+2:])
+	}
+	e := cm.layers[0].e
+	switch {
+	case len(f) == 5 && f[0] == "set" && f[1] == "cell":
+		x, y, err := cm.position(f[2], f[3])
+		if err != nil {
+			return err
+		}
+		s, err := parseState(e, f[4])
+		if err != nil {
+			return err
+		}
+		e.SetCell(x, y, s)
+	case len(f) == 4 && f[0] == "set" && f[1] == "tau":
+		d, err := time.ParseDuration(f[3])
+		if err != nil {
+			return err
+		}
+		for _, l := range cm.layers {
+			id, err := speciesID(l.e, f[2])
+			if err == nil {
+				err = l.e.SetReactionTime(id, d)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	case len(f) == 3 && f[0] == "set" && (f[1] == "noise" || f[1] == "decay"):
+		p, err := strconv.ParseFloat(f[2], 64)
+		if err != nil {
+			return err
+		}
+		for _, l := range cm.layers {
+			if err := l.e.SetDecay(p); err != nil {
+				return err
+			}
+		}
+	case len(f) == 3 && f[0] == "set" && f[1] == "speed":
+		scale, err := strconv.ParseFloat(f[2], 64)
+		if err != nil {
+			return err
+		}
+		for _, l := range cm.layers {
+			if err := l.e.SetTimeScale(scale); err != nil {
+				return err
+			}
+		}
+	case len(f) == 3 && f[0] == "set" && f[1] == "tie-break":
+		t, err := engine.ParseTieBreak(f[2])
+		if err != nil {
+			return err
+		}
+		for _, l := range cm.layers {
+			if err := l.e.SetTieBreak(t); err != nil {
+				return err
+			}
+		}
+	case len(f) == 4 && f[0] == "set" && f[1] == "rule":
+		rule, err := engine.ParseRule(f[3])
+		if err != nil {
+			return err
+		}
+		for _, l := range cm.layers {
+			id, err := speciesID(l.e, f[2])
+			if err == nil {
+				err = l.e.SetRule(id, rule)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	case len(f) == 3 && f[0] == "kill" && f[1] == "species":
+		id, err := speciesID(e, f[2])
+		if err != nil {
+			return err
+		}
+		wipeOut(cm.layers[0], id)
+	case len(f) == 5 && f[0] == "convert" && f[3] == "to":
+		p, err := parseFraction(f[1])
+		if err != nil {
+			return err
+		}
+		from, err := speciesID(e, f[2])
+		if err != nil {
+			return err
+		}
+		to, err := speciesID(e, f[4])
+		if err != nil {
+			return err
+		}
+		convertSpecies(cm.layers[0], from, to, p)
+	case len(f) == 6 && f[0] == "inject":
+		id, err := speciesID(e, f[1])
+		if err != nil {
+			return err
+		}
+		p, err := parseFraction(f[2])
+		if err != nil {
+			return err
+		}
+		x, y, err := cm.position(f[3], f[4])
+		if err != nil {
+			return err
+		}
+		var rows, cols int
+		if _, err := fmt.Sscanf(f[5], "%dx%d", &rows, &cols); err != nil || rows <= 0 || cols <= 0 {
+			return fmt.Errorf("region size %q is not HEIGHTxWIDTH", f[5])
+		}
+		inject(cm.layers[0], id, x, y, rows, cols, p)
+	case (len(f) == 4 || len(f) == 5) && f[0] == "stamp":
+		p, ok := pattern.Builtin(f[1])
+		if !ok {
+			var err error
+			if p, err = pattern.Load(f[1]); err != nil {
+				return err
+			}
+		}
+		x, y, err := cm.position(f[2], f[3])
+		if err != nil {
+			return err
+		}
+		species := 0
+		if len(f) == 5 {
+			if species, err = speciesID(e, f[4]); err != nil {
+				return err
+			}
+		}
+		stamp(e, p, x, y, species)
+	case len(f) == 2 && f[0] == "fastforward":
+		gens, err := strconv.ParseUint(strings.ReplaceAll(f[1], "_", ""), 10, 64)
+		if err != nil {
+			return fmt.Errorf("generations %q is not a whole number", f[1])
+		}
+		if !e.Paused() {
+			e.Pause()
+			defer e.Resume()
+		}
+		return e.FastForward(gens)
+	case len(f) == 1 && (f[0] == "pause" || f[0] == "resume"):
+		for _, l := range cm.layers {
+			if f[0] == "pause" {
+				l.e.Pause()
+			} else {
+				l.e.Resume()
+			}
+		}
+	default:
+		return fmt.Errorf("unknown command %q", line)
+	}
+	return nil
+}
+
+// position parses the row and column of a cell of the bottom layer.
+func (cm *commander) position(row, col string) (x, y int, err error) {
+	e := cm.layers[0].e
+	x, err = strconv.Atoi(row)
+	if err == nil {
+		y, err = strconv.Atoi(col)
+	}
+	switch {
+	case err != nil:
+		return 0, 0, err
+	case x < 0 || x >= e.Rows() || y < 0 || y >= e.Cols():
+		return 0, 0, fmt.Errorf("no cell %d, %d in the %dx%d grid", x, y, e.Rows(), e.Cols())
+	}
+	return x, y, nil
+}
+
+// speciesID returns the id of the species of e called name, or numbered
+// name; dead is species 0.
+func speciesID(e *engine.Engine, name string) (int, error) {
+	species := e.Species()
+	if id, err := strconv.Atoi(name); err == nil && id >= 0 && id < len(species) {
+		return id, nil
+	}
+	for id, sp := range species {
+		if sp.Name == name {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown species %q", name)
+}
+
+// parseState returns the state named name: a species of e, dead or wall.
+func parseState(e *engine.Engine, name string) (engine.State, error) {
+	if name == "wall" {
+		return engine.State{Wall: true}, nil
+	}
+	id, err := speciesID(e, name)
+	return engine.State{Species: id}, err
+}
+
+// stamp writes p to e with its top left corner at row x, column y, cutting
+// it short at the edges. Its live cells are species instead of their own
+// unless it is 0; species e lacks are written as dead.
+func stamp(e *engine.Engine, p *pattern.Pattern, x, y, species int) {
+	for i, cells := range p.Cells {
+		for j, v := range cells {
+			if x+i >= e.Rows() || y+j >= e.Cols() {
+				continue
+			}
+			var s engine.State
+			switch {
+			case v == pattern.Wall:
+				s.Wall = true
+			case v != 0 && species != 0:
+				s.Species = species
+			case v < len(e.Species()):
+				s.Species = v
+			}
+			e.SetCell(x+i, y+j, s)
+		}
+	}
+}
+
+// removeSocket removes the Unix socket at path, if there is one, and
+// refuses to remove anything else there.
+func removeSocket(path string) error {
+	fi, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	case fi.Mode()&os.ModeSocket == 0:
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	return os.Remove(path)
+}
+
+// serveCommands runs the commands read from standard input if source is
+// "-", or else sent over any connection to a Unix socket it listens on at
+// source, which is told "ok" or the error for each line. Errors of
+// standard input's commands are passed to report. stop closes the socket.
+func serveCommands(source string, cm *commander, report func(string)) (stop func(), err error) {
+	if source == "-" {
+		go readCommands(os.Stdin, cm, func(err error) {
+			if err != nil {
+				report("command: " + err.Error())
+			}
+		})
+		return func() {}, nil
+	}
+	if conn, err := net.Dial("unix", source); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s is in use", source)
+	}
+	if err := removeSocket(source); err != nil { // left by a run that was killed
+		return nil, err
+	}
+	ln, err := net.Listen("unix", source)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			} else if err != nil {
+				continue
+			}
+			go func() {
+				defer conn.Close()
+				w := bufio.NewWriter(conn)
+				readCommands(conn, cm, func(err error) {
+					if err == nil {
+						fmt.Fprintln(w, "ok")
+					} else {
+						fmt.Fprintln(w, "error:", err)
+					}
+					w.Flush()
+				})
+			}()
+		}
+	}()
+	return func() { ln.Close() }, nil
+}
+
+// readCommands runs the commands of r, one per line, until it ends, and
+// passes the outcome of each to done.
+func readCommands(r io.Reader, cm *commander, done func(error)) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		done(cm.run(sc.Text()))
+	}
+}
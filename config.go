@@ -0,0 +1,1103 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gdamore/tcell/v2"
+	"gopkg.in/yaml.v3"
+
+	"app/engine"
+)
+
+// Config is everything a simulation run can be configured with. It is
+// loaded from a TOML or YAML file given with -config; command-line flags
+// that are set explicitly override the file.
+type Config struct {
+	// Mode selects a built-in rule family; see modes.go.
+	Mode   string `toml:"mode" yaml:"mode"`
+	Rows   int    `toml:"rows" yaml:"rows"`
+	Cols   int    `toml:"cols" yaml:"cols"`
+	Invert bool   `toml:"invert" yaml:"invert"`
+	// Boundary is one of dead, alive, wrap, reflect, klein or sphere; see
+	// engine.Boundary.
+	Boundary string `toml:"boundary" yaml:"boundary"`
+	// Depth above 1 makes the grid a 3D volume of that many slices, with
+	// every species playing Rule3D unless it is empty.
+	Depth  int    `toml:"depth" yaml:"depth"`
+	Rule3D string `toml:"rule_3d" yaml:"rule_3d"`
+	// BirthCount, SurviveMin and SurviveMax, unless 0, replace parts of
+	// every species' rule; see engine.Rule.WithThresholds.
+	BirthCount int `toml:"birth_count" yaml:"birth_count"`
+	SurviveMin int `toml:"survive_min" yaml:"survive_min"`
+	SurviveMax int `toml:"survive_max" yaml:"survive_max"`
+	// AgeShading darkens live cells as they age; AgeFade is the age in
+	// updates at which they reach the darkest shade.
+	AgeShading bool `toml:"age_shading" yaml:"age_shading"`
+	AgeFade    int  `toml:"age_fade" yaml:"age_fade"`
+	// Trails leaves a fading ghost of recently dead cells for
+	// TrailLength frames.
+	Trails      bool            `toml:"trails" yaml:"trails"`
+	TrailLength int             `toml:"trail_length" yaml:"trail_length"`
+	Dead        DeadConfig      `toml:"dead" yaml:"dead"`
+	Wall        WallConfig      `toml:"wall" yaml:"wall"`
+	Species     []SpeciesConfig `toml:"species" yaml:"species"`
+	// Pattern is a pattern file placed in the middle of an otherwise empty
+	// grid instead of random seeding. Walls is a pattern file whose walls
+	// are added after seeding; its other cells are ignored.
+	Pattern string `toml:"pattern" yaml:"pattern"`
+	Walls   string `toml:"walls" yaml:"walls"`
+	// Image is a PNG image scaled onto the grid instead of random seeding,
+	// each pixel becoming the species of the nearest color, or a dead cell
+	// if it is dark; see seedImage.
+	Image string `toml:"image" yaml:"image"`
+	// Export is the format the export key writes, one of the names of
+	// pattern.Formats.
+	Export string `toml:"export" yaml:"export"`
+	// PatternDir is the directory the export key writes to, that of
+	// Pattern if empty, else the current one.
+	PatternDir string `toml:"pattern_dir" yaml:"pattern_dir"`
+	// Theme is the name of a built-in theme or a theme file; see theme.
+	Theme string `toml:"theme" yaml:"theme"`
+	// Mono draws in the terminal's default colors, telling the species
+	// apart by their letters; see themeLooks.
+	Mono bool `toml:"mono" yaml:"mono"`
+	// Density is the probability that a randomly seeded cell is alive,
+	// shared among the species by the mode's weights. Densities overrides
+	// it for individual species by name.
+	Density   float64            `toml:"density" yaml:"density"`
+	Densities map[string]float64 `toml:"densities" yaml:"densities"`
+	// Structures highlights still lifes, cells alive and unchanged for
+	// StillAfter updates, and short-period oscillators.
+	Structures bool `toml:"structures" yaml:"structures"`
+	StillAfter int  `toml:"still_after" yaml:"still_after"`
+	// Rewind is how many ticks of history are kept for stepping back
+	// while paused.
+	Rewind int `toml:"rewind" yaml:"rewind"`
+	// Sparklines graphs each species' recent population below the grid.
+	Sparklines bool `toml:"sparklines" yaml:"sparklines"`
+	// Heatmap colors cells by their recent activity, and Projection shows
+	// every layer at once.
+	Heatmap    bool `toml:"heatmap" yaml:"heatmap"`
+	Projection bool `toml:"projection" yaml:"projection"`
+	// Contact brightens the fronts where species meet and darkens the
+	// calm interiors.
+	Contact bool `toml:"contact" yaml:"contact"`
+	// Owners colors cells by the goroutine updating them, with how evenly
+	// the goroutines share the work, to tell the load balance of the
+	// tiled and synchronous updates.
+	Owners bool `toml:"owners" yaml:"owners"`
+	// FPS shows how fast frames are drawn and cells updated.
+	FPS bool `toml:"fps" yaml:"fps"`
+	// MaxFPS caps the frames drawn a second. Frames are only drawn after a
+	// tick of the simulation or an input event, so a paused or idle display
+	// costs next to nothing.
+	MaxFPS int `toml:"max_fps" yaml:"max_fps"`
+	// Camera starts the camera, which keeps the viewport of a grid larger
+	// than the screen on its busiest region.
+	Camera bool `toml:"camera" yaml:"camera"`
+	// Events shows the latest extinctions, dominance flips, cluster merges
+	// of MergeSize cells or more and edits; EventLog is a file that gains
+	// a line for each of them.
+	Events    bool   `toml:"events" yaml:"events"`
+	MergeSize int    `toml:"merge_size" yaml:"merge_size"`
+	EventLog  string `toml:"event_log" yaml:"event_log"`
+	// Notify shows a banner, and rings the bell if Bell is set, when a
+	// species dies out or reaches Majority of the live cells.
+	Notify   bool    `toml:"notify" yaml:"notify"`
+	Bell     bool    `toml:"bell" yaml:"bell"`
+	Majority float64 `toml:"majority" yaml:"majority"`
+	// Autosave is how often the state is saved for recovery after a crash;
+	// 0 disables it.
+	Autosave time.Duration `toml:"autosave" yaml:"autosave"`
+	// Commands is where the TUI reads commands from while it runs: - for
+	// standard input, or the path of a Unix socket to listen on; see
+	// commander.
+	Commands string `toml:"commands" yaml:"commands"`
+	// Timeline is a file of commands to run at simulated times; see
+	// loadTimeline.
+	Timeline string `toml:"timeline" yaml:"timeline"`
+	// Summary, unless 0, is the simulated time between summary lines; see
+	// startSummary.
+	Summary time.Duration `toml:"summary" yaml:"summary"`
+	// LogFile is a file that log records are appended to instead of
+	// standard error, as JSON lines if LogJSON is set. Verbose adds the
+	// debug records of the engines starting, stopping and pausing.
+	LogFile string `toml:"log_file" yaml:"log_file"`
+	LogJSON bool   `toml:"log_json" yaml:"log_json"`
+	Verbose bool   `toml:"verbose" yaml:"verbose"`
+	// Sound, if set, plays the bottom layer of the first pane as audio:
+	// events for births and deaths as notes, population for a tone per
+	// species. SoundOut is a command fed raw 16-bit mono PCM on its
+	// standard input, or a .wav file to record to; see package sound.
+	Sound    string `toml:"sound" yaml:"sound"`
+	SoundOut string `toml:"sound_out" yaml:"sound_out"`
+	// OSC is a host:port sent Cues as OSC messages over UDP, and MIDI a
+	// raw MIDI device, such as /dev/snd/midiC1D0, or a file sent them as
+	// notes; see cues.go.
+	OSC  string      `toml:"osc" yaml:"osc"`
+	MIDI string      `toml:"midi" yaml:"midi"`
+	Cues []CueConfig `toml:"cues" yaml:"cues"`
+	// Video is a file, such as out.mp4, that ffmpeg encodes the bottom
+	// layer of the first pane into, at VideoFPS frames per second with each
+	// cell VideoScale pixels wide. The display records VideoFPS frames a
+	// second of running time; the run subcommand records a frame per tick.
+	// VideoBlend, above 1, records the grid once every VideoBlend frames
+	// instead, cross-fading the frames in between from each to the next.
+	Video      string `toml:"video" yaml:"video"`
+	VideoFPS   int    `toml:"video_fps" yaml:"video_fps"`
+	VideoScale int    `toml:"video_scale" yaml:"video_scale"`
+	VideoBlend int    `toml:"video_blend" yaml:"video_blend"`
+	// Record is a recording (.nncr) of every tick of the bottom layer of
+	// the first pane, with a keyframe every RecordKeyframes ticks, for the
+	// replay subcommand; see package recording.
+	Record          string `toml:"record" yaml:"record"`
+	RecordKeyframes int    `toml:"record_keyframes" yaml:"record_keyframes"`
+	// Savepoints is a directory the savepoints made with the mark key are
+	// saved in, as autosaves, besides memory; see savepoints.
+	Savepoints string `toml:"savepoints" yaml:"savepoints"`
+	// Panel is a physical display the bottom layer of the first pane is
+	// shown on at PanelFPS frames a second, in the TUI and the daemon: a
+	// Linux framebuffer device such as /dev/fb0, or ft:HOST[:PORT] for a
+	// Flaschen Taschen server driving an LED matrix; see panel.
+	Panel    string `toml:"panel" yaml:"panel"`
+	PanelFPS int    `toml:"panel_fps" yaml:"panel_fps"`
+	// HTTP is an address, such as :8081, to serve the layers of the first
+	// pane on as PNG images, fetched and replaced over HTTP, in the TUI
+	// and the daemon; see startHTTP.
+	HTTP string `toml:"http" yaml:"http"`
+	// Montage is a PNG file written at the end of the run with a thumbnail
+	// of the bottom layer of the first pane every MontageEvery of simulated
+	// time, each cell MontageScale pixels wide; see montage.
+	Montage      string        `toml:"montage" yaml:"montage"`
+	MontageEvery time.Duration `toml:"montage_every" yaml:"montage_every"`
+	MontageScale int           `toml:"montage_scale" yaml:"montage_scale"`
+	// Report is an HTML file written at the end of the run documenting it:
+	// the populations of the bottom layer of the first pane over time, its
+	// events, its final grid and the configuration; see report.
+	Report string `toml:"report" yaml:"report"`
+	// Stats is a CSV file that gains a row of statistics every second.
+	Stats string `toml:"stats" yaml:"stats"`
+	// StatsJSONL is a file, or "-" for standard output, that gains a line
+	// of statistics as a JSON object every second.
+	StatsJSONL string `toml:"stats_jsonl" yaml:"stats_jsonl"`
+	// Seed, unless 0, makes the random choices of a run reproducible when
+	// the update order is fixed, as in the sequential and timed headless
+	// models.
+	Seed int64 `toml:"seed" yaml:"seed"`
+	// Init is the layout of the random cells and Symmetry mirrors them;
+	// see presets.go.
+	Init     string `toml:"init" yaml:"init"`
+	Symmetry string `toml:"symmetry" yaml:"symmetry"`
+	// Update is async, for every cell updating on its own reaction time,
+	// tiled, for the same on a goroutine per tile of TileSize cells square
+	// rather than per cell, or sync, for all of them at once every
+	// SyncInterval, by default the mean reaction time. Jitter moves each
+	// wait of an asynchronous cell by up to that much either way, and Drift
+	// makes every cell's clock faster or slower by up to that fraction.
+	// Sleep, unless 0, lets tiles that settled stop updating until a cell
+	// in or next to them changes; see engine.Params.Sleep.
+	Update       string        `toml:"update" yaml:"update"`
+	TileSize     int           `toml:"tile_size" yaml:"tile_size"`
+	Sleep        int           `toml:"sleep" yaml:"sleep"`
+	SyncInterval time.Duration `toml:"sync_interval" yaml:"sync_interval"`
+	Jitter       time.Duration `toml:"jitter" yaml:"jitter"`
+	Drift        float64       `toml:"drift" yaml:"drift"`
+	// CachedCounts keeps the live neighbours of every cell counted as they
+	// change; see engine.Params.CachedCounts.
+	CachedCounts bool `toml:"cached_counts" yaml:"cached_counts"`
+	// TimeScale is how many times as fast as real time the simulation
+	// runs, every reaction time and interval being simulated time; 0 means
+	// 1. Headless runs take no wall-clock time anyway.
+	TimeScale float64 `toml:"time_scale" yaml:"time_scale"`
+	// LateTolerance is how long after it was due an update may come
+	// before it counts as late, warned about when the machine cannot keep
+	// up and counted in the stats; 0 disables it.
+	LateTolerance time.Duration `toml:"late_tolerance" yaml:"late_tolerance"`
+	// CPULimit, unless 0, caps the cell updates of all the grids of the
+	// process at that many a second, to run in the background without
+	// taking every core; cells held back update late.
+	CPULimit int `toml:"cpu_limit" yaml:"cpu_limit"`
+	// MapFile, unless empty, keeps the grid in this file mapped into
+	// memory, for grids larger than memory; the file is always a saved
+	// state of the grid and, if it holds one of this size, takes the place
+	// of seeding. See engine.Params.MapFile.
+	MapFile string `toml:"map_file" yaml:"map_file"`
+	// AB shows two panes seeded identically, the right one updated
+	// synchronously unless its pane config says otherwise, and how much
+	// they diverge.
+	AB bool `toml:"ab" yaml:"ab"`
+	// Fork shows two panes starting from the same cells and settings but
+	// for the command of commander it runs on the right one, such as
+	// "set tau red 50ms", and how much they diverge; the fork key forks
+	// them again from the left one's current state.
+	Fork string `toml:"fork" yaml:"fork"`
+	// Attract, if set, moves on to new rules, colors and cells every so
+	// often, fading between them, for a display nobody watches over.
+	Attract time.Duration `toml:"attract" yaml:"attract"`
+	// Panes is the number of independent simulations shown side by side.
+	// PaneConfigs are config files loaded over this one for each pane in
+	// turn, implying as many panes; see paneConfigs.
+	Panes       int      `toml:"panes" yaml:"panes"`
+	PaneConfigs []string `toml:"pane_configs" yaml:"pane_configs"`
+	// RuleScript is a Lua file defining nextState; it replaces the
+	// species' rulestrings.
+	RuleScript string `toml:"rule_script" yaml:"rule_script"`
+	// RuleWASM is a WebAssembly rule plugin; see package wasmrule.
+	RuleWASM string              `toml:"rule_wasm" yaml:"rule_wasm"`
+	Keys     map[string][]string `toml:"keys" yaml:"keys"`
+	Energy   EnergyConfig        `toml:"energy" yaml:"energy"`
+	Resource ResourceConfig      `toml:"resource" yaml:"resource"`
+	// Mutation is the probability that a newborn is a random other species.
+	Mutation float64 `toml:"mutation" yaml:"mutation"`
+	// Decay is the probability that a live cell dies at an update whatever
+	// its neighbours.
+	Decay float64 `toml:"decay" yaml:"decay"`
+	// Switching is, by species name and then the name of another species,
+	// the probability that a live cell of the first switches to the second
+	// in place at an update; see engine.Params.Switching.
+	Switching map[string]map[string]float64 `toml:"switching" yaml:"switching"`
+	// MaxAge, unless 0, is how many of its updates a live cell survives
+	// before it dies whatever its neighbours; see engine.Params.Lifespan.
+	MaxAge int `toml:"max_age" yaml:"max_age"`
+	// StrictBirth allows births only among live neighbours of one species;
+	// see engine.Params.StrictBirth.
+	StrictBirth bool `toml:"strict_birth" yaml:"strict_birth"`
+	// TieBreak names how births tied between species are settled: random,
+	// priority, alternate or oldest; see engine.Params.TieBreak.
+	TieBreak string `toml:"tie_break" yaml:"tie_break"`
+	// Hybrids is how many hybrid species tied births may breed; see
+	// engine.Params.Hybrids.
+	Hybrids int `toml:"hybrids" yaml:"hybrids"`
+	// Sample, unless 0, is how many of its eight neighbours a cell sees at
+	// an update, picked at random; see engine.Params.Sample.
+	Sample int `toml:"sample" yaml:"sample"`
+	// Motility is the probability that a live cell moves at an update;
+	// see engine.Params.Motility.
+	Motility float64 `toml:"motility" yaml:"motility"`
+	// Kernel weighs the neighbours counted by the rules, a square matrix of
+	// odd side centred on the cell; see engine.Kernel. FarWeight, unless 0,
+	// is the shorthand for the radius-2 Moore kernel whose outer ring
+	// counts that much, and KernelName names a built-in kernel or a kernel
+	// file; see kernels.
+	Kernel     [][]float64 `toml:"kernel" yaml:"kernel"`
+	FarWeight  float64     `toml:"far_weight" yaml:"far_weight"`
+	KernelName string      `toml:"kernel_name" yaml:"kernel_name"`
+	// Zones give rectangles of the grid parameters of their own, listed
+	// as [[zones]].
+	Zones []ZoneConfig `toml:"zones" yaml:"zones"`
+	// Probes are rectangles of the grid tracked on their own, listed as
+	// [[probes]]; see ProbeConfig.
+	Probes []ProbeConfig `toml:"probes" yaml:"probes"`
+	// Schedules drive parameters over simulated time, listed as
+	// [[schedules]]; see ScheduleConfig.
+	Schedules []ScheduleConfig `toml:"schedules" yaml:"schedules"`
+	// Night gives the species other rules for part of every period of
+	// simulated time; see NightConfig.
+	Night NightConfig `toml:"night" yaml:"night"`
+	// Flow is a constant wind written DX,DY or a flow file, which carry
+	// births along; see flow.
+	Flow string `toml:"flow" yaml:"flow"`
+	// Teams allies species, each team written as the names of its species
+	// joined by +, such as "green+blue"; see engine.Species.Team.
+	Teams []string `toml:"teams" yaml:"teams"`
+	// Refractory is how long a cell cannot be born again after it dies,
+	// for species without a refractory time of their own.
+	Refractory time.Duration    `toml:"refractory" yaml:"refractory"`
+	SIR        SIRConfig        `toml:"sir" yaml:"sir"`
+	ForestFire ForestFireConfig `toml:"forest_fire" yaml:"forest_fire"`
+	Sandpile   SandpileConfig   `toml:"sandpile" yaml:"sandpile"`
+	Agents     AgentsConfig     `toml:"agents" yaml:"agents"`
+	GrayScott  GrayScottConfig  `toml:"gray_scott" yaml:"gray_scott"`
+	Lenia      LeniaConfig      `toml:"lenia" yaml:"lenia"`
+	Schelling  SchellingConfig  `toml:"schelling" yaml:"schelling"`
+	// Layers, if set, stacks several engines, bottom first; see layers.go.
+	Layers []LayerConfig `toml:"layers" yaml:"layers"`
+
+	// halo makes the grid a band of a larger one, for the shard
+	// subcommand.
+	halo bool
+	// remote disables the export key, for the sessions of the ssh
+	// subcommand, whose users may not write files on the server.
+	remote bool
+	// loaded holds the top-level keys that config files set, which count
+	// as given like flags; see session.apply.
+	loaded map[string]bool
+}
+
+// LayerConfig is one layer of a stacked simulation. Every other setting is
+// shared by all layers.
+type LayerConfig struct {
+	Mode       string `toml:"mode" yaml:"mode"`
+	RuleScript string `toml:"rule_script" yaml:"rule_script"`
+	RuleWASM   string `toml:"rule_wasm" yaml:"rule_wasm"`
+}
+
+// GrayScottConfig holds the parameters of the grayscott mode.
+type GrayScottConfig struct {
+	Feed float64 `toml:"feed" yaml:"feed"`
+	Kill float64 `toml:"kill" yaml:"kill"`
+	Du   float64 `toml:"du" yaml:"du"`
+	Dv   float64 `toml:"dv" yaml:"dv"`
+}
+
+// LeniaConfig holds the parameters of the lenia mode.
+type LeniaConfig struct {
+	Radius int     `toml:"radius" yaml:"radius"`
+	Mu     float64 `toml:"mu" yaml:"mu"`
+	Sigma  float64 `toml:"sigma" yaml:"sigma"`
+	Dt     float64 `toml:"dt" yaml:"dt"`
+}
+
+// AgentsConfig places turmites on the grid at random at startup.
+type AgentsConfig struct {
+	Count    int           `toml:"count" yaml:"count"`
+	Rule     string        `toml:"rule" yaml:"rule"`
+	Interval time.Duration `toml:"interval" yaml:"interval"`
+}
+
+// SIRConfig holds the parameters of the sir mode.
+type SIRConfig struct {
+	InfectionRate float64 `toml:"infection_rate" yaml:"infection_rate"`
+	Recovery      int     `toml:"recovery" yaml:"recovery"` // in updates
+}
+
+// ForestFireConfig holds the parameters of the forestfire mode.
+type ForestFireConfig struct {
+	Growth    float64 `toml:"growth" yaml:"growth"`
+	Lightning float64 `toml:"lightning" yaml:"lightning"`
+}
+
+// SchellingConfig holds the parameters of the schelling mode.
+type SchellingConfig struct {
+	Tolerance float64 `toml:"tolerance" yaml:"tolerance"`
+}
+
+// SandpileConfig holds the parameters of the sandpile mode.
+type SandpileConfig struct {
+	Drop float64 `toml:"drop" yaml:"drop"`
+}
+
+type WallConfig struct {
+	Color string `toml:"color" yaml:"color"`
+}
+
+type DeadConfig struct {
+	Color        string        `toml:"color" yaml:"color"`
+	ReactionTime time.Duration `toml:"reaction_time" yaml:"reaction_time"`
+}
+
+type SpeciesConfig struct {
+	Name         string        `toml:"name" yaml:"name"`
+	Color        string        `toml:"color" yaml:"color"`
+	ReactionTime time.Duration `toml:"reaction_time" yaml:"reaction_time"`
+	Rule         string        `toml:"rule" yaml:"rule"`
+	// Refractory, unless 0, overrides Config.Refractory for the species.
+	Refractory time.Duration `toml:"refractory" yaml:"refractory"`
+}
+
+// EnergyConfig enables the metabolism model; see engine.Metabolism.
+type EnergyConfig struct {
+	Enabled  bool    `toml:"enabled" yaml:"enabled"`
+	Initial  float64 `toml:"initial" yaml:"initial"`
+	Decay    float64 `toml:"decay" yaml:"decay"`
+	Transfer float64 `toml:"transfer" yaml:"transfer"`
+}
+
+// ResourceConfig enables the resource field; see engine.Resource.
+type ResourceConfig struct {
+	Enabled     bool    `toml:"enabled" yaml:"enabled"`
+	Consumption float64 `toml:"consumption" yaml:"consumption"`
+	Regrowth    float64 `toml:"regrowth" yaml:"regrowth"`
+	Starvation  float64 `toml:"starvation" yaml:"starvation"`
+}
+
+func defaultConfig() Config {
+	p := engine.DefaultParams()
+	cfg := Config{
+		Mode:            "life",
+		Rows:            p.Rows,
+		Cols:            p.Cols,
+		Boundary:        p.Boundary.String(),
+		TieBreak:        p.TieBreak.String(),
+		Depth:           1,
+		Density:         0.3,
+		Init:            "random",
+		Symmetry:        "none",
+		Rule3D:          "B5/S45",
+		AgeFade:         50,
+		TrailLength:     8,
+		StillAfter:      20,
+		Rewind:          100,
+		Panes:           1,
+		Update:          "async",
+		TileSize:        engine.DefaultTileSize,
+		Autosave:        30 * time.Second,
+		MergeSize:       50,
+		Notify:          true,
+		Majority:        0.8,
+		Export:          "rle",
+		VideoFPS:        25,
+		VideoScale:      4,
+		RecordKeyframes: 100,
+		PanelFPS:        25,
+		MaxFPS:          20,
+		MontageEvery:    10 * time.Second,
+		MontageScale:    2,
+		LateTolerance:   20 * time.Millisecond,
+		SoundOut:        "aplay -q -t raw -f S16_LE -r 44100 -c 1",
+		Dead:            DeadConfig{Color: "black", ReactionTime: p.DeadReactionTime},
+		Wall:            WallConfig{Color: "gray"},
+		Keys:            defaultKeys(),
+		SIR:             SIRConfig{InfectionRate: 0.25, Recovery: 20},
+		ForestFire:      ForestFireConfig{Growth: 0.01, Lightning: 0.00005},
+		Sandpile:        SandpileConfig{Drop: 0.002},
+		Agents:          AgentsConfig{Rule: "RL", Interval: p.AgentInterval},
+		Lenia:           LeniaConfig{Radius: 6, Mu: 0.15, Sigma: 0.03, Dt: 0.1},
+		Schelling:       SchellingConfig{Tolerance: 0.3},
+		Night:           NightConfig{Period: time.Minute, Length: 0.5},
+		GrayScott: GrayScottConfig{
+			Feed: engine.DefaultGrayScott.Feed,
+			Kill: engine.DefaultGrayScott.Kill,
+			Du:   engine.DefaultGrayScott.Du,
+			Dv:   engine.DefaultGrayScott.Dv,
+		},
+		Energy: EnergyConfig{
+			Initial:  p.Energy.Initial,
+			Decay:    p.Energy.Decay,
+			Transfer: p.Energy.Transfer,
+		},
+		Resource: ResourceConfig{
+			Consumption: p.Resource.Consumption,
+			Regrowth:    p.Resource.Regrowth,
+			Starvation:  p.Resource.Starvation,
+		},
+	}
+	for _, sp := range p.Species {
+		cfg.Species = append(cfg.Species, SpeciesConfig{
+			Name:         sp.Name,
+			Color:        sp.Name,
+			ReactionTime: sp.ReactionTime,
+			Rule:         sp.Rule.String(),
+		})
+	}
+	return cfg
+}
+
+// configFlag finds the value of -config in args ahead of normal flag
+// parsing, so that the file can supply the defaults of the other flags.
+func configFlag(args []string) string {
+	for i, arg := range args {
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// bindFlags registers the command-line flags that override cfg, using the
+// current values of cfg as their defaults: those of the simulation and of
+// what it writes, which every subcommand running one honours.
+func (cfg *Config) bindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.Mode, "mode", cfg.Mode, fmt.Sprintf("built-in rule family, one of %v", modeNames()))
+	fs.IntVar(&cfg.Rows, "rows", cfg.Rows, "grid rows")
+	fs.IntVar(&cfg.Cols, "cols", cfg.Cols, "grid columns")
+	fs.IntVar(&cfg.Depth, "depth", cfg.Depth, "grid depth; above 1 runs a 3D automaton (page slices with l/L)")
+	fs.StringVar(&cfg.Rule3D, "rule-3d", cfg.Rule3D, "rulestring every species plays in 3D, e.g. B5/S45 or B14-19/S13-26")
+	fs.IntVar(&cfg.BirthCount, "birth-count", cfg.BirthCount, "live neighbours a dead cell needs to be born, instead of the rule's (0 keeps the rule's)")
+	fs.IntVar(&cfg.SurviveMin, "survive-min", cfg.SurviveMin, "fewest neighbours of its species a cell survives with, below which it dies of loneliness (0 keeps the rule's)")
+	fs.IntVar(&cfg.SurviveMax, "survive-max", cfg.SurviveMax, "most neighbours of its species a cell survives with, above which it dies of overpopulation (0 keeps the rule's)")
+	fs.StringVar(&cfg.Boundary, "boundary", cfg.Boundary, fmt.Sprintf("what cells see beyond the grid's edge, one of %v", engine.Boundaries))
+	fs.DurationVar(&cfg.Autosave, "autosave", cfg.Autosave, "how often to save the state, offered for resuming after a crash (0 disables)")
+	fs.IntVar(&cfg.MergeSize, "merge-size", cfg.MergeSize, "log a cluster merge when a species' largest cluster grows by this many cells within a second (0 disables)")
+	fs.StringVar(&cfg.Commands, "commands", cfg.Commands, "read commands such as \"set cell 10 12 red\" while running, from standard input (-) or a Unix socket at this path")
+	fs.StringVar(&cfg.Timeline, "timeline", cfg.Timeline, "run the commands of this file at the simulated times they are given, as in \"t=10s: stamp gun at 5,5\"")
+	fs.DurationVar(&cfg.Summary, "summary", cfg.Summary, "every this much simulated time, write a line summing up the populations, below the grid or on standard output in run (0 for none)")
+	fs.BoolVar(&cfg.Verbose, "v", cfg.Verbose, "log debug records too, such as engines starting and pausing")
+	fs.StringVar(&cfg.LogFile, "log-file", cfg.LogFile, "append log records to this file instead of standard error")
+	fs.BoolVar(&cfg.LogJSON, "log-json", cfg.LogJSON, "write log records as JSON lines")
+	fs.StringVar(&cfg.EventLog, "event-log", cfg.EventLog, "append every event to this file")
+	fs.Float64Var(&cfg.Majority, "majority", cfg.Majority, "share of the live cells from which a species counts as dominant (0 disables)")
+	fs.StringVar(&cfg.Pattern, "pattern", cfg.Pattern, "pattern file (.rle, .cells, .lif) to start from instead of random cells")
+	fs.StringVar(&cfg.Image, "image", cfg.Image, "PNG image scaled onto the grid instead of random cells, pixels becoming the species of the nearest color (dark ones dead)")
+	fs.StringVar(&cfg.Walls, "walls", cfg.Walls, "RLE pattern file whose walls (z) are added to the grid")
+	fs.StringVar(&cfg.Video, "video", cfg.Video, "record the grid to this video file (e.g. out.mp4) through ffmpeg")
+	fs.IntVar(&cfg.VideoFPS, "video-fps", cfg.VideoFPS, "frames per second of the video")
+	fs.IntVar(&cfg.VideoScale, "video-scale", cfg.VideoScale, "pixels per cell of the video")
+	fs.IntVar(&cfg.VideoBlend, "video-blend", cfg.VideoBlend, "cross-fade this many frames of the video from each state of the grid to the next, smoothing the flicker of asynchronous updates (0 for none)")
+	fs.StringVar(&cfg.Record, "record", cfg.Record, "record every tick of the grid to this file (.nncr), compactly, for replay")
+	fs.IntVar(&cfg.RecordKeyframes, "record-keyframes", cfg.RecordKeyframes, "ticks between the whole frames of -record, which replay jumps to")
+	fs.StringVar(&cfg.HTTP, "http", cfg.HTTP, "serve the grid as a PNG on this address (e.g. :8081): GET /grid.png renders it, POST /grid.png replaces it")
+	fs.StringVar(&cfg.Panel, "panel", cfg.Panel, "also show the grid on this framebuffer device (e.g. /dev/fb0) or LED matrix behind a Flaschen Taschen server (ft:HOST[:PORT])")
+	fs.IntVar(&cfg.PanelFPS, "panel-fps", cfg.PanelFPS, "frames per second of -panel")
+	fs.StringVar(&cfg.Montage, "montage", cfg.Montage, "write thumbnails of the grid taken through the run to this PNG file at its end")
+	fs.DurationVar(&cfg.MontageEvery, "montage-every", cfg.MontageEvery, "simulated time between the thumbnails of -montage")
+	fs.IntVar(&cfg.MontageScale, "montage-scale", cfg.MontageScale, "pixels per cell of the thumbnails of -montage")
+	fs.StringVar(&cfg.Report, "report", cfg.Report, "write a self-contained HTML report of the run, with population charts, events, the final grid and the configuration, to this file at its end")
+	fs.StringVar(&cfg.Stats, "stats", cfg.Stats, "append per-second statistics (population, entropy, compression) to this CSV file")
+	fs.StringVar(&cfg.StatsJSONL, "stats-jsonl", cfg.StatsJSONL, "append per-second statistics and the events logged in between to this file as JSON lines, - for standard output")
+	fs.Float64Var(&cfg.Density, "density", cfg.Density, "probability that a randomly seeded cell is alive")
+	fs.StringVar(&cfg.Symmetry, "symmetry", cfg.Symmetry, fmt.Sprintf("mirror the initial cells, one of %v", symmetries))
+	fs.Int64Var(&cfg.Seed, "seed", cfg.Seed, "seed of the random choices, for reproducible headless runs (0 for a random seed)")
+	fs.StringVar(&cfg.Init, "init", cfg.Init, fmt.Sprintf("layout of the initial cells as name[:param=value,...], name one of %v", presetNames()))
+	for _, name := range allSpeciesNames(cfg) {
+		fs.Var(densityFlag{cfg, name}, "density-"+name, fmt.Sprintf("seeding probability of %s cells, overriding their share of -density", name))
+	}
+	fs.StringVar(&cfg.Update, "update", cfg.Update, "async (cells update on their own reaction times), tiled (the same with a goroutine per tile instead of per cell) or sync (all at once every -sync-interval)")
+	fs.IntVar(&cfg.TileSize, "tile-size", cfg.TileSize, "side of the tiles of -update tiled and -model tiles, in cells")
+	fs.BoolVar(&cfg.CachedCounts, "cached-counts", cfg.CachedCounts, "keep the live neighbours of every cell counted as they change rather than reading them at every update")
+	fs.IntVar(&cfg.Sleep, "sleep", cfg.Sleep, "with -update tiled, let a tile whose cells updated this many times each with nothing changing sleep until a cell in or next to it changes (0 for never)")
+	fs.IntVar(&cfg.CPULimit, "cpu-limit", cfg.CPULimit, "at most this many cell updates a second across all grids, to run in the background politely (0 for no limit)")
+	fs.StringVar(&cfg.MapFile, "map-file", cfg.MapFile, "keep the grid in this memory-mapped file (.nnmap), starting from the grid in it if it holds one of this size")
+	fs.DurationVar(&cfg.SyncInterval, "sync-interval", cfg.SyncInterval, "time between synchronous updates; 0 for the mean reaction time")
+	fs.DurationVar(&cfg.Jitter, "jitter", cfg.Jitter, "random offset of up to this much either way added to every wait of a cell between updates")
+	fs.DurationVar(&cfg.LateTolerance, "late-tolerance", cfg.LateTolerance, "warn when updates come more than this late, the machine not keeping up with the reaction times (0 disables)")
+	fs.Float64Var(&cfg.TimeScale, "time-scale", cfg.TimeScale, "run this many times as fast as real time, reaction times and intervals being simulated time (0 for 1)")
+	fs.Float64Var(&cfg.Drift, "drift", cfg.Drift, "fraction by which each cell's clock is permanently faster or slower, drawn at random per cell (e.g. 0.1)")
+	fs.StringVar(&cfg.RuleScript, "rule-script", cfg.RuleScript, "Lua script defining nextState(self, neighbors), reloaded on change")
+	fs.StringVar(&cfg.RuleWASM, "rule-wasm", cfg.RuleWASM, "WebAssembly rule plugin exporting next_state")
+	fs.Float64Var(&cfg.Mutation, "mutation", cfg.Mutation, "probability that a newborn becomes a random other species")
+	fs.Float64Var(&cfg.Decay, "decay", cfg.Decay, "probability that a live cell dies at an update whatever its neighbours")
+	fs.IntVar(&cfg.MaxAge, "max-age", cfg.MaxAge, "kill live cells after they survive this many of their updates, whatever their neighbours (0 for never)")
+	fs.BoolVar(&cfg.StrictBirth, "strict-birth", cfg.StrictBirth, "give birth only to cells whose live neighbours are all of one species")
+	fs.StringVar(&cfg.TieBreak, "tie-break", cfg.TieBreak, "who a birth tied between species goes to: random, priority, alternate or oldest")
+	fs.Var((*listFlag)(&cfg.Teams), "team", "species allied as a team, such as green+blue; repeat for each team")
+	fs.IntVar(&cfg.Sample, "sample", cfg.Sample, "neighbours out of 8 a cell sees at each update, picked at random, for imperfect local information (0 sees all)")
+	fs.Float64Var(&cfg.Motility, "motility", cfg.Motility, "probability that a live cell moves to a better empty neighbour at an update instead of living or dying in place")
+	fs.Float64Var(&cfg.FarWeight, "far-weight", cfg.FarWeight, "count the cells two rows or columns away with this weight as well as the eight nearest, rounding the weighted counts by chance (0 for not)")
+	fs.StringVar(&cfg.KernelName, "kernel", cfg.KernelName, fmt.Sprintf("count neighbours with a built-in kernel, one of %v, or that of a kernel file", kernelNames()))
+	fs.StringVar(&cfg.Flow, "flow", cfg.Flow, "bias births downstream of a constant wind DX,DY, in rows down and columns right of speed at most 1, or of the field of a flow file")
+	fs.IntVar(&cfg.Hybrids, "hybrids", cfg.Hybrids, "most hybrid species bred from births tied between species (0 picks one of them at random)")
+	fs.StringVar(&cfg.Night.Rule, "night-rule", cfg.Night.Rule, "B/S rule every species plays by night, such as B2/S for Seeds (empty for no nights)")
+	fs.DurationVar(&cfg.Night.Period, "night-period", cfg.Night.Period, "simulated time of a day and a night together")
+	fs.Float64Var(&cfg.Night.Length, "night-length", cfg.Night.Length, "share of the period that is night, at its end")
+	fs.DurationVar(&cfg.Refractory, "refractory", cfg.Refractory, "time a cell cannot be born again after it dies, for species without a refractory time of their own")
+	fs.Float64Var(&cfg.SIR.InfectionRate, "sir-rate", cfg.SIR.InfectionRate, "sir mode: infection probability per infected neighbour and update")
+	fs.IntVar(&cfg.SIR.Recovery, "sir-recovery", cfg.SIR.Recovery, "sir mode: updates until an infected cell recovers")
+	fs.Float64Var(&cfg.ForestFire.Growth, "fire-growth", cfg.ForestFire.Growth, "forestfire mode: probability an empty cell grows a tree per update")
+	fs.Float64Var(&cfg.ForestFire.Lightning, "fire-lightning", cfg.ForestFire.Lightning, "forestfire mode: probability a tree is struck by lightning per update")
+	fs.Float64Var(&cfg.Sandpile.Drop, "sand-drop", cfg.Sandpile.Drop, "sandpile mode: probability a cell receives a grain per update")
+	fs.Float64Var(&cfg.GrayScott.Feed, "gs-feed", cfg.GrayScott.Feed, "grayscott mode: feed rate")
+	fs.Float64Var(&cfg.GrayScott.Kill, "gs-kill", cfg.GrayScott.Kill, "grayscott mode: kill rate")
+	fs.IntVar(&cfg.Lenia.Radius, "lenia-radius", cfg.Lenia.Radius, "lenia mode: kernel radius in cells")
+	fs.Float64Var(&cfg.Lenia.Mu, "lenia-mu", cfg.Lenia.Mu, "lenia mode: growth center")
+	fs.Float64Var(&cfg.Lenia.Sigma, "lenia-sigma", cfg.Lenia.Sigma, "lenia mode: growth width")
+	fs.Float64Var(&cfg.Schelling.Tolerance, "schelling-tolerance", cfg.Schelling.Tolerance, "schelling mode: least share of a cell's live neighbours of its own species it is happy with")
+	fs.IntVar(&cfg.Agents.Count, "ants", cfg.Agents.Count, "number of turmites to place at random")
+	fs.StringVar(&cfg.Agents.Rule, "ant-rule", cfg.Agents.Rule, "turmite turn rule indexed by species, e.g. RL for Langton's ant")
+	fs.DurationVar(&cfg.Agents.Interval, "ant-interval", cfg.Agents.Interval, "time between turmite steps")
+	fs.BoolVar(&cfg.Energy.Enabled, "energy", cfg.Energy.Enabled, "enable the energy/metabolism model")
+	fs.Float64Var(&cfg.Energy.Initial, "energy-initial", cfg.Energy.Initial, "energy of a newborn cell")
+	fs.Float64Var(&cfg.Energy.Decay, "energy-decay", cfg.Energy.Decay, "energy a live cell spends per update")
+	fs.Float64Var(&cfg.Energy.Transfer, "energy-transfer", cfg.Energy.Transfer, "energy gained per neighbour of another species eaten")
+	fs.BoolVar(&cfg.Resource.Enabled, "resource", cfg.Resource.Enabled, "lay a regrowing resource field under the grid that live cells eat")
+	fs.Float64Var(&cfg.Resource.Consumption, "resource-consumption", cfg.Resource.Consumption, "share of the resource under it a live cell eats per update")
+	fs.Float64Var(&cfg.Resource.Regrowth, "resource-regrowth", cfg.Resource.Regrowth, "logistic regrowth rate of the resource per update")
+	fs.Float64Var(&cfg.Resource.Starvation, "resource-starvation", cfg.Resource.Starvation, "resource level below which a live cell dies")
+}
+
+// bindTUIFlags registers the flags of the terminal interface, for the
+// subcommands that show it; see bindFlags.
+func (cfg *Config) bindTUIFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&cfg.Invert, "invert", cfg.Invert, "invert foreground/background colors")
+	fs.BoolVar(&cfg.AgeShading, "age-shading", cfg.AgeShading, "darken live cells as they age (toggle with a)")
+	fs.BoolVar(&cfg.Trails, "trails", cfg.Trails, "leave fading trails behind dying cells (toggle with t)")
+	fs.BoolVar(&cfg.Structures, "structures", cfg.Structures, "highlight still lifes and oscillators (toggle with s)")
+	fs.IntVar(&cfg.StillAfter, "still-after", cfg.StillAfter, "updates a live cell must stay unchanged to count as a still life")
+	fs.IntVar(&cfg.Rewind, "rewind", cfg.Rewind, "ticks of history kept for stepping back while paused (0 disables)")
+	fs.BoolVar(&cfg.Sparklines, "sparklines", cfg.Sparklines, "graph each species' recent population below the grid (toggle with g)")
+	fs.BoolVar(&cfg.Heatmap, "heatmap", cfg.Heatmap, "color cells by how often they changed recently (toggle with h)")
+	fs.BoolVar(&cfg.Contact, "contact", cfg.Contact, "highlight the cells where species meet, darkening the rest (toggle with H)")
+	fs.BoolVar(&cfg.Owners, "owners", cfg.Owners, "color cells by the worker goroutine updating them, with the update rate of each (toggle with w)")
+	fs.BoolVar(&cfg.Projection, "projection", cfg.Projection, "show every layer or slice at once (toggle with p)")
+	fs.BoolVar(&cfg.Camera, "camera", cfg.Camera, "keep the view of a grid larger than the screen on its busiest region (toggle with z)")
+	fs.BoolVar(&cfg.Events, "events", cfg.Events, "show the latest extinctions, dominance flips, cluster merges and edits (toggle with E)")
+	fs.BoolVar(&cfg.Notify, "notify", cfg.Notify, "show a banner when a species dies out or reaches the -majority share")
+	fs.BoolVar(&cfg.Bell, "bell", cfg.Bell, "ring the terminal bell with each banner")
+	fs.BoolVar(&cfg.FPS, "fps", cfg.FPS, "show the frame and cell-update rates in the top right corner (toggle with f)")
+	fs.IntVar(&cfg.MaxFPS, "max-fps", cfg.MaxFPS, "most frames drawn a second; frames are drawn only after a tick or an input event")
+	fs.StringVar(&cfg.Export, "export", cfg.Export, fmt.Sprintf("format the export key (x) writes, one of %v", formatNames()))
+	fs.StringVar(&cfg.PatternDir, "pattern-dir", cfg.PatternDir, "directory the export key (x) writes to (default that of -pattern, else the current one)")
+	fs.StringVar(&cfg.Theme, "theme", cfg.Theme, fmt.Sprintf("look of the cells: a built-in theme, one of %v, or a TOML or YAML theme file", themeNames()))
+	fs.BoolVar(&cfg.Mono, "mono", cfg.Mono, "draw species as letters (G, R, B ...) in the terminal's default colors, for terminals without color")
+	fs.StringVar(&cfg.Savepoints, "savepoints", cfg.Savepoints, "also save the savepoints (m) in this directory, and offer those saved there by earlier runs")
+	fs.BoolVar(&cfg.AB, "ab", cfg.AB, "compare two identically seeded panes, the right one updated synchronously")
+	fs.StringVar(&cfg.Fork, "fork", cfg.Fork, "compare two panes from the same cells, the right one changed by this command, such as \"set tau red 50ms\"; F forks them again")
+	fs.DurationVar(&cfg.Attract, "attract", cfg.Attract, "attract mode: every this much time, fade to new random rules, colors and cells, for a wall display (0 disables)")
+	fs.IntVar(&cfg.Panes, "panes", cfg.Panes, "number of independent simulations side by side")
+	fs.Var((*listFlag)(&cfg.PaneConfigs), "pane-config", "config file loaded over the others for the next pane; repeat for each pane")
+}
+
+// bindCueFlags registers the flags that play the automaton as sound or
+// send its cues to other programs while it is watched; see bindFlags.
+func (cfg *Config) bindCueFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.Sound, "sound", cfg.Sound, "play the automaton as audio: events (births and deaths as notes) or population (a tone per species)")
+	fs.StringVar(&cfg.SoundOut, "sound-out", cfg.SoundOut, "command fed the audio as raw 16-bit mono PCM, or a .wav file to record it to")
+	fs.StringVar(&cfg.OSC, "osc", cfg.OSC, "send cues, by default extinctions, as OSC messages to this UDP host:port")
+	fs.StringVar(&cfg.MIDI, "midi", cfg.MIDI, "send cues, by default extinctions, as MIDI notes to this raw MIDI device or file")
+}
+
+// listFlag is a flag.Value appending each use of the flag to a list.
+type listFlag []string
+
+func (f *listFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *listFlag) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// clone returns a copy of cfg that can be loaded over without changing cfg.
+func (cfg *Config) clone() Config {
+	c := *cfg
+	c.Species = slices.Clone(cfg.Species)
+	c.Layers = slices.Clone(cfg.Layers)
+	c.Teams = slices.Clone(cfg.Teams)
+	c.Densities = maps.Clone(cfg.Densities)
+	c.Night.Rules = maps.Clone(cfg.Night.Rules)
+	c.Switching = maps.Clone(cfg.Switching)
+	c.loaded = maps.Clone(cfg.loaded)
+	return c
+}
+
+// paneConfigs returns the configuration of every pane. Each starts from cfg,
+// command-line flags included, and loads its entry of PaneConfigs, if any,
+// on top; in an a/b comparison the second pane is synchronous by default.
+// Only the first pane writes a stats file or maps a map file unless a
+// pane config names its own.
+func (cfg *Config) paneConfigs() ([]Config, error) {
+	n := max(cfg.Panes, len(cfg.PaneConfigs))
+	if n < 1 {
+		return nil, fmt.Errorf("panes must be at least 1")
+	}
+	if cfg.AB || cfg.Fork != "" {
+		if n > 2 {
+			return nil, fmt.Errorf("a/b comparison and fork need two panes, not %d", n)
+		}
+		n = 2
+	}
+	var pcs []Config
+	for i := range n {
+		pc := cfg.clone()
+		pc.Panes, pc.PaneConfigs = 1, nil
+		if cfg.AB && i == 1 {
+			pc.Update = "sync"
+		}
+		if i < len(cfg.PaneConfigs) {
+			if err := pc.load(cfg.PaneConfigs[i]); err != nil {
+				return nil, err
+			}
+		}
+		if i > 0 && pc.Stats == cfg.Stats {
+			pc.Stats = ""
+		}
+		if i > 0 && pc.StatsJSONL == cfg.StatsJSONL {
+			pc.StatsJSONL = ""
+		}
+		if i > 0 && pc.MapFile == cfg.MapFile {
+			pc.MapFile = ""
+		}
+		pcs = append(pcs, pc)
+	}
+	return pcs, nil
+}
+
+// densityFlag sets the density of one species in Config.Densities.
+type densityFlag struct {
+	cfg  *Config
+	name string
+}
+
+func (f densityFlag) String() string {
+	if f.cfg == nil {
+		return ""
+	}
+	if d, ok := f.cfg.Densities[f.name]; ok {
+		return strconv.FormatFloat(d, 'g', -1, 64)
+	}
+	return ""
+}
+
+func (f densityFlag) Set(s string) error {
+	d, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	if f.cfg.Densities == nil {
+		f.cfg.Densities = make(map[string]float64)
+	}
+	f.cfg.Densities[f.name] = d
+	return nil
+}
+
+// load decodes the file at path on top of cfg, choosing the format from the
+// file extension. A species list in the file replaces the default species
+// entirely; keybindings are merged per action.
+func (cfg *Config) load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := cfg.decode(data, strings.ToLower(filepath.Ext(path))); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// decode loads the settings of a config file in the format of ext, .toml,
+// .yaml or .yml, on top of cfg.
+func (cfg *Config) decode(data []byte, ext string) error {
+	defaults := cfg.Keys
+	cfg.Keys = nil
+	if cfg.loaded == nil {
+		cfg.loaded = map[string]bool{}
+	}
+
+	switch ext {
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown key %q", undecoded[0].String())
+		}
+		for _, key := range md.Keys() {
+			cfg.loaded[key[0]] = true
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil {
+			return err
+		}
+		var keys map[string]any
+		if err := yaml.Unmarshal(data, &keys); err != nil {
+			return err
+		}
+		for key := range keys {
+			cfg.loaded[key] = true
+		}
+	default:
+		return fmt.Errorf("unsupported config format %q (want .toml, .yaml or .yml)", ext)
+	}
+
+	for action, keys := range defaults {
+		if _, ok := cfg.Keys[action]; !ok {
+			if cfg.Keys == nil {
+				cfg.Keys = make(map[string][]string)
+			}
+			cfg.Keys[action] = keys
+		}
+	}
+	return nil
+}
+
+// underFlags loads the settings of the TOML config file data on top of
+// cfg, then puts back those of the flags set on the command line in fs, as
+// if data had been given with -config.
+func (cfg *Config) underFlags(data []byte, fs *flag.FlagSet) error {
+	set := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = f.Value.String() })
+	if err := cfg.decode(data, ".toml"); err != nil {
+		return err
+	}
+	for name, value := range set {
+		if err := fs.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dumped is dump for the metadata of saved files, empty if cfg cannot be
+// dumped.
+func (cfg *Config) dumped() string {
+	text, err := cfg.dump()
+	if err != nil {
+		slog.Warn("dumping config failed", "err", err)
+	}
+	return text
+}
+
+// dump returns cfg as a TOML config file, every setting included, that
+// loads back into the same configuration.
+func (cfg *Config) dump() (string, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// params converts cfg into engine parameters.
+func (cfg *Config) params() (engine.Params, error) {
+	p := engine.DefaultParams()
+	p.Rows, p.Cols = cfg.Rows, cfg.Cols
+	p.DeadReactionTime = cfg.Dead.ReactionTime
+	p.Energy = engine.Metabolism{
+		Enabled:  cfg.Energy.Enabled,
+		Initial:  cfg.Energy.Initial,
+		Decay:    cfg.Energy.Decay,
+		Transfer: cfg.Energy.Transfer,
+	}
+	p.Resource = engine.Resource{
+		Enabled:     cfg.Resource.Enabled,
+		Consumption: cfg.Resource.Consumption,
+		Regrowth:    cfg.Resource.Regrowth,
+		Starvation:  cfg.Resource.Starvation,
+	}
+	p.Mutation = cfg.Mutation
+	p.Decay = cfg.Decay
+	p.Lifespan = cfg.MaxAge
+	p.StrictBirth = cfg.StrictBirth
+	p.Hybrids = cfg.Hybrids
+	p.Motility = cfg.Motility
+	p.Sample = cfg.Sample
+	zones, err := cfg.zones()
+	if err != nil {
+		return p, err
+	}
+	p.Zones = zones
+	kernel, err := cfg.kernel()
+	if err != nil {
+		return p, err
+	}
+	p.Kernel = kernel
+	flow, err := cfg.flow()
+	if err != nil {
+		return p, err
+	}
+	p.Flow = flow
+	p.AgentInterval = cfg.Agents.Interval
+	p.History = historyLength
+	p.Events, p.MergeSize, p.Majority = eventsKept, cfg.MergeSize, cfg.Majority
+	if cfg.Seed != 0 {
+		p.Rand = engine.NewRand(cfg.Seed)
+	}
+	if cfg.Update != "async" && cfg.Update != "tiled" && cfg.Update != "sync" {
+		return p, fmt.Errorf("update must be async, tiled or sync, not %q", cfg.Update)
+	}
+	if cfg.TileSize <= 0 {
+		return p, fmt.Errorf("tile_size must be positive")
+	}
+	p.TileSize = cfg.TileSize
+	if cfg.Sleep < 0 {
+		return p, fmt.Errorf("sleep must not be negative")
+	}
+	if cfg.Update == "tiled" {
+		p.Sleep = cfg.Sleep // the others have no tiles to sleep
+	}
+	p.CachedCounts = cfg.CachedCounts
+	if cfg.CPULimit < 0 {
+		return p, fmt.Errorf("cpu_limit must not be negative")
+	}
+	p.Throttle = cpuThrottle(cfg.CPULimit)
+	if cfg.MapFile != "" && cfg.Depth > 1 {
+		return p, fmt.Errorf("a map file holds a single layer, not a volume")
+	}
+	p.MapFile = cfg.MapFile
+	if cfg.SyncInterval < 0 {
+		return p, fmt.Errorf("sync_interval must not be negative")
+	}
+	if cfg.Jitter < 0 {
+		return p, fmt.Errorf("jitter must not be negative")
+	}
+	p.Jitter = cfg.Jitter
+	if cfg.Drift < 0 || cfg.Drift >= 1 {
+		return p, fmt.Errorf("drift must be in [0, 1)")
+	}
+	p.Drift = cfg.Drift
+	if cfg.TimeScale < 0 {
+		return p, fmt.Errorf("time_scale must not be negative")
+	}
+	p.TimeScale = cfg.TimeScale
+	if cfg.LateTolerance < 0 {
+		return p, fmt.Errorf("late_tolerance must not be negative")
+	}
+	p.LateTolerance = cfg.LateTolerance
+	if cfg.Refractory < 0 {
+		return p, fmt.Errorf("refractory must not be negative")
+	}
+	if t := cfg.Schelling.Tolerance; t < 0 || t > 1 {
+		return p, fmt.Errorf("schelling tolerance must be in [0, 1]")
+	}
+	b, err := engine.ParseBoundary(cfg.Boundary)
+	if err != nil {
+		return p, err
+	}
+	p.Boundary, p.Halo = b, cfg.halo
+	if p.TieBreak, err = engine.ParseTieBreak(cfg.TieBreak); err != nil {
+		return p, err
+	}
+	p.Transition = cfg.modeTransition()
+	scs, err := cfg.speciesConfigs()
+	if err != nil {
+		return p, err
+	}
+	teams, err := cfg.teams(scs)
+	if err != nil {
+		return p, err
+	}
+	if p.Switching, err = cfg.switching(scs); err != nil {
+		return p, err
+	}
+	p.Species = nil
+	for _, sc := range scs {
+		if cfg.Depth > 1 && cfg.Rule3D != "" {
+			sc.Rule = cfg.Rule3D
+		}
+		if sc.Rule == "" {
+			sc.Rule = engine.Conway.String()
+		}
+		rule, err := engine.ParseRule(sc.Rule)
+		if err == nil {
+			rule, err = rule.WithThresholds(cfg.BirthCount, cfg.SurviveMin, cfg.SurviveMax)
+		}
+		if err != nil {
+			return p, fmt.Errorf("species %q: %w", sc.Name, err)
+		}
+		p.Species = append(p.Species, engine.Species{
+			Name:         sc.Name,
+			ReactionTime: sc.ReactionTime,
+			Rule:         rule,
+			Refractory:   cmp.Or(sc.Refractory, cfg.Refractory),
+			Team:         teams[sc.Name],
+		})
+	}
+	return p, nil
+}
+
+// cpuThrottles are the throttles of -cpu-limit by limit, so that all the
+// grids of the process, layers and panes included, share one budget.
+var cpuThrottles struct {
+	sync.Mutex
+	m map[int]*engine.Throttle
+}
+
+// cpuThrottle returns the throttle of limit updates a second, or nil for
+// 0.
+func cpuThrottle(limit int) *engine.Throttle {
+	if limit == 0 {
+		return nil
+	}
+	cpuThrottles.Lock()
+	defer cpuThrottles.Unlock()
+	if cpuThrottles.m[limit] == nil {
+		if cpuThrottles.m == nil {
+			cpuThrottles.m = make(map[int]*engine.Throttle)
+		}
+		cpuThrottles.m[limit] = engine.NewThrottle(float64(limit))
+	}
+	return cpuThrottles.m[limit]
+}
+
+// teams returns the team of each species of scs on a team, numbered from 1
+// in the order of cfg.Teams.
+func (cfg *Config) teams(scs []SpeciesConfig) (map[string]int, error) {
+	teams := map[string]int{}
+	for i, t := range cfg.Teams {
+		for name := range strings.SplitSeq(t, "+") {
+			name = strings.TrimSpace(name)
+			switch {
+			case !slices.ContainsFunc(scs, func(sc SpeciesConfig) bool { return sc.Name == name }):
+				return nil, fmt.Errorf("team %q: unknown species %q", t, name)
+			case teams[name] != 0:
+				return nil, fmt.Errorf("team %q: species %q is already on a team", t, name)
+			}
+			teams[name] = i + 1
+		}
+	}
+	return teams, nil
+}
+
+// switching returns cfg.Switching as a matrix indexed from species 1 in the
+// order of scs, nil if it is empty.
+func (cfg *Config) switching(scs []SpeciesConfig) ([][]float64, error) {
+	if len(cfg.Switching) == 0 {
+		return nil, nil
+	}
+	index := func(name string) int {
+		return slices.IndexFunc(scs, func(sc SpeciesConfig) bool { return sc.Name == name })
+	}
+	m := make([][]float64, len(scs))
+	for from, row := range cfg.Switching {
+		i := index(from)
+		if i < 0 {
+			return nil, fmt.Errorf("switching: unknown species %q", from)
+		}
+		m[i] = make([]float64, len(scs))
+		for to, p := range row {
+			j := index(to)
+			if j < 0 {
+				return nil, fmt.Errorf("switching: species %q: unknown species %q", from, to)
+			}
+			m[i][j] = p
+		}
+	}
+	return m, nil
+}
+
+// wallColor returns the display color of walls.
+func (cfg *Config) wallColor() (tcell.Color, error) {
+	c := tcell.GetColor(cfg.Wall.Color)
+	if c == tcell.ColorDefault {
+		return c, fmt.Errorf("walls: unknown color %q", cfg.Wall.Color)
+	}
+	return c, nil
+}
+
+// palette returns the display color of each species id, with the dead-cell
+// color at index 0.
+func (cfg *Config) palette() ([]tcell.Color, error) {
+	if cfg.AgeFade <= 0 {
+		return nil, fmt.Errorf("age_fade must be positive")
+	}
+	if cfg.TrailLength <= 0 {
+		return nil, fmt.Errorf("trail_length must be positive")
+	}
+	if cfg.Rewind < 0 {
+		return nil, fmt.Errorf("rewind must not be negative")
+	}
+	if cfg.StillAfter <= 0 {
+		return nil, fmt.Errorf("still_after must be positive")
+	}
+	colors := []tcell.Color{tcell.GetColor(cfg.Dead.Color)}
+	if colors[0] == tcell.ColorDefault {
+		return nil, fmt.Errorf("dead cells: unknown color %q", cfg.Dead.Color)
+	}
+	scs, err := cfg.speciesConfigs()
+	if err != nil {
+		return nil, err
+	}
+	for _, sc := range scs {
+		c := tcell.GetColor(sc.Color)
+		if c == tcell.ColorDefault {
+			return nil, fmt.Errorf("species %q: unknown color %q", sc.Name, sc.Color)
+		}
+		colors = append(colors, c)
+	}
+	return colors, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+)
+
+// contactTint is the color the contact overlay draws cells on a front
+// toward, and contactCalm how far it darkens the others.
+var contactTint = tcell.NewRGBColor(255, 224, 96)
+
+const contactCalm = 0.35
+
+// measureContact fills d.mixing with how mixed the neighbourhood of every
+// cell of the rows by cols window of e from (top, left) is, row by row: 0
+// where its live cells, the cell's own included, are fewer than two or all
+// of one species, up to 1 where the commonest species holds no more of
+// them than the next. It returns how many cells are on a front, above 0.
+func (d *display) measureContact(e *engine.Engine, top, left, rows, cols int) int {
+	// the species of the window and a margin of one cell around it, -1
+	// for dead cells, walls and beyond the grid
+	w := cols + 2
+	d.kinds = d.kinds[:0]
+	for i := top - 1; i <= top+rows; i++ {
+		for j := left - 1; j <= left+cols; j++ {
+			k := -1
+			if i >= 0 && i < e.Rows() && j >= 0 && j < e.Cols() {
+				if cell := e.Cell(i, j); cell.Alive() && !cell.Wall {
+					k = cell.Species
+				}
+			}
+			d.kinds = append(d.kinds, k)
+		}
+	}
+	d.mixing = d.mixing[:0]
+	fronts := 0
+	var seen [9]int
+	for i := range rows {
+		for j := range cols {
+			live := 0
+			for di := range 3 {
+				for dj := range 3 {
+					if k := d.kinds[(i+di)*w+j+dj]; k >= 0 {
+						seen[live] = k
+						live++
+					}
+				}
+			}
+			most, f := 0, 0.0
+			for a := range live {
+				n := 0
+				for b := range live {
+					if seen[b] == seen[a] {
+						n++
+					}
+				}
+				most = max(most, n)
+			}
+			if live >= 2 && most < live {
+				f = min(2*(1-float64(most)/float64(live)), 1)
+				fronts++
+			}
+			d.mixing = append(d.mixing, f)
+		}
+	}
+	return fronts
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"app/pattern"
+)
+
+// runConvert implements the "convert" subcommand: it writes grids read
+// from autosaves, state files, text written by dump or pattern files as
+// pattern or state files, without running a simulation, for sharing a state
+// a run reached, moving patterns between editors and keeping a library of
+// them in one format.
+func runConvert(args []string) {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	layer := fs.Int("layer", 0, "layer of autosaves, state files and dumps to write as patterns, 0 for the bottom one")
+	format := fs.String("format", "", fmt.Sprintf("format to write, json or a pattern format, one of %v; default by the extension of OUT, rle for - and directories", formatNames()))
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s convert [flags] IN... OUT\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "IN is a pattern file, an autosave (.gob), a state file (.json), a map file (.nnmap) or text written by dump (.txt).")
+		fmt.Fprintln(fs.Output(), "OUT is a file, - for stdout, or a directory to write every IN to under its name.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	ins, out := fs.Args()[:fs.NArg()-1], fs.Arg(fs.NArg()-1)
+
+	dir := false
+	if info, err := os.Stat(out); err == nil && info.IsDir() {
+		dir = true
+	} else if len(ins) > 1 {
+		log.Fatalf("%s: converting %d files needs a directory", out, len(ins))
+	}
+	for _, in := range ins {
+		to := out
+		if dir {
+			name := strings.TrimSuffix(filepath.Base(in), filepath.Ext(in))
+			ext, err := extensionOf(*format)
+			if err != nil {
+				log.Fatal(err)
+			}
+			to = filepath.Join(out, name+ext)
+		}
+		if err := convert(in, to, *format, *layer); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// extensionOf returns the extension of files in format, rle if it is "".
+func extensionOf(format string) (string, error) {
+	switch format {
+	case "json":
+		return ".json", nil
+	case "":
+		format = "rle"
+	}
+	f, err := pattern.FormatNamed(format)
+	if err != nil {
+		return "", err
+	}
+	return f.Extensions[0], nil
+}
+
+// convert writes the grid of in to out, - for stdout, in format, or by the
+// extension of out if format is "".
+func convert(in, out, format string, layer int) error {
+	if format == "json" || format == "" && out != "-" && isState(out) {
+		var s savedState
+		var err error
+		if isState(in) {
+			s, err = readSaved(in)
+		} else {
+			var g [][]int
+			g, err = loadGrid(in, layer)
+			s = gridState(g)
+		}
+		if err != nil {
+			return err
+		}
+		if out == "-" {
+			return writeState(os.Stdout, s)
+		}
+		if format == "json" && !strings.EqualFold(filepath.Ext(out), ".json") {
+			return fmt.Errorf("%s: state files end in .json", out)
+		}
+		return saveState(out, s)
+	}
+
+	g, err := loadGrid(in, layer)
+	if err != nil {
+		return err
+	}
+	p := &pattern.Pattern{Height: len(g)}
+	for _, row := range g {
+		p.Width = max(p.Width, len(row))
+	}
+	for _, row := range g {
+		p.Cells = append(p.Cells, append(row, make([]int, p.Width-len(row))...))
+	}
+	if p.Width == 0 || p.Height == 0 {
+		return fmt.Errorf("%s: empty grid", in)
+	}
+
+	var f pattern.Format
+	switch {
+	case format != "":
+		f, err = pattern.FormatNamed(format)
+	case out == "-":
+		f, err = pattern.FormatNamed("rle")
+	default:
+		f, err = pattern.FormatOf(out)
+	}
+	if err != nil {
+		return err
+	}
+	if f.Name != "rle" {
+		single(p)
+	}
+	if out == "-" {
+		return f.Write(os.Stdout, p)
+	}
+	w, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := f.Write(w, p); err != nil {
+		w.Close()
+		return fmt.Errorf("%s -> %s: %w", in, out, err)
+	}
+	return w.Close()
+}
+
+// single renumbers the cells of p as species 1 if they are all of a single
+// species, with no walls, as the formats other than RLE hold them.
+func single(p *pattern.Pattern) {
+	species := 0
+	for _, row := range p.Cells {
+		for _, v := range row {
+			switch {
+			case v == 0:
+			case v == pattern.Wall || species != 0 && v != species:
+				return
+			default:
+				species = v
+			}
+		}
+	}
+	for _, row := range p.Cells {
+		for j, v := range row {
+			if v != 0 {
+				row[j] = 1
+			}
+		}
+	}
+}
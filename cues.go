@@ -0,0 +1,143 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"app/engine"
+	"app/osc"
+)
+
+// CueConfig describes an event of the simulation sent as an OSC message
+// and a MIDI note, for live performance.
+type CueConfig struct {
+	// On is extinction, for a species dying out; threshold, for a
+	// species' population crossing Threshold either way; or cell, for the
+	// cell at X, Y coming alive.
+	On        string `toml:"on" yaml:"on"`
+	Species   string `toml:"species" yaml:"species"` // by name; empty for any
+	Threshold int    `toml:"threshold" yaml:"threshold"`
+	X         int    `toml:"x" yaml:"x"`
+	Y         int    `toml:"y" yaml:"y"`
+	// Address is the OSC address, by default /nnca/ followed by On.
+	Address string `toml:"address" yaml:"address"`
+	// Note, Channel (1 to 16) and Velocity make up the MIDI note, by
+	// default middle C on channel 1 at velocity 100.
+	Note     int `toml:"note" yaml:"note"`
+	Channel  int `toml:"channel" yaml:"channel"`
+	Velocity int `toml:"velocity" yaml:"velocity"`
+}
+
+// noteLength is how long a cue's MIDI note is held.
+const noteLength = 100 * time.Millisecond
+
+// cue is a fired CueConfig.
+type cue struct {
+	*CueConfig
+	args []any // of the OSC message
+}
+
+// startCues sends the cues of cfg.Cues, or an extinction cue for every
+// species if there are none, to cfg.OSC and cfg.MIDI as they happen on e.
+// Sending happens on its own goroutine; cues firing faster than they can be
+// sent are dropped, as are those that fail to send.
+func startCues(cfg *Config, e *engine.Engine) error {
+	cues := cfg.Cues
+	if len(cues) == 0 {
+		cues = []CueConfig{{On: "extinction"}}
+	}
+	species := e.Species()
+	ids := make([]int, len(cues)) // 0 for any species
+	for i := range cues {
+		c := &cues[i]
+		if c.Species != "" {
+			ids[i] = slices.IndexFunc(species, func(sp engine.Species) bool { return sp.Name == c.Species })
+			if ids[i] < 1 {
+				return fmt.Errorf("cue %d: unknown species %q", i+1, c.Species)
+			}
+		}
+		switch c.On {
+		case "extinction", "cell":
+		case "threshold":
+			if ids[i] == 0 {
+				return fmt.Errorf("cue %d: threshold needs a species", i+1)
+			}
+		default:
+			return fmt.Errorf("cue %d: on must be extinction, threshold or cell, not %q", i+1, c.On)
+		}
+		if c.Channel < 0 || c.Channel > 16 || c.Note < 0 || c.Note > 127 || c.Velocity < 0 || c.Velocity > 127 {
+			return fmt.Errorf("cue %d: bad MIDI note", i+1)
+		}
+	}
+
+	var client *osc.Client
+	if cfg.OSC != "" {
+		var err error
+		if client, err = osc.Dial(cfg.OSC); err != nil {
+			return err
+		}
+	}
+	var midi *os.File
+	if cfg.MIDI != "" {
+		var err error
+		if midi, err = os.OpenFile(cfg.MIDI, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+			return err
+		}
+	}
+
+	fired := make(chan cue, 256)
+	fire := func(c *CueConfig, args ...any) {
+		select {
+		case fired <- cue{c, args}:
+		default:
+		}
+	}
+	for i := range cues {
+		c, id := &cues[i], ids[i]
+		switch c.On {
+		case "extinction":
+			e.OnExtinction(func(sp int) {
+				if id == 0 || sp == id {
+					fire(c, e.Species()[sp].Name) // sp may be a hybrid bred since
+				}
+			})
+		case "threshold":
+			above := -1 // unknown until the first tick
+			e.OnTick(func(s engine.Stats) {
+				now := 0
+				if s.Population[id] > c.Threshold {
+					now = 1
+				}
+				if above >= 0 && now != above {
+					fire(c, species[id].Name, s.Population[id])
+				}
+				above = now
+			})
+		case "cell":
+			e.OnCellChanged(func(x, y int, old, new engine.State) {
+				if x == c.X && y == c.Y && !old.Alive() && new.Alive() && (id == 0 || new.Species == id) {
+					fire(c, e.Species()[new.Species].Name, x, y)
+				}
+			})
+		}
+	}
+
+	go func() {
+		for c := range fired {
+			if client != nil {
+				addr := cmp.Or(c.Address, "/nnca/"+c.On)
+				client.Send(addr, c.args...)
+			}
+			if midi != nil {
+				status := byte(cmp.Or(c.Channel, 1) - 1)
+				note, vel := byte(cmp.Or(c.Note, 60)), byte(cmp.Or(c.Velocity, 100))
+				midi.Write([]byte{0x90 | status, note, vel})
+				time.AfterFunc(noteLength, func() { midi.Write([]byte{0x80 | status, note, 0}) })
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"flag"
+	"log"
+	"log/slog"
+	"math/rand"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"app/engine"
+)
+
+// socketPath is where the daemon listens for viewers unless told otherwise.
+var socketPath = filepath.Join(os.TempDir(), "nnca.sock")
+
+// A viewer attached to the daemon receives a remoteHello, then a
+// remoteFrame of the layer it shows every frame interval, and sends
+// remoteCommands. Both directions are gob streams over the socket.
+type remoteHello struct {
+	Rows, Cols int
+	Layers     []string   // layer names, bottom first
+	Species    [][]string // species names of each layer, dead first
+}
+
+type remoteFrame struct {
+	Layer      int
+	Tick       int
+	Paused     bool
+	Colors     []int32 // RGB of each cell, row by row, -1 for the default color
+	Population []int   // by species id
+	Viewers    int
+}
+
+type remoteCommand struct {
+	Op                string // "show", "pause", "paint" or "stop"
+	Layer             int    // for show and paint
+	Row, Col, Species int    // for paint; species 0 kills the cell
+}
+
+// daemon serves the layers of a running simulation to viewers.
+type daemon struct {
+	layers   []*layer
+	interval time.Duration
+	viewers  atomic.Int32
+	ctx      context.Context    // done once the daemon stops
+	shutdown context.CancelFunc // stops the daemon
+}
+
+// runDaemon implements the "daemon" subcommand: it runs the configured
+// simulation headless, without a terminal, and lets any number of viewers
+// attach to it with "attach" over a Unix socket. The simulation goes on when
+// they detach or their terminal goes away, until a viewer stops it or the
+// daemon gets SIGINT or SIGTERM.
+func runDaemon(args []string) {
+	cfg := defaultConfig()
+	if path := configFlag(args); path != "" {
+		if err := cfg.load(path); err != nil {
+			log.Fatalf("loading config: %v", err)
+		}
+	}
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	fs.String("config", "", "load settings from a TOML or YAML file; other flags override it")
+	cfg.bindFlags(fs)
+	socket := fs.String("socket", socketPath, "Unix socket to accept viewers on")
+	interval := fs.Duration("frame-interval", 100*time.Millisecond, "time between frames sent to viewers")
+	fs.Parse(args)
+	closeLog, err := startLogging(&cfg)
+	if err != nil {
+		log.Fatalf("opening log: %v", err)
+	}
+	defer closeLog()
+
+	rand.Seed(time.Now().UnixNano())
+	cfg.Video, cfg.Montage, cfg.Report = "", "", ""
+	layers, release, err := buildLayers(&cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer release()
+	if cfg.EventLog != "" {
+		if err := writeEventLog(cfg.EventLog, layers); err != nil {
+			log.Fatalf("opening event log: %v", err)
+		}
+	}
+	if cfg.Timeline != "" {
+		if err := startTimeline(cfg.Timeline, layers, func(msg string) { slog.Warn("timeline command failed", "err", msg) }); err != nil {
+			log.Fatalf("loading timeline: %v", err)
+		}
+	}
+	if conn, err := net.Dial("unix", *socket); err == nil {
+		conn.Close()
+		log.Fatalf("a daemon is already running on %s", *socket)
+	}
+	if err := removeSocket(*socket); err != nil { // left by a daemon that was killed
+		log.Fatal(err)
+	}
+	ln, err := net.Listen("unix", *socket)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer ln.Close()
+
+	signal.Ignore(syscall.SIGHUP)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	for _, l := range layers {
+		l.start()
+	}
+	defer stopLayers(layers)
+	if cfg.Autosave > 0 {
+		defer startAutosave(ctx, layers, cfg.Autosave, cfg.dumped())()
+	}
+	if cfg.HTTP != "" {
+		if err := startHTTP(cfg.HTTP, layers); err != nil {
+			log.Fatalf("serving HTTP: %v", err)
+		}
+	}
+	if cfg.Panel != "" {
+		pn, err := startPanel(&cfg, layers[0])
+		if err != nil {
+			log.Fatalf("opening panel: %v", err)
+		}
+		stop := make(chan struct{})
+		go pn.every(time.Second/time.Duration(cfg.PanelFPS), stop)
+		defer func() {
+			close(stop)
+			if err := pn.close(); err != nil {
+				slog.Warn("showing panel failed", "err", err)
+			}
+		}()
+	}
+	dm := &daemon{layers: layers, interval: *interval, ctx: ctx, shutdown: cancel}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go dm.session(conn)
+		}
+	}()
+	e := layers[0].e
+	slog.Info("daemon running", "rows", e.Rows(), "cols", e.Cols(), "mode", cfg.Mode, "socket", ln.Addr())
+	<-ctx.Done()
+	slog.Info("daemon stopped", "tick", e.Ticks())
+}
+
+// session serves one viewer until it detaches.
+func (dm *daemon) session(conn net.Conn) {
+	defer conn.Close()
+	dm.viewers.Add(1)
+	defer dm.viewers.Add(-1)
+	hello := remoteHello{Rows: dm.layers[0].e.Rows(), Cols: dm.layers[0].e.Cols()}
+	for _, l := range dm.layers {
+		var names []string
+		for _, sp := range l.e.Species() {
+			names = append(names, sp.Name)
+		}
+		hello.Layers = append(hello.Layers, l.name)
+		hello.Species = append(hello.Species, names)
+	}
+	enc := gob.NewEncoder(conn)
+	if err := enc.Encode(hello); err != nil {
+		return
+	}
+
+	var shown atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dec := gob.NewDecoder(conn)
+		for {
+			var c remoteCommand
+			if err := dec.Decode(&c); err != nil {
+				return
+			}
+			if c.Layer < 0 || c.Layer >= len(dm.layers) {
+				continue
+			}
+			l := dm.layers[c.Layer]
+			switch c.Op {
+			case "show":
+				shown.Store(int32(c.Layer))
+			case "pause":
+				dm.togglePause()
+			case "paint":
+				if c.Row >= 0 && c.Row < l.e.Rows() && c.Col >= 0 && c.Col < l.e.Cols() &&
+					c.Species >= 0 && c.Species < len(l.e.Species()) {
+					l.e.SetCell(c.Row, c.Col, engine.State{Species: c.Species})
+				}
+			case "stop":
+				dm.shutdown()
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(dm.interval)
+	defer ticker.Stop()
+	for {
+		if err := enc.Encode(dm.frame(int(shown.Load()))); err != nil {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		case <-dm.ctx.Done():
+			return
+		}
+	}
+}
+
+// togglePause pauses every layer, or resumes them if the bottom one is
+// paused.
+func (dm *daemon) togglePause() {
+	paused := dm.layers[0].e.Paused()
+	for _, l := range dm.layers {
+		if paused {
+			l.e.Resume()
+		} else {
+			l.e.Pause()
+		}
+	}
+}
+
+// frame returns the current frame of layer i.
+func (dm *daemon) frame(i int) remoteFrame {
+	l := dm.layers[i]
+	snap := l.e.Snapshot()
+	f := remoteFrame{
+		Layer:      i,
+		Tick:       l.e.Ticks(),
+		Paused:     l.e.Paused(),
+		Colors:     make([]int32, 0, l.e.Rows()*l.e.Cols()),
+		Population: make([]int, len(l.e.Species())),
+		Viewers:    int(dm.viewers.Load()),
+	}
+	for _, row := range snap.Cells {
+		for _, s := range row {
+			f.Colors = append(f.Colors, l.color(s).Hex())
+			if !s.Wall && s.Species < len(f.Population) {
+				f.Population[s.Species]++
+			}
+		}
+	}
+	return f
+}
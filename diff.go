@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"app/pattern"
+)
+
+// runDiff implements the "diff" subcommand: it compares two saved grids,
+// such as two members of an ensemble or an asynchronous run and its
+// synchronous twin, and reports how many cells differ, the population of
+// every species in each and a similarity score, optionally with a map of
+// the cells that differ. It exits with status 1 if they differ, like
+// diff(1).
+func runDiff(args []string) {
+	cfg := defaultConfig()
+	if path := configFlag(args); path != "" {
+		if err := cfg.load(path); err != nil {
+			log.Fatalf("loading config: %v", err)
+		}
+	}
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.String("config", "", "load settings from a TOML or YAML file, for the names of the species")
+	layer := fs.Int("layer", 0, "layer of autosaves and dumps to compare, 0 for the bottom one")
+	showMap := fs.Bool("map", false, "print the grid with the cells of the second file where they differ from the first, spaces elsewhere")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s diff [flags] A B\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "A and B are pattern files, autosaves (.gob), state files (.json), map files (.nnmap) or text written by dump (.txt).")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var grids [2][][]int
+	for i, path := range fs.Args() {
+		g, err := loadGrid(path, *layer)
+		if err != nil {
+			log.Fatal(err)
+		}
+		grids[i] = g
+	}
+	scs, err := cfg.speciesConfigs()
+	if err != nil {
+		log.Fatalf("configuring species: %v", err)
+	}
+	names := []string{"dead"}
+	for _, sc := range scs {
+		names = append(names, sc.Name)
+	}
+	d := compareGrids(grids[0], grids[1])
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	fmt.Fprintf(w, "%dx%d cells, %d differ (%.1f%%), similarity %.3f\n",
+		d.rows, d.cols, d.differ, 100*float64(d.differ)/float64(d.rows*d.cols), d.similarity())
+	fmt.Fprintf(w, "%-12s %8s %8s %8s\n", "", "a", "b", "delta")
+	for k := range d.pop[0] {
+		name := fmt.Sprintf("species %d", k)
+		switch {
+		case k == len(d.pop[0])-1:
+			name = "wall"
+		case k < len(names):
+			name = names[k]
+		}
+		a, b := d.pop[0][k], d.pop[1][k]
+		if a == 0 && b == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%-12s %8d %8d %+8d\n", name, a, b, b-a)
+	}
+	if *showMap {
+		fmt.Fprintln(w)
+		for i := range d.rows {
+			for j := range d.cols {
+				a, b := cellAt(grids[0], i, j), cellAt(grids[1], i, j)
+				switch {
+				case a == b:
+					w.WriteByte(' ')
+				case b == pattern.Wall:
+					w.WriteByte('#')
+				case b == 0:
+					w.WriteByte('.')
+				case b <= 24:
+					w.WriteByte(byte('A' + b - 1))
+				default:
+					w.WriteByte('?')
+				}
+			}
+			w.WriteByte('\n')
+		}
+	}
+	if d.differ > 0 {
+		w.Flush()
+		os.Exit(1)
+	}
+}
+
+// gridDiff is how two grids differ.
+type gridDiff struct {
+	rows, cols int
+	differ     int
+	// pop counts the cells of each grid by species id, walls last.
+	pop [2][]int
+}
+
+// similarity returns the fraction of cells that are the same in both
+// grids.
+func (d gridDiff) similarity() float64 {
+	return 1 - float64(d.differ)/float64(d.rows*d.cols)
+}
+
+// compareGrids compares grids a and b of species ids, padding the smaller
+// one with dead cells.
+func compareGrids(a, b [][]int) gridDiff {
+	d := gridDiff{rows: max(len(a), len(b))}
+	species := 1
+	for _, g := range [][][]int{a, b} {
+		for _, row := range g {
+			d.cols = max(d.cols, len(row))
+			for _, v := range row {
+				species = max(species, v+1)
+			}
+		}
+	}
+	for k := range d.pop {
+		d.pop[k] = make([]int, species+1)
+	}
+	for i := range d.rows {
+		for j := range d.cols {
+			va, vb := cellAt(a, i, j), cellAt(b, i, j)
+			if va != vb {
+				d.differ++
+			}
+			for k, v := range [2]int{va, vb} {
+				if v == pattern.Wall {
+					v = species
+				}
+				d.pop[k][v]++
+			}
+		}
+	}
+	return d
+}
+
+// cellAt returns the species id at row i, column j of g, dead beyond its
+// edges.
+func cellAt(g [][]int, i, j int) int {
+	if i < len(g) && j < len(g[i]) {
+		return g[i][j]
+	}
+	return 0
+}
+
+// loadGrid reads the grid of the file at path as species ids by row, walls
+// as pattern.Wall: an autosave or state file, of which it takes layer, text
+// written by dump, of which it takes layer too, or a pattern file.
+func loadGrid(path string, layer int) ([][]int, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gob", ".json", ".nnmap":
+		s, err := readSaved(path)
+		if err != nil {
+			return nil, err
+		}
+		if layer < 0 || layer >= len(s.Layers) {
+			return nil, fmt.Errorf("%s: no layer %d", path, layer)
+		}
+		var g [][]int
+		for _, row := range s.Layers[layer].Cells {
+			ids := make([]int, len(row))
+			for j, st := range row {
+				if st.Wall {
+					ids[j] = pattern.Wall
+				} else {
+					ids[j] = st.Species
+				}
+			}
+			g = append(g, ids)
+		}
+		return g, nil
+	case ".txt":
+		return loadText(path, layer)
+	}
+	p, err := pattern.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.Cells, nil
+}
+
+// loadText reads layer of the text written by dump at path; see
+// engine.Engine.Text. With a single live species its cells are taken as
+// species 1.
+func loadText(path string, layer int) ([][]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var layers [][][]int
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, pattern.MaxCells)
+	started := false // a layer heading was read, or a row without one
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "# "):
+			layers, started = append(layers, nil), true
+			continue
+		case line == "":
+			continue
+		case !started:
+			layers, started = append(layers, nil), true
+		}
+		row := make([]int, len(line))
+		for j, r := range line {
+			switch {
+			case r == '.':
+			case r == '#':
+				row[j] = pattern.Wall
+			case r == 'o':
+				row[j] = 1
+			case r >= 'A' && r <= 'X':
+				row[j] = int(r-'A') + 1
+			default:
+				return nil, fmt.Errorf("%s:%d: unexpected %q", path, n, r)
+			}
+		}
+		layers[len(layers)-1] = append(layers[len(layers)-1], row)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if layer < 0 || layer >= len(layers) {
+		return nil, fmt.Errorf("%s: no layer %d", path, layer)
+	}
+	return layers[layer], nil
+}
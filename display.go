@@ -0,0 +1,479 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+)
+
+type display struct {
+	screen tcell.Screen
+	invert bool
+	status []func() string // joined and drawn on the line below the grid
+	// left is the first screen column of the display and width the number
+	// of columns it may use, 0 for the rest of the screen; there is one
+	// display per pane.
+	left, width int
+
+	// layers are drawn one at a time; current is the index of the one
+	// shown. A layer's intensity, if set, selects the continuous renderer:
+	// live and dead cells alike are colored by gradient(intensity(state)).
+	layers  []*layer
+	current atomic.Int32
+	// projection draws every layer at once instead: each cell shows the
+	// topmost live cell of its column, darker the deeper it lies.
+	projection atomic.Bool
+
+	// ageShading darkens live cells as they age, reaching the darkest
+	// shade after ageFade updates.
+	ageShading atomic.Bool
+	ageFade    int
+
+	// heatmap colors every cell by how often it changed recently instead.
+	heatmap atomic.Bool
+	heat    []int // scratch space for draw
+
+	// contact picks out the fronts where species meet, brightening cells
+	// by how mixed their neighbourhood is and darkening the rest; fronts
+	// is the count of cells on one in the last frame drawn with it on.
+	contact atomic.Bool
+	kinds   []int     // scratch space for measureContact
+	mixing  []float64 // of measureContact
+	fronts  atomic.Int32
+
+	// owners colors every cell by the worker updating it, for the tiled
+	// and synchronous updates; workerRates is how evenly they share the
+	// work, see measureWorkers.
+	owners      atomic.Bool
+	workerRates atomic.Pointer[string]
+
+	// trails draws recently dead cells in their species' color, fading to
+	// the dead color over trailLength frames.
+	trails      atomic.Bool
+	trailLength int
+
+	// structures marks cells of still lifes and oscillators; see classify.
+	structures atomic.Bool
+	stillAfter int
+	hist       []int // scratch space for draw
+	// stills and oscillators are the counts of the last frame drawn with
+	// structures on.
+	stills, oscillators atomic.Int32
+
+	// sparklines draws the recent population of each species below the
+	// status line.
+	sparklines atomic.Bool
+
+	// overlay shows rates, the frame and update rates, in the top right
+	// corner of the grid; see measureRates.
+	overlay   atomic.Bool
+	frames    atomic.Int64
+	rates     atomic.Pointer[string]
+	throttled atomic.Bool // frames are spaced out for a slow terminal; see pacer
+	// late warns that too many updates came late over the past second,
+	// empty when few did; see measureRates.
+	late atomic.Pointer[string]
+
+	// events shows the latest events of the shown layer; see drawEvents.
+	events atomic.Bool
+	banner banner // see notify
+
+	// summary, once set, is drawn below the status line; see startSummary.
+	summary atomic.Pointer[string]
+
+	exportAs   string // the pattern format export writes
+	noExport   bool   // see Config.remote
+	patternDir string // where export writes; see Config.PatternDir
+	themeName  string // Config.Theme, remembered in the session
+
+	// theme is how cells are drawn; looks caches the look of the species
+	// of each layer by id, touched only by draw.
+	theme *themeLooks
+	looks map[*layer][]cellLook
+
+	// drawn is what draw last wrote at each cell of the grid, row by row,
+	// so that only cells whose look changed are written again. covered
+	// makes the next frame write them all; see repaint.
+	drawn   []look
+	drawnAt [2]int // the origin of the viewport drawn last
+	covered atomic.Bool
+	// wake, shared by the panes, draws a frame before the next tick.
+	wake wake
+
+	// ghosts are the trails of each layer, touched only by draw, so that
+	// displays sharing layers keep trails of their own.
+	ghosts map[*layer][][]ghost
+
+	// recolor, if set, replaces the colors of the species by id, and dim
+	// is the float64 bits of how far every cell is faded to black, 0 to 1;
+	// see attract.
+	recolor atomic.Pointer[[]tcell.Color]
+	dim     atomic.Uint64
+
+	// originRow and originCol are the grid cell at the top left corner of
+	// the viewport, for grids larger than the screen, which camera moves;
+	// see window.
+	originRow, originCol atomic.Int32
+	camera               camera
+
+	done chan struct{} // closed by close
+}
+
+// A ghost remembers what a cell last looked like alive.
+type ghost struct {
+	species int
+	frames  int // since the cell was last seen alive; 0 while it is
+}
+
+// close ends the goroutines refreshing d, once it is no longer drawn.
+func (d *display) close() { close(d.done) }
+
+// every calls f every interval until d is closed.
+func (d *display) every(interval time.Duration, f func(now time.Time)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			f(now)
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// A look is what a grid cell is drawn as, on both its screen columns.
+type look struct {
+	glyphs [2]rune
+	style  tcell.Style
+}
+
+// repaint makes the next frame write every cell of the grid again, as
+// something other than the grid was drawn over it.
+func (d *display) repaint() { d.covered.Store(true) }
+
+// layer returns the layer being shown.
+func (d *display) layer() *layer {
+	return d.layers[d.current.Load()]
+}
+
+// moveLayer switches to the layer delta places above the current one,
+// wrapping around at the top and bottom.
+func (d *display) moveLayer(delta int) {
+	n := int32(len(d.layers))
+	d.current.Store(((d.current.Load()+int32(delta))%n + n) % n)
+}
+
+// project returns the topmost live cell or wall at (x, y) across all
+// layers, the layer it belongs to and how much to shade it for its depth.
+// If the whole column is empty it returns a dead cell of the bottom layer.
+func (d *display) project(x, y int) (*layer, engine.State, float64) {
+	for k := len(d.layers) - 1; k >= 0; k-- {
+		if cell := d.layers[k].e.Cell(x, y); cell.Alive() || cell.Wall {
+			depth := float64(len(d.layers)-1-k) / float64(max(len(d.layers)-1, 1))
+			return d.layers[k], cell, 1 - 0.75*depth
+		}
+	}
+	return d.layers[0], engine.State{}, 1
+}
+
+func (d *display) draw() {
+	l := d.layer()
+	e := l.e
+	ageShading := d.ageShading.Load()
+	projection := d.projection.Load()
+	trails := d.trails.Load() && !projection
+	heatmap := d.heatmap.Load() && !projection
+	structures := d.structures.Load() && !projection
+	contact := d.contact.Load() && !projection
+	owners := d.owners.Load() && !projection
+	stills, oscillators := 0, 0
+	maxHeat := 0
+	if heatmap {
+		d.heat = d.heat[:0]
+		for i := 0; i < e.Rows(); i++ {
+			for j := 0; j < e.Cols(); j++ {
+				n := l.activity.changes(i, j)
+				d.heat = append(d.heat, n)
+				maxHeat = max(maxHeat, n)
+			}
+		}
+	}
+	full := d.covered.Swap(false)
+	if len(d.drawn) != e.Rows()*e.Cols() {
+		d.drawn, full = make([]look, e.Rows()*e.Cols()), true
+	}
+	top, left, rows, cols := d.window(e.Rows(), e.Cols())
+	if origin := [2]int{top, left}; origin != d.drawnAt {
+		d.drawnAt, full = origin, true
+	}
+	if contact {
+		d.fronts.Store(int32(d.measureContact(e, top, left, rows, cols)))
+	}
+	dim := math.Float64frombits(d.dim.Load())
+	ghosts := d.ghosts[l]
+	if trails && len(ghosts) != e.Rows() {
+		ghosts = make([][]ghost, e.Rows())
+		for i := range ghosts {
+			ghosts[i] = make([]ghost, e.Cols())
+		}
+		d.ghosts[l] = ghosts
+	}
+	for i := top; i < top+rows; i++ {
+		for j := left; j < left+cols; j++ {
+			cl, cell, fade := l, engine.State{}, 1.0
+			if projection {
+				cl, cell, fade = d.project(i, j)
+			} else {
+				cell = e.Cell(i, j)
+			}
+
+			lk := d.theme.dead
+			if cell.Wall {
+				lk = d.theme.wall
+			} else if cell.Alive() {
+				lk = d.speciesLook(cl, cell.Species)
+			}
+			var fg, bg tcell.Color
+			if heatmap && !cell.Wall {
+				// log scale, so that slow oscillators stand out from
+				// frozen regions next to chaotic ones
+				f := 0.0
+				if maxHeat > 0 {
+					f = math.Log1p(float64(d.heat[i*e.Cols()+j])) / math.Log1p(float64(maxHeat))
+				}
+				fg, _ = lk.colors(tcell.ColorBlack, 0)
+				bg = gradient(f)
+			} else if cell.Wall {
+				fg, bg = lk.colors(tcell.ColorBlack, cl.wall)
+				if fade < 1 {
+					bg = shade(bg, fade)
+				}
+			} else if cl.intensity != nil {
+				fg, _ = lk.colors(tcell.ColorBlack, 0)
+				bg = gradient(cl.intensity(cell))
+			} else if cell.Alive() {
+				fg, bg = lk.colors(tcell.ColorBlack, d.speciesColor(cl, cell.Species))
+				if fade < 1 {
+					bg = shade(bg, fade)
+				}
+				if ageShading {
+					bg = shade(bg, 1-0.75*float64(min(cell.Age, d.ageFade))/float64(d.ageFade))
+				}
+			} else {
+				fg, bg = lk.colors(tcell.ColorGreen, cl.palette[engine.Dead])
+				if r, ok := cl.e.Resource(i, j); ok && !projection {
+					bg = blend(bg, groundColor, r)
+				}
+			}
+			if trails {
+				g := &ghosts[i][j]
+				switch {
+				case cell.Alive():
+					g.species, g.frames = cell.Species, 0
+				case g.species != engine.Dead && g.frames < d.trailLength:
+					g.frames++
+					if !cell.Wall && cl.intensity == nil {
+						_, trail := d.speciesLook(cl, g.species).colors(0, d.speciesColor(cl, g.species))
+						bg = blend(trail, bg, 0.4+0.6*float64(g.frames)/float64(d.trailLength))
+					}
+				default:
+					g.species = engine.Dead
+				}
+			}
+
+			if cl.seams != nil && cl.seams[i*e.Cols()+j] {
+				bg = blend(bg, seamTint, seamShare)
+			}
+			if owners && !cell.Wall {
+				if k := e.Owner(i, j); k >= 0 {
+					bg = ownerColor(k)
+					if !cell.Alive() {
+						bg = shade(bg, 0.45)
+					}
+				}
+			}
+			if contact && !cell.Wall {
+				if f := d.mixing[(i-top)*cols+j-left]; f > 0 {
+					bg = blend(bg, contactTint, 0.3+0.5*f)
+				} else {
+					bg = shade(bg, contactCalm)
+				}
+			}
+			if dim > 0 {
+				fg, bg = blend(fg, tcell.ColorBlack, dim), blend(bg, tcell.ColorBlack, dim)
+			}
+			if d.invert {
+				fg, bg = bg, fg
+			}
+
+			if structures && !cell.Wall {
+				d.hist = e.History(i, j, d.hist[:0])
+				switch classify(cell, d.hist, d.stillAfter) {
+				case stillLife:
+					lk = d.theme.still
+					fg, _ = lk.colors(tcell.ColorWhite, 0)
+					stills++
+				case oscillator:
+					lk = d.theme.oscillator
+					fg, _ = lk.colors(tcell.ColorWhite, 0)
+					oscillators++
+				}
+			}
+
+			if d.theme.mono {
+				fg, bg = tcell.ColorDefault, tcell.ColorDefault
+			}
+			dl := look{lk.glyphs, lk.style(fg, bg)}
+			if k := i*e.Cols() + j; full || d.drawn[k] != dl {
+				d.drawn[k] = dl
+				x := d.left + (j-left)*2
+				d.screen.SetContent(x, i-top, dl.glyphs[0], nil, dl.style)
+				d.screen.SetContent(x+1, i-top, dl.glyphs[1], nil, dl.style)
+			}
+		}
+	}
+	d.stills.Store(int32(stills))
+	d.oscillators.Store(int32(oscillators))
+	for _, t := range e.Turmites() {
+		if t.X < top || t.X >= top+rows || t.Y < left || t.Y >= left+cols {
+			continue
+		}
+		lk := d.theme.turmite
+		style := lk.style(lk.colors(tcell.ColorWhite, d.speciesColor(l, e.Cell(t.X, t.Y).Species)))
+		if d.theme.mono {
+			style = lk.style(tcell.ColorDefault, tcell.ColorDefault)
+		}
+		d.screen.SetContent(d.left+(t.Y-left)*2, t.X-top, turmiteGlyphs[t.Dir], nil, style)
+		d.drawn[t.X*e.Cols()+t.Y] = look{} // redrawn once the turmite moves on
+	}
+	if s := d.rates.Load(); s != nil && d.overlay.Load() {
+		d.repaint()
+		x := d.left + max(cols*2-utf8.RuneCountInString(*s), 0)
+		for _, r := range *s {
+			d.screen.SetContent(x, 0, r, nil, tcell.StyleDefault.Reverse(true))
+			x++
+		}
+	}
+	if d.events.Load() {
+		d.drawEvents(l, rows, cols)
+		d.repaint()
+	}
+	d.drawBanner(rows, cols)
+	if len(d.status) > 0 {
+		var parts []string
+		for _, f := range d.status {
+			if s := f(); s != "" {
+				parts = append(parts, s)
+			}
+		}
+		d.drawText(0, rows, strings.Join(parts, "  "))
+	}
+	y := rows + 1
+	if s := d.summary.Load(); s != nil {
+		d.drawText(0, y, *s)
+		y++
+	}
+	d.drawSparklines(l, y, d.sparklines.Load())
+	d.frames.Add(1)
+}
+
+// speciesLook returns the look of species id of l in the theme.
+func (d *display) speciesLook(l *layer, id int) cellLook {
+	looks := d.looks[l]
+	if id >= len(looks) { // first drawn, or a hybrid bred since
+		var names []string
+		for _, sp := range l.e.Species() {
+			names = append(names, sp.Name)
+		}
+		looks = looks[:0]
+		for k := range max(len(names), id+1) {
+			looks = append(looks, d.theme.of(k, names))
+		}
+		d.looks[l] = looks
+	}
+	return looks[id]
+}
+
+// right returns the screen column just past the display.
+func (d *display) right() int {
+	if d.width > 0 {
+		return d.left + d.width
+	}
+	w, _ := d.screen.Size()
+	return w
+}
+
+// turmiteGlyphs are indexed by direction.
+var turmiteGlyphs = [4]rune{'▲', '►', '▼', '◄'}
+
+// drawText writes s at column x of the display and row y, clearing the
+// rest of its line and cutting s short at the display's edge.
+func (d *display) drawText(x, y int, s string) {
+	x += d.left
+	w := d.right()
+	for _, r := range s {
+		if x >= w {
+			break
+		}
+		d.screen.SetContent(x, y, r, nil, tcell.StyleDefault)
+		x++
+	}
+	for ; x < w; x++ {
+		d.screen.SetContent(x, y, ' ', nil, tcell.StyleDefault)
+	}
+}
+
+// gradientStops run from black through blue and cyan to yellow and white.
+var gradientStops = [][3]float64{
+	{0, 0, 0}, {20, 40, 160}, {0, 200, 220}, {250, 230, 60}, {255, 255, 255},
+}
+
+// gradient maps f in [0, 1] to a color along gradientStops.
+func gradient(f float64) tcell.Color {
+	f = min(max(f, 0), 1) * float64(len(gradientStops)-1)
+	i := min(int(f), len(gradientStops)-2)
+	t := f - float64(i)
+	a, b := gradientStops[i], gradientStops[i+1]
+	mix := func(k int) int32 { return int32(a[k] + (b[k]-a[k])*t) }
+	return tcell.NewRGBColor(mix(0), mix(1), mix(2))
+}
+
+// speciesColor returns the color of species id of l, unless recolor
+// replaces it.
+func (d *display) speciesColor(l *layer, id int) tcell.Color {
+	if p := d.recolor.Load(); p != nil && id > engine.Dead && id < len(*p) {
+		return (*p)[id]
+	}
+	return l.speciesColor(id)
+}
+
+// blend mixes a and b, returning a for f = 0 and b for f = 1.
+func blend(a, b tcell.Color, f float64) tcell.Color {
+	ar, ag, ab := a.RGB()
+	br, bg, bb := b.RGB()
+	if ar < 0 || br < 0 {
+		return b
+	}
+	mix := func(x, y int32) int32 { return x + int32(float64(y-x)*f) }
+	return tcell.NewRGBColor(mix(ar, br), mix(ag, bg), mix(ab, bb))
+}
+
+// groundColor is the shade of dead cells on a full resource field, which
+// fades to the dead-cell color as it is eaten.
+var groundColor = tcell.NewRGBColor(38, 64, 22)
+
+// shade scales c's brightness by f in [0, 1].
+func shade(c tcell.Color, f float64) tcell.Color {
+	r, g, b := c.RGB()
+	if r < 0 {
+		return c
+	}
+	return tcell.NewRGBColor(int32(float64(r)*f), int32(float64(g)*f), int32(float64(b)*f))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+	"app/pattern"
+)
+
+// editor paints cells of the shown layer with the mouse while edit mode is
+// on: the primary button paints with the brush, the secondary one erases.
+// Each stroke, from pressing a button to releasing it, can be undone and
+// redone. In select mode the primary button selects a rectangle instead,
+// to copy or cut and paste elsewhere; see clipboard.go.
+type editor struct {
+	on    atomic.Bool
+	brush atomic.Int32 // 0 for dead, a species id, or pattern.Wall
+
+	mu       sync.Mutex
+	stroke   []change // of the stroke under way, if a button is held
+	undos    []edit
+	redos    []edit
+	stroking bool
+
+	selecting bool       // select mode
+	sel       *selection // nil if nothing is selected
+	clip      *pattern.Pattern
+	pasting   bool // the next click pastes clip
+
+	hover atomic.Pointer[hover] // see drawInspector
+}
+
+// An edit is a stroke, a cut or a paste, undone and redone as a whole.
+type edit struct {
+	changes []change
+	what    string // for the event log, e.g. "painted 12 cells green"
+}
+
+// A change is one cell painted by an edit.
+type change struct {
+	l        *layer
+	x, y     int
+	old, new engine.State
+}
+
+// toggle turns edit mode on or off, capturing the mouse only while it is on
+// so that the terminal's own selection keeps working otherwise.
+func (ed *editor) toggle(screen tcell.Screen) {
+	if ed.on.Load() {
+		screen.DisableMouse()
+	} else {
+		screen.EnableMouse()
+	}
+	ed.on.Store(!ed.on.Load())
+}
+
+// nextBrush cycles the brush through dead, every species of l and wall.
+func (ed *editor) nextBrush(l *layer) {
+	switch b := int(ed.brush.Load()); {
+	case b == pattern.Wall:
+		ed.brush.Store(0)
+	case b+1 >= len(l.e.Species()):
+		ed.brush.Store(pattern.Wall)
+	default:
+		ed.brush.Store(int32(b + 1))
+	}
+}
+
+// brushState returns the state the brush paints on l.
+func (ed *editor) brushState(l *layer) engine.State {
+	b := int(ed.brush.Load())
+	if b == pattern.Wall {
+		return engine.State{Wall: true}
+	}
+	return engine.State{Species: min(b, len(l.e.Species())-1)}
+}
+
+func (ed *editor) status(l *layer) string {
+	if !ed.on.Load() {
+		return ""
+	}
+	name := stateName(l, ed.brushState(l))
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	mode := fmt.Sprintf("brush %s [b]", name)
+	switch {
+	case ed.pasting:
+		mode = fmt.Sprintf("click to paste %dx%d", ed.clip.Width, ed.clip.Height)
+	case ed.selecting:
+		mode = "select [m]  copy [c]  cut [C]"
+	}
+	if ed.clip != nil && !ed.pasting {
+		mode += "  paste [v]"
+	}
+	return fmt.Sprintf("edit: %s  undo %d / redo %d [u/U]", mode, len(ed.undos), len(ed.redos))
+}
+
+// paint applies a mouse event over d to its shown layer.
+func (ed *editor) paint(d *display, ev *tcell.EventMouse) {
+	if !ed.on.Load() {
+		return
+	}
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	l := d.layer()
+	row, col := d.cellAt(ev.Position())
+	ed.hover.Store(&hover{d, row, col})
+	if ed.selecting || ed.pasting {
+		ed.pointer(d, ev, row, col)
+		return
+	}
+	var s engine.State
+	switch {
+	case ev.Buttons()&tcell.ButtonPrimary != 0:
+		s = ed.brushState(l)
+	case ev.Buttons()&tcell.ButtonSecondary != 0:
+	default:
+		// released: the stroke is complete
+		if len(ed.stroke) > 0 {
+			ed.endStroke("painted %d cells " + stateName(l, ed.stroke[0].new))
+		}
+		ed.stroke, ed.stroking = nil, false
+		return
+	}
+	ed.stroking = true
+	if row >= l.e.Rows() || col >= l.e.Cols() {
+		return
+	}
+	old := l.e.Cell(row, col)
+	if old.Species == s.Species && old.Wall == s.Wall {
+		return
+	}
+	l.e.SetCell(row, col, s)
+	ed.stroke = append(ed.stroke, change{l, row, col, old, s})
+}
+
+// endStroke makes the stroke under way, if any, the latest edit to undo.
+// what describes it for the event log, given the number of cells changed.
+func (ed *editor) endStroke(what string) {
+	if len(ed.stroke) > 0 {
+		ed.push(edit{ed.stroke, fmt.Sprintf(what, len(ed.stroke))})
+	}
+	ed.stroke, ed.stroking = nil, false
+}
+
+// push records an edit just made as the latest one to undo.
+func (ed *editor) push(x edit) {
+	ed.undos = append(ed.undos, x)
+	ed.redos = nil
+	x.log("")
+}
+
+// undo reverts the latest edit not yet undone, putting back the cells it
+// painted as they were before it, whatever they became since.
+func (ed *editor) undo() {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	if len(ed.undos) == 0 || ed.stroking {
+		return
+	}
+	last := ed.undos[len(ed.undos)-1]
+	ed.undos = ed.undos[:len(ed.undos)-1]
+	for i := len(last.changes) - 1; i >= 0; i-- {
+		c := last.changes[i]
+		c.l.e.SetCell(c.x, c.y, c.old)
+	}
+	ed.redos = append(ed.redos, last)
+	last.log("undid ")
+}
+
+// redo paints again the latest edit undone.
+func (ed *editor) redo() {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	if len(ed.redos) == 0 || ed.stroking {
+		return
+	}
+	last := ed.redos[len(ed.redos)-1]
+	ed.redos = ed.redos[:len(ed.redos)-1]
+	for _, c := range last.changes {
+		c.l.e.SetCell(c.x, c.y, c.new)
+	}
+	ed.undos = append(ed.undos, last)
+	last.log("redid ")
+}
+
+// log records the edit in the event log of the layer it painted.
+func (x edit) log(prefix string) {
+	c := x.changes[0]
+	c.l.e.LogEvent(engine.Edit, c.new.Species, prefix+x.what)
+}
+
+// stateName names the species of s, or wall.
+func stateName(l *layer, s engine.State) string {
+	if s.Wall {
+		return "wall"
+	}
+	return l.e.Species()[s.Species].Name
+}
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Directions a Turmite can face, clockwise from up.
+const (
+	Up = iota
+	Right
+	Down
+	Left
+)
+
+// A Turmite is a mobile agent in the style of Langton's ant. Each step it
+// looks at the species s of the cell it stands on, turns according to
+// Rule[s] (L, R, N for no turn or U for a U-turn), sets the cell to species
+// (s+1) mod len(Rule), and moves one cell forward, wrapping at the edges;
+// if that cell is a wall it stays put and turns around instead.
+// Langton's ant is Rule "RL". Cells whose species is outside the rule count
+// as species len(Rule)-1.
+type Turmite struct {
+	X, Y int
+	Dir  int
+	Rule string
+}
+
+// ValidateTurmiteRule reports whether rule can drive turmites on an engine
+// with nspecies live species.
+func ValidateTurmiteRule(rule string, nspecies int) error {
+	if len(rule) < 2 {
+		return fmt.Errorf("turmite rule %q: need at least two turns", rule)
+	}
+	if len(rule) > nspecies+1 {
+		return fmt.Errorf("turmite rule %q: %d states but only %d species", rule, len(rule), nspecies)
+	}
+	if strings.Trim(strings.ToUpper(rule), "LRNU") != "" {
+		return fmt.Errorf("turmite rule %q: turns must be L, R, N or U", rule)
+	}
+	return nil
+}
+
+type agents struct {
+	mu       sync.Mutex
+	turmites []Turmite
+}
+
+// AddTurmite places t on the grid. Its rule must pass ValidateTurmiteRule.
+func (e *Engine) AddTurmite(t Turmite) error {
+	if err := ValidateTurmiteRule(t.Rule, len(e.Species())-1); err != nil {
+		return err
+	}
+	t.Rule = strings.ToUpper(t.Rule)
+	t.X, t.Y, t.Dir = mod(t.X, e.rows), mod(t.Y, e.cols), mod(t.Dir, 4)
+	e.agents.mu.Lock()
+	e.agents.turmites = append(e.agents.turmites, t)
+	e.agents.mu.Unlock()
+	return nil
+}
+
+// Turmites returns a copy of the current turmites.
+func (e *Engine) Turmites() []Turmite {
+	e.agents.mu.Lock()
+	defer e.agents.mu.Unlock()
+	return append([]Turmite(nil), e.agents.turmites...)
+}
+
+// stepAgents moves every turmite one step.
+func (e *Engine) stepAgents() {
+	e.agents.mu.Lock()
+	defer e.agents.mu.Unlock()
+
+	for i := range e.agents.turmites {
+		t := &e.agents.turmites[i]
+		s := min(e.Cell(t.X, t.Y).Species, len(t.Rule)-1)
+		switch t.Rule[s] {
+		case 'R':
+			t.Dir = (t.Dir + 1) % 4
+		case 'L':
+			t.Dir = (t.Dir + 3) % 4
+		case 'U':
+			t.Dir = (t.Dir + 2) % 4
+		}
+		e.SetCell(t.X, t.Y, State{Species: (s + 1) % len(t.Rule)})
+		x, y := t.X, t.Y
+		switch t.Dir {
+		case Up:
+			x = mod(x-1, e.rows)
+		case Right:
+			y = mod(y+1, e.cols)
+		case Down:
+			x = mod(x+1, e.rows)
+		case Left:
+			y = mod(y-1, e.cols)
+		}
+		if e.Cell(x, y).Wall {
+			t.Dir = (t.Dir + 2) % 4 // bounce off walls
+			continue
+		}
+		t.X, t.Y = x, y
+	}
+}
+
+// runAgents steps the turmites every interval, forever.
+func (e *Engine) runAgents(interval time.Duration) {
+	e.every(interval, func() { e.unlessPaused(e.stepAgents) })
+}
+
+func mod(a, n int) int {
+	return ((a % n) + n) % n
+}
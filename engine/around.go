@@ -0,0 +1,108 @@
+package engine
+
+import "sync/atomic"
+
+// cachedSpecies is the most species, dead included, whose counts a cache
+// of Params.CachedCounts holds: a count of up to 8 takes 4 bits of a
+// 64-bit word, the first of which holds the total.
+const cachedSpecies = 16
+
+// around is the cache of Params.CachedCounts: the live neighbours of every
+// cell, counted by species as they change.
+type around struct {
+	// counts holds by cell the total in its lowest 4 bits and the count
+	// of species s in bits 4s to 4s+3, for species below cachedSpecies.
+	counts []atomic.Uint64
+	// watch[first[k]:first[k+1]] are the cells that see cell k among
+	// their neighbours, once for each time they do.
+	first []int32
+	watch []int32
+}
+
+// tally is what a neighbour with word w adds to a cell's counts.
+func tally(w word) uint64 {
+	switch {
+	case !w.alive():
+		return 0
+	case w.species() < cachedSpecies:
+		return 1<<(4*w.species()) | 1
+	default:
+		return 1
+	}
+}
+
+// newAround returns the cache of e's grid as it is, which must hold still
+// until it is in place.
+func newAround(e *Engine) *around {
+	n := e.rows * e.cols
+	a := &around{counts: make([]atomic.Uint64, n), first: make([]int32, n+1)}
+	for k := range n {
+		for _, offset := range Moore {
+			if i, j, ok := e.resolve(k/e.cols+offset[0], k%e.cols+offset[1]); ok {
+				a.first[i*e.cols+j+1]++
+			}
+		}
+	}
+	for k := range n {
+		a.first[k+1] += a.first[k]
+	}
+	a.watch = make([]int32, a.first[n])
+	next := append([]int32(nil), a.first[:n]...)
+	for k := range n {
+		x, y := k/e.cols, k%e.cols
+		for _, offset := range Moore {
+			i, j, ok := e.resolve(x+offset[0], y+offset[1])
+			if !ok {
+				// beyond a dead or alive edge, which never changes
+				s, _ := e.outside(x+offset[0], y+offset[1])
+				a.counts[k].Add(tally(pack(s.Wall, s.Species, 0)))
+				continue
+			}
+			a.watch[next[i*e.cols+j]] = int32(k)
+			next[i*e.cols+j]++
+			a.counts[k].Add(tally(word(e.words[i*e.cols+j].Load())))
+		}
+	}
+	return a
+}
+
+// changed updates the counts of the cells that see cell k, whose word went
+// from old to w.
+func (a *around) changed(k int, old, w word) {
+	// The counts never drop below 0, so the difference of the tallies
+	// changes them without carrying from one species into the next.
+	d := tally(w) - tally(old)
+	if d == 0 {
+		return
+	}
+	for _, j := range a.watch[a.first[k]:a.first[k+1]] {
+		a.counts[j].Add(d)
+	}
+}
+
+// cachedNeighbors is countAliveNeighbors from the cache, leaving Cells
+// empty. It reports false when the cache cannot serve the update: without
+// one, in a Stack, with too many species, or while the oldest neighbour
+// breaks ties.
+func (c *Cell) cachedNeighbors() (Neighborhood, bool) {
+	e := c.e
+	if e.around == nil || e.below != nil || e.above != nil {
+		return Neighborhood{}, false
+	}
+	species := len(e.Species())
+	if species > cachedSpecies || TieBreak(e.transition.(*speciesRules).tie.Load()) == TieOldest {
+		return Neighborhood{}, false
+	}
+	e.gridMu.RLock()
+	counts := e.around.counts[c.k].Load()
+	e.gridMu.RUnlock()
+
+	if len(c.counts) != species {
+		c.counts = make([]int, species)
+	}
+	n := Neighborhood{Counts: c.counts, Total: int(counts & 0xf), Rand: e.rand}
+	for s := 1; s < species; s++ {
+		n.Counts[s] = int(counts >> (4 * s) & 0xf)
+	}
+	return n, true
+}
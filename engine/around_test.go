@@ -0,0 +1,45 @@
+package engine_test
+
+import (
+	"testing"
+
+	"app/engine"
+)
+
+// TestCachedCounts checks that a grid with walls, under every boundary,
+// runs the same with cached neighbour counts as without them.
+func TestCachedCounts(t *testing.T) {
+	for _, b := range engine.Boundaries {
+		t.Run(b.String(), func(t *testing.T) {
+			grids := make([]engine.Snapshot, 2)
+			for i, cached := range []bool{false, true} {
+				params := engine.DefaultParams()
+				params.Rows, params.Cols, params.Boundary = 12, 17, b
+				params.Rand = engine.NewRand(7)
+				params.CachedCounts = cached
+				e, err := engine.New(params)
+				if err != nil {
+					t.Fatal(err)
+				}
+				e.Seed(0.4)
+				for y := 3; y < 9; y++ {
+					e.SetCell(6, y, engine.State{Wall: true})
+				}
+				e.RunTicks(engine.Sequential, 20)
+				grids[i] = e.Snapshot()
+			}
+			for x := range grids[0].Cells {
+				for y := range grids[0].Cells[x] {
+					if got, want := grids[1].Cells[x][y].Species, grids[0].Cells[x][y].Species; got != want {
+						t.Fatalf("cell %d,%d is of species %d with cached counts, want %d", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+	params := engine.DefaultParams()
+	params.CachedCounts, params.Sample = true, 4
+	if _, err := engine.New(params); err == nil {
+		t.Error("New accepted cached counts with a neighbour sample")
+	}
+}
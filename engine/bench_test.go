@@ -0,0 +1,54 @@
+package engine_test
+
+import (
+	"fmt"
+	"testing"
+
+	"app/engine"
+)
+
+// BenchmarkTick runs ticks of a large seeded grid under every model but the
+// racy goroutine per cell, reporting the cells ticked a second, with and
+// without cached neighbour counts.
+func BenchmarkTick(b *testing.B) {
+	const side = 512
+	for _, m := range engine.Models {
+		if m == engine.Goroutines {
+			continue
+		}
+		for _, cached := range []bool{false, true} {
+			b.Run(fmt.Sprintf("%v/cached=%v", m, cached), func(b *testing.B) {
+				params := engine.DefaultParams()
+				params.Rows, params.Cols = side, side
+				params.Rand = engine.NewRand(1)
+				params.CachedCounts = cached
+				e, err := engine.New(params)
+				if err != nil {
+					b.Fatal(err)
+				}
+				e.Seed(0.3)
+				b.ResetTimer()
+				for range b.N {
+					e.RunTicks(m, 1)
+				}
+				b.ReportMetric(float64(b.N)*side*side/b.Elapsed().Seconds(), "cells/s")
+			})
+		}
+	}
+}
+
+// BenchmarkHash hashes a large grid, which reads the word of every cell.
+func BenchmarkHash(b *testing.B) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 1024, 1024
+	params.Rand = engine.NewRand(1)
+	e, err := engine.New(params)
+	if err != nil {
+		b.Fatal(err)
+	}
+	e.Seed(0.3)
+	b.ResetTimer()
+	for range b.N {
+		e.Hash()
+	}
+}
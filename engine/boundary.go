@@ -0,0 +1,112 @@
+package engine
+
+import "fmt"
+
+// Boundary selects what a cell sees beyond the edge of the grid.
+type Boundary int
+
+const (
+	// BoundaryDead surrounds the grid with dead cells.
+	BoundaryDead Boundary = iota
+	// BoundaryAlive surrounds the grid with live cells of species 1.
+	BoundaryAlive
+	// BoundaryWrap joins opposite edges, making the grid a torus.
+	BoundaryWrap
+	// BoundaryReflect mirrors the grid at its edges, so a cell beyond the
+	// edge is the one just inside it.
+	BoundaryReflect
+	// BoundaryKlein joins the left and right edges as BoundaryWrap does
+	// and the top and bottom edges flipped, so that a glider leaving at
+	// the top comes back at the bottom mirrored left to right, making the
+	// grid a Klein bottle.
+	BoundaryKlein
+	// BoundarySphere treats the grid as a map of a sphere, rows of
+	// latitude and columns of longitude: the left and right edges join,
+	// and a cell beyond the top or bottom edge is across the pole, on the
+	// same row half way round.
+	BoundarySphere
+)
+
+var Boundaries = []Boundary{BoundaryDead, BoundaryAlive, BoundaryWrap, BoundaryReflect, BoundaryKlein, BoundarySphere}
+
+func (b Boundary) String() string {
+	switch b {
+	case BoundaryDead:
+		return "dead"
+	case BoundaryAlive:
+		return "alive"
+	case BoundaryWrap:
+		return "wrap"
+	case BoundaryReflect:
+		return "reflect"
+	case BoundaryKlein:
+		return "klein"
+	case BoundarySphere:
+		return "sphere"
+	default:
+		return fmt.Sprintf("Boundary(%d)", int(b))
+	}
+}
+
+// ParseBoundary returns the boundary with the given name.
+func ParseBoundary(name string) (Boundary, error) {
+	for _, b := range Boundaries {
+		if b.String() == name {
+			return b, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown boundary %q", name)
+}
+
+// at returns the cell a neighbour at (x, y) refers to under e's boundary.
+// It returns nil for coordinates beyond the edge of a dead or alive
+// boundary or in the halo; such a neighbour has the state e.outside(x, y).
+// e.gridMu must be held.
+func (e *Engine) at(x, y int) *Cell {
+	x, y, ok := e.resolve(x, y)
+	if !ok {
+		return nil
+	}
+	return e.cell(x, y)
+}
+
+// cell returns the cell at row x, column y, which must be in the grid.
+func (e *Engine) cell(x, y int) *Cell { return &e.cells[x*e.cols+y] }
+
+// resolve maps the coordinates of a neighbour into the grid under e's
+// boundary, reporting false for ones beyond a dead or alive edge or in the
+// halo.
+func (e *Engine) resolve(x, y int) (int, int, bool) {
+	if x >= 0 && x < e.rows && y >= 0 && y < e.cols {
+		return x, y, true
+	}
+	if e.halo != nil && (x < 0 || x >= e.rows) {
+		return 0, 0, false
+	}
+	switch e.boundary {
+	case BoundaryWrap:
+		return mod(x, e.rows), mod(y, e.cols), true
+	case BoundaryReflect:
+		return reflect(x, e.rows), reflect(y, e.cols), true
+	case BoundaryKlein:
+		if mod(x, 2*e.rows) >= e.rows {
+			y = e.cols - 1 - y
+		}
+		return mod(x, e.rows), mod(y, e.cols), true
+	case BoundarySphere:
+		if x < 0 || x >= e.rows {
+			x, y = reflect(x, e.rows), y+e.cols/2
+		}
+		return x, mod(y, e.cols), true
+	}
+	return 0, 0, false
+}
+
+// reflect mirrors i into [0, n) about the edges of the range.
+func reflect(i, n int) int {
+	i = mod(i, 2*n)
+	if i >= n {
+		i = 2*n - 1 - i
+	}
+	return i
+}
@@ -0,0 +1,49 @@
+package engine_test
+
+import (
+	"testing"
+
+	"app/engine"
+)
+
+// TestTopologies checks which cells across the edges see a live cell near
+// the top left corner as a neighbour under the Klein bottle and sphere
+// boundaries.
+func TestTopologies(t *testing.T) {
+	const rows, cols = 6, 8
+	tests := []struct {
+		boundary engine.Boundary
+		see      [][2]int // cells beyond the edges that see the one at 0, 1
+	}{
+		// the top edge joins the bottom flipped, so column 1 meets column 6
+		{engine.BoundaryKlein, [][2]int{{5, 5}, {5, 6}, {5, 7}}},
+		// across the pole, half way round: column 1 meets column 5
+		{engine.BoundarySphere, [][2]int{{0, 4}, {0, 5}, {0, 6}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.boundary.String(), func(t *testing.T) {
+			params := engine.DefaultParams()
+			params.Rows, params.Cols = rows, cols
+			params.Boundary = tt.boundary
+			e, err := engine.New(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.SetCell(0, 1, engine.State{Species: 1})
+			want := map[[2]int]bool{}
+			for _, c := range tt.see {
+				want[c] = true
+			}
+			for x := range rows {
+				for y := range cols {
+					if x <= 1 && y <= 2 { // its own neighbours within the grid
+						continue
+					}
+					if got := e.Inspect(x, y).Neighbors.Total > 0; got != want[[2]int{x, y}] {
+						t.Errorf("cell %d, %d sees it: %v, want %v", x, y, got, want[[2]int{x, y}])
+					}
+				}
+			}
+		})
+	}
+}
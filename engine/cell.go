@@ -0,0 +1,467 @@
+package engine
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Ids of the default species.
+const (
+	Dead = iota
+	Green
+	Red
+	Blue
+)
+
+// A cell's wall flag, species and age are packed into one word, so that a
+// neighbour reads them with a single atomic load:
+//
+//	bit 31      wall
+//	bits 20-30  species, 0 = dead, otherwise an index into Engine.species
+//	bits 0-19   age, updates survived as the current species
+const (
+	ageBits     = 20
+	speciesBits = 11
+	wallBit     = 1 << (ageBits + speciesBits)
+
+	// MaxAge is the oldest age a cell reports; older cells stay at it.
+	MaxAge = 1<<ageBits - 1
+	// maxSpecies is the most species an engine can have, dead included.
+	maxSpecies = 1 << speciesBits
+)
+
+type word uint32
+
+func pack(wall bool, species, age int) word {
+	w := word(species&(maxSpecies-1))<<ageBits | word(min(age, MaxAge))
+	if wall {
+		w |= wallBit
+	}
+	return w
+}
+
+func (w word) wall() bool   { return w&wallBit != 0 }
+func (w word) species() int { return int(w>>ageBits) & (maxSpecies - 1) }
+func (w word) age() int     { return int(w & MaxAge) }
+func (w word) alive() bool  { return !w.wall() && w.species() != Dead }
+
+type Cell struct {
+	x, y int
+	// seq lets writers of the cell take turns and readers see a
+	// consistent state without locking: it is odd while a write is under
+	// way, which raises it to the next even value when it ends.
+	seq    atomic.Uint32
+	k      int           // index in the engine's flat slices, which hold its word
+	energy atomic.Uint64 // float64 bits
+	value  atomic.Int64
+	u, v   atomic.Uint64 // float64 bits
+	until  atomic.Int64  // engine time the cell can be born again from; see Species.Refractory
+	ground atomic.Uint64 // float64 bits of the resource level; see Params.Resource
+	// arrived is set when a moving cell arrives in this one, whose next
+	// state computed before then no longer applies; see Params.Motility.
+	arrived atomic.Bool
+
+	nextEnergy  float64
+	nextValue   int
+	nextU       float64
+	nextV       float64
+	nextSpecies int
+	nextGround  float64
+	moveTo      *Cell   // destination of a moving cell
+	skew        float64 // factor of the reaction times; see Params.Drift and Zone.Speed
+	zone        *Zone   // the last of e.zones c is in, if any
+	e           *Engine
+	history     []uint16      // ring of species after the latest updates, written under lock
+	historyAt   int           // index of the oldest entry once history is full
+	updates     atomic.Int64  // applied so far, for Engine.Updates
+	updatedAt   time.Duration // of the latest update measured, 0 for none; see measure
+	counts      []int         // scratch space for countAliveNeighbors
+	far         []State       // scratch space for Neighborhood.Far
+	weighted    []float64     // scratch space for weigh
+
+	// lastAt and dueAt are the engine times of the latest update and of the
+	// next one scheduled; see Inspect.
+	lastAt, dueAt atomic.Int64
+}
+
+// groundLevel returns the level of the resource field under c.
+func (c *Cell) groundLevel() float64 { return math.Float64frombits(c.ground.Load()) }
+
+// lock waits for any write of c under way and starts one, which unlock
+// ends. Readers retry rather than see it half done.
+func (c *Cell) lock() {
+	for {
+		if s := c.seq.Load(); s&1 == 0 && c.seq.CompareAndSwap(s, s+1) {
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+func (c *Cell) unlock() { c.seq.Add(1) }
+
+// state returns the cell's State, as it was between two writes.
+func (c *Cell) state() State {
+	var s State
+	c.read(&s)
+	return s
+}
+
+// read is state writing to s, which spares the update loop a copy.
+func (c *Cell) read(s *State) {
+	for {
+		if v := c.seq.Load(); v&1 == 0 {
+			c.loadTo(s)
+			if c.seq.Load() == v {
+				return
+			}
+		}
+		runtime.Gosched()
+	}
+}
+
+// load returns the cell's State, which is only consistent if c is locked.
+func (c *Cell) load() State {
+	var s State
+	c.loadTo(&s)
+	return s
+}
+
+func (c *Cell) loadTo(s *State) {
+	w := word(c.e.words[c.k].Load())
+	s.Species, s.Age, s.Wall = w.species(), w.age(), w.wall()
+	s.Energy = math.Float64frombits(c.energy.Load())
+	s.Value = int(c.value.Load())
+	s.U = math.Float64frombits(c.u.Load())
+	s.V = math.Float64frombits(c.v.Load())
+}
+
+// peek is read for the update loop. While no cell of the engine has had a
+// payload it reads only the cell's word, with a single atomic load.
+func (c *Cell) peek(s *State) { c.e.peek(c.k, s) }
+
+// peek is Cell.peek of the cell at index k, which leaves the rest of its
+// state, and so its cache lines, alone while there is no payload.
+func (e *Engine) peek(k int, s *State) {
+	w := word(e.words[k].Load())
+	if e.payload.Load() {
+		e.cells[k].read(s)
+		return
+	}
+	*s = State{Species: w.species(), Age: w.age(), Wall: w.wall()}
+}
+
+// store sets the cell's State. c must be locked.
+func (c *Cell) store(s State) {
+	// Raised before the word is written, so that peek sees it whenever it
+	// sees the word.
+	if (s.Energy != 0 || s.Value != 0 || s.U != 0 || s.V != 0) && !c.e.payload.Load() {
+		c.e.payload.Store(true)
+	}
+	if sl := c.e.sleepers.Load(); sl != nil {
+		if old := word(c.e.words[c.k].Load()); old.species() != s.Species || old.wall() != s.Wall ||
+			int64(s.Value) != c.value.Load() || math.Float64bits(s.U) != c.u.Load() || math.Float64bits(s.V) != c.v.Load() {
+			sl.stir(c.e, c.x, c.y)
+		}
+	}
+	w := pack(s.Wall, s.Species, s.Age)
+	if a := c.e.around; a != nil {
+		a.changed(c.k, word(c.e.words[c.k].Swap(uint32(w))), w)
+	} else {
+		c.e.words[c.k].Store(uint32(w))
+	}
+	c.energy.Store(math.Float64bits(s.Energy))
+	c.value.Store(int64(s.Value))
+	c.u.Store(math.Float64bits(s.U))
+	c.v.Store(math.Float64bits(s.V))
+}
+
+// countAliveNeighbors returns the number of live neighbours of each
+// species and the state of every neighbour.
+func (c *Cell) countAliveNeighbors() Neighborhood {
+	c.e.gridMu.RLock()
+	defer c.e.gridMu.RUnlock()
+
+	if n := len(c.e.Species()); len(c.counts) != n {
+		c.counts = make([]int, n)
+	}
+	clear(c.counts)
+	n := Neighborhood{Counts: c.counts, Rand: c.e.rand}
+	var unseen [8]bool
+	if c.e.sample > 0 {
+		// hide all but sample of them, picked by a partial shuffle
+		order := [8]int{0, 1, 2, 3, 4, 5, 6, 7}
+		for i := range len(order) - c.e.sample {
+			j := i + c.e.rand.Intn(len(order)-i)
+			order[i], order[j] = order[j], order[i]
+			unseen[order[i]] = true
+		}
+	}
+	for k, offset := range Moore {
+		if unseen[k] {
+			continue
+		}
+		x, y, ok := c.e.resolve(c.x+offset[0], c.y+offset[1])
+		if !ok {
+			s, in := c.e.outside(c.x+offset[0], c.y+offset[1])
+			n.Cells[k], n.InGrid[k] = s, in
+			if s.Alive() {
+				n.Counts[s.Species]++
+				n.Total++
+			}
+			continue
+		}
+		s := &n.Cells[k]
+		c.e.peek(x*c.e.cols+y, s)
+		n.InGrid[k] = !s.Wall
+		if s.Alive() && !s.Wall { // a wall may carry a restored species
+			if s.Species >= len(n.Counts) { // a hybrid bred since
+				c.counts = append(c.counts, make([]int, s.Species+1-len(c.counts))...)
+				n.Counts = c.counts
+			}
+			n.Counts[s.Species]++
+			n.Total++
+		}
+	}
+
+	if c.e.radius > 0 {
+		offsets := Offsets(c.e.radius)
+		if len(c.far) != len(offsets) {
+			c.far = make([]State, len(offsets))
+		}
+		for k, offset := range offsets {
+			x, y, ok := c.e.resolve(c.x+offset[0], c.y+offset[1])
+			if !ok {
+				c.far[k], _ = c.e.outside(c.x+offset[0], c.y+offset[1])
+				continue
+			}
+			c.e.peek(x*c.e.cols+y, &c.far[k])
+		}
+		n.Far = c.far
+		if c.e.kernel != nil {
+			c.weigh(&n)
+		}
+	}
+	if c.e.below != nil {
+		n.Below, n.HasBelow = c.e.below.Cell(c.x, c.y), true
+		if c.e.volume {
+			n.Total += c.e.below.countColumn(c.x, c.y, n.Counts)
+		}
+	}
+	if c.e.above != nil {
+		n.Above, n.HasAbove = c.e.above.Cell(c.x, c.y), true
+		if c.e.volume {
+			n.Total += c.e.above.countColumn(c.x, c.y, n.Counts)
+		}
+	}
+	return n
+}
+
+// countColumn adds the live cells of e's 3x3 block centred on (x, y) to
+// counts and returns how many there were. It serves the cells of the
+// adjacent slices of a Volume.
+func (e *Engine) countColumn(x, y int, counts []int) int {
+	e.gridMu.RLock()
+	defer e.gridMu.RUnlock()
+
+	total := 0
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			i, j, ok := e.resolve(x+dx, y+dy)
+			if !ok {
+				if s, _ := e.outside(x+dx, y+dy); s.Alive() && s.Species < len(counts) {
+					counts[s.Species]++
+					total++
+				}
+				continue
+			}
+			if w := word(e.words[i*e.cols+j].Load()); w.alive() && w.species() < len(counts) {
+				counts[w.species()]++
+				total++
+			}
+		}
+	}
+	return total
+}
+
+func (c *Cell) computeNextState() {
+	if word(c.e.words[c.k].Load()).wall() {
+		return
+	}
+	if c.e.moving {
+		c.arrived.Store(false) // before reading the state an arrival would replace
+	}
+	n, ok := c.cachedNeighbors()
+	if !ok {
+		n = c.countAliveNeighbors()
+	}
+	var self State
+	c.peek(&self)
+	if c.e.refractory && !self.Alive() {
+		self.Refractory = time.Duration(c.until.Load()) > c.e.Now()
+	}
+	if c.e.flow != nil && !self.Alive() {
+		c.bias(&n)
+	}
+
+	c.moveTo = nil
+	switch {
+	case !self.Alive():
+	case c.e.relocating != nil && c.e.relocating.Unhappy(self, n):
+		c.moveTo = c.e.vacancy()
+	case c.e.motility > 0 && c.e.rand.Float64() < c.e.motility:
+		c.moveTo = c.destination(&n)
+	}
+
+	var next State
+	if c.zone != nil && c.zone.Rule != nil {
+		next = c.zoneNext(self, n)
+	} else {
+		next = c.e.transition.Next(self, n)
+	}
+	if next.Species < 0 || next.Species >= len(c.e.Species()) {
+		next = State{}
+	}
+	if self.Refractory {
+		next.Species = Dead
+	}
+	if p := math.Float64frombits(c.e.decay.Load()); p > 0 && self.Alive() && c.e.rand.Float64() < p {
+		next = State{}
+	}
+	if z := c.zone; z != nil && z.Decay > 0 && self.Alive() && c.e.rand.Float64() < z.Decay {
+		next = State{}
+	}
+	if c.e.lifespan > 0 && self.Alive() && self.Age >= c.e.lifespan {
+		next = State{}
+	}
+	if self.Alive() && next.Species == self.Species && self.Species <= len(c.e.switching) && len(c.e.switching[self.Species-1]) > 0 {
+		r := c.e.rand.Float64()
+		for j, p := range c.e.switching[self.Species-1] {
+			if r < p {
+				next = State{Species: j + 1}
+				break
+			}
+			r -= p
+		}
+	}
+	if !self.Alive() && next.Alive() && c.e.base > 2 && c.e.rand.Float64() < c.e.mutation {
+		// any configured species but the chosen one
+		s := 1 + c.e.rand.Intn(c.e.base-2)
+		if s >= next.Species {
+			s++
+		}
+		next.Species = s
+	}
+	if r := c.e.resource; r.Enabled {
+		g := c.groundLevel()
+		g += r.Regrowth * g * (1 - g)
+		switch {
+		case self.Alive() && g < r.Starvation:
+			next = State{}
+		case self.Alive():
+			g -= r.Consumption * g
+		}
+		c.nextGround = g
+	}
+	if m := c.e.energy; m.Enabled {
+		switch {
+		case !next.Alive():
+			c.nextEnergy = 0
+		case next.Species != self.Species:
+			c.nextEnergy = m.Initial
+		default:
+			prey := n.Total - n.Counts[self.Species]
+			c.nextEnergy = self.Energy - m.Decay + m.Transfer*float64(prey)
+			if c.nextEnergy <= 0 {
+				next, c.nextEnergy = State{}, 0
+			}
+		}
+	}
+	c.nextSpecies = next.Species
+	c.nextValue = next.Value
+	c.nextU, c.nextV = next.U, next.V
+}
+
+func (c *Cell) applyNextState() {
+	c.lastAt.Store(int64(c.e.Now()))
+	c.lock()
+	old := c.load()
+	if old.Wall || c.e.moving && c.arrived.Swap(false) {
+		c.unlock()
+		return
+	}
+	next := State{Species: c.nextSpecies, Energy: c.nextEnergy, Value: c.nextValue, U: c.nextU, V: c.nextV}
+	if next.Alive() && next.Species == old.Species {
+		next.Age = old.Age + 1
+	}
+	moved := c.moveTo != nil && old.Alive() && c.move(c.moveTo, old)
+	if moved {
+		next = State{}
+	}
+	if old.Alive() && !next.Alive() && !moved {
+		if r := c.e.Species()[old.Species].Refractory; r > 0 {
+			c.until.Store(int64(c.e.Now() + r))
+		}
+	}
+	c.store(next)
+	if c.e.resource.Enabled {
+		c.ground.Store(math.Float64bits(c.nextGround))
+	}
+	next.Age = min(next.Age, MaxAge)
+	c.updates.Add(1)
+	if c.e.history > 0 {
+		if len(c.history) < c.e.history {
+			c.history = append(c.history, uint16(next.Species))
+		} else {
+			c.history[c.historyAt] = uint16(next.Species)
+			c.historyAt = (c.historyAt + 1) % len(c.history)
+		}
+	}
+	c.unlock()
+
+	if next.Species != old.Species {
+		for _, f := range load(&c.e.hooks.cellChanged) {
+			f(c.x, c.y, old, next)
+		}
+	}
+}
+
+// reactionTime returns how long the cell waits before its next update.
+func (c *Cell) reactionTime() time.Duration {
+	t := c.e.Species()[word(c.e.words[c.k].Load()).species()].ReactionTime
+	if c.skew != 1 {
+		t = time.Duration(float64(t) * c.skew)
+	}
+	if j := c.e.jitter; j > 0 {
+		t += time.Duration((2*c.e.rand.Float64() - 1) * float64(j))
+	}
+	return max(t, 0)
+}
+
+func (c *Cell) run(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for {
+		c.e.sim.sleep(c.schedule(c.reactionTime()), c.e.done)
+		select {
+		case <-c.e.done:
+			return
+		default:
+		}
+		ran := false
+		c.e.unlessPaused(func() {
+			c.measure()
+			c.computeNextState()
+			c.applyNextState()
+			ran = true
+		})
+		if ran {
+			c.e.pace(1)
+		} else {
+			c.skip()
+		}
+	}
+}
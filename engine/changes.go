@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CellEvent is a change of a cell's species, as delivered by Changes.
+type CellEvent struct {
+	X, Y     int // row and column
+	Old, New State
+	Tick     int           // ticks completed before the change
+	At       time.Duration // simulated time of the change; see Now
+}
+
+// ChangePolicy is what an update does when the channel returned by Changes
+// is full.
+type ChangePolicy int
+
+const (
+	// DropChanges drops the event, counted by DroppedChanges, so that a
+	// slow reader never holds up the simulation.
+	DropChanges ChangePolicy = iota
+	// BlockChanges waits for the reader, so that it sees every change, at
+	// the cost of the simulation running no faster than it reads. Once the
+	// engine is stopped, events that find the channel full are dropped.
+	BlockChanges
+)
+
+func (p ChangePolicy) String() string {
+	switch p {
+	case DropChanges:
+		return "drop"
+	case BlockChanges:
+		return "block"
+	default:
+		return fmt.Sprintf("ChangePolicy(%d)", int(p))
+	}
+}
+
+// changes is the channel returned by Changes.
+type changes struct {
+	once    sync.Once
+	ch      chan CellEvent
+	buffer  int
+	policy  ChangePolicy
+	dropped atomic.Int64
+}
+
+// Changes returns a channel receiving every change of a cell's species, as
+// OnCellChanged sees them, from the first call on. It has room for
+// Params.ChangeBuffer events, and Params.ChangePolicy says what happens
+// when it is full. Every call returns the same channel, which is never
+// closed: readers should stop with Done.
+func (e *Engine) Changes() <-chan CellEvent {
+	e.changes.once.Do(func() {
+		e.changes.ch = make(chan CellEvent, e.changes.buffer)
+		e.OnCellChanged(e.sendChange)
+	})
+	return e.changes.ch
+}
+
+// DroppedChanges returns how many events Changes dropped because its
+// channel was full.
+func (e *Engine) DroppedChanges() int64 { return e.changes.dropped.Load() }
+
+// sendChange delivers a change to the channel of Changes.
+func (e *Engine) sendChange(x, y int, old, new State) {
+	ev := CellEvent{X: x, Y: y, Old: old, New: new, Tick: e.Ticks(), At: e.Now()}
+	select {
+	case e.changes.ch <- ev:
+		return
+	default:
+	}
+	if e.changes.policy == BlockChanges {
+		select {
+		case e.changes.ch <- ev:
+			return
+		case <-e.done:
+		}
+	}
+	e.changes.dropped.Add(1)
+}
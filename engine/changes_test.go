@@ -0,0 +1,54 @@
+package engine_test
+
+import (
+	"testing"
+
+	"app/engine"
+)
+
+// TestChanges checks that replaying the events of Changes onto the initial
+// grid gives the final one, under every model.
+func TestChanges(t *testing.T) {
+	for _, m := range engine.Models {
+		t.Run(m.String(), func(t *testing.T) {
+			params := engine.DefaultParams()
+			params.Rows, params.Cols = 16, 16
+			params.Rand = engine.NewRand(1)
+			params.ChangeBuffer = 1 << 16
+			e, err := engine.New(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.Seed(0.4)
+			grid := make([][]int, e.Rows())
+			for i := range grid {
+				grid[i] = make([]int, e.Cols())
+				for j := range grid[i] {
+					grid[i][j] = e.Cell(i, j).Species
+				}
+			}
+			changes := e.Changes()
+			e.RunTicks(m, 10)
+			if len(changes) == 0 {
+				t.Fatal("no changes")
+			}
+			if d := e.DroppedChanges(); d != 0 {
+				t.Fatalf("%d changes dropped", d)
+			}
+			for len(changes) > 0 {
+				ev := <-changes
+				if grid[ev.X][ev.Y] != ev.Old.Species {
+					t.Fatalf("change of (%d, %d) from %d at tick %d, but it is %d", ev.X, ev.Y, ev.Old.Species, ev.Tick, grid[ev.X][ev.Y])
+				}
+				grid[ev.X][ev.Y] = ev.New.Species
+			}
+			for i := range grid {
+				for j := range grid[i] {
+					if got := e.Cell(i, j).Species; grid[i][j] != got {
+						t.Errorf("(%d, %d) replayed as %d, is %d", i, j, grid[i][j], got)
+					}
+				}
+			}
+		})
+	}
+}
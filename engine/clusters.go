@@ -0,0 +1,61 @@
+package engine
+
+import "math/bits"
+
+// Clusters describes the connected groups of one species' cells, two cells
+// being connected when they are Moore neighbours across the grid's
+// Boundary.
+type Clusters struct {
+	Count   int
+	Largest int     // cells in the largest cluster
+	Mean    float64 // cells per cluster
+	// Sizes is the size distribution: Sizes[k] clusters have between 2^k
+	// and 2^(k+1)-1 cells.
+	Sizes []int
+}
+
+// Clusters flood-fills the current grid and returns the clusters of every
+// species, indexed by species id; the entry for dead cells is empty. Like
+// Complexity it reads every cell.
+func (e *Engine) Clusters() []Clusters {
+	grid := e.speciesGrid()
+	seen := make([]bool, len(grid))
+	cs := make([]Clusters, len(e.Species()))
+	var stack []int
+	for start, s := range grid {
+		if s == Dead || seen[start] {
+			continue
+		}
+		size := 0
+		seen[start] = true
+		stack = append(stack[:0], start)
+		for len(stack) > 0 {
+			k := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			size++
+			for _, offset := range Moore {
+				x, y, ok := e.resolve(k/e.cols+offset[0], k%e.cols+offset[1])
+				if n := x*e.cols + y; ok && !seen[n] && grid[n] == s {
+					seen[n] = true
+					stack = append(stack, n)
+				}
+			}
+		}
+
+		c := &cs[s]
+		c.Count++
+		c.Largest = max(c.Largest, size)
+		c.Mean += float64(size)
+		bin := bits.Len(uint(size)) - 1
+		for len(c.Sizes) <= bin {
+			c.Sizes = append(c.Sizes, 0)
+		}
+		c.Sizes[bin]++
+	}
+	for i := range cs {
+		if cs[i].Count > 0 {
+			cs[i].Mean /= float64(cs[i].Count)
+		}
+	}
+	return cs
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// Complexity measures how ordered the grid is.
+type Complexity struct {
+	// Entropy is the Shannon entropy of the grid's overlapping 2x2 blocks
+	// of species, in bits per cell: 0 for a uniform grid and up to the
+	// log2 of the number of species, dead included, for noise.
+	Entropy float64
+	// Compression is the DEFLATE-compressed size of the grid's species as
+	// a fraction of their raw size, a rough estimate of its algorithmic
+	// complexity: low for settled or periodic grids, high for chaotic ones.
+	Compression float64
+}
+
+// Complexity measures the current grid. It reads every cell, so calling it
+// more than a few times a second slows the engine down.
+func (e *Engine) Complexity() Complexity {
+	grid := e.speciesGrid()
+
+	var c Complexity
+	if e.rows > 1 && e.cols > 1 {
+		blocks := make(map[[4]uint16]int)
+		for i := 0; i+1 < e.rows; i++ {
+			for j := 0; j+1 < e.cols; j++ {
+				k := i*e.cols + j
+				blocks[[4]uint16{grid[k], grid[k+1], grid[k+e.cols], grid[k+e.cols+1]}]++
+			}
+		}
+		n := float64((e.rows - 1) * (e.cols - 1))
+		for _, count := range blocks {
+			p := float64(count) / n
+			c.Entropy -= p * math.Log2(p)
+		}
+		c.Entropy /= 4
+	}
+
+	raw := speciesBytes(grid)
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	w.Write(raw)
+	w.Close()
+	c.Compression = float64(buf.Len()) / float64(len(raw))
+	return c
+}
+
+// Hash returns a 64-bit FNV-1a hash of the species of every cell, so equal
+// grids hash equal. Ages and continuous state are left out.
+func (e *Engine) Hash() uint64 {
+	h := fnv.New64a()
+	h.Write(speciesBytes(e.speciesGrid()))
+	return h.Sum64()
+}
+
+// speciesGrid returns the species of every cell, row by row.
+func (e *Engine) speciesGrid() []uint16 {
+	e.gridMu.RLock()
+	defer e.gridMu.RUnlock()
+
+	grid := make([]uint16, 0, e.rows*e.cols)
+	for k := range e.words {
+		grid = append(grid, uint16(word(e.words[k].Load()).species()))
+	}
+	return grid
+}
+
+// speciesBytes encodes a speciesGrid as varints, a byte a cell while there
+// are fewer than 128 species, for hashing and compressing.
+func speciesBytes(grid []uint16) []byte {
+	b := make([]byte, 0, len(grid))
+	for _, s := range grid {
+		b = binary.AppendUvarint(b, uint64(s))
+	}
+	return b
+}
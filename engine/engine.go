@@ -0,0 +1,862 @@
+// Package engine implements the asynchronous three-species cellular
+// automaton, independently of how it is displayed.
+package engine
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Moore lists the row and column offsets of a cell's eight neighbours, in
+// the order of Neighborhood.Cells.
+var Moore = [][2]int{
+	{-1, -1}, {-1, 0}, {-1, 1},
+	{0, -1}, {0, 1},
+	{1, -1}, {1, 0}, {1, 1},
+}
+
+// Params configures an engine.
+type Params struct {
+	Rows, Cols       int
+	Species          []Species
+	DeadReactionTime time.Duration
+	// Transition, if set, replaces the species' own B/S rules.
+	Transition Transition
+	// TickInterval is how often tick hooks run while the engine runs in
+	// real time.
+	TickInterval time.Duration
+	Energy       Metabolism
+	Resource     Resource
+	// Mutation is the probability that a newborn becomes a random other
+	// species instead of the one the rules chose.
+	Mutation float64
+	// Decay is the probability that a live cell dies at an update whatever
+	// its neighbours.
+	Decay float64
+	// Switching, if set, is the probability that a live cell surviving an
+	// update switches species in place, as a phenotype switch, indexed
+	// from species 1 both ways: Switching[i][j] for a cell of species i+1
+	// becoming species j+1. Each row sums to at most 1. Unlike Mutation it
+	// spares newborns, and the switched cell starts over as a newborn of
+	// its new species.
+	Switching [][]float64
+	// Lifespan, unless 0, is the most updates a live cell survives as its
+	// species: one whose Age has reached it dies at its next update
+	// whatever its neighbours, so that still lifes turn over. It is at
+	// most MaxAge.
+	Lifespan int
+	// StrictBirth makes the species' rules give birth to a dead cell only
+	// if all its live neighbours are of one species, rather than to the
+	// dominant species among them. A Transition ignores it.
+	StrictBirth bool
+	// Hybrids, unless 0, is how many hybrid species the species' rules may
+	// breed: instead of picking one of the species tied for the most
+	// neighbours of a birth at random, they make the cell a hybrid of them
+	// all, registered with Species.Parents the first time the tie occurs.
+	// Once that many are bred, ties are settled by TieBreak again. A
+	// Transition ignores it.
+	Hybrids int
+	// TieBreak is how the species' rules settle a birth that several
+	// species tie for. A Transition ignores it.
+	TieBreak TieBreak
+	// CachedCounts keeps the count of live neighbours of every species of
+	// each cell up to date as its neighbours change, so that an update
+	// reads one word rather than the eight of its neighbours; a change
+	// writes to the eight instead, which pays off while most updates
+	// change nothing. It serves the species' rules on the Moore
+	// neighbourhood, so a Transition, Kernel, Sample, Halo, Flow or
+	// Motility cannot be combined with it. Updates count afresh in a
+	// Stack, while more than 15 species exist and while TieOldest breaks
+	// ties.
+	CachedCounts bool
+	// AgentInterval is the time between turmite steps while the engine
+	// runs in real time. In RunTicks turmites step once per tick.
+	AgentInterval time.Duration
+	// Boundary is what cells see beyond the edge of the grid. Turmites
+	// always wrap around.
+	Boundary Boundary
+	// Halo makes the grid a band of rows of a larger one: beyond its top
+	// and bottom edges, cells see the rows set with SetHalo instead of the
+	// boundary, which still applies to the left and right edges.
+	Halo bool
+	// Rand is the source of randomness; nil uses math/rand's top-level
+	// functions.
+	Rand Rand
+	// History is how many of its latest updates each cell remembers for
+	// Engine.History; 0 disables it.
+	History int
+	// Events is how many of the latest events Engine.Events keeps; 0
+	// disables the event log, but not OnEvent. MergeSize, unless 0, logs
+	// a Merge when a species' largest cluster grows by that many cells to
+	// a record size, measured once a second of simulated time, at the cost
+	// of a flood fill of the grid each time.
+	Events    int
+	MergeSize int
+	// Majority, unless 0, is the share of the live cells in (0, 1] from
+	// which a species counts as dominant, for OnMajority and the event
+	// log.
+	Majority float64
+	// TileSize is the side of the square tiles of StartTiled and the
+	// Tiles model; 0 means DefaultTileSize.
+	TileSize int
+	// Sleep, unless 0, lets the tiles of StartTiled sleep once settled:
+	// after its cells have updated Sleep times each on average with no
+	// cell in or next to it changing, a tile's cells stop updating until
+	// one does, or the rules or the decay change, saving the time they
+	// would spend finding out that nothing happens. Settled regions then
+	// cost nothing while the active frontiers update as usual. A tile
+	// asleep misses what would have happened to it by chance, such as
+	// decay, and what the adjacent layers of a Stack do.
+	Sleep int
+	// MapFile, unless empty, keeps the species, age and wall of every cell
+	// in a file mapped into memory rather than on the heap, so that a grid
+	// larger than memory pages in and out as the operating system sees fit
+	// and the file is always a saved state of the grid, in the format of
+	// WriteMap. If the file holds a grid of this size it is the starting
+	// grid, otherwise it must be empty or missing and is created with dead
+	// cells. The rest of the state of the cells, their schedule, energy,
+	// Value, U and V, stays in memory and is not saved. The file is locked
+	// for as long as the process runs; it needs a Unix system.
+	MapFile string
+	// Jitter, unless 0, moves every wait of a cell between two updates by a
+	// uniformly random offset in [-Jitter, Jitter], on top of its reaction
+	// time, in Start, StartTiled and the Timed model. Waits cut below
+	// zero are zero.
+	Jitter time.Duration
+	// Drift, unless 0, gives every cell a clock of its own for good: its
+	// reaction times are scaled by a factor drawn uniformly from
+	// [1-Drift, 1+Drift] when the engine is created. It must be below 1.
+	Drift float64
+	// LateTolerance, unless 0, is how long after it was due an update of
+	// Start or StartTiled may come before it counts as late, a sign that
+	// the machine cannot keep up with the reaction times; see Late.
+	LateTolerance time.Duration
+	// TimeScale is how many times as fast as real time the simulated clock
+	// of Start, StartTiled and StartSynchronous runs: reaction times, the
+	// tick and agent intervals and Jitter are simulated durations, so that
+	// 2 runs everything twice as fast and 0.5 at half speed. 0 means 1.
+	// RunTicks keeps its own clock and runs as fast as it can.
+	TimeScale float64
+	// Throttle, unless nil, caps the cell updates of the engine, together
+	// with those of every other engine sharing it, at its rate while the
+	// engine runs in real time.
+	Throttle *Throttle
+	// Motility, unless 0, is the probability that a live cell tries to
+	// move at an update instead of living or dying in place: it moves to
+	// the empty neighbour with the most resource if there is a resource
+	// field, else the one with the fewest live neighbours, if that is
+	// better than where it is, taking its state along. When two cells
+	// head for the same one, the first to get there takes it and the
+	// other updates in place.
+	Motility float64
+	// Sample, unless 0, is how many of its eight neighbours, picked at
+	// random at every update, a cell sees, modelling imperfect local
+	// information: the others are reported as beyond the edge, dead and
+	// not InGrid, and left out of the counts. It must be at most 8, which
+	// sees them all, and cannot be combined with a Kernel.
+	Sample int
+	// Kernel, if set, weighs the neighbours within its radius when live
+	// cells are counted, instead of counting the eight nearest once each;
+	// see Kernel.
+	Kernel Kernel
+	// Zones give rectangles of the grid parameters of their own; see Zone.
+	Zones []Zone
+	// Flow, if set, biases births downstream of a vector field; see Flow.
+	Flow Flow
+	// Logger, if set, receives a record when the engine starts, stops,
+	// pauses or resumes, at the debug level, and when its parameters
+	// change while it runs, at the info level.
+	Logger *slog.Logger
+	// ChangeBuffer is the room of the channel returned by Changes, and
+	// ChangePolicy what an update does when it is full.
+	ChangeBuffer int
+	ChangePolicy ChangePolicy
+}
+
+// Metabolism is the optional energy model. Newborn cells start with Initial
+// energy; every update a live cell spends Decay and gains Transfer for each
+// live neighbour of another species it eats, and it dies when its energy
+// runs out. Eating does not drain the prey.
+type Metabolism struct {
+	Enabled                  bool
+	Initial, Decay, Transfer float64
+}
+
+// Resource is the optional resource field under the grid: every cell's
+// ground holds a level in [0, 1], full at first, that regrows logistically
+// by Regrowth at every update of the cell and of which a live cell eats the
+// share Consumption. A live cell on ground below Starvation dies whatever
+// its neighbours. Snapshots and Restore leave the field out.
+type Resource struct {
+	Enabled                           bool
+	Consumption, Regrowth, Starvation float64
+}
+
+// DefaultParams returns the original 50x50 three-species setup.
+func DefaultParams() Params {
+	return Params{
+		Rows:             50,
+		Cols:             50,
+		Species:          DefaultSpecies(),
+		DeadReactionTime: 102 * time.Millisecond,
+		TickInterval:     100 * time.Millisecond,
+		AgentInterval:    20 * time.Millisecond,
+		Energy:           Metabolism{Initial: 10, Decay: 1, Transfer: 0.5},
+		Resource:         Resource{Consumption: 0.1, Regrowth: 0.2, Starvation: 0.1},
+	}
+}
+
+func (p Params) validate() error {
+	if p.Rows <= 0 || p.Cols <= 0 {
+		return fmt.Errorf("grid size %dx%d must be positive", p.Rows, p.Cols)
+	}
+	if len(p.Species) == 0 {
+		return fmt.Errorf("no species defined")
+	}
+	if len(p.Species) > maxSpecies {
+		return fmt.Errorf("%d species, at most %d are supported", len(p.Species), maxSpecies)
+	}
+	if p.Hybrids < 0 || len(p.Species)+p.Hybrids > maxSpecies {
+		return fmt.Errorf("%d hybrids must not be negative, and at most %d species with them are supported", p.Hybrids, maxSpecies)
+	}
+	for _, sp := range p.Species {
+		if sp.ReactionTime <= 0 {
+			return fmt.Errorf("species %q: reaction time must be positive", sp.Name)
+		}
+		if sp.Refractory < 0 {
+			return fmt.Errorf("species %q: refractory time must not be negative", sp.Name)
+		}
+	}
+	if p.DeadReactionTime <= 0 {
+		return fmt.Errorf("dead reaction time must be positive")
+	}
+	if p.TickInterval <= 0 {
+		return fmt.Errorf("tick interval must be positive")
+	}
+	if p.AgentInterval <= 0 {
+		return fmt.Errorf("agent interval must be positive")
+	}
+	if p.Boundary < BoundaryDead || p.Boundary > BoundarySphere {
+		return fmt.Errorf("unknown boundary %v", p.Boundary)
+	}
+	if p.Halo && (p.Boundary == BoundaryKlein || p.Boundary == BoundarySphere) {
+		return fmt.Errorf("the %v boundary joins the top and bottom edges, which a halo replaces", p.Boundary)
+	}
+	if p.Events < 0 || p.MergeSize < 0 {
+		return fmt.Errorf("event log length %d and merge size %d must not be negative", p.Events, p.MergeSize)
+	}
+	if p.Majority < 0 || p.Majority > 1 {
+		return fmt.Errorf("majority share %v must be in [0, 1]", p.Majority)
+	}
+	if p.History < 0 {
+		return fmt.Errorf("history length %d must not be negative", p.History)
+	}
+	if p.TileSize < 0 {
+		return fmt.Errorf("tile size %d must not be negative", p.TileSize)
+	}
+	if p.Sleep < 0 {
+		return fmt.Errorf("sleep %d must not be negative", p.Sleep)
+	}
+	if p.Jitter < 0 {
+		return fmt.Errorf("jitter %v must not be negative", p.Jitter)
+	}
+	if p.TieBreak < TieRandom || p.TieBreak > TieOldest {
+		return fmt.Errorf("unknown tie-break %v", p.TieBreak)
+	}
+	if p.CachedCounts && (p.Transition != nil || p.Kernel != nil || p.Sample > 0 || p.Halo || p.Flow != nil || p.Motility > 0) {
+		return fmt.Errorf("cached counts serve only the species' rules on the eight nearest neighbours")
+	}
+	if p.LateTolerance < 0 {
+		return fmt.Errorf("late tolerance %v must not be negative", p.LateTolerance)
+	}
+	if p.TimeScale < 0 {
+		return fmt.Errorf("time scale %v must not be negative", p.TimeScale)
+	}
+	if p.Motility < 0 || p.Motility > 1 {
+		return fmt.Errorf("motility %v must be in [0, 1]", p.Motility)
+	}
+	if p.Drift < 0 || p.Drift >= 1 {
+		return fmt.Errorf("drift %v must be in [0, 1)", p.Drift)
+	}
+	if p.ChangeBuffer < 0 || p.ChangePolicy < DropChanges || p.ChangePolicy > BlockChanges {
+		return fmt.Errorf("changes: buffer must be non-negative and policy drop or block")
+	}
+	if p.Mutation < 0 || p.Mutation > 1 {
+		return fmt.Errorf("mutation probability %v must be in [0, 1]", p.Mutation)
+	}
+	if p.Decay < 0 || p.Decay > 1 {
+		return fmt.Errorf("decay probability %v must be in [0, 1]", p.Decay)
+	}
+	if len(p.Switching) > len(p.Species) {
+		return fmt.Errorf("switching: %d rows for %d species", len(p.Switching), len(p.Species))
+	}
+	for i, row := range p.Switching {
+		if len(row) > len(p.Species) {
+			return fmt.Errorf("switching: species %q: %d probabilities for %d species", p.Species[i].Name, len(row), len(p.Species))
+		}
+		sum := 0.0
+		for _, q := range row {
+			if q < 0 || q > 1 {
+				return fmt.Errorf("switching: species %q: probability %v must be in [0, 1]", p.Species[i].Name, q)
+			}
+			sum += q
+		}
+		if sum > 1 {
+			return fmt.Errorf("switching: species %q: probabilities sum to %v, more than 1", p.Species[i].Name, sum)
+		}
+	}
+	if p.Sample < 0 || p.Sample > len(Moore) {
+		return fmt.Errorf("neighbour sample %d must be in [0, %d]", p.Sample, len(Moore))
+	}
+	if p.Sample > 0 && p.Kernel != nil {
+		return fmt.Errorf("a neighbour sample and a kernel cannot be combined")
+	}
+	if p.Kernel != nil {
+		if err := p.Kernel.validate(); err != nil {
+			return err
+		}
+	}
+	if err := p.validateZones(); err != nil {
+		return err
+	}
+	if p.Lifespan < 0 || p.Lifespan > MaxAge {
+		return fmt.Errorf("lifespan %d must be in [0, %d]", p.Lifespan, MaxAge)
+	}
+	if m := p.Energy; m.Enabled && (m.Initial <= 0 || m.Decay < 0 || m.Transfer < 0) {
+		return fmt.Errorf("energy: initial must be positive and decay and transfer non-negative")
+	}
+	if r := p.Resource; r.Enabled && (r.Consumption < 0 || r.Consumption >= 1 || r.Regrowth < 0 || r.Regrowth > 1 || r.Starvation < 0 || r.Starvation >= 1) {
+		return fmt.Errorf("resource: consumption and starvation must be in [0, 1) and regrowth in [0, 1]")
+	}
+	return nil
+}
+
+type Engine struct {
+	rows, cols int
+	species    atomic.Pointer[[]Species] // indexed by species id; species 0 is dead cells
+	speciesMu  sync.Mutex                // serializes changes of species
+	base       int                       // species from Params, dead cells included
+	hybrids    hybrids
+	transition Transition
+	radius     int       // of a Ranged transition or the kernel, else 0
+	kernel     []float64 // weights in Offsets(radius) order, nil without Params.Kernel
+	boundary   Boundary
+	edge       State // of neighbours beyond a dead or alive boundary
+	halo       *halo // nil unless Params.Halo is set
+	history    int
+	events     int // kept in log
+	mergeSize  int
+	majority   float64
+	log        eventLog
+	energy     Metabolism
+	resource   Resource
+	motility   float64
+	sample     int        // neighbours seen, 0 for all
+	around     *around    // nil without Params.CachedCounts
+	relocating Relocating // the transition, if it is one
+	moving     bool       // cells may move, by motility or relocation
+	mutation   float64
+	switching  [][]float64 // see Params.Switching, nil if none
+	lifespan   int
+	zones      []Zone
+	flow       [][2]float64  // by cell, nil without Params.Flow
+	decay      atomic.Uint64 // float64 bits of the probability; see Params.Decay
+	// The grid is laid out row by row in flat slices indexed by
+	// x*cols+y, allocated once: words holds the word of every cell, which
+	// neighbours read on every update, apart from the rest of its state in
+	// cells, so that a cell's neighbourhood spans a few cache lines rather
+	// than as many heap objects.
+	words  []atomic.Uint32 // of type word
+	cells  []Cell
+	gridMu sync.RWMutex
+	// payload is set once any cell has held a nonzero Energy, Value, U or
+	// V. Until then the whole state of a cell is in its word; see peek.
+	payload   atomic.Bool
+	hooks     hooks
+	changes   changes
+	turnover  turnover
+	latencies latencies
+	lateness  lateness
+	below     *Engine // adjacent layers; see Stack
+	above     *Engine
+	volume    bool // the adjacent layers are slices of a Volume
+	agents    agents
+	agentTau  time.Duration
+	sim       *simClock // of the Start functions
+	interval  time.Duration
+	clock     *clock // of the Timed model, once used
+	tileSize  int
+	sleep     int                      // see Params.Sleep
+	sleepers  atomic.Pointer[sleepers] // once StartTiled runs with sleep set
+	workers   atomic.Pointer[workers]  // of the latest run; see Workers
+	jitter    time.Duration
+	throttle  *Throttle // see Params.Throttle
+	mapped    []byte    // the map file, nil without Params.MapFile
+	mapLock   *os.File  // the map file, open for its lock
+	mapLoaded bool      // the map file held the starting grid
+	// refractory is set if any species has a refractory period. realTime
+	// is set by the Start functions, for now.
+	refractory bool
+	realTime   atomic.Bool
+	rand       Rand
+	logger     *slog.Logger
+
+	// paused stops the goroutines started by Start. Every update holds
+	// runMu for reading, so that Pause can wait for those under way.
+	paused atomic.Bool
+	runMu  sync.RWMutex
+	// done is closed by Stop, ending those goroutines for good.
+	done     chan struct{}
+	stopOnce sync.Once
+
+	tickMu  sync.Mutex
+	ticks   atomic.Int64
+	lastPop []int // population at the previous tick, for extinction checks
+}
+
+// New returns an engine with a grid of dead cells.
+func New(p Params) (*Engine, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	e := &Engine{
+		done:      make(chan struct{}),
+		rows:      p.Rows,
+		cols:      p.Cols,
+		sim:       newSimClock(time.Now(), cmp.Or(p.TimeScale, 1)),
+		interval:  p.TickInterval,
+		energy:    p.Energy,
+		resource:  p.Resource,
+		motility:  p.Motility,
+		sample:    p.Sample % len(Moore),
+		mutation:  p.Mutation,
+		lifespan:  p.Lifespan,
+		agentTau:  p.AgentInterval,
+		boundary:  p.Boundary,
+		history:   p.History,
+		events:    p.Events,
+		mergeSize: p.MergeSize,
+		majority:  p.Majority,
+		tileSize:  cmp.Or(p.TileSize, DefaultTileSize),
+		sleep:     p.Sleep,
+		jitter:    p.Jitter,
+		lateness:  lateness{tolerance: p.LateTolerance},
+		throttle:  p.Throttle,
+		rand:      p.Rand,
+		logger:    p.Logger,
+	}
+	if e.rand == nil {
+		e.rand = globalRand{}
+	}
+	e.changes.buffer, e.changes.policy = p.ChangeBuffer, p.ChangePolicy
+	if p.Flow != nil {
+		var err error
+		if e.flow, err = sampleFlow(p.Flow, p.Rows, p.Cols); err != nil {
+			return nil, err
+		}
+	}
+	if e.logger == nil {
+		e.logger = slog.New(slog.DiscardHandler)
+	}
+	e.decay.Store(math.Float64bits(p.Decay))
+	species := append([]Species{{Name: "dead", ReactionTime: p.DeadReactionTime}}, p.Species...)
+	e.species.Store(&species)
+	e.base = len(species)
+	for _, sp := range e.Species() {
+		e.refractory = e.refractory || sp.Refractory > 0
+	}
+	if p.Boundary == BoundaryAlive {
+		e.edge = State{Species: 1}
+	}
+	if p.Halo {
+		e.halo = &halo{}
+	}
+	e.transition = p.Transition
+	if e.transition == nil {
+		sr := newSpeciesRules(e.Species(), p.StrictBirth)
+		sr.tie.Store(int32(p.TieBreak))
+		if p.Hybrids > 0 {
+			e.hybrids.left = p.Hybrids
+			sr.hybrid = e.hybrid
+		}
+		e.transition = sr
+	}
+	if r, ok := e.transition.(Ranged); ok {
+		e.radius = max(r.Radius(), 1)
+	}
+	e.relocating, _ = e.transition.(Relocating)
+	e.moving = e.motility > 0 || e.relocating != nil
+	if p.Kernel != nil {
+		e.radius = max(e.radius, p.Kernel.Radius())
+		e.kernel = p.Kernel.weights(e.radius)
+	}
+	if e.sleep > 0 && e.radius > e.tileSize {
+		return nil, fmt.Errorf("sleeping tiles of %d cells must be at least as wide as the radius %d of the neighbourhood", e.tileSize, e.radius)
+	}
+	if p.MapFile != "" {
+		if err := e.mapWords(p.MapFile); err != nil {
+			return nil, err
+		}
+	} else {
+		e.words = make([]atomic.Uint32, e.rows*e.cols)
+	}
+	e.cells = make([]Cell, e.rows*e.cols)
+	for k := range e.cells {
+		c := &e.cells[k]
+		c.x, c.y, c.k, c.e, c.skew = k/e.cols, k%e.cols, k, e, 1
+		if p.Drift > 0 {
+			c.skew = 1 + p.Drift*(2*e.rand.Float64()-1)
+		}
+		if p.Resource.Enabled {
+			c.ground.Store(math.Float64bits(1))
+		}
+		if p.History > 0 {
+			c.history = make([]uint16, 0, p.History)
+		}
+	}
+	if p.CachedCounts {
+		e.around = newAround(e)
+	}
+	for _, row := range p.Switching {
+		e.switching = append(e.switching, append([]float64(nil), row...))
+	}
+	e.zones = append([]Zone(nil), p.Zones...)
+	_, rules := e.transition.(*speciesRules)
+	for i := range e.zones {
+		z := &e.zones[i]
+		if !rules {
+			z.Rule = nil
+		}
+		for x := max(z.Top, 0); x < min(z.Top+z.Rows, e.rows); x++ {
+			for y := max(z.Left, 0); y < min(z.Left+z.Cols, e.cols); y++ {
+				e.cells[x*e.cols+y].zone = z
+			}
+		}
+	}
+	for k := range e.cells {
+		if c := &e.cells[k]; c.zone != nil && c.zone.Speed > 0 {
+			c.skew /= c.zone.Speed
+		}
+	}
+	return e, nil
+}
+
+// Resource returns the level of the resource field at row x, column y, and
+// whether there is one.
+func (e *Engine) Resource(x, y int) (float64, bool) {
+	if !e.resource.Enabled {
+		return 0, false
+	}
+	return math.Float64frombits(e.cell(x, y).ground.Load()), true
+}
+
+func (e *Engine) Rows() int { return e.rows }
+func (e *Engine) Cols() int { return e.cols }
+
+// Rand returns the engine's source of randomness, for seeding it.
+func (e *Engine) Rand() Rand { return e.rand }
+
+// Species returns the species definitions, indexed by species id; index 0
+// describes dead cells. Hybrids bred while the engine runs are appended to
+// it and SetReactionTime changes it; the returned slice itself never
+// changes.
+func (e *Engine) Species() []Species { return *e.species.Load() }
+
+// Seed makes each cell alive with probability density, with a uniformly
+// random species of Params.Species. Walls are left in place.
+func (e *Engine) Seed(density float32) {
+	e.SeedWeighted(density, nil)
+}
+
+// SeedWeighted is like Seed, but picks the species of each live cell with
+// probability proportional to weights, indexed from species 1. A nil
+// weights means uniform.
+func (e *Engine) SeedWeighted(density float32, weights []float64) {
+	weights = weights[:min(len(weights), e.base-1)]
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	densities := make([]float64, e.base-1)
+	for i := range densities {
+		switch {
+		case total <= 0:
+			densities[i] = float64(density) / float64(len(densities))
+		case i < len(weights):
+			densities[i] = float64(density) * weights[i] / total
+		}
+	}
+	e.SeedDensities(densities)
+}
+
+// SeedDensities makes each cell a live cell of species i+1 with probability
+// densities[i], and dead otherwise. The densities should sum to at most 1.
+// Walls are left in place.
+func (e *Engine) SeedDensities(densities []float64) {
+	pick := func() int {
+		r := e.rand.Float64()
+		for i, d := range densities[:min(len(densities), e.base-1)] {
+			if r < d {
+				return 1 + i
+			}
+			r -= d
+		}
+		return Dead
+	}
+
+	e.gridMu.Lock()
+	defer e.gridMu.Unlock()
+
+	for k := range e.cells {
+		c := &e.cells[k]
+		c.lock()
+		if !c.load().Wall {
+			s := State{Species: pick()}
+			if s.Alive() && e.energy.Enabled {
+				s.Energy = e.energy.Initial
+			}
+			c.store(s)
+			c.until.Store(0)
+		}
+		c.unlock()
+	}
+}
+
+// SetCell replaces the state of the cell at row x, column y. Age is reset,
+// energy starts at the metabolism's initial value, and cell-change hooks run
+// if the species changes. A State with Wall set turns the cell into a dead
+// wall; any other State clears it.
+func (e *Engine) SetCell(x, y int, s State) {
+	if s.Species < 0 || s.Species >= len(e.Species()) || s.Wall {
+		s = State{Wall: s.Wall}
+	}
+	e.gridMu.RLock()
+	c := e.cell(x, y)
+	e.gridMu.RUnlock()
+
+	s.Age, s.Energy = 0, 0
+	if s.Alive() && e.energy.Enabled {
+		s.Energy = e.energy.Initial
+	}
+	c.lock()
+	old := c.load()
+	c.store(s)
+	c.until.Store(0)
+	c.unlock()
+
+	if s.Species != old.Species {
+		for _, f := range load(&e.hooks.cellChanged) {
+			f(x, y, old, s)
+		}
+	}
+}
+
+// Cell reports the state of the cell at row x, column y.
+func (e *Engine) Cell(x, y int) State {
+	e.gridMu.RLock()
+	defer e.gridMu.RUnlock()
+
+	return e.cell(x, y).state()
+}
+
+// Ticks returns how many ticks the engine has completed.
+func (e *Engine) Ticks() int { return int(e.ticks.Load()) }
+
+// Updates returns how many cell updates the engine has applied since it was
+// created. It reads every cell, so call it sparingly.
+func (e *Engine) Updates() int64 {
+	e.gridMu.RLock()
+	defer e.gridMu.RUnlock()
+
+	var n int64
+	for k := range e.cells {
+		n += e.cells[k].updates.Load()
+	}
+	return n
+}
+
+// History appends to buf the species the cell at row x, column y had after
+// each of its latest updates, oldest first, up to Params.History of them.
+func (e *Engine) History(x, y int, buf []int) []int {
+	e.gridMu.RLock()
+	defer e.gridMu.RUnlock()
+
+	c := e.cell(x, y)
+	c.lock()
+	defer c.unlock()
+	n := len(c.history)
+	for k := range n {
+		buf = append(buf, int(c.history[(c.historyAt+k)%n]))
+	}
+	return buf
+}
+
+// Now returns the simulated time of the engine: the time since it was
+// created, at Params.TimeScale, once Start, StartTiled or StartSynchronous
+// has run it, else the clock of the timed model, or else its ticks times
+// Params.TickInterval. See Species.Refractory.
+func (e *Engine) Now() time.Duration {
+	switch {
+	case e.realTime.Load():
+		return e.sim.now()
+	case e.clock != nil:
+		return e.clock.now
+	default:
+		return time.Duration(e.ticks.Load()) * e.interval
+	}
+}
+
+// Start launches one goroutine per cell, each updating on its own
+// species-dependent reaction time, plus ones running the tick hooks and the
+// turmites. The goroutines run until Stop.
+func (e *Engine) Start() {
+	e.logger.Debug("engine started", "update", "async", "rows", e.rows, "cols", e.cols)
+	e.realTime.Store(true)
+	e.noWorkers()
+	var wg sync.WaitGroup
+	wg.Add(e.rows * e.cols)
+	for k := range e.cells {
+		go e.cells[k].run(&wg)
+	}
+	go e.every(e.interval, func() { e.unlessPaused(e.endTick) })
+	go e.runAgents(e.agentTau)
+}
+
+// StartSynchronous is the synchronous alternative to Start: every interval
+// it computes the next state of every cell, then applies them all at once,
+// sharing the work among GOMAXPROCS goroutines, and steps the turmites and
+// runs the tick hooks. Reaction times are ignored.
+func (e *Engine) StartSynchronous(interval time.Duration) {
+	e.logger.Debug("engine started", "update", "sync", "rows", e.rows, "cols", e.cols, "interval", interval)
+	e.realTime.Store(true)
+	workers := min(runtime.GOMAXPROCS(0), e.rows)
+	go e.every(interval, func() {
+		updated := false
+		e.unlessPaused(func() {
+			e.parallel(workers, (*Cell).computeNextState)
+			e.parallel(workers, (*Cell).applyNextState)
+			e.stepAgents()
+			e.endTick()
+			updated = true
+		})
+		if updated {
+			e.pace(len(e.cells))
+		}
+	})
+}
+
+// every runs f every interval of simulated time until Stop, skipping the
+// runs f falls behind on as a time.Ticker does.
+func (e *Engine) every(interval time.Duration, f func()) {
+	next := e.sim.now() + interval
+	for {
+		e.sim.sleep(next-e.sim.now(), e.done)
+		select {
+		case <-e.done:
+			return
+		default:
+		}
+		f()
+		next = max(next+interval, e.sim.now())
+	}
+}
+
+// Stop ends the goroutines started by Start or StartSynchronous for good,
+// waiting for updates already under way to finish; the engine can still be
+// read, edited and run with RunTicks. Engines that are never stopped run
+// until the process exits.
+func (e *Engine) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.done)
+		e.logger.Debug("engine stopped", "tick", e.ticks.Load())
+	})
+	e.runMu.Lock()
+	e.runMu.Unlock()
+}
+
+// Done returns a channel closed by Stop, for goroutines that watch the
+// engine to end with it.
+func (e *Engine) Done() <-chan struct{} { return e.done }
+
+// SetReactionTime changes the reaction time of species, dead cells
+// included, from the next wait of each cell on, while the engine runs or
+// not.
+func (e *Engine) SetReactionTime(species int, d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("reaction time %v must be positive", d)
+	}
+	e.speciesMu.Lock()
+	defer e.speciesMu.Unlock()
+	old := e.Species()
+	if species < 0 || species >= len(old) {
+		return fmt.Errorf("no species %d", species)
+	}
+	changed := append([]Species(nil), old...)
+	changed[species].ReactionTime = d
+	e.species.Store(&changed)
+	e.logger.Info("reaction time changed", "species", old[species].Name, "from", old[species].ReactionTime, "to", d)
+	return nil
+}
+
+// SetDecay changes the probability of Params.Decay, while the engine runs
+// or not.
+func (e *Engine) SetDecay(p float64) error {
+	if p < 0 || p > 1 {
+		return fmt.Errorf("decay probability %v must be in [0, 1]", p)
+	}
+	old := e.decay.Swap(math.Float64bits(p))
+	e.wakeAll()
+	e.logger.Info("decay changed", "from", math.Float64frombits(old), "to", p)
+	return nil
+}
+
+// MeanReactionTime returns the mean reaction time of the species, dead
+// cells included, a fair interval for StartSynchronous.
+func (e *Engine) MeanReactionTime() time.Duration {
+	var sum time.Duration
+	for _, sp := range e.Species() {
+		sum += sp.ReactionTime
+	}
+	return sum / time.Duration(len(e.Species()))
+}
+
+// Pause stops the cell updates, turmites and tick hooks started by Start
+// until Resume, waiting for updates already under way to finish. RunTicks
+// is not affected.
+func (e *Engine) Pause() {
+	if !e.paused.Swap(true) {
+		e.logger.Debug("engine paused", "tick", e.ticks.Load())
+	}
+	e.runMu.Lock()
+	e.runMu.Unlock()
+}
+
+// Resume undoes Pause.
+func (e *Engine) Resume() {
+	if e.paused.Swap(false) {
+		e.logger.Debug("engine resumed", "tick", e.ticks.Load())
+	}
+}
+
+// Started reports whether Start, StartTiled or StartSynchronous has run
+// the engine, whose tick hooks then run while cells update.
+func (e *Engine) Started() bool { return e.realTime.Load() }
+
+// Paused reports whether the engine is paused.
+func (e *Engine) Paused() bool { return e.paused.Load() }
+
+// unlessPaused runs f unless the engine is paused.
+func (e *Engine) unlessPaused(f func()) {
+	e.runMu.RLock()
+	defer e.runMu.RUnlock()
+	if !e.paused.Load() {
+		f()
+	}
+}
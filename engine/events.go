@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// mergeInterval is how often, in simulated time, the clusters are measured
+// for Merge events, as it takes a flood fill of the grid.
+const mergeInterval = time.Second
+
+// EventKind classifies the entries of the event log.
+type EventKind int
+
+const (
+	// Extinction is a species dying out.
+	Extinction EventKind = iota
+	// Dominance is a species overtaking the most numerous one by a tenth,
+	// so that two close rivals don't log a flip every tick.
+	Dominance
+	// Majority is a species reaching Params.Majority of the live cells.
+	Majority
+	// Merge is a species' largest cluster growing by at least
+	// Params.MergeSize cells since the previous check, made every
+	// mergeInterval, to a size it never had before.
+	Merge
+	// Edit is a change made from outside the engine, such as painting
+	// cells, logged with LogEvent.
+	Edit
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case Extinction:
+		return "extinction"
+	case Dominance:
+		return "dominance"
+	case Majority:
+		return "majority"
+	case Merge:
+		return "merge"
+	case Edit:
+		return "edit"
+	default:
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+}
+
+// Event is an entry of the event log.
+type Event struct {
+	Tick    int
+	Elapsed time.Duration // simulated time, as of Engine.Now
+	Kind    EventKind
+	Species int // the species concerned, 0 if none
+	Text    string
+}
+
+func (ev Event) String() string {
+	return fmt.Sprintf("tick %d  %s  %s", ev.Tick, ev.Elapsed.Truncate(time.Millisecond*100), ev.Text)
+}
+
+// eventLog is the engine's record of its latest events.
+type eventLog struct {
+	mu     sync.Mutex
+	events []Event
+	// Detection state, touched only under tickMu.
+	leader  int           // most numerous species at the previous tick, 0 if none
+	holding []bool        // by species, whether it holds the majority share
+	largest []int         // largest cluster of every species at the previous check
+	record  []int         // and ever
+	checked time.Duration // Elapsed at the previous check, 0 if none
+}
+
+// Events returns the latest Params.Events events, oldest first.
+func (e *Engine) Events() []Event {
+	e.log.mu.Lock()
+	defer e.log.mu.Unlock()
+	return append([]Event(nil), e.log.events...)
+}
+
+// OnEvent registers f to be called with every event as it is logged. f runs
+// on the goroutine that logged it, which is the tick's for the events the
+// engine detects itself.
+func (e *Engine) OnEvent(f func(Event)) {
+	register(&e.hooks.mu, &e.hooks.event, f)
+}
+
+// LogEvent adds an event to the log, stamped with the current tick.
+func (e *Engine) LogEvent(kind EventKind, species int, text string) {
+	e.logEvent(Event{
+		Tick:    int(e.ticks.Load()),
+		Elapsed: e.Now(),
+		Kind:    kind,
+		Species: species,
+		Text:    text,
+	})
+}
+
+func (e *Engine) logEvent(ev Event) {
+	if e.events > 0 {
+		e.log.mu.Lock()
+		if len(e.log.events) == e.events {
+			e.log.events = append(e.log.events[:0], e.log.events[1:]...)
+		}
+		e.log.events = append(e.log.events, ev)
+		e.log.mu.Unlock()
+	}
+	for _, f := range load(&e.hooks.event) {
+		f(ev)
+	}
+}
+
+// detectEvents logs the dominance flips and cluster merges of the tick
+// described by s. It runs under tickMu.
+func (e *Engine) detectEvents(s Stats) {
+	event := func(kind EventKind, species int, format string, args ...any) {
+		e.logEvent(Event{s.Tick, s.Elapsed, kind, species, fmt.Sprintf(format, args...)})
+	}
+
+	top := 0
+	for sp := 1; sp < len(s.Population); sp++ {
+		if s.Population[sp] > 0 && (top == 0 || s.Population[sp] > s.Population[top]) {
+			top = sp
+		}
+	}
+	switch old := e.log.leader; {
+	case top == 0 || top == old:
+	case old == 0:
+		e.log.leader = top
+	case s.Population[top]*10 >= s.Population[old]*11:
+		event(Dominance, top, "%s overtook %s with %d cells to %d",
+			e.Species()[top].Name, e.Species()[old].Name, s.Population[top], s.Population[old])
+		e.log.leader = top
+	}
+
+	if e.majority > 0 {
+		for len(e.log.holding) < len(s.Population) { // hybrids are bred later
+			e.log.holding = append(e.log.holding, false)
+		}
+		live := 0
+		for sp := 1; sp < len(s.Population); sp++ {
+			live += s.Population[sp]
+		}
+		hooks := load(&e.hooks.majority)
+		for sp := 1; sp < len(s.Population); sp++ {
+			share := float64(s.Population[sp]) / float64(max(live, 1))
+			if holds := live > 0 && share >= e.majority; holds && !e.log.holding[sp] {
+				for _, f := range hooks {
+					f(sp, share)
+				}
+				event(Majority, sp, "%s holds %.0f%% of the live cells", e.Species()[sp].Name, 100*share)
+				e.log.holding[sp] = true
+			} else if !holds {
+				e.log.holding[sp] = false
+			}
+		}
+	}
+
+	if e.mergeSize == 0 || e.log.checked != 0 && s.Elapsed-e.log.checked < mergeInterval {
+		return
+	}
+	e.log.checked = s.Elapsed
+	clusters := e.Clusters()
+	for len(e.log.largest) < len(clusters) {
+		e.log.largest, e.log.record = append(e.log.largest, 0), append(e.log.record, 0)
+	}
+	for sp := 1; sp < len(clusters); sp++ {
+		size := clusters[sp].Largest
+		if grown := size - e.log.largest[sp]; grown >= e.mergeSize && size > e.log.record[sp] && s.Tick > 1 {
+			event(Merge, sp, "%s clusters merged into one of %d cells (+%d)", e.Species()[sp].Name, size, grown)
+		}
+		e.log.largest[sp], e.log.record[sp] = size, max(e.log.record[sp], size)
+	}
+}
@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"app/pattern"
+)
+
+// Pattern returns the smallest rectangle of the grid holding every live
+// cell and wall, as a pattern with species ids and pattern.Wall. Its rule
+// is that of the species if they all play the same one.
+func (e *Engine) Pattern() *pattern.Pattern {
+	top, left, bottom, right := e.rows, e.cols, -1, -1
+	for i := range e.rows {
+		for j := range e.cols {
+			if s := e.Cell(i, j); s.Alive() || s.Wall {
+				top, left = min(top, i), min(left, j)
+				bottom, right = max(bottom, i), max(right, j)
+			}
+		}
+	}
+	p := &pattern.Pattern{Width: max(right-left+1, 0), Height: max(bottom-top+1, 0)}
+	for i := top; i <= bottom; i++ {
+		row := make([]int, p.Width)
+		for j := left; j <= right; j++ {
+			switch s := e.Cell(i, j); {
+			case s.Wall:
+				row[j-left] = pattern.Wall
+			default:
+				row[j-left] = s.Species
+			}
+		}
+		p.Cells = append(p.Cells, row)
+	}
+	if _, ok := e.transition.(*speciesRules); !ok {
+		return p
+	}
+	p.Rule = e.Rule(1).String()
+	for sp := 2; sp < len(e.Species()); sp++ {
+		if e.Rule(sp) != e.Rule(1) {
+			p.Rule = ""
+		}
+	}
+	return p
+}
+
+// ExportRLE writes the live cells and walls of the grid to w in RLE, cropped
+// as by Pattern, with a comment naming the species. The output can be read
+// back with pattern.ReadRLE, and by Golly if there is one species and no
+// walls.
+func (e *Engine) ExportRLE(w io.Writer) error {
+	var names []string
+	for sp := 1; sp < len(e.Species()); sp++ {
+		names = append(names, fmt.Sprintf("%c=%s", 'A'+sp-1, e.Species()[sp].Name))
+	}
+	if _, err := fmt.Fprintf(w, "#C species %s, z=wall\n", strings.Join(names, " ")); err != nil {
+		return err
+	}
+	return pattern.WriteRLE(w, e.Pattern())
+}
+
+// Text returns the grid as plain text, a line per row and a character per
+// cell: . for dead cells, # for walls, and for live ones o if there is a
+// single species, or A to X for species 1 to 24 (? beyond).
+func (e *Engine) Text() string {
+	var b strings.Builder
+	b.Grow((e.cols + 1) * e.rows)
+	for i := range e.rows {
+		for j := range e.cols {
+			s := e.Cell(i, j)
+			switch {
+			case s.Wall:
+				b.WriteByte('#')
+			case !s.Alive():
+				b.WriteByte('.')
+			case len(e.Species()) == 2:
+				b.WriteByte('o')
+			case s.Species <= 24:
+				b.WriteByte(byte('A' + s.Species - 1))
+			default:
+				b.WriteByte('?')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"app/hashlife"
+)
+
+// fastForwardNodes is how many distinct squares FastForward remembers
+// before it forgets what it worked out so far.
+const fastForwardNodes = 1 << 22
+
+// The states of a hashlife universe besides the species: a wall, and a cell
+// beyond an alive boundary, which counts as species 1 and never changes.
+const (
+	hashWall = 255
+	hashEdge = 254
+)
+
+// firstRand settles every tie of the species' rules in favour of the
+// species with the lowest id, which is deterministic.
+type firstRand struct{}
+
+func (firstRand) Float64() float64 { return 0 }
+func (firstRand) Intn(int) int     { return 0 }
+func (firstRand) Int63() int64     { return 0 }
+
+// FastForward moves the grid gens generations on at once, as if every cell
+// updated in lockstep, as StartSynchronous does, with HashLife, so that
+// billions of generations of a grid that settles into regular patterns
+// take a moment; the asynchronous engine then carries on from there. Only
+// the species' own B/S rules play, with ties going to the species with the
+// lowest id instead of one at random, and the grid keeps its dead or alive
+// boundary and walls. Other boundaries and anything else the rules do not
+// decide, or decide at random, such as decay, mutation, switching, energy
+// and zones, are refused, and so are rules giving birth on 0 neighbours.
+//
+// Cells ending in another state than they started are set as by SetCell,
+// newborn; the others keep their age. Neither the ticks nor the clock
+// move on. Call it while the engine is paused or not running.
+func (e *Engine) FastForward(gens uint64) error {
+	sr, ok := e.transition.(*speciesRules)
+	switch {
+	case !ok:
+		return errors.New("fast-forward: only the species' own rules can be fast-forwarded")
+	case e.boundary != BoundaryDead && e.boundary != BoundaryAlive:
+		return fmt.Errorf("fast-forward: the %v boundary is not supported, only dead and alive", e.boundary)
+	case len(e.Species()) > hashEdge:
+		return fmt.Errorf("fast-forward: at most %d species are supported", hashEdge-1)
+	case sr.hybrid != nil || e.decay.Load() != 0 || e.mutation > 0 || e.switching != nil || e.sample > 0 || e.lifespan > 0 || e.refractory ||
+		e.energy.Enabled || e.resource.Enabled || e.moving || e.radius > 0 || e.zones != nil || e.flow != nil ||
+		e.halo != nil || e.volume:
+		return errors.New("fast-forward: only the species' B/S rules can be played, without hybrids, decay, mutation, switching, neighbour samples, lifespan, refractory periods, energy, resource, motility, kernels, zones, flow, halos or volumes")
+	}
+	rules := *sr.rules.Load()
+	for id, r := range rules[1:] {
+		if r.Birth[0] {
+			return fmt.Errorf("fast-forward: species %s is born with no neighbours", e.Species()[id+1].Name)
+		}
+	}
+
+	// the rules as they are now, settled deterministically
+	played := &speciesRules{strict: sr.strict, allies: sr.allies}
+	played.rules.Store(&rules)
+	species := len(rules)
+	u := hashlife.New(func(self uint8, n [8]uint8) uint8 {
+		if self >= hashEdge {
+			return self
+		}
+		var counts [hashEdge]int
+		total := 0
+		for _, s := range n {
+			switch s {
+			case 0, hashWall:
+			case hashEdge:
+				counts[1]++
+				total++
+			default:
+				counts[s]++
+				total++
+			}
+		}
+		next := played.next(State{Species: int(self)}, Neighborhood{Counts: counts[:species], Total: total, Rand: firstRand{}}, nil)
+		return uint8(next.Species)
+	}, fastForwardNodes)
+
+	start := make([]State, e.rows*e.cols)
+	for i := range e.rows {
+		for j := range e.cols {
+			s := e.Cell(i, j)
+			start[i*e.cols+j] = s
+			switch {
+			case s.Wall:
+				u.Set(int64(i), int64(j), hashWall)
+			case s.Alive():
+				u.Set(int64(i), int64(j), uint8(s.Species))
+			}
+		}
+	}
+	// Beyond the edge, walls keep cells from being born outside the grid,
+	// within a ring of species 1 under an alive boundary.
+	ring := func(d int, s uint8) {
+		for i := -d; i < e.rows+d; i++ {
+			u.Set(int64(i), int64(-d), s)
+			u.Set(int64(i), int64(e.cols-1+d), s)
+		}
+		for j := -d; j < e.cols+d; j++ {
+			u.Set(int64(-d), int64(j), s)
+			u.Set(int64(e.rows-1+d), int64(j), s)
+		}
+	}
+	if e.boundary == BoundaryAlive {
+		ring(1, hashEdge)
+		ring(2, hashWall)
+	} else {
+		ring(1, hashWall)
+	}
+
+	err := u.Advance(gens)
+	if err != nil && u.Generation() == 0 {
+		return fmt.Errorf("fast-forward: %w", err)
+	}
+	for i := range e.rows {
+		for j := range e.cols {
+			s := start[i*e.cols+j]
+			if s.Wall {
+				continue
+			}
+			if next := int(u.Get(int64(i), int64(j))); next != s.Species {
+				e.SetCell(i, j, State{Species: next})
+			}
+		}
+	}
+	e.LogEvent(Edit, Dead, fmt.Sprintf("fast-forwarded %d generations", u.Generation()))
+	if err != nil {
+		return fmt.Errorf("fast-forward: stopped after %d generations: %w", u.Generation(), err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestFastForward checks that fast-forwarding a grid ends where as many
+// sequential ticks do, under the dead and alive boundaries, with walls and
+// two species.
+func TestFastForward(t *testing.T) {
+	for _, b := range []engine.Boundary{engine.BoundaryDead, engine.BoundaryAlive} {
+		for _, gens := range []int{1, 6, 45, 200} {
+			newEngine := func() *engine.Engine {
+				params := engine.DefaultParams()
+				params.Rows, params.Cols = 30, 37
+				params.Species = []engine.Species{
+					{Name: "a", ReactionTime: time.Millisecond, Rule: engine.MustParseRule("B3/S23")},
+					{Name: "b", ReactionTime: time.Millisecond, Rule: engine.MustParseRule("B36/S23")},
+				}
+				params.Boundary = b
+				params.StrictBirth = true // so that no tie is settled at random
+				params.Rand = engine.NewRand(7)
+				e, err := engine.New(params)
+				if err != nil {
+					t.Fatal(err)
+				}
+				e.Seed(0.4)
+				for i := 5; i < 25; i++ {
+					e.SetCell(i, 18, engine.State{Wall: true})
+				}
+				return e
+			}
+			want, got := newEngine(), newEngine()
+			want.RunTicks(engine.Sequential, gens)
+			if err := got.FastForward(uint64(gens)); err != nil {
+				t.Fatal(err)
+			}
+			if got.Text() != want.Text() {
+				t.Errorf("%v boundary, %d generations:\ngot\n%swant\n%s", b, gens, got.Text(), want.Text())
+			}
+		}
+	}
+}
+
+// TestFastForwardFar checks that an oscillator is in the right phase after
+// a trillion generations.
+func TestFastForwardFar(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 9, 9
+	params.Species = []engine.Species{{Name: "a", ReactionTime: time.Millisecond, Rule: engine.Conway}}
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := 3; j < 6; j++ {
+		e.SetCell(4, j, engine.State{Species: 1})
+	}
+	horizontal := e.Text()
+	if err := e.FastForward(1_000_000_000_001); err != nil {
+		t.Fatal(err)
+	}
+	if e.Text() == horizontal || e.Cell(3, 4).Species != 1 || e.Cell(5, 4).Species != 1 {
+		t.Errorf("blinker in the wrong phase:\n%s", e.Text())
+	}
+	if err := e.FastForward(1_000_000_000_001); err != nil {
+		t.Fatal(err)
+	}
+	if e.Text() != horizontal {
+		t.Errorf("blinker in the wrong phase:\n%s", e.Text())
+	}
+}
+
+// TestFastForwardRefused checks that what HashLife cannot play is refused.
+func TestFastForwardRefused(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Boundary = engine.BoundaryWrap
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.FastForward(10); err == nil {
+		t.Error("fast-forwarded a wrapping grid")
+	}
+	params = engine.DefaultParams()
+	params.Decay = 0.1
+	if e, err = engine.New(params); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.FastForward(10); err == nil {
+		t.Error("fast-forwarded a decaying grid")
+	}
+}
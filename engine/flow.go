@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+)
+
+// A Flow is a vector field over the grid that carries births along, as
+// though the cells lived in a sheared fluid: Flow(x, y) is the velocity at
+// row x, column y, in rows down and columns right, of length at most 1.
+// When a dead cell updates, each live neighbour upstream of it, one whose
+// offset from the cell points against the velocity there, counts twice with
+// probability the share of the velocity along that offset. Populations are
+// born more readily downstream of themselves and so drift with the flow;
+// survival is unaffected.
+type Flow func(x, y int) (dx, dy float64)
+
+// Wind returns the Flow of constant velocity dx, dy.
+func Wind(dx, dy float64) Flow {
+	return func(int, int) (float64, float64) { return dx, dy }
+}
+
+// sampleFlow returns the velocity of f at every cell of a rows by cols
+// grid, indexed as the grid.
+func sampleFlow(f Flow, rows, cols int) ([][2]float64, error) {
+	v := make([][2]float64, rows*cols)
+	for x := range rows {
+		for y := range cols {
+			dx, dy := f(x, y)
+			if s := math.Hypot(dx, dy); !(s <= 1+1e-9) {
+				return nil, fmt.Errorf("flow at %d, %d: speed %v must be at most 1", x, y, s)
+			}
+			v[x*cols+y] = [2]float64{dx, dy}
+		}
+	}
+	return v, nil
+}
+
+// bias counts the live neighbours in n upstream of a dead cell a second
+// time by chance, as the flow has it.
+func (c *Cell) bias(n *Neighborhood) {
+	f := c.e.flow[c.k]
+	if f == [2]float64{} {
+		return
+	}
+	for k, offset := range Moore {
+		s := n.Cells[k]
+		if !s.Alive() || s.Wall || s.Species >= len(n.Counts) || n.Total >= MaxNeighbors {
+			continue
+		}
+		along := -(float64(offset[0])*f[0] + float64(offset[1])*f[1]) / math.Hypot(float64(offset[0]), float64(offset[1]))
+		if along > 0 && n.Rand.Float64() < along {
+			n.Counts[s.Species]++
+			n.Total++
+		}
+	}
+}
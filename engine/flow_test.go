@@ -0,0 +1,51 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestFlow checks that a wind carries births downstream only: a lone cell
+// of a species born with two neighbours grows to its right under a wind
+// blowing right, and not at all without one.
+func TestFlow(t *testing.T) {
+	for _, wind := range []bool{false, true} {
+		params := engine.DefaultParams()
+		params.Rows, params.Cols = 9, 9
+		params.Species = []engine.Species{
+			{Name: "a", ReactionTime: time.Millisecond, Rule: engine.MustParseRule("B2/S012345678")},
+		}
+		if wind {
+			params.Flow = engine.Wind(0, 1)
+		}
+		params.Rand = engine.NewRand(1)
+		e, err := engine.New(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.SetCell(4, 4, engine.State{Species: 1})
+		e.RunTicks(engine.Sequential, 3)
+		right := 0
+		for i := range e.Rows() {
+			for j := range e.Cols() {
+				switch {
+				case !e.Cell(i, j).Alive():
+				case j < 4:
+					t.Errorf("wind %v: cell %d, %d born upstream", wind, i, j)
+				case j > 4:
+					right++
+				}
+			}
+		}
+		if wind && right == 0 || !wind && right != 0 {
+			t.Errorf("wind %v: %d cells born downstream", wind, right)
+		}
+	}
+	params := engine.DefaultParams()
+	params.Flow = engine.Wind(1, 1)
+	if _, err := engine.New(params); err == nil {
+		t.Errorf("a flow faster than 1 was accepted")
+	}
+}
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"time"
+)
+
+// Species ids used by ForestFire.
+const (
+	Tree = 1 + iota
+	Fire
+)
+
+// ForestFireSpecies are the species ForestFire expects, in id order.
+func ForestFireSpecies() []Species {
+	return []Species{
+		{Name: "tree", ReactionTime: 100 * time.Millisecond},
+		{Name: "fire", ReactionTime: 100 * time.Millisecond},
+	}
+}
+
+// ForestFire is the Drossel-Schwabl forest-fire model. On each update an
+// empty (dead) cell grows a tree with probability Growth, a tree catches
+// fire if any neighbour is burning or, failing that, is struck by lightning
+// with probability Lightning, and a burning cell becomes empty.
+type ForestFire struct {
+	Growth, Lightning float64
+}
+
+func (f ForestFire) Next(self State, n Neighborhood) State {
+	switch self.Species {
+	case Dead:
+		if n.Rand.Float64() < f.Growth {
+			return State{Species: Tree}
+		}
+	case Tree:
+		if n.Counts[Fire] > 0 || n.Rand.Float64() < f.Lightning {
+			return State{Species: Fire}
+		}
+	case Fire:
+		return State{}
+	}
+	return self
+}
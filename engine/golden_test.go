@@ -0,0 +1,89 @@
+package engine_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"app/engine"
+	"app/pattern"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestGolden runs Conway's Life synchronously on known patterns and compares
+// the grids reached with the ones stored in testdata/*.golden.
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name       string
+		rows, cols int
+		boundary   engine.Boundary
+		gens       int
+	}{
+		{"blinker", 5, 5, engine.BoundaryDead, 3},
+		{"glider", 8, 8, engine.BoundaryWrap, 4},
+		{"glider", 8, 8, engine.BoundaryWrap, 32},
+		{"glider", 8, 8, engine.BoundaryDead, 30},
+		{"rpentomino", 32, 32, engine.BoundaryDead, 100},
+	}
+	for _, tt := range tests {
+		name := fmt.Sprintf("%s-%s-%d", tt.name, tt.boundary, tt.gens)
+		t.Run(name, func(t *testing.T) {
+			got := run(t, tt.name, tt.rows, tt.cols, tt.boundary, tt.gens)
+			path := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("after %d generations got\n%s\nwant\n%s", tt.gens, got, want)
+			}
+		})
+	}
+}
+
+// run places the pattern testdata/name.rle in the middle of a Life grid,
+// runs it for gens generations and returns the grid as rows of . and o.
+func run(t *testing.T, name string, rows, cols int, boundary engine.Boundary, gens int) string {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name+".rle"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, err := pattern.ReadRLE(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, err := engine.ParseRule(p.Rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = rows, cols
+	params.Species = []engine.Species{{Name: "life", ReactionTime: time.Millisecond, Rule: rule}}
+	params.Boundary = boundary
+	params.Rand = engine.NewRand(1)
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, left := (rows-p.Height)/2, (cols-p.Width)/2
+	for i, row := range p.Cells {
+		for j, v := range row {
+			e.SetCell(top+i, left+j, engine.State{Species: v})
+		}
+	}
+	e.RunTicks(engine.Sequential, gens)
+	return e.Text()
+}
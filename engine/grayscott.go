@@ -0,0 +1,58 @@
+package engine
+
+import "time"
+
+// GrayScottSpecies is the single species GrayScott marks cells with while
+// their V concentration is above GrayScottVisible.
+func GrayScottSpecies() []Species {
+	return []Species{{Name: "reagent", ReactionTime: 5 * time.Millisecond}}
+}
+
+// GrayScottVisible is the V concentration above which a cell counts as
+// alive for statistics and species-based rendering.
+const GrayScottVisible = 0.1
+
+// laplace9 weighs the neighbours in Moore order for a nine-point discrete
+// Laplacian; the centre weight is -1.
+var laplace9 = [8]float64{
+	0.05, 0.2, 0.05,
+	0.2, 0.2,
+	0.05, 0.2, 0.05,
+}
+
+// GrayScott is the Gray-Scott reaction-diffusion model. Each cell holds the
+// concentrations U and V of two chemicals evolving by
+//
+//	dU/dt = Du ∇²U - UV² + Feed (1 - U)
+//	dV/dt = Dv ∇²V + UV² - (Feed + Kill) V
+//
+// integrated with one explicit Euler step of size Dt per update. The grid
+// edge is a zero-flux boundary.
+type GrayScott struct {
+	Du, Dv, Feed, Kill, Dt float64
+}
+
+// DefaultGrayScott produces the classic "mitosis" spots.
+var DefaultGrayScott = GrayScott{Du: 1, Dv: 0.5, Feed: 0.0367, Kill: 0.0649, Dt: 1}
+
+func (g GrayScott) Next(self State, n Neighborhood) State {
+	var lapU, lapV float64
+	for k, w := range laplace9 {
+		u, v := self.U, self.V
+		if n.InGrid[k] {
+			u, v = n.Cells[k].U, n.Cells[k].V
+		}
+		lapU += w * (u - self.U)
+		lapV += w * (v - self.V)
+	}
+	uvv := self.U * self.V * self.V
+	u := self.U + g.Dt*(g.Du*lapU-uvv+g.Feed*(1-self.U))
+	v := self.V + g.Dt*(g.Dv*lapV+uvv-(g.Feed+g.Kill)*self.V)
+	u, v = min(max(u, 0), 1), min(max(v, 0), 1)
+
+	next := State{U: u, V: v}
+	if v > GrayScottVisible {
+		next.Species = 1
+	}
+	return next
+}
@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+)
+
+// halo holds copies of the rows next to a band of a larger grid, kept by
+// the engines of the adjacent bands; see Params.Halo.
+type halo struct {
+	mu          sync.RWMutex
+	top, bottom [][]State // nearest row first
+}
+
+// HaloDepth returns how many rows beyond each edge the cells of e see: 1,
+// or the radius of a Ranged transition.
+func (e *Engine) HaloDepth() int {
+	return max(e.radius, 1)
+}
+
+// EdgeRows copies the HaloDepth rows at the top and bottom of the grid,
+// nearest the edge first, for the halos of the adjacent bands: the top
+// rows are the bottom halo of the band above and the bottom rows the top
+// halo of the band below. It may be called from tick hooks.
+func (e *Engine) EdgeRows() (top, bottom [][]State) {
+	n := min(e.HaloDepth(), e.rows)
+	e.gridMu.RLock()
+	defer e.gridMu.RUnlock()
+	row := func(x int) []State {
+		r := make([]State, e.cols)
+		for y := range r {
+			r[y] = e.cell(x, y).state()
+		}
+		return r
+	}
+	for i := range n {
+		top = append(top, row(i))
+		bottom = append(bottom, row(e.rows-1-i))
+	}
+	return top, bottom
+}
+
+// SetHalo replaces the rows cells see beyond the top and bottom edges of a
+// grid created with Params.Halo, nearest the edge first. Rows missing from
+// either, such as all of them at the edge of the larger grid for a dead or
+// alive boundary, are seen as that boundary's cells.
+func (e *Engine) SetHalo(top, bottom [][]State) error {
+	if e.halo == nil {
+		return fmt.Errorf("engine has no halo")
+	}
+	for _, r := range append(top[:len(top):len(top)], bottom...) {
+		if len(r) != e.cols {
+			return fmt.Errorf("halo row of %d cells, want %d", len(r), e.cols)
+		}
+	}
+	e.halo.mu.Lock()
+	defer e.halo.mu.Unlock()
+	e.halo.top, e.halo.bottom = top, bottom
+	e.wakeAll()
+	return nil
+}
+
+// outside returns the state of a neighbour at (x, y) that at does not map
+// into the grid: a halo cell, with the boundary applied to its column, or
+// the edge state. in reports whether it is a cell of the larger grid other
+// than a wall, as for Neighborhood.InGrid.
+func (e *Engine) outside(x, y int) (s State, in bool) {
+	h := e.halo
+	if h == nil || (x >= 0 && x < e.rows) {
+		return e.edge, false
+	}
+	if y < 0 || y >= e.cols {
+		switch e.boundary {
+		case BoundaryWrap:
+			y = mod(y, e.cols)
+		case BoundaryReflect:
+			y = reflect(y, e.cols)
+		default:
+			return e.edge, false
+		}
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	rows, i := h.bottom, x-e.rows
+	if x < 0 {
+		rows, i = h.top, -x-1
+	}
+	if i >= len(rows) {
+		return e.edge, false
+	}
+	return rows[i][y], !rows[i][y].Wall
+}
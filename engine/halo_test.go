@@ -0,0 +1,94 @@
+package engine_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestHalo checks that a grid split into bands exchanging halos every tick
+// runs exactly like the whole grid, for each boundary.
+func TestHalo(t *testing.T) {
+	const rows, cols, bands, gens = 24, 20, 3, 60
+	rpentomino := [][2]int{{0, 1}, {0, 2}, {1, 0}, {1, 1}, {2, 1}}
+	life := func(rows int, boundary engine.Boundary, halo bool) *engine.Engine {
+		rule, err := engine.ParseRule("B3/S23")
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := engine.DefaultParams()
+		params.Rows, params.Cols = rows, cols
+		params.Species = []engine.Species{{Name: "life", ReactionTime: time.Millisecond, Rule: rule}}
+		params.Boundary, params.Halo = boundary, halo
+		e, err := engine.New(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	for _, boundary := range engine.Boundaries {
+		if boundary == engine.BoundaryKlein || boundary == engine.BoundarySphere {
+			continue // their top and bottom edges join across the bands
+		}
+		t.Run(boundary.String(), func(t *testing.T) {
+			whole := life(rows, boundary, false)
+			var parts []*engine.Engine
+			for range bands {
+				parts = append(parts, life(rows/bands, boundary, true))
+			}
+			// Straddle the first two bands.
+			for _, c := range rpentomino {
+				x, y := rows/bands-1+c[0], cols/2+c[1]
+				whole.SetCell(x, y, engine.State{Species: 1})
+				parts[x/(rows/bands)].SetCell(x%(rows/bands), y, engine.State{Species: 1})
+			}
+			for gen := range gens {
+				exchange(t, parts, boundary)
+				whole.RunTicks(engine.Sequential, 1)
+				var got strings.Builder
+				for _, p := range parts {
+					p.RunTicks(engine.Sequential, 1)
+					got.WriteString(p.Text())
+				}
+				if want := whole.Text(); got.String() != want {
+					t.Fatalf("after %d generations got\n%s\nwant\n%s", gen+1, got.String(), want)
+				}
+			}
+		})
+	}
+}
+
+// exchange sets the halos of bands, top first, from their neighbours' edge
+// rows, as the processes holding them would over the network.
+func exchange(t *testing.T, bands []*engine.Engine, boundary engine.Boundary) {
+	t.Helper()
+	tops, bottoms := make([][][]engine.State, len(bands)), make([][][]engine.State, len(bands))
+	for i, b := range bands {
+		tops[i], bottoms[i] = b.EdgeRows()
+	}
+	n := len(bands)
+	for i, b := range bands {
+		var above, below [][]engine.State
+		switch {
+		case i > 0:
+			above = bottoms[i-1]
+		case boundary == engine.BoundaryWrap:
+			above = bottoms[n-1]
+		case boundary == engine.BoundaryReflect:
+			above = tops[0]
+		}
+		switch {
+		case i < n-1:
+			below = tops[i+1]
+		case boundary == engine.BoundaryWrap:
+			below = tops[0]
+		case boundary == engine.BoundaryReflect:
+			below = bottoms[n-1]
+		}
+		if err := b.SetHalo(above, below); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats summarises the grid at the end of a tick.
+type Stats struct {
+	Tick       int
+	Elapsed    time.Duration // simulated time, as of Now
+	Population []int         // cells per species id; Population[Dead] counts dead cells
+}
+
+// hooks holds registered callbacks. Each list is replaced wholesale on
+// registration so the hot path only needs an atomic load.
+type hooks struct {
+	mu          sync.Mutex // serializes registration
+	cellChanged atomic.Pointer[[]func(x, y int, old, new State)]
+	tick        atomic.Pointer[[]func(Stats)]
+	extinction  atomic.Pointer[[]func(species int)]
+	event       atomic.Pointer[[]func(Event)]
+	majority    atomic.Pointer[[]func(species int, share float64)]
+}
+
+func register[F any](mu *sync.Mutex, list *atomic.Pointer[[]F], f F) {
+	mu.Lock()
+	defer mu.Unlock()
+	var fs []F
+	if old := list.Load(); old != nil {
+		fs = append(fs, *old...)
+	}
+	fs = append(fs, f)
+	list.Store(&fs)
+}
+
+func load[F any](list *atomic.Pointer[[]F]) []F {
+	if fs := list.Load(); fs != nil {
+		return *fs
+	}
+	return nil
+}
+
+// OnCellChanged registers f to be called whenever a cell's species changes
+// (including births and deaths; ageing alone does not count).
+// f runs on the updating cell's goroutine, concurrently with other calls,
+// and must not call back into the engine's grid accessors for that cell.
+func (e *Engine) OnCellChanged(f func(x, y int, old, new State)) {
+	register(&e.hooks.mu, &e.hooks.cellChanged, f)
+}
+
+// OnTick registers f to be called with fresh Stats once per tick: every
+// Params.TickInterval while the engine runs in real time, and after every
+// sweep (or once at the end, for the Goroutines model) in RunTicks.
+func (e *Engine) OnTick(f func(Stats)) {
+	register(&e.hooks.mu, &e.hooks.tick, f)
+}
+
+// OnExtinction registers f to be called when a tick finds no live cells of
+// a species that had some at the previous tick.
+func (e *Engine) OnExtinction(f func(species int)) {
+	register(&e.hooks.mu, &e.hooks.extinction, f)
+}
+
+// OnMajority registers f to be called when a tick finds a species holding
+// at least Params.Majority of the live cells, with its share, after a tick
+// at which it held less.
+func (e *Engine) OnMajority(f func(species int, share float64)) {
+	register(&e.hooks.mu, &e.hooks.majority, f)
+}
+
+// census counts the cells of every species.
+func (e *Engine) census() []int {
+	e.gridMu.RLock()
+	defer e.gridMu.RUnlock()
+
+	pop := make([]int, len(e.Species()))
+	for k := range e.words {
+		s := word(e.words[k].Load()).species()
+		if s >= len(pop) { // a hybrid bred during the count
+			pop = append(pop, make([]int, s+1-len(pop))...)
+		}
+		pop[s]++
+	}
+	if n := len(e.Species()); n > len(pop) {
+		pop = append(pop, make([]int, n-len(pop))...)
+	}
+	return pop
+}
+
+// endTick advances the tick counter, runs the tick and extinction hooks and
+// logs the tick's events.
+func (e *Engine) endTick() {
+	tickHooks, extinctionHooks := load(&e.hooks.tick), load(&e.hooks.extinction)
+	e.tickMu.Lock()
+	defer e.tickMu.Unlock()
+
+	tick := e.ticks.Add(1)
+	if len(tickHooks) == 0 && len(extinctionHooks) == 0 && e.events == 0 &&
+		load(&e.hooks.event) == nil && load(&e.hooks.majority) == nil {
+		return
+	}
+	stats := Stats{Tick: int(tick), Elapsed: e.Now(), Population: e.census()}
+	for _, f := range tickHooks {
+		f(stats)
+	}
+	for s := 1; s < len(stats.Population); s++ {
+		if s < len(e.lastPop) && e.lastPop[s] > 0 && stats.Population[s] == 0 {
+			for _, f := range extinctionHooks {
+				f(s)
+			}
+			e.logEvent(Event{stats.Tick, stats.Elapsed, Extinction, s, e.Species()[s].Name + " died out"})
+		}
+	}
+	e.detectEvents(stats)
+	e.lastPop = stats.Population
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"strings"
+	"time"
+)
+
+// hybrids are the species bred by Params.Hybrids so far, under the
+// engine's speciesMu.
+type hybrids struct {
+	left int            // how many more may be bred
+	ids  map[string]int // by the key of their parents
+}
+
+// hybrid returns the id of the hybrid of parents, which are in increasing
+// order, breeding it if it is the first birth of that tie. It reports false
+// once Params.Hybrids are bred and parents have none yet.
+//
+// A hybrid belongs to no team. It is named after its parents, the names of hybrid ones in
+// parentheses, reacts in their mean reaction time
+// and refractory time, and plays the rule whose birth and survival counts
+// are those of at least half of them. Its rule is registered before the
+// species, so that the rules always cover every species a cell may show.
+func (e *Engine) hybrid(parents []int) (int, bool) {
+	key := make([]byte, 0, 2*len(parents))
+	for _, p := range parents {
+		key = append(key, byte(p>>8), byte(p))
+	}
+	h := &e.hybrids
+	e.speciesMu.Lock()
+	defer e.speciesMu.Unlock()
+	if id, ok := h.ids[string(key)]; ok {
+		return id, true
+	}
+	if h.left == 0 {
+		return 0, false
+	}
+
+	species := e.Species()
+	hy := Species{Parents: append([]int(nil), parents...)}
+	var names []string
+	var birth, survive [MaxNeighbors + 1]int
+	for _, p := range parents {
+		name := species[p].Name
+		if species[p].Parents != nil {
+			name = "(" + name + ")"
+		}
+		names = append(names, name)
+		hy.ReactionTime += species[p].ReactionTime
+		hy.Refractory += species[p].Refractory
+		r := e.Rule(p)
+		for k := range birth {
+			if r.Birth[k] {
+				birth[k]++
+			}
+			if r.Survive[k] {
+				survive[k]++
+			}
+		}
+	}
+	n := len(parents)
+	hy.Name = strings.Join(names, "×")
+	hy.ReactionTime /= time.Duration(n)
+	hy.Refractory /= time.Duration(n)
+	for k := range birth {
+		hy.Rule.Birth[k] = 2*birth[k] >= n
+		hy.Rule.Survive[k] = 2*survive[k] >= n
+	}
+
+	e.transition.(*speciesRules).add(hy.Rule)
+	id := len(species)
+	grown := append(append([]Species(nil), species...), hy)
+	e.species.Store(&grown)
+	if h.ids == nil {
+		h.ids = make(map[string]int)
+	}
+	h.ids[string(key)] = id
+	h.left--
+	e.logger.Info("hybrid bred", "species", hy.Name, "id", id)
+	return id, true
+}
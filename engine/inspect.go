@@ -0,0 +1,39 @@
+package engine
+
+import "time"
+
+// CellInfo is what Inspect tells about a cell, for debugging rules.
+type CellInfo struct {
+	State State
+	// Neighbors are its live neighbours now, as its next update sees
+	// them: under Params.Sample, a sample drawn as for an update.
+	Neighbors Neighborhood
+	Updates   int64 // applied to it so far
+	// LastUpdate and NextUpdate are the times, on the engine's clock as
+	// Now tells it, of the cell's latest update and of the next one
+	// scheduled, 0 for none yet. Synchronous updates schedule none, as
+	// every cell is updated at once.
+	LastUpdate, NextUpdate time.Duration
+}
+
+// Inspect returns the state, neighbourhood and timing of the cell at
+// (x, y).
+func (e *Engine) Inspect(x, y int) CellInfo {
+	c := e.cell(x, y)
+	// a cell of its own, as the scratch space of c is its goroutine's
+	probe := &Cell{x: c.x, y: c.y, k: c.k, e: e}
+	return CellInfo{
+		State:      e.Cell(x, y),
+		Neighbors:  probe.countAliveNeighbors(),
+		Updates:    c.updates.Load(),
+		LastUpdate: time.Duration(c.lastAt.Load()),
+		NextUpdate: time.Duration(c.dueAt.Load()),
+	}
+}
+
+// schedule notes for Inspect that c is due again after d, from now on the
+// engine's clock, and returns d.
+func (c *Cell) schedule(d time.Duration) time.Duration {
+	c.dueAt.Store(int64(c.e.Now() + d))
+	return d
+}
@@ -0,0 +1,43 @@
+package engine_test
+
+import (
+	"testing"
+
+	"app/engine"
+)
+
+// TestInspect checks that Inspect counts the live neighbours of a cell and
+// places its latest and next updates around the engine's clock.
+func TestInspect(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 6, 6
+	params.Rand = engine.NewRand(1)
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range [][2]int{{1, 1}, {1, 2}, {2, 1}} {
+		e.SetCell(p[0], p[1], engine.State{Species: 1})
+	}
+	info := e.Inspect(2, 2)
+	if info.Neighbors.Total != 3 || info.Neighbors.Counts[1] != 3 {
+		t.Errorf("neighbours %d, %v of species 1, want 3", info.Neighbors.Total, info.Neighbors.Counts)
+	}
+	if info.Updates != 0 || info.LastUpdate != 0 {
+		t.Errorf("%d updates, the latest at %v, before running", info.Updates, info.LastUpdate)
+	}
+
+	e.RunTicks(engine.Timed, 3)
+	now := e.Now()
+	for x := range params.Rows {
+		for y := range params.Cols {
+			info := e.Inspect(x, y)
+			if info.Updates == 0 {
+				continue
+			}
+			if info.LastUpdate <= 0 || info.LastUpdate > now || info.NextUpdate < now {
+				t.Errorf("cell %d, %d: latest update at %v, next at %v, against %v now", x, y, info.LastUpdate, info.NextUpdate, now)
+			}
+		}
+	}
+}
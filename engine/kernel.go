@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+)
+
+// A Kernel weighs the neighbours of a cell when their live cells are
+// counted: a square matrix of odd side centred on the cell, its first row
+// above it, whose centre, the cell itself, is 0. It need not be symmetric,
+// so that only the cells on one side may count and growth then runs away
+// from it. An example is the radius-2 Moore neighbourhood whose outer ring
+// counts half
+//
+//	0.5 0.5 0.5 0.5 0.5
+//	0.5  1   1   1  0.5
+//	0.5  1   0   1  0.5
+//	0.5  1   1   1  0.5
+//	0.5 0.5 0.5 0.5 0.5
+//
+// The weighted count of each species is rounded to a whole count by chance,
+// up with probability its fraction, so that 2.3 neighbours count 3 one
+// time in three and rules respond smoothly to distant neighbours.
+type Kernel [][]float64
+
+// FarKernel returns the radius-2 Moore kernel whose nearest ring counts 1
+// and whose outer ring counts far.
+func FarKernel(far float64) Kernel {
+	k := make(Kernel, 5)
+	for i := range k {
+		k[i] = make([]float64, 5)
+		for j := range k[i] {
+			switch {
+			case i == 2 && j == 2:
+			case i == 0 || i == 4 || j == 0 || j == 4:
+				k[i][j] = far
+			default:
+				k[i][j] = 1
+			}
+		}
+	}
+	return k
+}
+
+// Radius returns the number of rows and columns k reaches on either side of
+// the cell.
+func (k Kernel) Radius() int { return len(k) / 2 }
+
+// validate checks the shape and weights of k.
+func (k Kernel) validate() error {
+	if len(k) < 3 || len(k)%2 == 0 {
+		return fmt.Errorf("kernel: %d rows, not an odd number from 3", len(k))
+	}
+	r := k.Radius()
+	for i, row := range k {
+		if len(row) != len(k) {
+			return fmt.Errorf("kernel: row %d has %d weights, not %d", i+1, len(row), len(k))
+		}
+		for j, w := range row {
+			switch {
+			case i == r && j == r && w != 0:
+				return fmt.Errorf("kernel: the centre is the cell itself and must be 0, not %v", w)
+			case w < 0 || math.IsNaN(w) || math.IsInf(w, 0):
+				return fmt.Errorf("kernel: weight %v in row %d is not a non-negative number", w, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// weights returns the weights of k in the order of Offsets(radius), which
+// is at least k's radius.
+func (k Kernel) weights(radius int) []float64 {
+	r := k.Radius()
+	offsets := Offsets(radius)
+	w := make([]float64, len(offsets))
+	for i, o := range offsets {
+		if max(o[0], -o[0], o[1], -o[1]) <= r {
+			w[i] = k[r+o[0]][r+o[1]]
+		}
+	}
+	return w
+}
+
+// weigh replaces the counts of n by those weighed with the engine's kernel
+// over n.Far.
+func (c *Cell) weigh(n *Neighborhood) {
+	if len(c.weighted) != len(n.Counts) {
+		c.weighted = make([]float64, len(n.Counts))
+	}
+	clear(c.weighted)
+	for k, s := range n.Far {
+		if w := c.e.kernel[k]; w > 0 && s.Alive() && !s.Wall {
+			if s.Species >= len(c.weighted) { // a hybrid bred since
+				c.weighted = append(c.weighted, make([]float64, s.Species+1-len(c.weighted))...)
+				c.counts = append(c.counts, make([]int, s.Species+1-len(c.counts))...)
+				n.Counts = c.counts
+			}
+			c.weighted[s.Species] += w
+		}
+	}
+	n.Total = 0
+	for s, w := range c.weighted {
+		count := int(w)
+		if f := w - float64(count); f > 0 && n.Rand.Float64() < f {
+			count++
+		}
+		count = min(count, MaxNeighbors-n.Total)
+		n.Counts[s] = count
+		n.Total += count
+	}
+}
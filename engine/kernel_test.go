@@ -0,0 +1,83 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestKernel checks that the radius-2 kernel whose outer ring counts
+// nothing plays exactly as the plain Moore neighbourhood, under every
+// deterministic model, and that malformed kernels are refused.
+func TestKernel(t *testing.T) {
+	for _, m := range engine.Models {
+		if m == engine.Goroutines {
+			continue // cells race each other from one tick to the next
+		}
+		t.Run(m.String(), func(t *testing.T) {
+			var text [2]string
+			for i, kernel := range []engine.Kernel{nil, engine.FarKernel(0)} {
+				params := engine.DefaultParams()
+				params.Rows, params.Cols = 16, 16
+				params.Kernel = kernel
+				params.Rand = engine.NewRand(1)
+				e, err := engine.New(params)
+				if err != nil {
+					t.Fatal(err)
+				}
+				e.Seed(0.4)
+				e.RunTicks(m, 10)
+				text[i] = e.Text()
+			}
+			if text[0] != text[1] {
+				t.Errorf("with the kernel\n%s\nwithout\n%s", text[1], text[0])
+			}
+		})
+	}
+	for _, kernel := range []engine.Kernel{
+		{{1, 1}, {1, 1}},
+		{{1, 1, 1}, {1, 1, 1}, {1, 1, 1}},
+		{{1, 1, 1}, {1, 0}, {1, 1, 1}},
+		{{1, 1, 1}, {1, 0, -1}, {1, 1, 1}},
+	} {
+		params := engine.DefaultParams()
+		params.Kernel = kernel
+		if _, err := engine.New(params); err == nil {
+			t.Errorf("kernel %v was accepted", kernel)
+		}
+	}
+}
+
+// TestKernelAnisotropic checks that a kernel counting only the cells above
+// makes growth fall: a lone cell of a species born with one neighbour
+// spreads down the grid and never up.
+func TestKernelAnisotropic(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 9, 9
+	params.Species = []engine.Species{{Name: "a", ReactionTime: time.Millisecond, Rule: engine.MustParseRule("B1/S012345678")}}
+	params.Kernel = engine.Kernel{{1, 1, 1}, {0, 0, 0}, {0, 0, 0}}
+	params.Boundary = engine.BoundaryDead
+	params.Rand = engine.NewRand(1)
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetCell(4, 4, engine.State{Species: 1})
+	e.RunTicks(engine.Sequential, 3)
+	below := 0
+	for i := range e.Rows() {
+		for j := range e.Cols() {
+			switch {
+			case !e.Cell(i, j).Alive():
+			case i < 4:
+				t.Errorf("cell %d, %d born above", i, j)
+			case i > 4:
+				below++
+			}
+		}
+	}
+	if below == 0 {
+		t.Errorf("no cell born below")
+	}
+}
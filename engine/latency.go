@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// Latency is the distribution of the intervals between the updates of the
+// cells of a species measured in simulated time, at Params.TimeScale, as returned by
+// Engine.Latencies, against which the nominal reaction time can be
+// checked: the scheduler, Params.Jitter and Params.Drift and a loaded
+// machine all move the intervals away from it. Quantiles are accurate to
+// within about 1%.
+type Latency struct {
+	Count               int
+	Nominal             time.Duration // the species' reaction time now
+	Mean, P50, P90, P99 time.Duration
+	Min, Max            time.Duration
+}
+
+// latencySub is the number of bins of a histogram per doubling of the
+// interval, in microseconds, and latencyBins their total, up to 2^41µs.
+const (
+	latencySub  = 64
+	latencyBins = (41 - 5) * latencySub
+)
+
+// latencyBin returns the bin of an interval of us microseconds.
+func latencyBin(us uint64) int {
+	if us < latencySub {
+		return int(us)
+	}
+	n := bits.Len64(us)
+	return min((n-6)*latencySub+int(us>>(n-7))-latencySub, latencyBins-1)
+}
+
+// latencyFloor returns the shortest interval, in microseconds, of bin i.
+func latencyFloor(i int) uint64 {
+	if i < latencySub {
+		return uint64(i)
+	}
+	n := i/latencySub + 6
+	return uint64(i%latencySub+latencySub) << (n - 7)
+}
+
+// latencyHist is the histogram of the intervals of one species.
+type latencyHist struct {
+	bins          [latencyBins]atomic.Int64
+	count, sum    atomic.Int64 // sum in microseconds
+	lowest, worst atomic.Int64 // in microseconds, lowest 0 until the first
+}
+
+// latencies holds a histogram per species id, made on its first interval.
+type latencies [maxSpecies]atomic.Pointer[latencyHist]
+
+// record adds an interval of d between updates of a cell of species.
+func (l *latencies) record(species int, d time.Duration) {
+	h := l[species].Load()
+	if h == nil {
+		l[species].CompareAndSwap(nil, new(latencyHist))
+		h = l[species].Load()
+	}
+	us := max(d.Microseconds(), 0)
+	h.bins[latencyBin(uint64(us))].Add(1)
+	h.count.Add(1)
+	h.sum.Add(us)
+	for old := h.worst.Load(); us > old && !h.worst.CompareAndSwap(old, us); old = h.worst.Load() {
+	}
+	for old := h.lowest.Load(); (old == 0 || us < old) && !h.lowest.CompareAndSwap(old, us); old = h.lowest.Load() {
+	}
+}
+
+// measure records the simulated time since c's previous update for
+// Latencies, and how late this one is for Late, and marks it. The goroutine
+// scheduling c calls it as it updates c; skip forgets the previous update,
+// when a pause came between them.
+func (c *Cell) measure() {
+	now := c.e.sim.now()
+	if l := &c.e.lateness; l.tolerance > 0 {
+		l.record(now - time.Duration(c.dueAt.Load()))
+	}
+	if c.updatedAt != 0 {
+		c.e.latencies.record(word(c.e.words[c.k].Load()).species(), now-c.updatedAt)
+	}
+	c.updatedAt = now
+}
+
+func (c *Cell) skip() { c.updatedAt = 0 }
+
+// Latencies returns the distribution of the intervals between the updates
+// of the cells of every species id, dead cells included, measured since
+// the engine started, in simulated time. Only Start and StartTiled measure
+// them: RunTicks follows its schedule exactly, on a simulated clock, and
+// StartSynchronous updates every cell every interval. An interval counts
+// towards the species the cell was at its end.
+func (e *Engine) Latencies() []Latency {
+	species := e.Species()
+	ls := make([]Latency, len(species))
+	for id := range ls {
+		ls[id].Nominal = species[id].ReactionTime
+		h := e.latencies[id].Load()
+		if h == nil {
+			continue
+		}
+		l := &ls[id]
+		var bins [latencyBins]int64
+		n := int64(0)
+		for i := range bins {
+			bins[i] = h.bins[i].Load()
+			n += bins[i]
+		}
+		if n == 0 {
+			continue
+		}
+		l.Count = int(n)
+		l.Mean = time.Duration(h.sum.Load()/max(h.count.Load(), 1)) * time.Microsecond
+		l.Min = time.Duration(h.lowest.Load()) * time.Microsecond
+		l.Max = time.Duration(h.worst.Load()) * time.Microsecond
+		quantile := func(q float64) time.Duration {
+			seen, want := int64(0), int64(q*float64(n))
+			for i, c := range bins {
+				if seen += c; seen > want {
+					mid := time.Duration((latencyFloor(i)+latencyFloor(i+1))/2) * time.Microsecond
+					return min(max(mid, l.Min), l.Max)
+				}
+			}
+			return l.Max
+		}
+		l.P50, l.P90, l.P99 = quantile(0.5), quantile(0.9), quantile(0.99)
+	}
+	return ls
+}
+
+// lateness counts the updates that came over tolerance after they were
+// due.
+type lateness struct {
+	tolerance      time.Duration
+	measured, late atomic.Int64
+	worst          atomic.Int64 // the latest of the late ones, in nanoseconds
+}
+
+func (l *lateness) record(d time.Duration) {
+	l.measured.Add(1)
+	if d <= l.tolerance {
+		return
+	}
+	l.late.Add(1)
+	for old := l.worst.Load(); int64(d) > old && !l.worst.CompareAndSwap(old, int64(d)); old = l.worst.Load() {
+	}
+}
+
+// Late returns how many of the updates of Start and StartTiled came more
+// than Params.LateTolerance after they were due, on the simulated clock, of
+// how many measured, and how late the latest of them was, all 0 unless
+// LateTolerance is set. Late updates mean that the scheduler or
+// Params.Throttle held the cells back: the grid then runs slower than its
+// reaction times say, and the races between neighbours play out
+// differently.
+func (e *Engine) Late() (late, measured int64, worst time.Duration) {
+	l := &e.lateness
+	return l.late.Load(), l.measured.Load(), time.Duration(l.worst.Load())
+}
@@ -0,0 +1,85 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestLatencies checks that Start measures intervals between updates close
+// to the reaction time of every species, and that RunTicks measures none.
+func TestLatencies(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 8, 8
+	params.Rand = engine.NewRand(1)
+	params.DeadReactionTime = 20 * time.Millisecond
+	for k := range params.Species {
+		params.Species[k].ReactionTime = 20 * time.Millisecond
+	}
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Seed(0.4)
+	e.RunTicks(engine.Timed, 5)
+	for id, l := range e.Latencies() {
+		if l.Count != 0 {
+			t.Errorf("species %d: %d intervals measured by RunTicks", id, l.Count)
+		}
+	}
+
+	e.Start()
+	time.Sleep(300 * time.Millisecond)
+	e.Stop()
+	n := 0
+	for id, l := range e.Latencies() {
+		n += l.Count
+		if l.Count == 0 {
+			continue
+		}
+		if l.Nominal != 20*time.Millisecond {
+			t.Errorf("species %d: nominal %v, want 20ms", id, l.Nominal)
+		}
+		// Sleeps overshoot, never undershoot, by however long a loaded
+		// machine takes to wake the goroutine.
+		if l.P50 < 19*time.Millisecond || l.P50 > 200*time.Millisecond {
+			t.Errorf("species %d: median interval %v against 20ms", id, l.P50)
+		}
+		if !(l.Min <= l.P50 && l.P50 <= l.P90 && l.P90 <= l.P99 && l.P99 <= l.Max) {
+			t.Errorf("species %d: min %v, p50 %v, p90 %v, p99 %v, max %v out of order", id, l.Min, l.P50, l.P90, l.P99, l.Max)
+		}
+	}
+	if n == 0 {
+		t.Errorf("no intervals measured by Start")
+	}
+}
+
+// TestLate checks that updates held back by a throttle count as late, and
+// that none are counted without a tolerance.
+func TestLate(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 10, 10
+	params.DeadReactionTime = time.Millisecond
+	for k := range params.Species {
+		params.Species[k].ReactionTime = time.Millisecond
+	}
+	params.Throttle = engine.NewThrottle(2000) // of the 100000 a second due
+	for _, tolerance := range []time.Duration{0, 5 * time.Millisecond} {
+		params.LateTolerance = tolerance
+		e, err := engine.New(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.StartTiled()
+		time.Sleep(200 * time.Millisecond)
+		e.Stop()
+		late, measured, worst := e.Late()
+		switch {
+		case tolerance == 0 && measured != 0:
+			t.Errorf("%d updates measured without a tolerance", measured)
+		case tolerance > 0 && (measured == 0 || late < measured/2 || worst <= tolerance):
+			t.Errorf("%d late of %d, the latest by %v, with a throttle far below the reaction times", late, measured, worst)
+		}
+	}
+}
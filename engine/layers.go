@@ -0,0 +1,26 @@
+package engine
+
+import "fmt"
+
+// Stack links engines into layers, bottom first, so that every cell's
+// Transition sees the corresponding cell of the layers directly below and
+// above it in Neighborhood.Below and Neighborhood.Above. All layers must
+// have the same size; each keeps its own species, rules and clocks. Call
+// Stack before starting any of the layers.
+func Stack(layers ...*Engine) error {
+	for i, e := range layers {
+		if e.rows != layers[0].rows || e.cols != layers[0].cols {
+			return fmt.Errorf("layer %d is %dx%d, want %dx%d", i, e.rows, e.cols, layers[0].rows, layers[0].cols)
+		}
+	}
+	for i, e := range layers {
+		e.below, e.above = nil, nil
+		if i > 0 {
+			e.below = layers[i-1]
+		}
+		if i+1 < len(layers) {
+			e.above = layers[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"math"
+	"time"
+)
+
+// LeniaSpecies is the single species Lenia marks cells with while their
+// state is above LeniaVisible.
+func LeniaSpecies() []Species {
+	return []Species{{Name: "lenia", ReactionTime: 20 * time.Millisecond}}
+}
+
+// LeniaVisible is the state above which a Lenia cell counts as alive.
+const LeniaVisible = 0.1
+
+// Lenia is a continuous cellular automaton in the style of Lenia and
+// SmoothLife. Each cell holds a state in [0, 1] in State.U. On each update
+// the states within Radius are averaged with a smooth ring-shaped kernel,
+// the average u is passed through the growth function
+//
+//	G(u) = 2 exp(-(u-Mu)² / 2Sigma²) - 1
+//
+// and the cell's state moves by Dt·G(u). Build one with NewLenia.
+type Lenia struct {
+	radius        int
+	mu, sigma, dt float64
+	kernel        []float64 // weights in Offsets(radius) order, summing to 1
+}
+
+// NewLenia returns a Lenia rule with the given kernel radius in cells and
+// growth parameters.
+func NewLenia(radius int, mu, sigma, dt float64) *Lenia {
+	l := &Lenia{radius: max(radius, 1), mu: mu, sigma: sigma, dt: dt}
+	offsets := Offsets(l.radius)
+	l.kernel = make([]float64, len(offsets))
+	var sum float64
+	for k, o := range offsets {
+		r := math.Hypot(float64(o[0]), float64(o[1])) / float64(l.radius)
+		if r > 0 && r < 1 {
+			l.kernel[k] = math.Exp(4 - 1/(r*(1-r)))
+			sum += l.kernel[k]
+		}
+	}
+	for k := range l.kernel {
+		l.kernel[k] /= sum
+	}
+	return l
+}
+
+func (l *Lenia) Radius() int { return l.radius }
+
+func (l *Lenia) Next(self State, n Neighborhood) State {
+	var u float64
+	for k, w := range l.kernel {
+		u += w * n.Far[k].U
+	}
+	d := (u - l.mu) / l.sigma
+	growth := 2*math.Exp(-d*d/2) - 1
+	a := min(max(self.U+l.dt*growth, 0), 1)
+
+	next := State{U: a}
+	if a > LeniaVisible {
+		next.Species = 1
+	}
+	return next
+}
@@ -0,0 +1,38 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestLifespan checks that no live cell outlives the lifespan, under every
+// model.
+func TestLifespan(t *testing.T) {
+	const lifespan = 5
+	for _, m := range engine.Models {
+		t.Run(m.String(), func(t *testing.T) {
+			params := engine.DefaultParams()
+			params.Rows, params.Cols = 16, 16
+			params.Species = []engine.Species{
+				{Name: "a", ReactionTime: time.Millisecond, Rule: engine.MustParseRule("B3/S012345678")},
+			}
+			params.Lifespan = lifespan
+			params.Rand = engine.NewRand(1)
+			e, err := engine.New(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.Seed(0.5)
+			e.RunTicks(m, 30)
+			for i := range e.Rows() {
+				for j := range e.Cols() {
+					if s := e.Cell(i, j); s.Alive() && s.Age > lifespan {
+						t.Fatalf("cell %d, %d is %d updates old", i, j, s.Age)
+					}
+				}
+			}
+		})
+	}
+}
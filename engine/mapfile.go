@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"unsafe"
+)
+
+// A map file of Params.MapFile starts with mapMagic and the number of rows
+// and columns, then holds the word of every cell, row by row, all
+// little-endian: 4 bytes a cell, so that a grid of 100,000 cells square
+// takes 40 GB.
+const (
+	mapMagic  = "NNCAMAP1"
+	mapHeader = len(mapMagic) + 8
+)
+
+// errMapEndian is returned where the words of a map file would not read
+// as they are in memory.
+var errMapEndian = errors.New("map files need a little-endian machine")
+
+func mapSize(rows, cols int) int64 { return int64(mapHeader) + 4*int64(rows)*int64(cols) }
+
+func mapHeaderOf(rows, cols int) []byte {
+	h := make([]byte, mapHeader)
+	copy(h, mapMagic)
+	binary.LittleEndian.PutUint32(h[len(mapMagic):], uint32(rows))
+	binary.LittleEndian.PutUint32(h[len(mapMagic)+4:], uint32(cols))
+	return h
+}
+
+// readMapHeader returns the grid size in the header of a map file.
+func readMapHeader(r io.Reader) (rows, cols int, err error) {
+	h := make([]byte, mapHeader)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return 0, 0, fmt.Errorf("not a map file: %w", err)
+	}
+	if !bytes.Equal(h[:len(mapMagic)], []byte(mapMagic)) {
+		return 0, 0, errors.New("not a map file")
+	}
+	return int(binary.LittleEndian.Uint32(h[len(mapMagic):])), int(binary.LittleEndian.Uint32(h[len(mapMagic)+4:])), nil
+}
+
+// mapWords backs the words of the grid with the map file at path; see
+// Params.MapFile.
+func (e *Engine) mapWords(path string) error {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		return errMapEndian
+	}
+	f, data, loaded, err := mapFile(path, e.rows, e.cols)
+	if err != nil {
+		return fmt.Errorf("map file %s: %w", path, err)
+	}
+	words := unsafe.Slice((*atomic.Uint32)(unsafe.Pointer(&data[mapHeader])), e.rows*e.cols)
+	if loaded {
+		for k := range words {
+			if s := word(words[k].Load()).species(); s >= e.base {
+				unmap(data)
+				f.Close()
+				return fmt.Errorf("map file %s: cell %d,%d is of species %d, beyond the %d species", path, k/e.cols, k%e.cols, s, e.base-1)
+			}
+		}
+	}
+	e.mapLock, e.mapped, e.mapLoaded, e.words = f, data, loaded, words
+	return nil
+}
+
+// MapLoaded reports whether the engine started from the grid in its map
+// file, which then needs no seeding.
+func (e *Engine) MapLoaded() bool { return e.mapLoaded }
+
+// Sync writes the changes to the grid so far through to its map file, and
+// so makes the file a saved state of it as of now; without
+// Params.MapFile it does nothing. The operating system writes them anyway
+// in its own time, even if the process ends without a Sync.
+func (e *Engine) Sync() error {
+	if e.mapped == nil {
+		return nil
+	}
+	return syncMap(e.mapped)
+}
+
+// Close stops the engine and, with Params.MapFile, unmaps the grid, which
+// the file keeps, and releases the file for other engines. The engine must
+// not be used after Close.
+func (e *Engine) Close() error {
+	e.Stop()
+	if e.mapped == nil {
+		return nil
+	}
+	err := unmap(e.mapped)
+	if cerr := e.mapLock.Close(); err == nil {
+		err = cerr
+	}
+	e.mapped, e.mapLock, e.words = nil, nil, nil
+	return err
+}
+
+// ReadMap reads the grid of a map file, which holds the species, age and
+// wall of every cell but nothing else of their state nor any turmites.
+func ReadMap(path string) (Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer f.Close()
+	rows, cols, err := readMapHeader(f)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if fi, err := f.Stat(); err != nil {
+		return Snapshot{}, err
+	} else if fi.Size() != mapSize(rows, cols) {
+		return Snapshot{}, fmt.Errorf("%s: %d bytes for a grid of %dx%d, not %d", path, fi.Size(), rows, cols, mapSize(rows, cols))
+	}
+	r := io.NewSectionReader(f, int64(mapHeader), 4*int64(rows)*int64(cols))
+	row := make([]byte, 4*cols)
+	s := Snapshot{Cells: make([][]State, rows)}
+	for i := range rows {
+		if _, err := io.ReadFull(r, row); err != nil {
+			return Snapshot{}, fmt.Errorf("%s: %w", path, err)
+		}
+		s.Cells[i] = make([]State, cols)
+		for j := range cols {
+			w := word(binary.LittleEndian.Uint32(row[4*j:]))
+			s.Cells[i][j] = State{Species: w.species(), Age: w.age(), Wall: w.wall()}
+		}
+	}
+	return s, nil
+}
+
+// WriteMap writes the cells of s to a map file at path, which an engine
+// can then start from with Params.MapFile. Only their species, ages and
+// walls are written.
+func WriteMap(path string, s Snapshot) (err error) {
+	rows, cols := len(s.Cells), 0
+	if rows > 0 {
+		cols = len(s.Cells[0])
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if _, err := f.Write(mapHeaderOf(rows, cols)); err != nil {
+		return err
+	}
+	row := make([]byte, 4*cols)
+	for _, cells := range s.Cells {
+		for j, c := range cells[:cols] {
+			binary.LittleEndian.PutUint32(row[4*j:], uint32(pack(c.Wall, c.Species, c.Age)))
+		}
+		if _, err := f.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !unix || aix
+
+package engine
+
+import (
+	"errors"
+	"os"
+)
+
+var errMapUnsupported = errors.New("map files need a Unix system")
+
+func mapFile(path string, rows, cols int) (*os.File, []byte, bool, error) {
+	return nil, nil, false, errMapUnsupported
+}
+
+func syncMap(data []byte) error { return errMapUnsupported }
+
+func unmap(data []byte) error { return errMapUnsupported }
@@ -0,0 +1,98 @@
+package engine_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestMapFile checks that a grid backed by a map file starts from it when
+// reopened, that the file reads as a saved state, and that a file in use,
+// of another grid or of something else is refused until closed.
+func TestMapFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "grid.nnmap")
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 12, 20
+	params.Species = []engine.Species{
+		{Name: "a", ReactionTime: time.Millisecond, Rule: engine.Conway},
+		{Name: "b", ReactionTime: time.Millisecond, Rule: engine.Conway},
+	}
+	params.Rand = engine.NewRand(1)
+	params.MapFile = path
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.MapLoaded() {
+		t.Error("a new map file counts as loaded")
+	}
+	e.Seed(0.4)
+	e.SetCell(0, 0, engine.State{Wall: true})
+	e.RunTicks(engine.Sequential, 3)
+	if err := e.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	want := e.Snapshot()
+
+	saved, err := engine.ReadMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(saved.Cells, want.Cells) {
+		t.Errorf("map file read as\n%v\nnot\n%v", saved.Cells, want.Cells)
+	}
+
+	// a copy, as the original stays locked while e is around
+	copied := filepath.Join(t.TempDir(), "copy.nnmap")
+	if err := engine.WriteMap(copied, saved); err != nil {
+		t.Fatal(err)
+	}
+	params.MapFile = copied
+	reopened, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reopened.MapLoaded() {
+		t.Error("a map file of the grid does not count as loaded")
+	}
+	if got := reopened.Snapshot(); !reflect.DeepEqual(got.Cells, want.Cells) {
+		t.Errorf("reopened as\n%s\nnot\n%s", reopened.Text(), e.Text())
+	}
+
+	other := filepath.Join(t.TempDir(), "other.nnmap")
+	if err := os.WriteFile(other, []byte("not a grid"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	params.MapFile = other
+	if _, err := engine.New(params); err == nil {
+		t.Error("accepted a file that is not a map file")
+	}
+	params.MapFile = path
+	if _, err := engine.New(params); err == nil {
+		t.Error("mapped a map file in use")
+	}
+	smaller := filepath.Join(t.TempDir(), "smaller.nnmap")
+	if err := engine.WriteMap(smaller, engine.Snapshot{Cells: saved.Cells[1:]}); err != nil {
+		t.Fatal(err)
+	}
+	params.MapFile = smaller
+	if _, err := engine.New(params); err == nil {
+		t.Error("accepted a map file of another grid size")
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	params.MapFile = path
+	again, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.MapLoaded() {
+		t.Error("a closed map file does not count as loaded")
+	}
+}
@@ -0,0 +1,65 @@
+//go:build unix && !aix
+
+package engine
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mapFile maps the map file at path for a grid of rows by cols, creating
+// it if it is empty or missing, and reports whether it held a grid already.
+// The file is returned open and locked, so that no other engine maps it as
+// long as it stays open.
+func mapFile(path string, rows, cols int) (_ *os.File, data []byte, loaded bool, err error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+		}
+	}()
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		return nil, nil, false, fmt.Errorf("in use by another engine: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	size := mapSize(rows, cols)
+	if fi.Size() > 0 {
+		r, c, err := readMapHeader(f)
+		switch {
+		case err != nil:
+			return nil, nil, false, err
+		case r != rows || c != cols:
+			return nil, nil, false, fmt.Errorf("holds a grid of %dx%d, not %dx%d", r, c, rows, cols)
+		case fi.Size() != size:
+			return nil, nil, false, fmt.Errorf("%d bytes for a grid of %dx%d, not %d", fi.Size(), rows, cols, size)
+		}
+		loaded = true
+	} else {
+		if err := f.Truncate(size); err != nil {
+			return nil, nil, false, err
+		}
+		if _, err := f.WriteAt(mapHeaderOf(rows, cols), 0); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	if size != int64(int(size)) {
+		return nil, nil, false, fmt.Errorf("a grid of %dx%d is too large to map", rows, cols)
+	}
+	data, err = unix.Mmap(int(f.Fd()), 0, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return f, data, loaded, nil
+}
+
+func syncMap(data []byte) error { return unix.Msync(data, unix.MS_SYNC) }
+
+func unmap(data []byte) error { return unix.Munmap(data) }
@@ -0,0 +1,235 @@
+package engine
+
+import (
+	"container/heap"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Model selects how cell updates are scheduled when the engine is driven
+// for a fixed number of ticks rather than in real time.
+type Model int
+
+const (
+	// Goroutines runs one goroutine per cell, each updating as fast as it
+	// can with no reaction-time delay. Updates stay asynchronous.
+	Goroutines Model = iota
+	// Sequential sweeps the grid on a single goroutine, computing every
+	// cell before applying any of them.
+	Sequential
+	// Pool splits each synchronous sweep across GOMAXPROCS workers.
+	Pool
+	// Timed reproduces Start on a simulated clock, on a single goroutine:
+	// every cell updates after its reaction time, turmites step every
+	// Params.AgentInterval and a tick is Params.TickInterval, without
+	// waiting for any of them. Cells due at the same instant update in
+	// random order.
+	Timed
+	// Tiles runs one goroutine per tile of Params.TileSize cells square,
+	// each updating its cells in turn as fast as it can. Updates stay
+	// asynchronous across tiles, as with Goroutines, on far fewer
+	// goroutines.
+	Tiles
+)
+
+var Models = []Model{Goroutines, Sequential, Pool, Timed, Tiles}
+
+func (m Model) String() string {
+	switch m {
+	case Goroutines:
+		return "goroutines"
+	case Sequential:
+		return "sequential"
+	case Pool:
+		return "pool"
+	case Timed:
+		return "timed"
+	case Tiles:
+		return "tiles"
+	default:
+		return fmt.Sprintf("Model(%d)", int(m))
+	}
+}
+
+// ParseModel returns the model with the given name.
+func ParseModel(name string) (Model, error) {
+	for _, m := range Models {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown model %q", name)
+}
+
+// RunTicks updates every cell, and steps every turmite, ticks times using
+// model m and returns once all updates are done.
+func (e *Engine) RunTicks(m Model, ticks int) {
+	switch m {
+	case Goroutines, Sequential, Timed:
+		e.noWorkers()
+	}
+	switch m {
+	case Goroutines:
+		var wg sync.WaitGroup
+		wg.Add(e.rows * e.cols)
+		for k := range e.cells {
+			go func(c *Cell) {
+				defer wg.Done()
+				for range ticks {
+					c.computeNextState()
+					c.applyNextState()
+				}
+			}(&e.cells[k])
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range ticks {
+				e.stepAgents()
+			}
+		}()
+		wg.Wait()
+		e.endTick()
+	case Sequential:
+		for range ticks {
+			e.sweep(0, e.rows, (*Cell).computeNextState)
+			e.sweep(0, e.rows, (*Cell).applyNextState)
+			e.stepAgents()
+			e.endTick()
+		}
+	case Pool:
+		workers := min(runtime.GOMAXPROCS(0), e.rows)
+		for range ticks {
+			e.parallel(workers, (*Cell).computeNextState)
+			e.parallel(workers, (*Cell).applyNextState)
+			e.stepAgents()
+			e.endTick()
+		}
+	case Timed:
+		if e.clock == nil {
+			e.clock = newClock(e)
+		}
+		for range ticks {
+			e.clock.advance(e.clock.now + e.interval)
+			e.endTick()
+		}
+	case Tiles:
+		var wg sync.WaitGroup
+		w := e.useWorkers(e.tileSize, 0)
+		for i, cells := range e.tiles() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				start := time.Now()
+				defer func() { w.busy[i].Add(int64(time.Since(start))) }()
+				for range ticks {
+					for _, c := range cells {
+						c.computeNextState()
+						c.applyNextState()
+					}
+				}
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range ticks {
+				e.stepAgents()
+			}
+		}()
+		wg.Wait()
+		e.endTick()
+	}
+}
+
+// clock is the schedule of the Timed model, kept between calls to RunTicks.
+type clock struct {
+	e         *Engine
+	now       time.Duration
+	due       dueCells
+	nextAgent time.Duration
+}
+
+func newClock(e *Engine) *clock {
+	c := &clock{e: e, nextAgent: e.agentTau}
+	for k := range e.cells {
+		cell := &e.cells[k]
+		c.due = append(c.due, dueCell{cell.reactionTime(), e.rand.Int63(), cell})
+		cell.dueAt.Store(int64(c.due[k].at))
+	}
+	heap.Init(&c.due)
+	return c
+}
+
+// advance runs every update due before until and moves the clock there.
+func (c *clock) advance(until time.Duration) {
+	for len(c.due) > 0 && c.due[0].at < until {
+		d := &c.due[0]
+		for c.nextAgent <= d.at {
+			c.e.stepAgents()
+			c.nextAgent += c.e.agentTau
+		}
+		c.now = d.at
+		d.cell.computeNextState()
+		d.cell.applyNextState()
+		d.at += d.cell.reactionTime()
+		d.cell.dueAt.Store(int64(d.at))
+		d.order = c.e.rand.Int63()
+		heap.Fix(&c.due, 0)
+	}
+	for c.nextAgent < until {
+		c.e.stepAgents()
+		c.nextAgent += c.e.agentTau
+	}
+	c.now = until
+}
+
+// dueCells is a heap of cells by the time of their next update.
+type dueCells []dueCell
+
+type dueCell struct {
+	at    time.Duration
+	order int64 // breaks ties at random
+	cell  *Cell
+}
+
+func (h dueCells) Len() int { return len(h) }
+func (h dueCells) Less(i, j int) bool {
+	if h[i].at != h[j].at {
+		return h[i].at < h[j].at
+	}
+	return h[i].order < h[j].order
+}
+func (h dueCells) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *dueCells) Push(x any)   { *h = append(*h, x.(dueCell)) }
+func (h *dueCells) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+func (e *Engine) sweep(from, to int, f func(*Cell)) {
+	for k := from * e.cols; k < to*e.cols; k++ {
+		f(&e.cells[k])
+	}
+}
+
+// parallel applies f to every cell, giving each worker a band of rows.
+func (e *Engine) parallel(workers int, f func(*Cell)) {
+	var wg sync.WaitGroup
+	band := (e.rows + workers - 1) / workers
+	w := e.useWorkers(0, band)
+	for from := 0; from < e.rows; from += band {
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+			start := time.Now()
+			e.sweep(from, to, f)
+			w.busy[from/band].Add(int64(time.Since(start)))
+		}(from, min(from+band, e.rows))
+	}
+	wg.Wait()
+}
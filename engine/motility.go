@@ -0,0 +1,84 @@
+package engine
+
+// destination returns the empty neighbour c's live cell would rather be
+// in, or nil if none is better than where it is: the one with the most
+// resource left if there is a resource field, else the one with the fewest
+// other live neighbours. Ties are broken at random.
+func (c *Cell) destination(n *Neighborhood) *Cell {
+	var best *Cell
+	var bestScore float64
+	if c.e.resource.Enabled {
+		bestScore = c.groundLevel()
+	} else {
+		bestScore = -float64(n.Total)
+	}
+	start := c.e.rand.Intn(len(Moore))
+	for i := range Moore {
+		k := (start + i) % len(Moore)
+		if !n.InGrid[k] || n.Cells[k].Alive() {
+			continue
+		}
+		t := c.e.at(c.x+Moore[k][0], c.y+Moore[k][1])
+		if t == nil {
+			continue
+		}
+		var score float64
+		if c.e.resource.Enabled {
+			score = t.groundLevel()
+		} else {
+			score = -float64(c.e.crowding(t) - 1) // c itself is not crowding t
+		}
+		if score > bestScore {
+			best, bestScore = t, score
+		}
+	}
+	return best
+}
+
+// crowding returns the number of live neighbours of t, as a cell there
+// would count them.
+func (e *Engine) crowding(t *Cell) int {
+	total := 0
+	for _, offset := range Moore {
+		x, y := t.x+offset[0], t.y+offset[1]
+		if i, j, ok := e.resolve(x, y); ok {
+			if word(e.words[i*e.cols+j].Load()).alive() {
+				total++
+			}
+		} else if s, _ := e.outside(x, y); s.Alive() {
+			total++
+		}
+	}
+	return total
+}
+
+// tryLock starts a write of c like lock, unless one is under way, and
+// reports whether it did.
+func (c *Cell) tryLock() bool {
+	s := c.seq.Load()
+	return s&1 == 0 && c.seq.CompareAndSwap(s, s+1)
+}
+
+// move moves the live cell old of c, whose write is under way, to its
+// destination t unless another cell got there first, and reports whether
+// it did. t is left alone if its own write is under way, so that two
+// cells moving into each other's way cannot wait on each other.
+func (c *Cell) move(t *Cell, old State) bool {
+	if !t.tryLock() {
+		return false
+	}
+	prev := t.load()
+	if prev.Wall || prev.Alive() {
+		t.unlock()
+		return false
+	}
+	moved := old
+	moved.Age = min(old.Age+1, MaxAge)
+	t.store(moved)
+	t.arrived.Store(true)
+	t.unlock()
+	for _, f := range load(&c.e.hooks.cellChanged) {
+		f(t.x, t.y, prev, moved)
+	}
+	return true
+}
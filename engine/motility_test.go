@@ -0,0 +1,50 @@
+package engine_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestMotility checks that moving cells are neither lost nor duplicated
+// when they compete for the same empty cells, under every model.
+func TestMotility(t *testing.T) {
+	for _, m := range engine.Models {
+		t.Run(m.String(), func(t *testing.T) {
+			params := engine.DefaultParams()
+			params.Rows, params.Cols = 16, 16
+			params.Species = []engine.Species{
+				{Name: "a", ReactionTime: time.Millisecond, Rule: engine.MustParseRule("B/S012345678")},
+				{Name: "b", ReactionTime: 2 * time.Millisecond, Rule: engine.MustParseRule("B/S012345678")},
+			}
+			params.Motility = 1
+			params.Rand = engine.NewRand(1)
+			e, err := engine.New(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.Seed(0.4)
+			before, want := e.Text(), population(e)
+			e.RunTicks(m, 20)
+			if got := population(e); !slices.Equal(got, want) {
+				t.Errorf("population went from %v to %v", want, got)
+			}
+			if e.Text() == before {
+				t.Errorf("no cell moved")
+			}
+		})
+	}
+}
+
+// population returns the number of cells of every species of e.
+func population(e *engine.Engine) []int {
+	pop := make([]int, len(e.Species()))
+	for i := range e.Rows() {
+		for j := range e.Cols() {
+			pop[e.Cell(i, j).Species]++
+		}
+	}
+	return pop
+}
@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Rand is the source of randomness of an engine: seeding, mutation, the
+// turmites' and Timed model's schedules and, through Neighborhood.Rand,
+// stochastic transitions. It must be safe for concurrent use.
+type Rand interface {
+	Float64() float64
+	Intn(n int) int
+	Int63() int64
+}
+
+// globalRand draws from the math/rand top-level functions.
+type globalRand struct{}
+
+func (globalRand) Float64() float64 { return rand.Float64() }
+func (globalRand) Intn(n int) int   { return rand.Intn(n) }
+func (globalRand) Int63() int64     { return rand.Int63() }
+
+// NewRand returns a Rand whose sequence is determined by seed. Together with
+// a model with a fixed update order, such as Sequential, it makes runs
+// reproducible.
+func NewRand(seed int64) Rand {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (l *lockedRand) Float64() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Float64()
+}
+
+func (l *lockedRand) Intn(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Intn(n)
+}
+
+func (l *lockedRand) Int63() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63()
+}
@@ -0,0 +1,39 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestSample checks that a cell sees as many of its neighbours as the
+// sample size, picked afresh at every update.
+func TestSample(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 3, 3
+	params.Species = []engine.Species{{Name: "a", ReactionTime: time.Millisecond, Rule: engine.Conway}}
+	params.Sample = 5
+	params.Rand = engine.NewRand(1)
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SeedDensities([]float64{1})
+	seen := map[[8]bool]bool{}
+	for range 50 {
+		n := e.Inspect(1, 1).Neighbors
+		if n.Total != 5 {
+			t.Fatalf("saw %d of 8 live neighbours, want 5", n.Total)
+		}
+		seen[n.InGrid] = true
+	}
+	if len(seen) < 10 {
+		t.Errorf("only %d different samples in 50 updates", len(seen))
+	}
+
+	params.Sample = 9
+	if _, err := engine.New(params); err == nil {
+		t.Error("accepted a sample of 9 neighbours")
+	}
+}
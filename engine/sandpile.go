@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"time"
+)
+
+// SandpileSpecies are the species Sandpile uses to color cells by grain
+// count: a cell with g grains has species min(g, 4), so dead cells are empty
+// and species 4 marks cells about to topple.
+func SandpileSpecies() []Species {
+	tau := 50 * time.Millisecond
+	return []Species{
+		{Name: "one", ReactionTime: tau},
+		{Name: "two", ReactionTime: tau},
+		{Name: "three", ReactionTime: tau},
+		{Name: "toppling", ReactionTime: tau},
+	}
+}
+
+// SandpileThreshold is the grain count at which a cell topples.
+const SandpileThreshold = 4
+
+// vonNeumann holds the indexes into Moore of the four orthogonal neighbours.
+var vonNeumann = [4]int{1, 3, 4, 6}
+
+// Sandpile is the Abelian sandpile. A cell's grain count is kept in
+// State.Value. On each update a cell holding SandpileThreshold or more
+// grains sheds that many, receives one grain from each orthogonal neighbour
+// that is toppling, and with probability Drop receives a grain from above.
+// Grains toppled off the edge of the grid are lost.
+//
+// The rule is written from the receiving cell's point of view, which is
+// exact under synchronous updates. Under asynchronous updates a neighbour
+// may topple between two of this cell's updates, or twice, so grains are
+// not strictly conserved.
+type Sandpile struct {
+	Drop float64
+}
+
+func (sp Sandpile) Next(self State, n Neighborhood) State {
+	grains := self.Value
+	if grains >= SandpileThreshold {
+		grains -= SandpileThreshold
+	}
+	for _, k := range vonNeumann {
+		if n.Cells[k].Value >= SandpileThreshold {
+			grains++
+		}
+	}
+	if n.Rand.Float64() < sp.Drop {
+		grains++
+	}
+	return State{Species: min(grains, SandpileThreshold), Value: grains}
+}
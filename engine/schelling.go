@@ -0,0 +1,84 @@
+package engine
+
+import "time"
+
+// SchellingSpecies are the two groups Schelling expects, in id order.
+func SchellingSpecies() []Species {
+	return []Species{
+		{Name: "red", ReactionTime: 102 * time.Millisecond},
+		{Name: "blue", ReactionTime: 102 * time.Millisecond},
+	}
+}
+
+// schellingTries is how many cells picked at random an unhappy cell tries
+// for an empty one before it stays put until its next update.
+const schellingTries = 64
+
+// Schelling is Schelling's segregation model. Cells are neither born nor
+// die; a live cell is unhappy when less than the share Tolerance of its
+// live neighbours are of its species, and an unhappy cell moves on its
+// update to an empty cell picked at random from the whole grid. A cell
+// without live neighbours is content.
+type Schelling struct {
+	Tolerance float64
+}
+
+func (s Schelling) Next(self State, n Neighborhood) State { return self }
+
+func (s Schelling) Unhappy(self State, n Neighborhood) bool {
+	return n.Total > 0 && float64(n.Counts[self.Species]) < s.Tolerance*float64(n.Total)
+}
+
+// vacancy returns an empty cell picked at random, or nil if none of
+// schellingTries picks was.
+func (e *Engine) vacancy() *Cell {
+	for range schellingTries {
+		k := e.rand.Intn(len(e.cells))
+		if w := word(e.words[k].Load()); !w.wall() && !w.alive() {
+			return &e.cells[k]
+		}
+	}
+	return nil
+}
+
+// Similarity returns the share of the live neighbours of live cells that
+// are of the same species, averaged over the live cells with any: 0.5 for
+// two well-mixed species of equal numbers, 1 when they are fully
+// segregated.
+func (e *Engine) Similarity() float64 {
+	e.gridMu.RLock()
+	defer e.gridMu.RUnlock()
+	var sum float64
+	cells := 0
+	for k := range e.cells {
+		w := word(e.words[k].Load())
+		if !w.alive() || w.wall() {
+			continue
+		}
+		c := &e.cells[k]
+		same, total := 0, 0
+		for _, offset := range Moore {
+			x, y := c.x+offset[0], c.y+offset[1]
+			var s State
+			if i, j, ok := e.resolve(x, y); ok {
+				e.peek(i*e.cols+j, &s)
+			} else {
+				s, _ = e.outside(x, y)
+			}
+			if s.Alive() && !s.Wall {
+				total++
+				if s.Species == w.species() {
+					same++
+				}
+			}
+		}
+		if total > 0 {
+			sum += float64(same) / float64(total)
+			cells++
+		}
+	}
+	if cells == 0 {
+		return 0
+	}
+	return sum / float64(cells)
+}
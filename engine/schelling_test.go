@@ -0,0 +1,35 @@
+package engine_test
+
+import (
+	"slices"
+	"testing"
+
+	"app/engine"
+)
+
+// TestSchelling checks that unhappy cells move without being lost or
+// duplicated, under every model, and that the groups segregate.
+func TestSchelling(t *testing.T) {
+	for _, m := range engine.Models {
+		t.Run(m.String(), func(t *testing.T) {
+			params := engine.DefaultParams()
+			params.Rows, params.Cols = 24, 24
+			params.Species = engine.SchellingSpecies()
+			params.Transition = engine.Schelling{Tolerance: 0.5}
+			params.Rand = engine.NewRand(1)
+			e, err := engine.New(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.Seed(0.8)
+			want, before := population(e), e.Similarity()
+			e.RunTicks(m, 20)
+			if got := population(e); !slices.Equal(got, want) {
+				t.Errorf("population went from %v to %v", want, got)
+			}
+			if after := e.Similarity(); after < before+0.1 {
+				t.Errorf("similarity went from %.3f to only %.3f", before, after)
+			}
+		})
+	}
+}
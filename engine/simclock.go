@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// simClock is the simulated time of Start, StartTiled and
+// StartSynchronous: it runs scale times as fast as the wall clock, from 0
+// when the engine is created. Reaction times, tick and turmite intervals
+// are waited on it, so that changing the scale speeds up or slows down the
+// whole simulation alike. Every wait of every cell reads it, so it reads
+// without a lock.
+type simClock struct {
+	state atomic.Pointer[clockState]
+	mu    sync.Mutex // serializes setScale
+}
+
+// clockState is a scale of a simClock and when it took effect, replaced
+// whole on every change.
+type clockState struct {
+	scale float64
+	base  time.Time     // of the change of scale
+	at    time.Duration // simulated time at base
+}
+
+func newSimClock(start time.Time, scale float64) *simClock {
+	c := &simClock{}
+	c.state.Store(&clockState{scale: scale, base: start})
+	return c
+}
+
+// now returns the simulated time.
+func (c *simClock) now() time.Duration {
+	s := c.state.Load()
+	return s.at + time.Duration(float64(time.Since(s.base))*s.scale)
+}
+
+// wall returns how long a simulated duration d lasts in real time.
+func (c *simClock) wall(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / c.state.Load().scale)
+}
+
+// sleep waits a simulated duration d, or until done is closed.
+func (c *simClock) sleep(d time.Duration, done <-chan struct{}) {
+	t := time.NewTimer(c.wall(d))
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-done:
+	}
+}
+
+func (c *simClock) setScale(scale float64) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.state.Load()
+	now := time.Now()
+	c.state.Store(&clockState{
+		scale: scale,
+		base:  now,
+		at:    old.at + time.Duration(float64(now.Sub(old.base))*old.scale),
+	})
+	return old.scale
+}
+
+// TimeScale returns how many times as fast as real time the simulated
+// clock of Start, StartTiled and StartSynchronous runs.
+func (e *Engine) TimeScale() float64 { return e.sim.state.Load().scale }
+
+// SetTimeScale changes Params.TimeScale, while the engine runs or not.
+// Waits already under way end at the old pace.
+func (e *Engine) SetTimeScale(scale float64) error {
+	if scale <= 0 {
+		return fmt.Errorf("time scale %v must be positive", scale)
+	}
+	old := e.sim.setScale(scale)
+	e.logger.Info("time scale changed", "from", old, "to", scale)
+	return nil
+}
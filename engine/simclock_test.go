@@ -0,0 +1,57 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestTimeScale checks that cells update, and the simulated clock runs,
+// Params.TimeScale times as fast as real time, and at the new pace after
+// SetTimeScale.
+func TestTimeScale(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 4, 4
+	params.Species = []engine.Species{{Name: "a", ReactionTime: 20 * time.Millisecond, Rule: engine.Conway}}
+	params.DeadReactionTime = 20 * time.Millisecond
+	params.TimeScale = 4
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetTimeScale(0); err == nil {
+		t.Error("SetTimeScale(0) succeeded")
+	}
+	updates := func() (n int64) {
+		for x := range params.Rows {
+			for y := range params.Cols {
+				n += e.Inspect(x, y).Updates
+			}
+		}
+		return n
+	}
+	const run = 200 * time.Millisecond
+	start := time.Now()
+	e.Start()
+	defer e.Stop()
+	time.Sleep(run)
+	// 16 cells every 5ms of real time
+	if n := updates(); n < 320 || n > 800 {
+		t.Errorf("%d updates in %v at 4x, want about 640", n, run)
+	}
+	if now, elapsed := e.Now(), time.Since(start); now < 3*elapsed || now > 5*elapsed {
+		t.Errorf("simulated time %v after %v at 4x", now, elapsed)
+	}
+
+	if err := e.SetTimeScale(0.25); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond) // for the waits under way
+	before := updates()
+	time.Sleep(run)
+	// 16 cells every 80ms of real time
+	if n := updates() - before; n > 64 {
+		t.Errorf("%d updates in %v at 0.25x, want about 40", n, run)
+	}
+}
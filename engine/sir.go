@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Species ids used by SIR.
+const (
+	Susceptible = 1 + iota
+	Infected
+	Recovered
+)
+
+// SIRSpecies are the species SIR expects, in id order.
+func SIRSpecies() []Species {
+	return []Species{
+		{Name: "susceptible", ReactionTime: 100 * time.Millisecond},
+		{Name: "infected", ReactionTime: 100 * time.Millisecond},
+		{Name: "recovered", ReactionTime: 100 * time.Millisecond},
+	}
+}
+
+// SIR is an epidemic Transition. Each update, a susceptible cell is
+// infected by each infected neighbour independently with the infection
+// rate; an infected cell recovers, becoming permanently immune, once it has
+// been infected for the recovery time, counted in its own updates. Dead
+// cells are empty space and stay empty. Both parameters may be changed
+// while the engine runs.
+type SIR struct {
+	rate     atomic.Uint64 // math.Float64bits of the infection rate
+	recovery atomic.Int64
+}
+
+func NewSIR(rate float64, recovery int) *SIR {
+	s := &SIR{}
+	s.SetInfectionRate(rate)
+	s.SetRecovery(recovery)
+	return s
+}
+
+func (s *SIR) InfectionRate() float64 { return math.Float64frombits(s.rate.Load()) }
+
+// SetInfectionRate sets the per-neighbour infection probability, clamped to
+// [0, 1].
+func (s *SIR) SetInfectionRate(p float64) {
+	s.rate.Store(math.Float64bits(min(max(p, 0), 1)))
+}
+
+func (s *SIR) Recovery() int { return int(s.recovery.Load()) }
+
+// SetRecovery sets the number of updates an infection lasts, at least 1.
+func (s *SIR) SetRecovery(updates int) { s.recovery.Store(int64(max(updates, 1))) }
+
+func (s *SIR) Next(self State, n Neighborhood) State {
+	switch self.Species {
+	case Susceptible:
+		if k := n.Counts[Infected]; k > 0 {
+			escape := math.Pow(1-s.InfectionRate(), float64(k))
+			if n.Rand.Float64() >= escape {
+				return State{Species: Infected}
+			}
+		}
+	case Infected:
+		if self.Age+1 >= s.Recovery() {
+			return State{Species: Recovered}
+		}
+	}
+	return self
+}
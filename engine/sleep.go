@@ -0,0 +1,75 @@
+package engine
+
+import "sync/atomic"
+
+// tile is a tile of StartTiled, which sleeps once its cells have settled
+// if Params.Sleep is set.
+type tile struct {
+	cells  []*Cell
+	stir   atomic.Int64 // changes in or next to it so far
+	asleep atomic.Bool
+	wake   chan struct{} // room for 1, sent when stirred asleep
+	busy   *atomic.Int64 // of its worker; see Workers
+}
+
+// sleepers are the tiles of an engine run by StartTiled with Params.Sleep
+// set, across of them to a row of tiles.
+type sleepers struct {
+	tiles  []*tile
+	across int
+}
+
+// stir records a change of the cell at (x, y), waking the tiles of the
+// cells that see it.
+func (sl *sleepers) stir(e *Engine, x, y int) {
+	r := max(e.radius, 1)
+	for dx := -1; dx <= 1; dx++ {
+		for dy := -1; dy <= 1; dy++ {
+			i, j, ok := e.resolve(x+dx*r, y+dy*r)
+			if !ok {
+				continue
+			}
+			t := sl.tiles[i/e.tileSize*sl.across+j/e.tileSize]
+			t.stir.Add(1)
+			if t.asleep.Load() {
+				select {
+				case t.wake <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// wakeAll wakes every sleeping tile, when a change of parameters may
+// unsettle them.
+func (e *Engine) wakeAll() {
+	sl := e.sleepers.Load()
+	if sl == nil {
+		return
+	}
+	for _, t := range sl.tiles {
+		t.stir.Add(1)
+		if t.asleep.Load() {
+			select {
+			case t.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// Sleeping returns how many tiles of StartTiled are asleep, of how many,
+// both 0 unless Params.Sleep is set and StartTiled runs the engine.
+func (e *Engine) Sleeping() (asleep, tiles int) {
+	sl := e.sleepers.Load()
+	if sl == nil {
+		return 0, 0
+	}
+	for _, t := range sl.tiles {
+		if t.asleep.Load() {
+			asleep++
+		}
+	}
+	return asleep, len(sl.tiles)
+}
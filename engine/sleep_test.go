@@ -0,0 +1,63 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestSleep checks that the tiles of a settled grid fall asleep, and that a
+// change wakes the tiles around it and no others.
+func TestSleep(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 32, 32
+	params.TileSize = 8
+	params.Species = []engine.Species{{Name: "a", ReactionTime: time.Millisecond, Rule: engine.Conway}}
+	params.DeadReactionTime = time.Millisecond
+	params.Sleep = 3
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range [][2]int{{3, 3}, {3, 4}, {4, 3}, {4, 4}} { // a block
+		e.SetCell(p[0], p[1], engine.State{Species: 1})
+	}
+	e.StartTiled()
+	defer e.Stop()
+	waitFor := func(what string, ok func(asleep int) bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if asleep, _ := e.Sleeping(); ok(asleep) {
+				return
+			}
+		}
+		asleep, tiles := e.Sleeping()
+		t.Fatalf("%s: %d of %d tiles asleep", what, asleep, tiles)
+	}
+	waitFor("settling", func(asleep int) bool { return asleep == 16 })
+
+	updates := func(x, y int) int64 { return e.Inspect(x, y).Updates }
+	near, far := updates(12, 12), updates(28, 28)
+	time.Sleep(30 * time.Millisecond)
+	if updates(12, 12) != near || updates(28, 28) != far {
+		t.Fatal("cells of sleeping tiles updated")
+	}
+	// another block, in the tile at the second row and column of tiles,
+	// wakes it and those around it until they settle again
+	for _, p := range [][2]int{{12, 12}, {12, 13}, {13, 12}, {13, 13}} {
+		e.SetCell(p[0], p[1], engine.State{Species: 1})
+	}
+	for deadline := time.Now().Add(5 * time.Second); updates(12, 12) == near; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the tile of the new block did not wake")
+		}
+	}
+	waitFor("settling again", func(asleep int) bool { return asleep == 16 })
+	if updates(28, 28) != far {
+		t.Error("a tile far from the new block woke")
+	}
+	if e.Cell(3, 3).Species != 1 || e.Cell(13, 13).Species != 1 {
+		t.Errorf("blocks disturbed:\n%s", e.Text())
+	}
+}
@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxNeighbors is the largest neighbourhood a Rule covers: the 26 cells
+// around a cell of a Volume.
+const MaxNeighbors = 26
+
+// Rule is a Life-like birth/survival rule. Birth is indexed by the total
+// number of live neighbours of a dead cell, Survive by the number of live
+// neighbours of the cell's own species.
+type Rule struct {
+	Birth, Survive [MaxNeighbors + 1]bool
+}
+
+// Conway is B3/S23.
+var Conway = MustParseRule("B3/S23")
+
+// ParseRule parses a rulestring in B/S notation, e.g. "B3/S23". The order of
+// the two halves does not matter and either may be empty. Counts above 8,
+// for 3D rules, are written as a comma-separated list of numbers and
+// ranges, e.g. "B5/S4,5" or "B14-19/S13-26".
+func ParseRule(s string) (Rule, error) {
+	var r Rule
+	parts := strings.Split(strings.ToUpper(strings.TrimSpace(s)), "/")
+	if len(parts) != 2 {
+		return r, fmt.Errorf("rule %q: want B.../S...", s)
+	}
+	if parts[0] != "" && parts[1] != "" && parts[0][0] == parts[1][0] {
+		return r, fmt.Errorf("rule %q: want one B half and one S half", s)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return r, fmt.Errorf("rule %q: empty half", s)
+		}
+		var set *[MaxNeighbors + 1]bool
+		switch part[0] {
+		case 'B':
+			set = &r.Birth
+		case 'S':
+			set = &r.Survive
+		default:
+			return r, fmt.Errorf("rule %q: %q must start with B or S", s, part)
+		}
+		if !strings.ContainsAny(part, ",-") {
+			for _, ch := range part[1:] {
+				if ch < '0' || ch > '8' {
+					return r, fmt.Errorf("rule %q: bad neighbour count %q", s, ch)
+				}
+				set[ch-'0'] = true
+			}
+			continue
+		}
+		for _, item := range strings.Split(part[1:], ",") {
+			lo, hi, isRange := strings.Cut(item, "-")
+			if !isRange {
+				hi = lo
+			}
+			from, err1 := strconv.Atoi(lo)
+			to, err2 := strconv.Atoi(hi)
+			if err1 != nil || err2 != nil || from < 0 || from > to || to > MaxNeighbors {
+				return r, fmt.Errorf("rule %q: bad neighbour count %q", s, item)
+			}
+			for n := from; n <= to; n++ {
+				set[n] = true
+			}
+		}
+	}
+	return r, nil
+}
+
+// MustParseRule is like ParseRule but panics on error.
+func MustParseRule(s string) Rule {
+	r, err := ParseRule(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// WithThresholds returns r with births on exactly birth live neighbours and
+// survival on surviveMin to surviveMax neighbours of the cell's species, as
+// simple knobs for those who would rather not write B/S notation. A count of
+// 0 leaves that part of r alone: birth keeps r's births and each survival
+// bound r's lowest or highest survival count.
+func (r Rule) WithThresholds(birth, surviveMin, surviveMax int) (Rule, error) {
+	for _, n := range []int{birth, surviveMin, surviveMax} {
+		if n < 0 || n > MaxNeighbors {
+			return r, fmt.Errorf("neighbour count %d must be in [0, %d]", n, MaxNeighbors)
+		}
+	}
+	if birth > 0 {
+		r.Birth = [MaxNeighbors + 1]bool{}
+		r.Birth[birth] = true
+	}
+	if surviveMin == 0 && surviveMax == 0 {
+		return r, nil
+	}
+	lo, hi := -1, -1 // of r's survival counts
+	for n, ok := range r.Survive {
+		if ok && lo < 0 {
+			lo = n
+		}
+		if ok {
+			hi = n
+		}
+	}
+	if lo < 0 { // no bound of r to keep: the one set goes for both
+		lo, hi = cmp.Or(surviveMin, surviveMax), cmp.Or(surviveMax, surviveMin)
+	}
+	if surviveMin > 0 {
+		lo = surviveMin
+	}
+	if surviveMax > 0 {
+		hi = surviveMax
+	}
+	if lo > hi {
+		return r, fmt.Errorf("survival from %d to %d neighbours is empty", lo, hi)
+	}
+	r.Survive = [MaxNeighbors + 1]bool{}
+	for n := lo; n <= hi; n++ {
+		r.Survive[n] = true
+	}
+	return r, nil
+}
+
+func (r Rule) String() string {
+	return "B" + countsString(r.Birth) + "/S" + countsString(r.Survive)
+}
+
+// countsString writes the counts in set as digits if they are all below 9
+// and as a list of numbers and ranges otherwise.
+func countsString(set [MaxNeighbors + 1]bool) string {
+	var b strings.Builder
+	if !slices.Contains(set[9:], true) {
+		for n, ok := range set {
+			if ok {
+				b.WriteByte(byte('0' + n))
+			}
+		}
+		return b.String()
+	}
+	for n := 0; n <= MaxNeighbors; n++ {
+		if !set[n] {
+			continue
+		}
+		end := n
+		for end < MaxNeighbors && set[end+1] {
+			end++
+		}
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(n))
+		if end > n {
+			b.WriteString("-" + strconv.Itoa(end))
+		}
+		n = end
+	}
+	return b.String()
+}
+
+// Species describes one kind of live cell. Species are numbered from 1 in
+// the order they appear in Params.Species; 0 means dead.
+type Species struct {
+	Name         string
+	ReactionTime time.Duration
+	Rule         Rule
+	// Refractory, unless 0, is how long a cell stays dead after a cell of
+	// the species dies there: whatever its neighbours, it cannot be born
+	// again before then, which makes the grid an excitable medium. The
+	// time is the running time in real time, the simulated clock of the
+	// Timed model and Params.TickInterval per completed tick in the other
+	// models of RunTicks.
+	Refractory time.Duration
+	// Team, unless 0, allies the species with the others on the same team:
+	// the species' rules count their live neighbours toward each other's
+	// survival and births, though each is born and survives as itself.
+	Team int
+	// Parents, set only for a hybrid bred by Params.Hybrids, are the ids
+	// of the species it was bred from, in increasing order.
+	Parents []int
+}
+
+// DefaultSpecies are the original green, red and blue species, all playing
+// Conway's rule with green reacting slightly faster.
+func DefaultSpecies() []Species {
+	return []Species{
+		{Name: "green", ReactionTime: 101 * time.Millisecond, Rule: Conway},
+		{Name: "red", ReactionTime: 102 * time.Millisecond, Rule: Conway},
+		{Name: "blue", ReactionTime: 102 * time.Millisecond, Rule: Conway},
+	}
+}
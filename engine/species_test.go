@@ -0,0 +1,170 @@
+package engine_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// FuzzParseRule checks that no input makes ParseRule panic and that every
+// rule it accepts is written back as a rulestring that parses to the same
+// rule.
+func FuzzParseRule(f *testing.F) {
+	for _, s := range []string{
+		"B3/S23", "B36/S23", "B3678/S34678", "B2/S", "S23/B3", "b3/s23",
+		"B5/S4,5", "B14-19/S13-26", "B4,12-13/S0-26", "B/S",
+	} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		r, err := engine.ParseRule(s)
+		if err != nil {
+			return
+		}
+		again, err := engine.ParseRule(r.String())
+		if err != nil {
+			t.Fatalf("%q parsed as %v, which doesn't parse: %v", s, r, err)
+		}
+		if again != r {
+			t.Fatalf("%q parsed as %v, which parses as %v", s, r, again)
+		}
+	})
+}
+
+// TestWithThresholds checks the knobs against the rulestrings they stand
+// for.
+func TestWithThresholds(t *testing.T) {
+	tests := []struct {
+		rule                          string
+		birth, surviveMin, surviveMax int
+		want                          string
+	}{
+		{"B3/S23", 0, 0, 0, "B3/S23"},
+		{"B3/S23", 4, 0, 0, "B4/S23"},
+		{"B3/S23", 0, 1, 0, "B3/S123"},
+		{"B3/S23", 0, 0, 5, "B3/S2345"},
+		{"B3/S23", 0, 3, 3, "B3/S3"},
+		{"B36/S1358", 2, 0, 6, "B2/S123456"},
+		{"B2/S", 0, 0, 4, "B2/S4"},
+		{"B2/S", 0, 0, 0, "B2/S"},
+		{"B5/S4,5", 0, 0, 12, "B5/S4-12"},
+	}
+	for _, tt := range tests {
+		got, err := engine.MustParseRule(tt.rule).WithThresholds(tt.birth, tt.surviveMin, tt.surviveMax)
+		if err != nil {
+			t.Errorf("%s with %d, %d, %d: %v", tt.rule, tt.birth, tt.surviveMin, tt.surviveMax, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s with %d, %d, %d = %v, want %s", tt.rule, tt.birth, tt.surviveMin, tt.surviveMax, got, tt.want)
+		}
+	}
+	for _, knobs := range [][3]int{{0, 4, 2}, {-1, 0, 0}, {0, 0, engine.MaxNeighbors + 1}} {
+		if _, err := engine.Conway.WithThresholds(knobs[0], knobs[1], knobs[2]); err == nil {
+			t.Errorf("Conway with %v: no error", knobs)
+		}
+	}
+}
+
+// TestHybrids checks that a three-way tie breeds one hybrid of the three,
+// which every birth of the same tie then shares.
+func TestHybrids(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 5, 5
+	params.Species = []engine.Species{
+		{Name: "a", ReactionTime: 10 * time.Millisecond, Rule: engine.Conway},
+		{Name: "b", ReactionTime: 20 * time.Millisecond, Rule: engine.Conway},
+		{Name: "c", ReactionTime: 30 * time.Millisecond, Rule: engine.MustParseRule("B36/S23")},
+	}
+	params.Hybrids = 1
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for sp := 1; sp <= 3; sp++ {
+		e.SetCell(1, sp, engine.State{Species: sp})
+	}
+	e.RunTicks(engine.Sequential, 1)
+
+	species := e.Species()
+	if len(species) != 5 {
+		t.Fatalf("got %d species, want the dead, three and a hybrid", len(species))
+	}
+	hy := species[4]
+	if hy.Name != "a×b×c" || !slices.Equal(hy.Parents, []int{1, 2, 3}) ||
+		hy.ReactionTime != 20*time.Millisecond || hy.Rule != engine.Conway {
+		t.Errorf("got hybrid %+v", hy)
+	}
+	for _, x := range []int{0, 2} {
+		if s := e.Cell(x, 2).Species; s != 4 {
+			t.Errorf("cell %d, 2 is species %d, want the hybrid", x, s)
+		}
+	}
+}
+
+// TestTeams checks that a cell survives on its allies' neighbours, which
+// it would not on its own.
+func TestTeams(t *testing.T) {
+	for _, team := range []int{0, 1} {
+		params := engine.DefaultParams()
+		params.Rows, params.Cols = 5, 5
+		params.Species = []engine.Species{
+			{Name: "a", ReactionTime: time.Millisecond, Rule: engine.Conway, Team: team},
+			{Name: "b", ReactionTime: time.Millisecond, Rule: engine.Conway, Team: team},
+		}
+		e, err := engine.New(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.SetCell(2, 1, engine.State{Species: 1})
+		e.SetCell(2, 2, engine.State{Species: 1})
+		e.SetCell(2, 3, engine.State{Species: 2})
+		e.RunTicks(engine.Sequential, 1)
+		want := engine.Dead
+		if team != 0 {
+			want = 1
+		}
+		if got := e.Cell(2, 2).Species; got != want {
+			t.Errorf("team %d: the middle cell is species %d, want %d", team, got, want)
+		}
+	}
+}
+
+// TestManySpecies checks that species 256 apart are told apart by Hash,
+// Complexity, Clusters and History.
+func TestManySpecies(t *testing.T) {
+	species := make([]engine.Species, 300)
+	for i := range species {
+		species[i] = engine.Species{Name: fmt.Sprint("s", i+1), ReactionTime: time.Millisecond, Rule: engine.MustParseRule("B/S012345678")}
+	}
+	grid := func(right int) *engine.Engine {
+		params := engine.DefaultParams()
+		params.Rows, params.Cols = 3, 3
+		params.Species = species
+		params.History = 2
+		e, err := engine.New(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.SetCell(1, 0, engine.State{Species: 1})
+		e.SetCell(1, 1, engine.State{Species: right})
+		e.RunTicks(engine.Sequential, 1)
+		return e
+	}
+	e, same := grid(257), grid(1)
+	if e.Hash() == same.Hash() {
+		t.Error("species 1 and 257 hash the same")
+	}
+	if e.Complexity() == same.Complexity() {
+		t.Error("species 1 and 257 are as complex as two of species 1")
+	}
+	if cs := e.Clusters(); cs[1].Count != 1 || cs[1].Largest != 1 || cs[257].Count != 1 {
+		t.Errorf("clusters of species 1 %+v and 257 %+v, want one cell each", cs[1], cs[257])
+	}
+	if h := e.History(1, 1, nil); len(h) != 1 || h[0] != 257 {
+		t.Errorf("history %v, want [257]", h)
+	}
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// GridStats is a census of the grid, as returned by Engine.Stats. Its
+// slices are indexed by species id, dead cells included.
+type GridStats struct {
+	Tick       int
+	Population []int
+	// Births and Deaths count the cells that became and stopped being each
+	// species since the previous call to Stats, as OnCellChanged sees
+	// them: a cell taken over by another species is a death of one and a
+	// birth of the other. They are all 0 at the first call.
+	Births, Deaths []int
+	// Ages is the age distribution of each species: Ages[s][0] cells of s
+	// are of age 0 and Ages[s][k] between 2^(k-1) and 2^k-1.
+	Ages [][]int
+	// Clusters are the connected groups of each species; see Clusters.
+	Clusters []Clusters
+}
+
+// turnover counts the births and deaths of Stats.
+type turnover struct {
+	once   sync.Once
+	births []atomic.Int64 // by species id
+	deaths []atomic.Int64
+}
+
+// Stats returns a census of the grid, so that tools need not walk the
+// cells themselves. Births and deaths are counted from the first call on,
+// and every call takes those counted since the previous one, whoever made
+// it. Like Clusters it reads every cell, more than once.
+func (e *Engine) Stats() GridStats {
+	e.turnover.once.Do(func() {
+		e.turnover.births = make([]atomic.Int64, maxSpecies)
+		e.turnover.deaths = make([]atomic.Int64, maxSpecies)
+		e.OnCellChanged(func(x, y int, old, new State) {
+			e.turnover.deaths[old.Species].Add(1)
+			e.turnover.births[new.Species].Add(1)
+		})
+	})
+
+	st := GridStats{Tick: e.Ticks(), Clusters: e.Clusters()}
+	e.gridMu.RLock()
+	for k := range e.words {
+		w := word(e.words[k].Load())
+		s := w.species()
+		for len(st.Population) <= s { // a hybrid bred during the count
+			st.Population = append(st.Population, 0)
+			st.Ages = append(st.Ages, nil)
+		}
+		st.Population[s]++
+		bin := bits.Len(uint(w.age()))
+		for len(st.Ages[s]) <= bin {
+			st.Ages[s] = append(st.Ages[s], 0)
+		}
+		st.Ages[s][bin]++
+	}
+	e.gridMu.RUnlock()
+
+	n := max(len(e.Species()), len(st.Population), len(st.Clusters))
+	for len(st.Population) < n {
+		st.Population = append(st.Population, 0)
+		st.Ages = append(st.Ages, nil)
+	}
+	for len(st.Clusters) < n {
+		st.Clusters = append(st.Clusters, Clusters{})
+	}
+	st.Births, st.Deaths = make([]int, n), make([]int, n)
+	for s := range n {
+		st.Births[s] = int(e.turnover.births[s].Swap(0))
+		st.Deaths[s] = int(e.turnover.deaths[s].Swap(0))
+	}
+	return st
+}
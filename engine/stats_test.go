@@ -0,0 +1,46 @@
+package engine_test
+
+import (
+	"testing"
+
+	"app/engine"
+)
+
+// TestStats checks that the births and deaths of Stats account for the
+// change in population between two calls, and that its age histograms
+// count every cell.
+func TestStats(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 16, 16
+	params.Rand = engine.NewRand(1)
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Seed(0.4)
+	before := e.Stats()
+	e.RunTicks(engine.Sequential, 10)
+	after := e.Stats()
+	if after.Tick != 10 {
+		t.Errorf("tick %d, want 10", after.Tick)
+	}
+	for s := range after.Population {
+		if s < len(before.Births) && before.Births[s]+before.Deaths[s] != 0 {
+			t.Errorf("species %d: births or deaths before the first call", s)
+		}
+		if d := after.Births[s] - after.Deaths[s]; before.Population[s]+d != after.Population[s] {
+			t.Errorf("species %d: %d cells and %d born and %d dead make %d, not %d",
+				s, before.Population[s], after.Births[s], after.Deaths[s], before.Population[s]+d, after.Population[s])
+		}
+		n := 0
+		for _, count := range after.Ages[s] {
+			n += count
+		}
+		if n != after.Population[s] {
+			t.Errorf("species %d: ages of %d cells out of %d", s, n, after.Population[s])
+		}
+	}
+	if again := e.Stats(); again.Births[1] != 0 || again.Deaths[1] != 0 {
+		t.Errorf("births and deaths counted twice")
+	}
+}
@@ -0,0 +1,44 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestSwitching checks that live cells switch species in place as the
+// matrix says, and that dead cells are left alone.
+func TestSwitching(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 20, 20
+	still := engine.MustParseRule("B/S012345678") // every cell survives, none is born
+	params.Species = []engine.Species{
+		{Name: "a", ReactionTime: time.Millisecond, Rule: still},
+		{Name: "b", ReactionTime: time.Millisecond, Rule: still},
+		{Name: "c", ReactionTime: time.Millisecond, Rule: still},
+	}
+	params.Switching = [][]float64{{0, 1}, {0, 0, 0.5}}
+	params.Rand = engine.NewRand(3)
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SeedDensities([]float64{0.4, 0, 0})
+	before := e.Stats().Population
+	e.RunTicks(engine.Sequential, 1)
+	after := e.Stats().Population
+	if after[1] != 0 || after[2] != before[1] || after[3] != 0 || after[0] != before[0] {
+		t.Errorf("a switching to b: population %v, then %v", before, after)
+	}
+	e.RunTicks(engine.Sequential, 1)
+	final := e.Stats().Population
+	if final[2]+final[3] != after[2] || final[3] == 0 || final[2] == 0 {
+		t.Errorf("b switching to c half the time: population %v, then %v", after, final)
+	}
+
+	params.Switching = [][]float64{{0, 0.7, 0.7}}
+	if _, err := engine.New(params); err == nil {
+		t.Error("accepted switching probabilities summing to more than 1")
+	}
+}
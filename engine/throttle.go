@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"sync"
+	"time"
+)
+
+// A Throttle caps the cell updates of the engines that share it through
+// Params.Throttle at a number a second, whatever their reaction times, so
+// that a simulation runs politely in the background rather than on every
+// core. Cells held back update late, as if they were slower. It only
+// paces the engines running in real time, under Start, StartTiled and
+// StartSynchronous, not RunTicks.
+type Throttle struct {
+	mu   sync.Mutex
+	per  float64   // nanoseconds an update
+	next time.Time // when the updates so far have been paid for
+}
+
+// NewThrottle returns a Throttle of rate cell updates a second, which must
+// be positive.
+func NewThrottle(rate float64) *Throttle {
+	return &Throttle{per: float64(time.Second) / rate}
+}
+
+// wait takes n updates just made out of the budget, sleeping until they
+// are paid for or done is closed.
+func (t *Throttle) wait(n int, done <-chan struct{}) {
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	t.next = t.next.Add(time.Duration(float64(n) * t.per))
+	until := t.next
+	t.mu.Unlock()
+	d := time.Until(until)
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-done:
+	}
+}
+
+// pace holds the caller back after n updates, if the engine has a
+// Throttle. It must not be called under runMu, so as not to hold up Pause.
+func (e *Engine) pace(n int) {
+	if e.throttle != nil && n > 0 {
+		e.throttle.wait(n, e.done)
+	}
+}
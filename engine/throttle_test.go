@@ -0,0 +1,45 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestThrottle checks that a Throttle shared by two engines caps their
+// updates together, whatever their reaction times.
+func TestThrottle(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 10, 10
+	params.Species = []engine.Species{{Name: "a", ReactionTime: time.Millisecond, Rule: engine.Conway}}
+	params.DeadReactionTime = time.Millisecond
+	params.Throttle = engine.NewThrottle(5000)
+	var engines []*engine.Engine
+	for range 2 {
+		e, err := engine.New(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engines = append(engines, e)
+	}
+	engines[0].Start()
+	engines[1].StartTiled()
+	const run = 200 * time.Millisecond
+	time.Sleep(run)
+	for _, e := range engines {
+		e.Stop()
+	}
+	updates := int64(0)
+	for _, e := range engines {
+		for x := range params.Rows {
+			for y := range params.Cols {
+				updates += e.Inspect(x, y).Updates
+			}
+		}
+	}
+	// unthrottled, each of the 200 cells would update about 200 times
+	if most := int64(5000 * run.Seconds() * 1.5); updates > most || updates == 0 {
+		t.Errorf("%d updates in %v, want at most %d", updates, run, most)
+	}
+}
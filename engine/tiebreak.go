@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+)
+
+// TieBreak selects which species a dead cell is born as when several tie
+// for the most neighbours under the species' rules. The choice shifts the
+// balance of the species measurably over a run.
+type TieBreak int
+
+const (
+	// TieRandom picks one of the tied species at random.
+	TieRandom TieBreak = iota
+	// TiePriority picks the tied species listed first, the lowest id.
+	TiePriority
+	// TieAlternate takes the tied species in turn, one tie after the
+	// other across the grid.
+	TieAlternate
+	// TieOldest picks the species of the oldest neighbour of the tied
+	// species, at random among the oldest.
+	TieOldest
+)
+
+var TieBreaks = []TieBreak{TieRandom, TiePriority, TieAlternate, TieOldest}
+
+func (t TieBreak) String() string {
+	switch t {
+	case TieRandom:
+		return "random"
+	case TiePriority:
+		return "priority"
+	case TieAlternate:
+		return "alternate"
+	case TieOldest:
+		return "oldest"
+	default:
+		return fmt.Sprintf("TieBreak(%d)", int(t))
+	}
+}
+
+// ParseTieBreak returns the tie-breaking strategy with the given name.
+func ParseTieBreak(name string) (TieBreak, error) {
+	for _, t := range TieBreaks {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tie-break %q", name)
+}
+
+// breakTie returns the species of candidates, two or more in increasing
+// order, that a birth with neighbourhood n goes to.
+func (sr *speciesRules) breakTie(candidates []int, n Neighborhood) int {
+	switch TieBreak(sr.tie.Load()) {
+	case TiePriority:
+		return candidates[0]
+	case TieAlternate:
+		return candidates[(sr.turn.Add(1)-1)%uint64(len(candidates))]
+	case TieOldest:
+		best, oldest, tied := -1, -1, 0
+		for k, c := range n.Cells {
+			if !n.InGrid[k] || !c.Alive() {
+				continue
+			}
+			switch {
+			case !slices.Contains(candidates, c.Species) || c.Age < oldest:
+			case c.Age > oldest:
+				best, oldest, tied = c.Species, c.Age, 1
+			default:
+				if tied++; n.Rand.Intn(tied) == 0 {
+					best = c.Species
+				}
+			}
+		}
+		if best >= 0 {
+			return best
+		}
+	}
+	return candidates[n.Rand.Intn(len(candidates))]
+}
+
+// TieBreak returns how the species' rules settle ties between species for
+// a birth.
+func (e *Engine) TieBreak() TieBreak {
+	if sr, ok := e.transition.(*speciesRules); ok {
+		return TieBreak(sr.tie.Load())
+	}
+	return TieRandom
+}
+
+// SetTieBreak changes Params.TieBreak, taking effect at the next birth,
+// while the engine runs or not.
+func (e *Engine) SetTieBreak(t TieBreak) error {
+	sr, ok := e.transition.(*speciesRules)
+	if !ok {
+		return errNoRules
+	}
+	if t < TieRandom || t > TieOldest {
+		return fmt.Errorf("unknown tie-break %v", t)
+	}
+	old := TieBreak(sr.tie.Swap(int32(t)))
+	e.wakeAll()
+	e.logger.Info("tie-break changed", "from", old, "to", t)
+	return nil
+}
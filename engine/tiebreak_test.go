@@ -0,0 +1,50 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestTieBreak checks which species two births tied between a and b go to
+// under each deterministic strategy: each has a neighbour of both, a of
+// age 5 and b of age 9.
+func TestTieBreak(t *testing.T) {
+	for _, tc := range []struct {
+		tie          engine.TieBreak
+		first, other int
+	}{
+		{engine.TiePriority, 1, 1},
+		{engine.TieAlternate, 1, 2},
+		{engine.TieOldest, 2, 2},
+	} {
+		t.Run(tc.tie.String(), func(t *testing.T) {
+			params := engine.DefaultParams()
+			params.Rows, params.Cols = 3, 9
+			params.Species = []engine.Species{
+				{Name: "a", ReactionTime: time.Millisecond, Rule: engine.MustParseRule("B2/S")},
+				{Name: "b", ReactionTime: time.Millisecond, Rule: engine.MustParseRule("B2/S")},
+			}
+			params.Rand = engine.NewRand(1)
+			params.TieBreak = tc.tie
+			e, err := engine.New(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := e.Snapshot()
+			s.Cells[0][0], s.Cells[0][6] = engine.State{Species: 1, Age: 5}, engine.State{Species: 1, Age: 5}
+			s.Cells[2][2], s.Cells[2][8] = engine.State{Species: 2, Age: 9}, engine.State{Species: 2, Age: 9}
+			if err := e.Restore(s); err != nil {
+				t.Fatal(err)
+			}
+			e.RunTicks(engine.Sequential, 1)
+			if got, other := e.Cell(1, 1).Species, e.Cell(1, 7).Species; got != tc.first || other != tc.other {
+				t.Errorf("births as species %d and %d, want %d and %d", got, other, tc.first, tc.other)
+			}
+		})
+	}
+	if _, err := engine.ParseTieBreak("coin"); err == nil {
+		t.Error("ParseTieBreak accepted an unknown strategy")
+	}
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"container/heap"
+	"time"
+)
+
+// DefaultTileSize is the side of the tiles of StartTiled and the Tiles
+// model unless Params.TileSize says otherwise.
+const DefaultTileSize = 16
+
+// tiles returns the cells of every tile of the grid, row by row within a
+// tile. Tiles along the bottom and right edges may be smaller.
+func (e *Engine) tiles() [][]*Cell {
+	k := e.tileSize
+	var tiles [][]*Cell
+	for top := 0; top < e.rows; top += k {
+		for left := 0; left < e.cols; left += k {
+			var cells []*Cell
+			for i := top; i < min(top+k, e.rows); i++ {
+				for j := left; j < min(left+k, e.cols); j++ {
+					cells = append(cells, e.cell(i, j))
+				}
+			}
+			tiles = append(tiles, cells)
+		}
+	}
+	return tiles
+}
+
+// StartTiled is an alternative to Start with the same asynchronous updates
+// on far fewer goroutines: one per tile of Params.TileSize cells square,
+// which keeps its cells in order of their next update and sleeps until the
+// first is due. With Params.Sleep set, a settled tile sleeps until a cell
+// in or next to it changes. The goroutines run until Stop.
+func (e *Engine) StartTiled() {
+	e.logger.Debug("engine started", "update", "tiled", "rows", e.rows, "cols", e.cols, "tile_size", e.tileSize)
+	e.realTime.Store(true)
+	var tiles []*tile
+	w := e.useWorkers(e.tileSize, 0)
+	for i, cells := range e.tiles() {
+		tiles = append(tiles, &tile{cells: cells, wake: make(chan struct{}, 1), busy: &w.busy[i]})
+	}
+	if e.sleep > 0 {
+		e.sleepers.Store(&sleepers{tiles: tiles, across: (e.cols + e.tileSize - 1) / e.tileSize})
+	}
+	for _, t := range tiles {
+		go e.runTile(t)
+	}
+	go e.every(e.interval, func() { e.unlessPaused(e.endTick) })
+	go e.runAgents(e.agentTau)
+}
+
+// runTile updates the cells of t on their reaction times until Stop. While
+// the engine is paused the cells fall due as usual but are not updated, as
+// the goroutines of Start sleep through a pause. With Params.Sleep set, once
+// every cell has updated that many times on average with nothing changing
+// in or next to the tile, its cells stop until something does, and then
+// fall due afresh.
+func (e *Engine) runTile(t *tile) {
+	due := make(dueCells, 0, len(t.cells))
+	start := e.sim.now()
+	for _, c := range t.cells {
+		due = append(due, dueCell{start + c.schedule(c.reactionTime()), e.rand.Int63(), c})
+	}
+	heap.Init(&due)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	seen, quiet := t.stir.Load(), 0 // updates since the last change
+	for {
+		if e.sleep > 0 && quiet >= e.sleep*len(t.cells) {
+			t.asleep.Store(true)
+			if t.stir.Load() == seen {
+				select {
+				case <-t.wake:
+				case <-e.done:
+					return
+				}
+			}
+			t.asleep.Store(false)
+			select {
+			case <-t.wake:
+			default:
+			}
+			quiet = 0
+			now := e.sim.now()
+			for i := range due {
+				d := &due[i]
+				d.cell.skip()
+				d.at, d.order = now+d.cell.schedule(d.cell.reactionTime()), e.rand.Int63()
+			}
+			heap.Init(&due)
+		}
+		timer.Reset(e.sim.wall(due[0].at - e.sim.now()))
+		select {
+		case <-timer.C:
+		case <-e.done:
+			return
+		}
+		now := e.sim.now()
+		updates := 0
+		step := func(update bool) {
+			for due[0].at <= now {
+				d := &due[0]
+				if update {
+					d.cell.measure()
+					d.cell.computeNextState()
+					d.cell.applyNextState()
+					updates++
+				} else {
+					d.cell.skip()
+				}
+				d.at, d.order = now+d.cell.schedule(d.cell.reactionTime()), e.rand.Int63()
+				heap.Fix(&due, 0)
+			}
+		}
+		updated := false
+		e.unlessPaused(func() {
+			start := time.Now()
+			step(true)
+			t.busy.Add(int64(time.Since(start)))
+			updated = true
+		})
+		if !updated {
+			step(false)
+		}
+		quiet += updates
+		if s := t.stir.Load(); s != seen {
+			seen, quiet = s, 0
+		}
+		e.pace(updates)
+	}
+}
@@ -0,0 +1,248 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// State is the externally visible state of one cell.
+type State struct {
+	Species int     // 0 = dead
+	Age     int     // updates survived as Species, up to MaxAge; 0 for newborn and dead cells
+	Energy  float64 // remaining energy, when Params.Energy is enabled
+	Value   int     // integer payload for rules that need one, e.g. sand grains
+	U, V    float64 // continuous concentrations, e.g. for reaction-diffusion
+	// Wall marks an immutable obstacle: it is dead, never updates and is
+	// reported to its neighbours as outside the grid. Walls are placed
+	// with Engine.SetCell; a Transition cannot create them.
+	Wall bool
+	// Refractory marks a dead cell still in the refractory period of the
+	// species that died there, which will not be born whatever
+	// Transition.Next returns. It is only set in the cell's own State
+	// passed to Next.
+	Refractory bool
+}
+
+func (s State) Alive() bool { return s.Species != Dead }
+
+// Neighborhood summarises a cell's live neighbours.
+type Neighborhood struct {
+	// Counts is indexed by species id; Counts[Dead] is always 0. In a
+	// Volume, Counts and Total cover all 26 neighbours. Under a Kernel
+	// they are weighted and cover its radius.
+	Counts []int
+	Total  int
+	// Cells holds the state of each neighbour, in the order of Moore.
+	// Walls, and neighbours beyond the edge of the grid under a dead or
+	// alive Boundary, have InGrid false; the latter are dead or species 1.
+	// Under a wrapping or reflecting boundary every neighbour is in the
+	// grid.
+	Cells  [8]State
+	InGrid [8]bool
+	// Far holds, for a Ranged transition or a Kernel, the state of every cell within
+	// its radius, in the order of Offsets. Cells beyond the edge follow
+	// the Boundary as in Cells.
+	Far []State
+	// Below and Above hold the corresponding cell of the adjacent layers
+	// when the engine is part of a Stack.
+	Below, Above       State
+	HasBelow, HasAbove bool
+	// Rand is the engine's source of randomness, for stochastic
+	// transitions.
+	Rand Rand
+}
+
+// A Transition computes the next state of a cell from its current state and
+// its neighbourhood. Only the Species, Value, U and V of the returned State
+// are used; the engine maintains Age and Energy itself. Next is called
+// concurrently from many goroutines and must not retain n.Counts or n.Far.
+type Transition interface {
+	Next(self State, n Neighborhood) State
+}
+
+// A Ranged transition sees beyond the Moore neighbourhood: every cell
+// within Radius rows and columns of the updating cell is reported in
+// Neighborhood.Far.
+type Ranged interface {
+	Transition
+	Radius() int
+}
+
+// A Relocating transition moves cells across the grid: on its update, a live
+// cell for which Unhappy is true moves to an empty cell picked at random,
+// taking its state along, instead of taking the state Next returns.
+type Relocating interface {
+	Transition
+	Unhappy(self State, n Neighborhood) bool
+}
+
+var offsetCache sync.Map // radius -> [][2]int
+
+// Offsets lists the row and column offsets of the cells within radius rows
+// and columns of a cell, excluding the cell itself, row by row.
+func Offsets(radius int) [][2]int {
+	if o, ok := offsetCache.Load(radius); ok {
+		return o.([][2]int)
+	}
+	var o [][2]int
+	for dx := -radius; dx <= radius; dx++ {
+		for dy := -radius; dy <= radius; dy++ {
+			if dx != 0 || dy != 0 {
+				o = append(o, [2]int{dx, dy})
+			}
+		}
+	}
+	offsetCache.Store(radius, o)
+	return o
+}
+
+// speciesRules is the default Transition: each species survives by its own
+// B/S rule, and a dead cell is born as the dominant neighbouring species
+// whose rule allows it, chosen by the tie-break on a tie or bred by hybrid
+// from the tied ones if set, or, if strict, only when all its live neighbours
+// are of that species. Allies, the species of a team, count as one for
+// survival, dominance and strictness, and a birth goes to the ally with
+// the most neighbours.
+// The rules, indexed by species id, are replaced wholesale by SetRule and
+// add.
+type speciesRules struct {
+	mu     sync.Mutex // serializes SetRule and add
+	rules  atomic.Pointer[[]Rule]
+	strict bool
+	allies [][]int // by species id, the species of its team, nil if it has none
+	hybrid func(parents []int) (species int, ok bool)
+	tie    atomic.Int32  // a TieBreak
+	turn   atomic.Uint64 // ties settled so far, for TieAlternate
+}
+
+func newSpeciesRules(species []Species, strict bool) *speciesRules {
+	rules := make([]Rule, len(species))
+	for i, sp := range species {
+		rules[i] = sp.Rule
+	}
+	sr := &speciesRules{strict: strict}
+	sr.rules.Store(&rules)
+	for i, sp := range species {
+		if sp.Team == 0 {
+			continue
+		}
+		if sr.allies == nil {
+			sr.allies = make([][]int, len(species))
+		}
+		for j, other := range species {
+			if other.Team == sp.Team {
+				sr.allies[i] = append(sr.allies[i], j)
+			}
+		}
+	}
+	return sr
+}
+
+// allied returns how many of counts are of species s or its allies.
+func (sr *speciesRules) allied(s int, counts []int) int {
+	if s >= len(sr.allies) || sr.allies[s] == nil {
+		return counts[s]
+	}
+	n := 0
+	for _, a := range sr.allies[s] {
+		n += counts[a]
+	}
+	return n
+}
+
+func (sr *speciesRules) Next(self State, n Neighborhood) State { return sr.next(self, n, nil) }
+
+// next is Next with every species playing only, unless it is nil.
+func (sr *speciesRules) next(self State, n Neighborhood, only *Rule) State {
+	rules := *sr.rules.Load()
+	rule := func(s int) *Rule {
+		if only != nil {
+			return only
+		}
+		return &rules[s]
+	}
+	if self.Alive() {
+		if rule(self.Species).Survive[sr.allied(self.Species, n.Counts)] {
+			return self
+		}
+		return State{}
+	}
+	if sr.strict {
+		for s := 1; s < len(n.Counts); s++ {
+			if n.Counts[s] != 0 && sr.allied(s, n.Counts) != n.Total {
+				return State{} // mixed parentage
+			}
+		}
+	}
+
+	maxCount, maxOwn := 0, 0
+	var candidates []int
+	for s := 1; s < len(n.Counts); s++ {
+		if n.Counts[s] == 0 || !rule(s).Birth[n.Total] {
+			continue
+		}
+		count, own := sr.allied(s, n.Counts), n.Counts[s]
+		switch {
+		case count > maxCount || count == maxCount && own > maxOwn:
+			maxCount, maxOwn = count, own
+			candidates = append(candidates[:0], s)
+		case count == maxCount && own == maxOwn:
+			candidates = append(candidates, s)
+		}
+	}
+	if len(candidates) == 0 {
+		return State{}
+	}
+	if len(candidates) > 1 && sr.hybrid != nil {
+		if s, ok := sr.hybrid(candidates); ok {
+			return State{Species: s}
+		}
+	}
+	if len(candidates) > 1 {
+		return State{Species: sr.breakTie(candidates, n)}
+	}
+	return State{Species: candidates[0]}
+}
+
+// add appends the rule of a new species.
+func (sr *speciesRules) add(r Rule) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	rules := append(append([]Rule(nil), *sr.rules.Load()...), r)
+	sr.rules.Store(&rules)
+}
+
+// errNoRules is returned by SetRule when a Transition replaces the species'
+// rules.
+var errNoRules = errors.New("the species' rules are replaced by a transition")
+
+// Rule returns the B/S rule species currently plays, which SetRule may have
+// changed since New.
+func (e *Engine) Rule(species int) Rule {
+	if sr, ok := e.transition.(*speciesRules); ok {
+		return (*sr.rules.Load())[species]
+	}
+	return e.Species()[species].Rule
+}
+
+// SetRule replaces the B/S rule of species, taking effect at the next
+// update of each cell, while the engine runs or not.
+func (e *Engine) SetRule(species int, r Rule) error {
+	sr, ok := e.transition.(*speciesRules)
+	if !ok {
+		return errNoRules
+	}
+	if species < 1 || species >= len(e.Species()) {
+		return fmt.Errorf("no species %d", species)
+	}
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	rules := append([]Rule(nil), *sr.rules.Load()...)
+	rules[species] = r
+	sr.rules.Store(&rules)
+	e.wakeAll()
+	e.logger.Info("rule changed", "species", e.Species()[species].Name, "rule", r.String())
+	return nil
+}
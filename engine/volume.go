@@ -0,0 +1,71 @@
+package engine
+
+import "fmt"
+
+// Volume is a three-dimensional grid of Rows x Cols x Depth cells, built
+// from Depth engines stacked as slices. Each slice is an ordinary Engine,
+// but its cells count the 26 neighbours around them: the 8 of their own
+// slice plus the 3x3 blocks of the slices directly below and above. Rules
+// should therefore be written for neighbour counts up to MaxNeighbors.
+type Volume struct {
+	slices []*Engine
+}
+
+// NewVolume creates a volume of depth slices, each with parameters p. A
+// Transition in p is shared by every slice and must be safe for concurrent
+// use. The slices share their species, so p must not breed Hybrids.
+func NewVolume(p Params, depth int) (*Volume, error) {
+	if depth <= 0 {
+		return nil, fmt.Errorf("depth %d must be positive", depth)
+	}
+	if p.Hybrids > 0 {
+		return nil, fmt.Errorf("a volume cannot breed hybrids")
+	}
+	v := &Volume{}
+	for range depth {
+		e, err := New(p)
+		if err != nil {
+			return nil, err
+		}
+		e.volume = true
+		v.slices = append(v.slices, e)
+	}
+	if err := Stack(v.slices...); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *Volume) Rows() int  { return v.slices[0].rows }
+func (v *Volume) Cols() int  { return v.slices[0].cols }
+func (v *Volume) Depth() int { return len(v.slices) }
+
+// Slice returns the engine holding the cells at depth z, for hooks,
+// seeding and display.
+func (v *Volume) Slice(z int) *Engine {
+	return v.slices[z]
+}
+
+// Cell returns the state of the cell at (x, y, z).
+func (v *Volume) Cell(x, y, z int) State {
+	return v.slices[z].Cell(x, y)
+}
+
+// SetCell sets the cell at (x, y, z); see Engine.SetCell.
+func (v *Volume) SetCell(x, y, z int, s State) {
+	v.slices[z].SetCell(x, y, s)
+}
+
+// Seed fills every slice at random; see Engine.Seed.
+func (v *Volume) Seed(density float32) {
+	for _, e := range v.slices {
+		e.Seed(density)
+	}
+}
+
+// Start starts every slice.
+func (v *Volume) Start() {
+	for _, e := range v.slices {
+		e.Start()
+	}
+}
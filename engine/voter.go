@@ -0,0 +1,63 @@
+package engine
+
+// Voter is the voter model: on each update a cell copies the state of one
+// of its neighbours picked at random, empty or not, among those in the
+// grid. Clusters of a species coarsen until one holds the whole grid.
+type Voter struct{}
+
+func (Voter) Next(self State, n Neighborhood) State {
+	var in [8]int
+	k := 0
+	for i, ok := range n.InGrid {
+		if ok {
+			in[k] = i
+			k++
+		}
+	}
+	if k == 0 {
+		return self
+	}
+	return State{Species: n.Cells[in[n.Rand.Intn(k)]].Species}
+}
+
+// MajorityVote is the majority-vote model: on each update a cell takes the
+// state most common among itself and its neighbours in the grid, empty
+// counting as a state like any species. On a tie a cell keeps its state if
+// it is one of those tied and otherwise takes one of them at random.
+type MajorityVote struct{}
+
+func (MajorityVote) Next(self State, n Neighborhood) State {
+	empty := 0
+	for i, ok := range n.InGrid {
+		if ok && !n.Cells[i].Alive() {
+			empty++
+		}
+	}
+	votes := func(s int) int {
+		v := empty
+		if s != Dead {
+			v = n.Counts[s]
+		}
+		if s == self.Species {
+			v++
+		}
+		return v
+	}
+	best, most, tied := self.Species, votes(self.Species), 0
+	for s := range n.Counts {
+		switch v := votes(s); {
+		case s == self.Species || v < most:
+		case v > most:
+			best, most, tied = s, v, 1
+		case best != self.Species: // tied with the best of the others
+			tied++
+			if n.Rand.Intn(tied) == 0 {
+				best = s
+			}
+		}
+	}
+	if best == self.Species {
+		return self
+	}
+	return State{Species: best}
+}
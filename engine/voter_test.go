@@ -0,0 +1,63 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// opinions returns a full 8x8 grid of two species playing t.
+func opinions(t *testing.T, tr engine.Transition) *engine.Engine {
+	t.Helper()
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 8, 8
+	params.Species = []engine.Species{
+		{Name: "a", ReactionTime: time.Millisecond},
+		{Name: "b", ReactionTime: time.Millisecond},
+	}
+	params.Transition = tr
+	params.Rand = engine.NewRand(1)
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range e.Rows() {
+		for j := range e.Cols() {
+			e.SetCell(i, j, engine.State{Species: 1 + (i+j)%2})
+		}
+	}
+	return e
+}
+
+// TestVoter checks that the voter model reaches a consensus and keeps it.
+func TestVoter(t *testing.T) {
+	e := opinions(t, engine.Voter{})
+	for range 100 {
+		e.RunTicks(engine.Sequential, 100)
+		if pop := population(e); pop[1] == 0 || pop[2] == 0 {
+			e.RunTicks(engine.Sequential, 10)
+			if got := population(e); got[1] != pop[1] || got[2] != pop[2] {
+				t.Errorf("consensus %v broke to %v", pop, got)
+			}
+			return
+		}
+	}
+	t.Errorf("no consensus: %v", population(e))
+}
+
+// TestMajorityVote checks that a lone dissenter comes round to its
+// neighbours and that a uniform grid stays uniform.
+func TestMajorityVote(t *testing.T) {
+	e := opinions(t, engine.MajorityVote{})
+	for i := range e.Rows() {
+		for j := range e.Cols() {
+			e.SetCell(i, j, engine.State{Species: 1})
+		}
+	}
+	e.SetCell(3, 3, engine.State{Species: 2})
+	e.RunTicks(engine.Sequential, 1)
+	if pop := population(e); pop[1] != 64 {
+		t.Errorf("population %v, want all a", pop)
+	}
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Worker is the part of the grid one goroutine updates, a tile of
+// StartTiled and the Tiles model or a band of rows of StartSynchronous and
+// the Pool model, for telling how evenly the work is shared.
+type Worker struct {
+	Top, Left, Rows, Cols int
+	// Updates is the sum of the updates of its cells so far, and Busy how
+	// long the goroutine has spent updating them.
+	Updates int64
+	Busy    time.Duration
+}
+
+// workers are the regions of the goroutines of the latest run of the
+// engine: tiles of side tile, across of them to a row, or else bands of
+// band rows.
+type workers struct {
+	tile, across, band int
+	regions            []Worker // without Updates or Busy
+	busy               []atomic.Int64
+}
+
+// useWorkers returns the workers of tiles of side tile, or bands of band
+// rows if tile is 0, noting them as those of the engine's run.
+func (e *Engine) useWorkers(tile, band int) *workers {
+	if w := e.workers.Load(); w != nil && w.tile == tile && w.band == band {
+		return w
+	}
+	w := &workers{tile: tile, band: band}
+	if tile > 0 {
+		w.across = (e.cols + tile - 1) / tile
+		for top := 0; top < e.rows; top += tile {
+			for left := 0; left < e.cols; left += tile {
+				w.regions = append(w.regions, Worker{Top: top, Left: left, Rows: min(tile, e.rows-top), Cols: min(tile, e.cols-left)})
+			}
+		}
+	} else {
+		for top := 0; top < e.rows; top += band {
+			w.regions = append(w.regions, Worker{Top: top, Rows: min(band, e.rows-top), Cols: e.cols})
+		}
+	}
+	w.busy = make([]atomic.Int64, len(w.regions))
+	e.workers.Store(w)
+	return w
+}
+
+// noWorkers notes that the engine runs on a goroutine per cell, or on a
+// single one.
+func (e *Engine) noWorkers() { e.workers.Store(nil) }
+
+// owner returns the index of the worker of the cell at row x, column y.
+func (w *workers) owner(x, y int) int {
+	if w.tile > 0 {
+		return x/w.tile*w.across + y/w.tile
+	}
+	return x / w.band
+}
+
+// Workers returns the goroutines sharing the grid in the latest run of the
+// engine, nil when it ran on one per cell, as Start and the Goroutines
+// model do, or on a single one.
+func (e *Engine) Workers() []Worker {
+	w := e.workers.Load()
+	if w == nil {
+		return nil
+	}
+	e.gridMu.RLock()
+	defer e.gridMu.RUnlock()
+	ws := append([]Worker(nil), w.regions...)
+	for i := range ws {
+		r := &ws[i]
+		for x := r.Top; x < r.Top+r.Rows; x++ {
+			for y := r.Left; y < r.Left+r.Cols; y++ {
+				r.Updates += e.cell(x, y).updates.Load()
+			}
+		}
+		r.Busy = time.Duration(w.busy[i].Load())
+	}
+	return ws
+}
+
+// Owner returns the index in Workers of the worker updating the cell at row
+// x, column y, or -1 if there are none.
+func (e *Engine) Owner(x, y int) int {
+	w := e.workers.Load()
+	if w == nil {
+		return -1
+	}
+	return w.owner(x, y)
+}
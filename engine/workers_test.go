@@ -0,0 +1,52 @@
+package engine_test
+
+import (
+	"testing"
+
+	"app/engine"
+)
+
+// TestWorkers checks the regions, owners and update counts Workers reports
+// for the tiled and pooled models, and that the sequential one has none.
+func TestWorkers(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols, params.TileSize = 8, 12, 4
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.RunTicks(engine.Tiles, 3)
+	ws := e.Workers()
+	if len(ws) != 6 {
+		t.Fatalf("%d tiled workers, want 6", len(ws))
+	}
+	if got := e.Owner(5, 9); got != 5 {
+		t.Errorf("Owner(5, 9) = %d with tiles, want 5", got)
+	}
+	for i, w := range ws {
+		if w.Rows != 4 || w.Cols != 4 || w.Updates != 3*16 {
+			t.Errorf("tile %d: %+v, want 4x4 with %d updates", i, w, 3*16)
+		}
+	}
+
+	e.RunTicks(engine.Pool, 2)
+	total := int64(0)
+	for _, w := range e.Workers() {
+		if w.Cols != params.Cols || w.Busy <= 0 {
+			t.Errorf("band %+v: want all %d columns and some time busy", w, params.Cols)
+		}
+		if got := e.Owner(w.Top, 0); e.Workers()[got].Top != w.Top {
+			t.Errorf("Owner(%d, 0) = %d, a band from row %d", w.Top, got, e.Workers()[got].Top)
+		}
+		total += w.Updates
+	}
+	if want := int64(5 * params.Rows * params.Cols); total != want {
+		t.Errorf("%d updates across bands, want %d", total, want)
+	}
+
+	e.RunTicks(engine.Sequential, 1)
+	if ws := e.Workers(); ws != nil || e.Owner(0, 0) != -1 {
+		t.Errorf("sequential model has workers %v, owner %d", ws, e.Owner(0, 0))
+	}
+}
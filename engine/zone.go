@@ -0,0 +1,49 @@
+package engine
+
+import "fmt"
+
+// A Zone gives a rectangle of the grid parameters of its own, for
+// experiments in spatially varying dynamics such as a viscous half of the
+// grid beside a fluid one. Where zones overlap, the last one listed in
+// Params.Zones wins.
+type Zone struct {
+	// Top and Left are the row and column of the top left cell of the
+	// rectangle, Rows and Cols its size. It is cut short at the edges.
+	Top, Left, Rows, Cols int
+	// Speed, unless 0, is the pace of the cells of the zone: their
+	// reaction times are divided by it, so that at 2 they update twice as
+	// often, in Start, StartTiled and the Timed model.
+	Speed float64
+	// Rule, if set, is the B/S rule every species plays in the zone
+	// instead of its own. A Transition ignores it.
+	Rule *Rule
+	// Decay is the probability that a live cell of the zone dies at an
+	// update whatever its neighbours, on top of Params.Decay.
+	Decay float64
+}
+
+// validateZones checks the zones of p.
+func (p *Params) validateZones() error {
+	for i, z := range p.Zones {
+		switch {
+		case z.Rows <= 0 || z.Cols <= 0:
+			return fmt.Errorf("zone %d: size %dx%d must be positive", i+1, z.Rows, z.Cols)
+		case z.Top >= p.Rows || z.Left >= p.Cols || z.Top+z.Rows <= 0 || z.Left+z.Cols <= 0:
+			return fmt.Errorf("zone %d lies outside the %dx%d grid", i+1, p.Rows, p.Cols)
+		case z.Speed < 0:
+			return fmt.Errorf("zone %d: speed %v must not be negative", i+1, z.Speed)
+		case z.Decay < 0 || z.Decay > 1:
+			return fmt.Errorf("zone %d: decay probability %v must be in [0, 1]", i+1, z.Decay)
+		}
+	}
+	return nil
+}
+
+// Zones returns the zones of the grid, as in Params.Zones.
+func (e *Engine) Zones() []Zone { return append([]Zone(nil), e.zones...) }
+
+// zoneNext returns the next state of c under the rule of its zone, with
+// the species' rules' other settings.
+func (c *Cell) zoneNext(self State, n Neighborhood) State {
+	return c.e.transition.(*speciesRules).next(self, n, c.zone.Rule)
+}
@@ -0,0 +1,62 @@
+package engine_test
+
+import (
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestZone checks that a zone's rule holds inside it only, and that its
+// speed makes its cells update more often under the Timed model.
+func TestZone(t *testing.T) {
+	none := engine.MustParseRule("B/S")
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 8, 16
+	params.Species = []engine.Species{
+		{Name: "a", ReactionTime: time.Millisecond, Rule: engine.MustParseRule("B/S012345678")},
+	}
+	params.Zones = []engine.Zone{
+		{Top: 0, Left: 0, Rows: 8, Cols: 8, Rule: &none},
+		{Top: 0, Left: 8, Rows: 8, Cols: 8, Speed: 2},
+	}
+	params.DeadReactionTime = time.Millisecond
+	params.TickInterval = 100 * time.Millisecond
+	params.History = 1000
+	params.Rand = engine.NewRand(1)
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range e.Rows() {
+		for j := range e.Cols() {
+			e.SetCell(i, j, engine.State{Species: 1})
+		}
+	}
+	e.RunTicks(engine.Timed, 2)
+	var left, right int
+	for i := range e.Rows() {
+		for j := range e.Cols() {
+			alive := e.Cell(i, j).Alive()
+			switch {
+			case j < 8 && alive:
+				t.Errorf("cell %d, %d survived the zone without survival", i, j)
+			case j >= 8 && !alive:
+				t.Errorf("cell %d, %d died outside it", i, j)
+			}
+			if n := len(e.History(i, j, nil)); j < 8 {
+				left += n
+			} else {
+				right += n
+			}
+		}
+	}
+	if right < left*3/2 {
+		t.Errorf("%d updates in the fast zone against %d in the other", right, left)
+	}
+
+	params.Zones = []engine.Zone{{Top: 8, Left: 0, Rows: 2, Cols: 2}}
+	if _, err := engine.New(params); err == nil {
+		t.Errorf("a zone outside the grid was accepted")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+)
+
+// eventsKept is how many events each engine keeps for the event panel.
+const eventsKept = 1000
+
+// eventPanelLines is the most events the panel shows.
+const eventPanelLines = 8
+
+// writeEventLog appends a line to the file at path for every event of the
+// layers, with the wall-clock time, the layer's name and the event.
+func writeEventLog(path string, layers []*layer) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	for _, l := range layers {
+		l.e.OnEvent(func(ev engine.Event) {
+			fmt.Fprintf(f, "%s  %s  %s  %s\n", time.Now().Format(time.DateTime), l.name, ev.Kind, ev)
+		})
+	}
+	return nil
+}
+
+// drawEvents shows the latest events of l in the bottom left corner of the
+// rows by cols cells of the grid shown, newest last.
+func (d *display) drawEvents(l *layer, rows, cols int) {
+	var lines []string
+	for _, ev := range l.e.Events() {
+		lines = append(lines, ev.String())
+	}
+	if len(lines) == 0 {
+		lines = []string{"no events yet"}
+	}
+	n := min(len(lines), eventPanelLines, rows)
+	width := 2 * cols
+	style := tcell.StyleDefault.Reverse(true)
+	for i, line := range lines[len(lines)-n:] {
+		s := " " + line + " "
+		if utf8.RuneCountInString(s) > width {
+			s = string([]rune(s)[:width])
+		}
+		x, y := d.left, rows-n+i
+		for _, r := range s {
+			d.screen.SetContent(x, y, r, nil, style)
+			x++
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"math"
+	"math/rand"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"app/engine"
+)
+
+// fitnesses are the scores evolve can breed for, by name.
+var fitnesses = map[string]func(r sweepResult, ticks int) float64{
+	"coexistence": coexistence,
+	"interest":    func(r sweepResult, _ int) float64 { return interest(r) },
+}
+
+// runEvolve implements the "evolve" subcommand: a genetic search over the
+// rules and reaction times of the species and the density, starting from
+// candidates like those of explore. Every generation each is run headless
+// and scored, the best carried over unchanged and the rest replaced by the
+// offspring of parents picked by tournament, crossed over species by
+// species and mutated. The best found are written as config files.
+func runEvolve(args []string) {
+	cfg := defaultConfig()
+	if path := configFlag(args); path != "" {
+		if err := cfg.load(path); err != nil {
+			log.Fatalf("loading config: %v", err)
+		}
+	}
+	fs := flag.NewFlagSet("evolve", flag.ExitOnError)
+	fs.String("config", "", "load settings from a TOML or YAML file; other flags override it and the genomes vary it")
+	cfg.bindFlags(fs)
+	size := fs.Int("population", 30, "genomes per generation")
+	generations := fs.Int("generations", 20, "generations to breed")
+	elite := fs.Int("elite", 2, "best genomes carried over unchanged to the next generation")
+	mutation := fs.Float64("mutation-rate", 0.05, "probability that each gene of an offspring mutates")
+	fitness := fs.String("fitness", "coexistence", fmt.Sprintf("score to maximize, one of %v", slices.Sorted(maps.Keys(fitnesses))))
+	top := fs.Int("top", 5, "best genomes to list and write")
+	ticks := fs.Int("ticks", 500, "ticks to run each genome for, unless it settles first")
+	cycle := fs.Int("cycle", 8, "longest period of a cycle counted as settling")
+	parallel := fs.Int("parallel", 1, "genomes to run at once")
+	model := fs.String("model", "timed", fmt.Sprintf("how cells are updated, one of %v", engine.Models))
+	out := fs.String("out", "evolve", "directory to write the best genomes to as config files, empty for none")
+	fs.Parse(args)
+	closeLog, err := startLogging(&cfg)
+	if err != nil {
+		log.Fatalf("opening log: %v", err)
+	}
+	defer closeLog()
+
+	m, err := engine.ParseModel(*model)
+	if err != nil {
+		log.Fatalf("parsing model: %v", err)
+	}
+	score, ok := fitnesses[*fitness]
+	if !ok {
+		log.Fatalf("unknown fitness %q (want one of %v)", *fitness, slices.Sorted(maps.Keys(fitnesses)))
+	}
+	if *size < 2 || *generations < 1 || *top < 1 || *cycle < 1 || *parallel < 1 {
+		log.Fatalf("population must be at least 2, and generations, top, cycle and parallel at least 1")
+	}
+	if *elite < 0 || *elite >= *size {
+		log.Fatalf("elite must be at least 0 and less than the population")
+	}
+	if cfg.Mode != "life" || cfg.Depth > 1 {
+		log.Fatalf("evolve needs -mode life in 2D, whose species play rulestrings")
+	}
+	scs, err := cfg.speciesConfigs()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.Stats, cfg.StatsJSONL, cfg.Autosave, cfg.EventLog, cfg.MergeSize, cfg.Video, cfg.Montage = "", "", 0, "", 0, "", ""
+	cfg.Record, cfg.Panel = "", ""
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	rng := rand.New(rand.NewSource(cmp.Or(cfg.Seed, time.Now().UnixNano())))
+	rand.Seed(time.Now().UnixNano())
+	var pop []candidate // scored, best first
+	var fresh []Config  // to score and add to pop
+	for range *size {
+		fresh = append(fresh, cfg.randomCandidate(rng, scs))
+	}
+	for gen := 1; ; gen++ {
+		results, err := simulateAll(ctx, fresh, m, *ticks, *cycle, *parallel)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, r := range results {
+			pop = append(pop, candidate{&fresh[i], r, score(r, *ticks)})
+		}
+		slices.SortStableFunc(pop, func(a, b candidate) int { return cmp.Compare(b.score, a.score) })
+		mean := 0.0
+		for _, c := range pop {
+			mean += c.score / float64(len(pop))
+		}
+		fmt.Printf("generation %d: best %.3f, mean %.3f\n", gen, pop[0].score, mean)
+		if gen == *generations {
+			break
+		}
+		pick := func() *Config { // the best of three at random
+			best := pop[rng.Intn(len(pop))]
+			for range 2 {
+				if c := pop[rng.Intn(len(pop))]; c.score > best.score {
+					best = c
+				}
+			}
+			return best.cfg
+		}
+		fresh = nil
+		for range *size - *elite {
+			child := crossover(rng, pick(), pick())
+			child.mutate(rng, *mutation)
+			fresh = append(fresh, child)
+		}
+		pop = pop[:*elite]
+	}
+	if err := listCandidates(os.Stdout, *out, "evolve", pop[:min(*top, len(pop))]); err != nil {
+		log.Fatalf("writing genomes: %v", err)
+	}
+}
+
+// coexistence scores how long all the species of a run lived side by side,
+// from 0 up to 2: the fraction of the ticks before the first died out, 1
+// if none did, plus, if none did, the population of the scarcest species
+// over that of the most common, so that balanced survivors win.
+func coexistence(r sweepResult, ticks int) float64 {
+	first := 0
+	for _, t := range r.extinct[1:] {
+		if t > 0 && (first == 0 || t < first) {
+			first = t
+		}
+	}
+	if first > 0 {
+		return float64(first) / float64(ticks)
+	}
+	lo, hi := math.MaxInt, 0
+	for _, n := range r.population[1:] {
+		lo, hi = min(lo, n), max(hi, n)
+	}
+	return 1 + float64(lo)/float64(max(hi, 1))
+}
+
+// crossover returns a child of a and b with the seed of neither, each
+// species and the density taken from one parent or the other at random.
+func crossover(rng *rand.Rand, a, b *Config) Config {
+	c := a.clone()
+	c.Seed = rng.Int63()
+	if rng.Intn(2) == 0 {
+		c.Density = b.Density
+	}
+	for k := range c.Species {
+		if rng.Intn(2) == 0 {
+			c.Species[k] = b.Species[k]
+		}
+	}
+	return c
+}
+
+// mutate flips each birth and survival count of each species' rule, and
+// moves each reaction time and the density, with probability p.
+func (cfg *Config) mutate(rng *rand.Rand, p float64) {
+	for k := range cfg.Species {
+		sc := &cfg.Species[k]
+		r := engine.MustParseRule(sc.Rule)
+		for n := range 9 {
+			if n > 0 && rng.Float64() < p {
+				r.Birth[n] = !r.Birth[n]
+			}
+			if rng.Float64() < p {
+				r.Survive[n] = !r.Survive[n]
+			}
+		}
+		if !slices.Contains(r.Birth[:], true) {
+			r.Birth[1+rng.Intn(8)] = true
+		}
+		sc.Rule = r.String()
+		if rng.Float64() < p {
+			rt := float64(sc.ReactionTime) * math.Exp(0.3*rng.NormFloat64())
+			sc.ReactionTime = min(max(time.Duration(rt).Round(time.Millisecond), 10*time.Millisecond), 2*time.Second)
+		}
+	}
+	if rng.Float64() < p {
+		cfg.Density = min(max(cfg.Density+0.05*rng.NormFloat64(), 0.02), 0.9)
+	}
+}
@@ -0,0 +1,95 @@
+//go:build js && wasm
+
+// Command browser runs the automaton in a web page, entirely client side:
+// it registers a global nnca object that nnca.js drives and draws on a
+// canvas. Build it, and serve the directory, with
+//
+//	GOOS=js GOARCH=wasm go build -o examples/browser/nnca.wasm ./examples/browser
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" examples/browser
+//	python3 -m http.server -d examples/browser
+//
+// The engine runs under the Timed model, which keeps the species' reaction
+// times on a simulated clock on a single goroutine, as a browser has no
+// threads to run a goroutine per cell on.
+package main
+
+import (
+	"syscall/js"
+	"time"
+
+	"app/engine"
+)
+
+// wall is the id written for walls by frame.
+const wall = 255
+
+func main() {
+	var e *engine.Engine
+	var buf []byte
+	api := map[string]any{
+		// create(rows, cols, density, seed) makes a new grid of the
+		// default species, a seed of 0 picking one at random, and
+		// returns the names of the species, dead first.
+		"create": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			params := engine.DefaultParams()
+			params.Rows, params.Cols = args[0].Int(), args[1].Int()
+			seed := int64(args[3].Int())
+			if seed == 0 {
+				seed = time.Now().UnixNano()
+			}
+			params.Rand = engine.NewRand(seed)
+			var err error
+			if e, err = engine.New(params); err != nil {
+				return js.Global().Get("Error").New(err.Error())
+			}
+			e.Seed(float32(args[2].Float()))
+			buf = make([]byte, e.Rows()*e.Cols())
+			var names []any
+			for _, sp := range e.Species() {
+				names = append(names, sp.Name)
+			}
+			return names
+		}),
+		// step(ticks) runs the grid ticks ticks and returns the tick
+		// reached.
+		"step": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			e.RunTicks(engine.Timed, args[0].Int())
+			return e.Ticks()
+		}),
+		// frame(dst) copies the species id of every cell, row by row,
+		// to the Uint8Array dst, walls as 255, and returns the
+		// population of every species, dead first.
+		"frame": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			pop := make([]any, len(e.Species()))
+			for i := range pop {
+				pop[i] = 0
+			}
+			for i, row := range e.Snapshot().Cells {
+				for j, s := range row {
+					id := byte(min(s.Species, wall-1))
+					if s.Wall {
+						id = wall
+					} else if s.Species < len(pop) {
+						pop[s.Species] = pop[s.Species].(int) + 1
+					}
+					buf[i*e.Cols()+j] = id
+				}
+			}
+			js.CopyBytesToJS(args[0], buf)
+			return pop
+		}),
+		// paint(row, col, species) sets a cell; species 0 kills it.
+		"paint": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			x, y, s := args[0].Int(), args[1].Int(), args[2].Int()
+			if x >= 0 && x < e.Rows() && y >= 0 && y < e.Cols() {
+				e.SetCell(x, y, engine.State{Species: s})
+			}
+			return nil
+		}),
+	}
+	js.Global().Set("nnca", js.ValueOf(api))
+	if ready := js.Global().Get("nncaReady"); ready.Type() == js.TypeFunction {
+		ready.Invoke()
+	}
+	select {} // keep the functions alive
+}
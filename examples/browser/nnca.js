@@ -0,0 +1,100 @@
+// nnca.js runs nnca.wasm, built from main.go, and draws its grid on the
+// canvas #grid: one pixel per cell, scaled up. Space pauses, a click paints
+// a cell of the brush species, 1-9 picks the brush and r reseeds.
+
+const palette = {
+  dead: [0, 0, 0],
+  green: [0, 200, 0],
+  red: [220, 0, 0],
+  blue: [0, 80, 255],
+  wall: [128, 128, 128],
+};
+
+const options = Object.fromEntries(new URLSearchParams(location.search));
+const rows = Number(options.rows || 100);
+const cols = Number(options.cols || 160);
+const density = Number(options.density || 0.3);
+const ticksPerSecond = Number(options.tps || 10);
+
+const canvas = document.getElementById("grid");
+const status = document.getElementById("status");
+canvas.width = cols;
+canvas.height = rows;
+const ctx = canvas.getContext("2d");
+const image = ctx.createImageData(cols, rows);
+const cells = new Uint8Array(rows * cols);
+
+let names = [];
+let colors = [];
+let paused = false;
+let brush = 1;
+let tick = 0;
+
+function reseed() {
+  names = nnca.create(rows, cols, density, Number(options.seed || 0));
+  if (names instanceof Error) {
+    throw names;
+  }
+  colors = names.map((name) => palette[name] || [255, 255, 255]);
+  tick = 0;
+}
+
+function draw() {
+  const pop = nnca.frame(cells);
+  const px = image.data;
+  for (let i = 0; i < cells.length; i++) {
+    const c = cells[i] === 255 ? palette.wall : colors[cells[i]] || palette.dead;
+    px[4 * i] = c[0];
+    px[4 * i + 1] = c[1];
+    px[4 * i + 2] = c[2];
+    px[4 * i + 3] = 255;
+  }
+  ctx.putImageData(image, 0, 0);
+  const counts = names.slice(1).map((name, i) => `${name} ${pop[i + 1]}`);
+  status.textContent = [`tick ${tick}`, paused ? "paused" : "", ...counts,
+    `brush ${names[brush]} [1-9]`, "pause [space]  reseed [r]"].filter(Boolean).join("  ");
+}
+
+function run() {
+  let last = performance.now();
+  function frame(now) {
+    if (!paused && now - last >= 1000 / ticksPerSecond) {
+      tick = nnca.step(1);
+      last = now;
+    }
+    draw();
+    requestAnimationFrame(frame);
+  }
+  requestAnimationFrame(frame);
+}
+
+canvas.addEventListener("mousedown", (ev) => {
+  const r = canvas.getBoundingClientRect();
+  const row = Math.floor(((ev.clientY - r.top) / r.height) * rows);
+  const col = Math.floor(((ev.clientX - r.left) / r.width) * cols);
+  nnca.paint(row, col, ev.button === 2 ? 0 : brush);
+});
+canvas.addEventListener("contextmenu", (ev) => ev.preventDefault());
+
+document.addEventListener("keydown", (ev) => {
+  if (ev.key === " ") {
+    paused = !paused;
+    ev.preventDefault();
+  } else if (ev.key === "r") {
+    reseed();
+  } else if (ev.key >= "1" && ev.key <= "9" && Number(ev.key) < names.length) {
+    brush = Number(ev.key);
+  }
+});
+
+window.nncaReady = () => {
+  reseed();
+  run();
+};
+
+const go = new Go();
+WebAssembly.instantiateStreaming(fetch("nnca.wasm"), go.importObject)
+  .then((result) => go.run(result.instance))
+  .catch((err) => {
+    status.textContent = `loading nnca.wasm failed: ${err}`;
+  });
@@ -0,0 +1,29 @@
+-- Example layered rule, used by examples/layers.toml.
+--
+-- Conway's rule for every species, except that a cell is only born where
+-- the layer below holds a tree (forestfire species 1) and a live cell over
+-- burning ground dies.
+function nextState(self, neighbors)
+  local ground = neighbors.below and neighbors.below.species or 1
+  if ground == 2 then
+    return 0
+  end
+  if self.alive then
+    local same = neighbors[self.species]
+    if same == 2 or same == 3 then
+      return self.species
+    end
+    return 0
+  end
+
+  if neighbors.total ~= 3 or ground ~= 1 then
+    return 0
+  end
+  local best, bestCount = 0, 0
+  for id = 1, 3 do
+    if neighbors[id] > bestCount or (neighbors[id] == bestCount and math.random() < 0.5) then
+      best, bestCount = id, neighbors[id]
+    end
+  end
+  return best
+end
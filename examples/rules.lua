@@ -0,0 +1,31 @@
+-- Example rule script: go run . -rule-script examples/rules.lua
+--
+-- Conway's rule for every species, except that red also survives with a
+-- single red neighbour and a dead cell surrounded by exactly four greens
+-- is taken over by blue.
+function nextState(self, neighbors)
+  if self.alive then
+    local same = neighbors[self.species]
+    if same == 2 or same == 3 then
+      return self.species
+    end
+    if self.name == "red" and same == 1 then
+      return self.species
+    end
+    return 0
+  end
+
+  if neighbors.green == 4 and neighbors.total == 4 then
+    return "blue"
+  end
+  if neighbors.total ~= 3 then
+    return 0
+  end
+  local best, bestCount = 0, 0
+  for id = 1, 3 do
+    if neighbors[id] > bestCount or (neighbors[id] == bestCount and math.random() < 0.5) then
+      best, bestCount = id, neighbors[id]
+    end
+  end
+  return best
+end
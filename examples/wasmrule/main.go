@@ -0,0 +1,49 @@
+//go:build wasip1
+
+// Command wasmrule is an example rule plugin: HighLife (B36/S23) for every
+// species, with births going to the dominant neighbour species. Build it
+// with
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o highlife.wasm ./examples/wasmrule
+package main
+
+import "unsafe"
+
+var counts [64]int32
+
+//go:wasmimport env random
+func random() float64
+
+//go:wasmexport counts_ptr
+func countsPtr() int32 {
+	return int32(uintptr(unsafe.Pointer(&counts[0])))
+}
+
+//go:wasmexport next_state
+func nextState(self, total, nspecies int32) int32 {
+	if self != 0 {
+		if same := counts[self]; same == 2 || same == 3 {
+			return self
+		}
+		return 0
+	}
+	if total != 3 && total != 6 {
+		return 0
+	}
+	best, bestCount, ties := int32(0), int32(0), 0
+	for id := int32(1); id <= nspecies; id++ {
+		switch c := counts[id]; {
+		case c > bestCount:
+			best, bestCount, ties = id, c, 1
+		case c == bestCount && c > 0:
+			// reservoir-sample among tied species
+			ties++
+			if random() < 1/float64(ties) {
+				best = id
+			}
+		}
+	}
+	return best
+}
+
+func main() {}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"app/engine"
+)
+
+// runExplore implements the "explore" subcommand: it makes up candidates,
+// each the configured simulation with a random rule and reaction time for
+// every species, a random density and a seed, runs each briefly headless,
+// scores how interesting it stayed and lists the best, writing each as a
+// config file to replay it from.
+func runExplore(args []string) {
+	cfg := defaultConfig()
+	if path := configFlag(args); path != "" {
+		if err := cfg.load(path); err != nil {
+			log.Fatalf("loading config: %v", err)
+		}
+	}
+	fs := flag.NewFlagSet("explore", flag.ExitOnError)
+	fs.String("config", "", "load settings from a TOML or YAML file; other flags override it and the candidates vary it")
+	cfg.bindFlags(fs)
+	candidates := fs.Int("candidates", 100, "random rule sets to try")
+	top := fs.Int("top", 10, "best candidates to list and write")
+	ticks := fs.Int("ticks", 300, "ticks to run each candidate for, unless it settles first")
+	cycle := fs.Int("cycle", 8, "longest period of a cycle counted as settling")
+	parallel := fs.Int("parallel", 1, "candidates to run at once")
+	model := fs.String("model", "timed", fmt.Sprintf("how cells are updated, one of %v", engine.Models))
+	out := fs.String("out", "explore", "directory to write the best candidates to as config files, empty for none")
+	fs.Parse(args)
+	closeLog, err := startLogging(&cfg)
+	if err != nil {
+		log.Fatalf("opening log: %v", err)
+	}
+	defer closeLog()
+
+	m, err := engine.ParseModel(*model)
+	if err != nil {
+		log.Fatalf("parsing model: %v", err)
+	}
+	if *candidates < 1 || *top < 1 || *cycle < 1 || *parallel < 1 {
+		log.Fatalf("candidates, top, cycle and parallel must be at least 1")
+	}
+	if cfg.Mode != "life" || cfg.Depth > 1 {
+		log.Fatalf("explore needs -mode life in 2D, whose species play rulestrings")
+	}
+	scs, err := cfg.speciesConfigs()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.Stats, cfg.StatsJSONL, cfg.Autosave, cfg.EventLog, cfg.MergeSize, cfg.Video, cfg.Montage = "", "", 0, "", 0, "", ""
+	cfg.Record, cfg.Panel = "", ""
+	rng := rand.New(rand.NewSource(cmp.Or(cfg.Seed, time.Now().UnixNano())))
+	runs := make([]Config, *candidates)
+	for i := range runs {
+		runs[i] = cfg.randomCandidate(rng, scs)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	rand.Seed(time.Now().UnixNano())
+	results, err := simulateAll(ctx, runs, m, *ticks, *cycle, *parallel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	order := make([]int, len(runs))
+	scores := make([]float64, len(runs))
+	for i, r := range results {
+		order[i], scores[i] = i, interest(r)
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(scores[b], scores[a]) })
+	order = order[:min(*top, len(order))]
+
+	var best []candidate
+	for _, i := range order {
+		best = append(best, candidate{&runs[i], results[i], scores[i]})
+	}
+	if err := listCandidates(os.Stdout, *out, "explore", best); err != nil {
+		log.Fatalf("writing candidates: %v", err)
+	}
+}
+
+// candidate is a configuration tried by explore or evolve, with the outcome
+// of its run and its score.
+type candidate struct {
+	cfg    *Config
+	result sweepResult
+	score  float64
+}
+
+// listCandidates writes a table of cands, best first, to w and, unless out
+// is empty, each as a config file named after prefix and its rank in the
+// directory out.
+func listCandidates(w io.Writer, out, prefix string, cands []candidate) error {
+	if out != "" {
+		if err := os.MkdirAll(out, 0o755); err != nil {
+			return err
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "rank\tscore\tseed\tdensity\trules\tlive\tcompression\tfile")
+	for rank, c := range cands {
+		var rules []string
+		for _, sc := range c.cfg.Species {
+			rules = append(rules, sc.Name+" "+sc.Rule+" "+sc.ReactionTime.String())
+		}
+		file := ""
+		if out != "" {
+			file = filepath.Join(out, fmt.Sprintf("%s-%d.toml", prefix, rank+1))
+			if err := writeCandidate(file, c.cfg); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(tw, "%d\t%.3f\t%d\t%.2f\t%s\t%.3f\t%.3f\t%s\n", rank+1, c.score, c.cfg.Seed, c.cfg.Density,
+			strings.Join(rules, ", "), liveFraction(c.result), c.result.complexity.Compression, file)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if out != "" && len(cands) > 0 {
+		fmt.Fprintf(w, "replay one with: %s -config %s\n", os.Args[0], filepath.Join(out, prefix+"-1.toml"))
+	}
+	return nil
+}
+
+// randomCandidate returns a copy of cfg whose species scs each play a
+// random rule, without birth on 0 neighbours, at a random reaction time,
+// seeded at a random density with a seed of its own.
+func (cfg *Config) randomCandidate(rng *rand.Rand, scs []SpeciesConfig) Config {
+	c := cfg.clone()
+	c.Seed = rng.Int63()
+	c.Density = 0.1 + 0.5*rng.Float64()
+	c.Densities = nil
+	c.Species = slices.Clone(scs)
+	for k := range c.Species {
+		var r engine.Rule
+		born, survive := 0.1+0.3*rng.Float64(), 0.2+0.4*rng.Float64()
+		for n := range 9 {
+			r.Birth[n] = n > 0 && rng.Float64() < born
+			r.Survive[n] = rng.Float64() < survive
+		}
+		if !slices.Contains(r.Birth[:], true) {
+			r.Birth[1+rng.Intn(8)] = true // the rule must breed to do anything
+		}
+		c.Species[k].Rule = r.String()
+		c.Species[k].ReactionTime = time.Duration(50+rng.Intn(451)) * time.Millisecond
+	}
+	return c
+}
+
+// interest scores how interesting the outcome of a run is, from 0 up to 1:
+// highest for grids about half alive, as opposed to dead or overrun, whose
+// compressibility is halfway between order and noise and that never
+// settled into a still life or short cycle.
+func interest(r sweepResult) float64 {
+	live := liveFraction(r)
+	c := r.complexity.Compression
+	score := 4 * live * (1 - live) * 4 * c * (1 - c)
+	if r.period > 0 {
+		score /= 4
+	}
+	return score
+}
+
+// liveFraction returns the fraction of the cells of a run alive at its end.
+func liveFraction(r sweepResult) float64 {
+	total := 0
+	for _, n := range r.population {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return 1 - float64(r.population[engine.Dead])/float64(total)
+}
+
+// writeCandidate writes cfg to path as a TOML config file.
+func writeCandidate(path string, cfg *Config) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := toml.NewEncoder(f).Encode(cfg); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return f.Close()
+}
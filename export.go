@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"app/pattern"
+)
+
+// export writes the shown layer of d to a pattern file in its pattern
+// directory, named after the time, and reports where in its banner.
+func (d *display) export() {
+	if d.noExport {
+		d.flash("export is disabled here")
+		return
+	}
+	format, err := pattern.FormatNamed(d.exportAs)
+	if err == nil {
+		path := filepath.Join(d.patternDir, "nnca-"+time.Now().Format("20060102-150405")+format.Extensions[0])
+		if err = writePattern(path, format, d.layer()); err == nil {
+			d.flash("exported to " + path)
+			return
+		}
+	}
+	d.flash(fmt.Sprintf("export failed: %v", err))
+}
+
+// writePattern writes the live cells and walls of l to the file at path in
+// format. RLE files also get the comment naming the species.
+func writePattern(path string, format pattern.Format, l *layer) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if format.Name == "rle" {
+		err = l.e.ExportRLE(f)
+	} else {
+		err = format.Write(f, l.e.Pattern())
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// formatNames lists the names of the pattern formats, for flag help.
+func formatNames() []string {
+	var names []string
+	for _, f := range pattern.Formats {
+		names = append(names, f.Name)
+	}
+	return names
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"app/engine"
+	"app/hashlife"
+	"app/pattern"
+)
+
+// runFastForward implements the "fastforward" subcommand: it reads a grid,
+// moves it on by -generations synchronous generations at once with
+// HashLife (see engine.Engine.FastForward) and writes where it ended, for
+// replay to run on from asynchronously, or as a starting pattern. Autosaves,
+// state files and dumps keep their size; a pattern is centred in a grid of
+// the configured size.
+func runFastForward(args []string) {
+	cfg := defaultConfig()
+	if path := configFlag(args); path != "" {
+		if err := cfg.load(path); err != nil {
+			log.Fatalf("loading config: %v", err)
+		}
+	}
+	fs := flag.NewFlagSet("fastforward", flag.ExitOnError)
+	fs.String("config", "", "load settings from a TOML or YAML file; other flags override it")
+	cfg.bindFlags(fs)
+	gens := fs.Uint64("generations", 1_000_000, "generations to move the grid on")
+	layer := fs.Int("layer", 0, "layer of autosaves, state files and dumps to read, 0 for the bottom one")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s fastforward [flags] IN OUT\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "IN is a pattern file, an autosave (.gob), a state file (.json), a map file (.nnmap) or text written by dump (.txt).")
+		fmt.Fprintln(fs.Output(), "OUT is a state file (.json), an autosave (.gob), a map file (.nnmap) or a pattern file.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	in, out := fs.Arg(0), fs.Arg(1)
+
+	g, err := loadGrid(in, *layer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p := &pattern.Pattern{Height: len(g), Cells: g}
+	for _, row := range g {
+		p.Width = max(p.Width, len(row))
+	}
+	top, left := 0, 0
+	if isState(in) || strings.EqualFold(filepath.Ext(in), ".txt") {
+		cfg.Rows, cfg.Cols = p.Height, p.Width
+	} else if top, left = (cfg.Rows-p.Height)/2, (cfg.Cols-p.Width)/2; top < 0 || left < 0 {
+		log.Fatalf("%s: the %dx%d pattern does not fit the %dx%d grid", in, p.Height, p.Width, cfg.Rows, cfg.Cols)
+	}
+	params, err := cfg.params()
+	if err != nil {
+		log.Fatal(err)
+	}
+	e, err := engine.New(params)
+	if err != nil {
+		log.Fatal(err)
+	}
+	stamp(e, p, top, left, 0)
+
+	start := time.Now()
+	switch err := e.FastForward(*gens); {
+	case errors.Is(err, hashlife.ErrTooLarge):
+		slog.Warn("fast-forward stopped early; writing the grid as far as it got", "err", err)
+	case err != nil:
+		log.Fatal(err)
+	default:
+		fmt.Fprintf(os.Stderr, "%d generations in %v\n", *gens, time.Since(start).Round(time.Millisecond))
+	}
+
+	if isState(out) {
+		if err := saveState(out, savedState{Saved: time.Now(), Layers: []engine.Snapshot{e.Snapshot()}, Config: cfg.dumped()}); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	f, err := pattern.FormatOf(out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p = e.Pattern()
+	if f.Name != "rle" {
+		single(p)
+	}
+	w, err := os.Create(out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Write(w, p); err != nil {
+		w.Close()
+		log.Fatalf("%s: %v", out, err)
+	}
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"app/engine"
+)
+
+// flow returns the flow field of cfg.Flow, nil if it is empty: a constant
+// wind written DX,DY, in rows down and columns right, or a flow file. A flow
+// file holds a row of the field per line, each cell a DX,DY pair, the pairs
+// separated by spaces; blank lines and those starting with # are skipped.
+// The field is tiled across a grid larger than it, so that a single line
+// makes a shear that varies across the columns and a single column of
+// lines one that varies down the rows.
+func (cfg *Config) flow() (engine.Flow, error) {
+	if cfg.Flow == "" {
+		return nil, nil
+	}
+	if dx, dy, err := parseVelocity(cfg.Flow); err == nil {
+		return engine.Wind(dx, dy), nil
+	} else if _, statErr := os.Stat(cfg.Flow); statErr != nil {
+		return nil, fmt.Errorf("flow %q is neither DX,DY (%v) nor a file", cfg.Flow, err)
+	}
+	data, err := os.ReadFile(cfg.Flow)
+	if err != nil {
+		return nil, err
+	}
+	var field [][][2]float64
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var row [][2]float64
+		for pair := range strings.FieldsSeq(line) {
+			dx, dy, err := parseVelocity(pair)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", cfg.Flow, i+1, err)
+			}
+			row = append(row, [2]float64{dx, dy})
+		}
+		if len(field) > 0 && len(row) != len(field[0]) {
+			return nil, fmt.Errorf("%s:%d: %d cells, not %d as above", cfg.Flow, i+1, len(row), len(field[0]))
+		}
+		field = append(field, row)
+	}
+	if len(field) == 0 {
+		return nil, fmt.Errorf("%s: empty flow field", cfg.Flow)
+	}
+	return func(x, y int) (float64, float64) {
+		row := field[x%len(field)]
+		v := row[y%len(row)]
+		return v[0], v[1]
+	}, nil
+}
+
+// parseVelocity parses a velocity written DX,DY.
+func parseVelocity(s string) (dx, dy float64, err error) {
+	a, b, ok := strings.Cut(s, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("velocity %q is not DX,DY", s)
+	}
+	if dx, err = strconv.ParseFloat(strings.TrimSpace(a), 64); err == nil {
+		dy, err = strconv.ParseFloat(strings.TrimSpace(b), 64)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("velocity %q: %w", s, err)
+	}
+	return dx, dy, nil
+}
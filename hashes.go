@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+
+	"app/engine"
+)
+
+// onTickHash calls f after every tick of layers with a hash of their grids
+// chained to the hash of the tick before, so that two runs hash equal at a
+// tick only if they went through the same grids up to it.
+func onTickHash(layers []*layer, f func(tick int, h uint64)) {
+	var h uint64
+	layers[len(layers)-1].e.OnTick(func(s engine.Stats) { // layers tick bottom first
+		buf := binary.LittleEndian.AppendUint64(nil, h)
+		for _, l := range layers {
+			buf = binary.LittleEndian.AppendUint64(buf, l.e.Hash())
+		}
+		sum := fnv.New64a()
+		sum.Write(buf)
+		h = sum.Sum64()
+		f(s.Tick, h)
+	})
+}
+
+// writeHashes writes a line with the tick and chained hash of layers to w
+// after every tick.
+func writeHashes(w io.Writer, layers []*layer) {
+	onTickHash(layers, func(tick int, h uint64) { fmt.Fprintf(w, "%d %016x\n", tick, h) })
+}
+
+// hashCheck compares the hashes of a run with those written by an earlier
+// one.
+type hashCheck struct {
+	want     map[int]uint64 // by tick
+	checked  int            // ticks
+	mismatch int            // the first tick whose hash differs, or 0
+}
+
+// verifyHashes reads the hashes written by writeHashes to the file at path
+// and checks those of layers against them after every tick.
+func verifyHashes(path string, layers []*layer) (*hashCheck, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	hc := &hashCheck{want: map[int]uint64{}}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		var tick int
+		var h uint64
+		if _, err := fmt.Sscanf(sc.Text(), "%d %x", &tick, &h); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		hc.want[tick] = h
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	onTickHash(layers, func(tick int, h uint64) {
+		want, ok := hc.want[tick]
+		if !ok {
+			return
+		}
+		hc.checked++
+		if want != h && hc.mismatch == 0 {
+			hc.mismatch = tick
+		}
+	})
+	return hc, nil
+}
+
+// result describes the outcome of the check, and whether it passed: every
+// tick of the file was run, with the same hash.
+func (hc *hashCheck) result() (string, bool) {
+	switch {
+	case hc.mismatch != 0:
+		return fmt.Sprintf("hashes differ from tick %d on", hc.mismatch), false
+	case hc.checked < len(hc.want):
+		return fmt.Sprintf("hashes match for the %d ticks run, of %d recorded", hc.checked, len(hc.want)), false
+	}
+	return fmt.Sprintf("hashes match for all %d ticks", hc.checked), true
+}
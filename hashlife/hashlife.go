@@ -0,0 +1,300 @@
+// Package hashlife advances deterministic cellular automata of up to 256
+// states on the Moore neighbourhood by huge numbers of generations at
+// once, with Gosper's HashLife: the plane is a quadtree of squares, each
+// stored once however often it appears, and the future of each square is
+// worked out once and remembered, so that the regular, repeating parts of
+// a pattern cost next to nothing however long they run.
+//
+// The plane is unbounded and state 0, the dead state, must stay dead among
+// dead neighbours: rules with birth on 0 neighbours are not supported.
+package hashlife
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// Rule returns the next state of a cell in state self whose neighbours, in
+// rows from the top left one to the bottom right one, skipping the cell
+// itself, are in the states of n. Rule(0, [8]uint8{}) must be 0.
+type Rule func(self uint8, n [8]uint8) uint8
+
+// ErrTooLarge is returned by Advance when the squares it would have to
+// remember outgrow the universe's limit.
+var ErrTooLarge = errors.New("hashlife: too many distinct squares")
+
+// node is a square of 2^level cells on a side: a single cell of state at
+// level 0, else four quadrants of the level below.
+type node struct {
+	nw, ne, sw, se *node
+	level          uint8
+	state          uint8
+	pop            uint64 // cells not in state 0
+}
+
+type quad [4]*node
+
+type stepKey struct {
+	n *node
+	j uint8
+}
+
+// Universe is an unbounded plane of cells, all dead but for those set.
+// It is not safe for concurrent use.
+type Universe struct {
+	rule     Rule
+	limit    int
+	leaves   [256]*node
+	nodes    map[quad]*node
+	empty    []*node // by level
+	steps    map[stepKey]*node
+	root     *node // centred on the origin
+	gens     uint64
+	overflow bool
+}
+
+// New returns an empty universe playing rule, which remembers at most
+// about limit distinct squares before it forgets what it worked out so
+// far, and gives up with ErrTooLarge if a single step needs four times
+// that.
+func New(rule Rule, limit int) *Universe {
+	u := &Universe{rule: rule, limit: limit, nodes: map[quad]*node{}, steps: map[stepKey]*node{}}
+	for s := range u.leaves {
+		u.leaves[s] = &node{state: uint8(s), pop: uint64(min(s, 1))}
+	}
+	u.root = u.emptyNode(3)
+	return u
+}
+
+// join returns the square of the four quadrants given.
+func (u *Universe) join(nw, ne, sw, se *node) *node {
+	q := quad{nw, ne, sw, se}
+	if n, ok := u.nodes[q]; ok {
+		return n
+	}
+	if len(u.nodes) > 4*u.limit {
+		u.overflow = true
+	}
+	n := &node{nw: nw, ne: ne, sw: sw, se: se, level: nw.level + 1, pop: nw.pop + ne.pop + sw.pop + se.pop}
+	u.nodes[q] = n
+	return n
+}
+
+// emptyNode returns the dead square of level.
+func (u *Universe) emptyNode(level uint8) *node {
+	for int(level) >= len(u.empty) {
+		if len(u.empty) == 0 {
+			u.empty = append(u.empty, u.leaves[0])
+			continue
+		}
+		e := u.empty[len(u.empty)-1]
+		u.empty = append(u.empty, u.join(e, e, e, e))
+	}
+	return u.empty[level]
+}
+
+// expand returns n centred in a dead square twice its size.
+func (u *Universe) expand(n *node) *node {
+	e := u.emptyNode(n.level - 1)
+	return u.join(
+		u.join(e, e, e, n.nw), u.join(e, e, n.ne, e),
+		u.join(e, n.sw, e, e), u.join(n.se, e, e, e),
+	)
+}
+
+// centre returns the middle half of n.
+func (u *Universe) centre(n *node) *node {
+	return u.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+}
+
+// half returns the side of the root divided by 2, the distance from the
+// origin to its edges.
+func (u *Universe) half() int64 { return 1 << (u.root.level - 1) }
+
+// Set makes the cell at row x, column y of state s.
+func (u *Universe) Set(x, y int64, s uint8) {
+	for x < -u.half() || x >= u.half() || y < -u.half() || y >= u.half() {
+		u.root = u.expand(u.root)
+	}
+	u.root = u.set(u.root, x+u.half(), y+u.half(), s)
+}
+
+// set returns n with its cell at x, y from its top left corner of state s.
+func (u *Universe) set(n *node, x, y int64, s uint8) *node {
+	if n.level == 0 {
+		return u.leaves[s]
+	}
+	h := int64(1) << (n.level - 1)
+	nw, ne, sw, se := n.nw, n.ne, n.sw, n.se
+	switch {
+	case x < h && y < h:
+		nw = u.set(nw, x, y, s)
+	case x < h:
+		ne = u.set(ne, x, y-h, s)
+	case y < h:
+		sw = u.set(sw, x-h, y, s)
+	default:
+		se = u.set(se, x-h, y-h, s)
+	}
+	return u.join(nw, ne, sw, se)
+}
+
+// Get returns the state of the cell at row x, column y.
+func (u *Universe) Get(x, y int64) uint8 {
+	if x < -u.half() || x >= u.half() || y < -u.half() || y >= u.half() {
+		return 0
+	}
+	n := u.root
+	x, y = x+u.half(), y+u.half()
+	for n.level > 0 {
+		if n.pop == 0 {
+			return 0
+		}
+		h := int64(1) << (n.level - 1)
+		switch {
+		case x < h && y < h:
+			n = n.nw
+		case x < h:
+			n, y = n.ne, y-h
+		case y < h:
+			n, x = n.sw, x-h
+		default:
+			n, x, y = n.se, x-h, y-h
+		}
+	}
+	return n.state
+}
+
+// Population returns the number of cells not in state 0.
+func (u *Universe) Population() uint64 { return u.root.pop }
+
+// Generation returns the number of generations advanced so far.
+func (u *Universe) Generation() uint64 { return u.gens }
+
+// Advance moves the universe gens generations on, a power of two at a
+// time. If it returns ErrTooLarge the universe is left at the last power
+// of two it reached, which Generation tells.
+func (u *Universe) Advance(gens uint64) error {
+	for j := range bits.Len64(gens) {
+		if gens>>j&1 == 0 {
+			continue
+		}
+		if len(u.nodes) > u.limit {
+			u.collect()
+		}
+		// The cells must lie within the middle quarter of a root at least
+		// j+3 levels high for the middle half of its successor 2^j
+		// generations later to hold every cell they could grow into.
+		root := u.root
+		for root.level < uint8(j)+3 || u.centre(u.centre(root)).pop != root.pop {
+			root = u.expand(root)
+		}
+		next := u.successor(root, uint8(j))
+		if u.overflow {
+			u.overflow = false
+			u.collect()
+			return ErrTooLarge
+		}
+		u.root = next
+		u.gens += 1 << j
+	}
+	return nil
+}
+
+// collect forgets every square and future worked out but those the root
+// and the dead squares are made of.
+func (u *Universe) collect() {
+	u.nodes, u.steps = map[quad]*node{}, map[stepKey]*node{}
+	var keep func(n *node)
+	keep = func(n *node) {
+		if n.level == 0 {
+			return
+		}
+		if _, ok := u.nodes[quad{n.nw, n.ne, n.sw, n.se}]; ok {
+			return
+		}
+		u.nodes[quad{n.nw, n.ne, n.sw, n.se}] = n
+		keep(n.nw)
+		keep(n.ne)
+		keep(n.sw)
+		keep(n.se)
+	}
+	for _, e := range u.empty {
+		keep(e)
+	}
+	keep(u.root)
+}
+
+// successor returns the middle half of n, of level 2 or more, 2^j
+// generations on, for j up to n.level-2.
+func (u *Universe) successor(n *node, j uint8) *node {
+	j = min(j, n.level-2)
+	if n.pop == 0 {
+		return u.emptyNode(n.level - 1)
+	}
+	key := stepKey{n, j}
+	if r, ok := u.steps[key]; ok {
+		return r
+	}
+	var r *node
+	if n.level == 2 {
+		r = u.step4(n)
+	} else {
+		// nine overlapping squares of half the size, each moved on
+		c := [9]*node{
+			u.successor(n.nw, j),
+			u.successor(u.join(n.nw.ne, n.ne.nw, n.nw.se, n.ne.sw), j),
+			u.successor(n.ne, j),
+			u.successor(u.join(n.nw.sw, n.nw.se, n.sw.nw, n.sw.ne), j),
+			u.successor(u.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw), j),
+			u.successor(u.join(n.ne.sw, n.ne.se, n.se.nw, n.se.ne), j),
+			u.successor(n.sw, j),
+			u.successor(u.join(n.sw.ne, n.se.nw, n.sw.se, n.se.sw), j),
+			u.successor(n.se, j),
+		}
+		if j < n.level-2 {
+			// they are far enough on already: take the middle of each four
+			r = u.join(
+				u.join(c[0].se, c[1].sw, c[3].ne, c[4].nw),
+				u.join(c[1].se, c[2].sw, c[4].ne, c[5].nw),
+				u.join(c[3].se, c[4].sw, c[6].ne, c[7].nw),
+				u.join(c[4].se, c[5].sw, c[7].ne, c[8].nw),
+			)
+		} else {
+			r = u.join(
+				u.successor(u.join(c[0], c[1], c[3], c[4]), j),
+				u.successor(u.join(c[1], c[2], c[4], c[5]), j),
+				u.successor(u.join(c[3], c[4], c[6], c[7]), j),
+				u.successor(u.join(c[4], c[5], c[7], c[8]), j),
+			)
+		}
+	}
+	u.steps[key] = r
+	return r
+}
+
+// step4 returns the middle 2x2 cells of the 4x4 square n one generation
+// on.
+func (u *Universe) step4(n *node) *node {
+	var g [4][4]uint8
+	for x := range 4 {
+		for y := range 4 {
+			q := [2][2]*node{{n.nw, n.ne}, {n.sw, n.se}}[x/2][y/2]
+			g[x][y] = [2][2]*node{{q.nw, q.ne}, {q.sw, q.se}}[x%2][y%2].state
+		}
+	}
+	cell := func(x, y int) *node {
+		var nb [8]uint8
+		k := 0
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				if dx != 0 || dy != 0 {
+					nb[k] = g[x+dx][y+dy]
+					k++
+				}
+			}
+		}
+		return u.leaves[u.rule(g[x][y], nb)]
+	}
+	return u.join(cell(1, 1), cell(1, 2), cell(2, 1), cell(2, 2))
+}
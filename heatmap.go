@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"app/engine"
+)
+
+// heatWindow is the span of the activity heatmap, kept as heatBuckets
+// slices of time so that old changes drop out in steps.
+const (
+	heatWindow  = 5 * time.Second
+	heatBuckets = 5
+)
+
+// activity counts how often each cell of an engine changed species over
+// the last heatWindow.
+type activity struct {
+	cols    int
+	buckets [heatBuckets][]atomic.Int32 // row-major counts per slice of time
+	current atomic.Int32                // the bucket being filled
+}
+
+// trackActivity starts counting the changes of every cell of e.
+func trackActivity(e *engine.Engine) *activity {
+	a := &activity{cols: e.Cols()}
+	for i := range a.buckets {
+		a.buckets[i] = make([]atomic.Int32, e.Rows()*e.Cols())
+	}
+	e.OnCellChanged(func(x, y int, old, new engine.State) {
+		a.buckets[a.current.Load()][x*a.cols+y].Add(1)
+	})
+	go func() {
+		t := time.NewTicker(heatWindow / heatBuckets)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+			case <-e.Done():
+				return
+			}
+			next := (a.current.Load() + 1) % heatBuckets
+			for i := range a.buckets[next] {
+				a.buckets[next][i].Store(0)
+			}
+			a.current.Store(next)
+		}
+	}()
+	return a
+}
+
+// changes returns how often the cell at (x, y) changed within the window.
+func (a *activity) changes(x, y int) int {
+	n := 0
+	for i := range a.buckets {
+		n += int(a.buckets[i][x*a.cols+y].Load())
+	}
+	return n
+}
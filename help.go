@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// help is the overlay listing the key bindings and the parameters of the
+// simulation, toggled with ?.
+type help struct {
+	on  atomic.Bool
+	cfg *Config
+}
+
+// lines returns the text of the overlay for the layer shown by d.
+func (h *help) lines(d *display) []string {
+	lines := []string{"Keys"}
+	width := 0
+	for _, action := range actions {
+		width = max(width, len(keyNames(h.cfg.Keys[action])))
+	}
+	for _, action := range actions {
+		if keys := h.cfg.Keys[action]; len(keys) > 0 {
+			lines = append(lines, fmt.Sprintf("  %-*s  %s", width, keyNames(keys), actionHelp[action]))
+		}
+	}
+
+	cfg, l := h.cfg, d.layer()
+	e := l.e
+	seed := "random"
+	if cfg.Seed != 0 {
+		seed = fmt.Sprint(cfg.Seed)
+	}
+	lines = append(lines, "", "Simulation",
+		fmt.Sprintf("  mode %s, %dx%d grid, %s boundary, %s updates", cfg.Mode, e.Rows(), e.Cols(), cfg.Boundary, cfg.Update),
+		fmt.Sprintf("  init %s, density %.2f, symmetry %s, seed %s", cfg.Init, cfg.Density, cfg.Symmetry, seed))
+	if len(d.layers) > 1 {
+		lines = append(lines, fmt.Sprintf("  layer %s, %d of %d", l.name, d.current.Load()+1, len(d.layers)))
+	}
+	species := e.Species()
+	name := 0
+	for _, sp := range species[1:] {
+		name = max(name, len(sp.Name))
+	}
+	for id, sp := range species[1:] {
+		lines = append(lines, fmt.Sprintf("  %-*s  %-12v  reacts in %s", name, sp.Name, e.Rule(id+1), sp.ReactionTime))
+	}
+	if cfg.Energy.Enabled {
+		lines = append(lines, fmt.Sprintf("  energy initial %g, decay %g, transfer %g", cfg.Energy.Initial, cfg.Energy.Decay, cfg.Energy.Transfer))
+	}
+	if cfg.Mutation > 0 {
+		lines = append(lines, fmt.Sprintf("  mutation %g", cfg.Mutation))
+	}
+	if cfg.MaxAge > 0 {
+		lines = append(lines, fmt.Sprintf("  max age %d", cfg.MaxAge))
+	}
+	switch {
+	case cfg.Kernel != nil:
+		lines = append(lines, fmt.Sprintf("  kernel %dx%d", len(cfg.Kernel), len(cfg.Kernel)))
+	case cfg.FarWeight > 0:
+		lines = append(lines, fmt.Sprintf("  far weight %g", cfg.FarWeight))
+	case cfg.KernelName != "":
+		lines = append(lines, "  kernel "+cfg.KernelName)
+	}
+	if cfg.Flow != "" {
+		lines = append(lines, "  flow "+cfg.Flow)
+	}
+	return lines
+}
+
+// keyNames lists key names for the overlay, showing the space bar by name.
+func keyNames(keys []string) string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k
+		if k == " " {
+			names[i] = "Space"
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// draw draws the overlay in the middle of the screen, over the panes, and
+// reports whether it did.
+func (h *help) draw(screen tcell.Screen, d *display) bool {
+	if !h.on.Load() {
+		return false
+	}
+	lines := h.lines(d)
+	width := 0
+	for _, s := range lines {
+		width = max(width, utf8.RuneCountInString(s))
+	}
+	w, ht := screen.Size()
+	left, top := max((w-width-4)/2, 0), max((ht-len(lines)-2)/2, 0)
+	style := tcell.StyleDefault.Reverse(true)
+	for i := -1; i <= len(lines); i++ {
+		s := ""
+		if i >= 0 && i < len(lines) {
+			s = lines[i]
+		}
+		x := left
+		for _, r := range "  " + s + strings.Repeat(" ", width-utf8.RuneCountInString(s)) + "  " {
+			screen.SetContent(x, top+1+i, r, nil, style)
+			x++
+		}
+	}
+	return true
+}
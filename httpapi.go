@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"app/engine"
+)
+
+// With -http the grid is served over HTTP, for dashboards and scripts
+// watching or driving a run without a streaming client:
+//
+//	GET /grid.png?layer=L&scale=S    the grid as a PNG in its plain cell
+//	                                 colors, each cell S pixels square (default 1)
+//	POST /grid.png?layer=L           replace the grid by the PNG in the body
+//
+// Layers are numbered bottom first, from those of the first pane; the
+// bottom one is the default. A posted image is scaled to fit the grid as
+// -image does, each cell becoming dead where dark, otherwise the species
+// or wall whose color is nearest; the cells beyond it are dead. A grid
+// fetched at any scale posts back unchanged, ages apart.
+
+// Limits of the HTTP API, against requests that would take all memory or
+// hold a connection forever.
+const (
+	httpMaxScale  = 64
+	httpMaxPixels = 1 << 26
+	httpMaxBody   = 32 << 20
+	httpTimeout   = 30 * time.Second
+)
+
+// startHTTP serves the grids of layers over HTTP on addr until the process
+// ends.
+func startHTTP(addr string, layers []*layer) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /grid.png", func(w http.ResponseWriter, r *http.Request) {
+		l, ok := httpLayer(w, r, layers)
+		if !ok {
+			return
+		}
+		scale := 1
+		if v := r.URL.Query().Get("scale"); v != "" {
+			var err error
+			if scale, err = strconv.Atoi(v); err != nil || scale < 1 || scale > httpMaxScale {
+				http.Error(w, fmt.Sprintf("scale must be from 1 to %d", httpMaxScale), http.StatusBadRequest)
+				return
+			}
+		}
+		if l.e.Rows()*l.e.Cols()*scale*scale > httpMaxPixels {
+			http.Error(w, "image too large, try a smaller scale", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		png.Encode(w, l.image(scale))
+	})
+	mux.HandleFunc("POST /grid.png", func(w http.ResponseWriter, r *http.Request) {
+		l, ok := httpLayer(w, r, layers)
+		if !ok {
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, httpMaxBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		// A small file can declare a huge image, which Decode would
+		// allocate whole.
+		cfg, err := png.DecodeConfig(bytes.NewReader(body))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("not a PNG image: %v", err), http.StatusBadRequest)
+			return
+		}
+		if cfg.Width*cfg.Height > httpMaxPixels {
+			http.Error(w, fmt.Sprintf("image of %dx%d pixels too large", cfg.Width, cfg.Height), http.StatusBadRequest)
+			return
+		}
+		img, err := png.Decode(bytes.NewReader(body))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("not a PNG image: %v", err), http.StatusBadRequest)
+			return
+		}
+		if img.Bounds().Empty() {
+			http.Error(w, "empty image", http.StatusBadRequest)
+			return
+		}
+		if err := l.e.Restore(imageGrid(img, l)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		l.e.LogEvent(engine.Edit, engine.Dead, "grid replaced over HTTP")
+		slog.Info("grid replaced over HTTP", "from", r.RemoteAddr)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	slog.Info("serving the grid over HTTP", "url", "http://"+ln.Addr().String()+"/grid.png")
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: httpTimeout,
+		ReadTimeout:       httpTimeout,
+		WriteTimeout:      httpTimeout,
+	}
+	go srv.Serve(ln)
+	return nil
+}
+
+// httpLayer returns the layer of the layer parameter of r, or answers with
+// an error.
+func httpLayer(w http.ResponseWriter, r *http.Request, layers []*layer) (*layer, bool) {
+	i := 0
+	if v := r.URL.Query().Get("layer"); v != "" {
+		var err error
+		if i, err = strconv.Atoi(v); err != nil || i < 0 || i >= len(layers) {
+			http.Error(w, fmt.Sprintf("layer must be from 0 to %d", len(layers)-1), http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	return layers[i], true
+}
+
+// imageGrid returns the grid of l drawn from img, keeping its turmites.
+func imageGrid(img image.Image, l *layer) engine.Snapshot {
+	s := engine.Snapshot{Cells: make([][]engine.State, l.e.Rows()), Turmites: l.e.Turmites()}
+	for x := range s.Cells {
+		s.Cells[x] = make([]engine.State, l.e.Cols())
+	}
+	wr, wg, wb := l.wall.RGB()
+	imageCells(img, l.e.Rows(), l.e.Cols(), func(x, y int, r, g, b float64) {
+		species := nearestSpecies(r, g, b, l.palette)
+		if species != engine.Dead {
+			sr, sg, sb := l.palette[species].RGB()
+			if colorDistance(r, g, b, wr, wg, wb) < colorDistance(r, g, b, sr, sg, sb) {
+				s.Cells[x][y].Wall = true
+				return
+			}
+		}
+		s.Cells[x][y].Species = species
+	})
+	return s
+}
+
+// colorDistance is the squared distance between a color from 0 to 1 per
+// channel and one from 0 to 255.
+func colorDistance(r, g, b float64, cr, cg, cb int32) float64 {
+	dr, dg, db := r-float64(cr)/255, g-float64(cg)/255, b-float64(cb)/255
+	return dr*dr + dg*dg + db*db
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/png"
+	"os"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+)
+
+// imageDark is the luminance, from 0 to 1, below which a pixel seeds a dead
+// cell.
+const imageDark = 0.25
+
+// seedImage draws the PNG image at path onto e, scaled to fit the grid with
+// its aspect ratio kept and centered. Each cell takes the average color of
+// the pixels it covers: dead if that is dark, otherwise the species whose
+// color in palette, indexed by species id, is nearest.
+func seedImage(e *engine.Engine, path string, palette []tcell.Color) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if img.Bounds().Empty() {
+		return fmt.Errorf("%s: empty image", path)
+	}
+	imageCells(img, e.Rows(), e.Cols(), func(x, y int, r, g, b float64) {
+		e.SetCell(x, y, engine.State{Species: nearestSpecies(r, g, b, palette)})
+	})
+	return nil
+}
+
+// imageCells scales img to fit a grid of rows by cols with its aspect
+// ratio kept, centered, and calls f with the average color of the pixels
+// each cell covers, from 0 to 1 per channel. The cells beyond the image
+// are left out.
+func imageCells(img image.Image, rows, cols int, f func(x, y int, r, g, b float64)) {
+	// Cells are drawn two columns wide, so they are about square.
+	b := img.Bounds()
+	h, w := rows, max(b.Dx()*rows/b.Dy(), 1)
+	if w > cols {
+		h, w = max(b.Dy()*cols/b.Dx(), 1), cols
+	}
+	x0, y0 := (rows-h)/2, (cols-w)/2
+	for i := range h {
+		for j := range w {
+			px := image.Rect(b.Min.X+j*b.Dx()/w, b.Min.Y+i*b.Dy()/h,
+				b.Min.X+(j+1)*b.Dx()/w, b.Min.Y+(i+1)*b.Dy()/h)
+			r, g, bl := meanColor(img, px)
+			f(x0+i, y0+j, r, g, bl)
+		}
+	}
+}
+
+// meanColor returns the mean color of the pixels of img in rect, from 0 to 1
+// per channel, with transparent pixels counting as black.
+func meanColor(img image.Image, rect image.Rectangle) (r, g, b float64) {
+	n := 0
+	for y := rect.Min.Y; y < max(rect.Max.Y, rect.Min.Y+1); y++ {
+		for x := rect.Min.X; x < max(rect.Max.X, rect.Min.X+1); x++ {
+			pr, pg, pb, _ := img.At(x, y).RGBA()
+			r, g, b = r+float64(pr), g+float64(pg), b+float64(pb)
+			n++
+		}
+	}
+	return r / float64(n) / 0xffff, g / float64(n) / 0xffff, b / float64(n) / 0xffff
+}
+
+// nearestSpecies returns dead for a dark color, and otherwise the live
+// species whose color in palette is nearest to it.
+func nearestSpecies(r, g, b float64, palette []tcell.Color) int {
+	if 0.299*r+0.587*g+0.114*b < imageDark {
+		return engine.Dead
+	}
+	best, dist := engine.Dead, 0.0
+	for id, c := range palette[1:] {
+		cr, cg, cb := c.RGB()
+		if d := colorDistance(r, g, b, cr, cg, cb); best == engine.Dead || d < dist {
+			best, dist = id+1, d
+		}
+	}
+	return best
+}
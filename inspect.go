@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+)
+
+// hover is the cell under the mouse pointer in edit mode, which the
+// inspector describes.
+type hover struct {
+	d        *display
+	row, col int
+}
+
+// drawInspector draws a tooltip describing the cell under the pointer in
+// edit mode, just below it or, on the bottom row, above it.
+func (ed *editor) drawInspector() {
+	h := ed.hover.Load()
+	if h == nil || !ed.on.Load() {
+		return
+	}
+	d := h.d
+	l := d.layer()
+	top, left, rows, cols := d.window(l.e.Rows(), l.e.Cols())
+	if h.row < top || h.row >= top+rows || h.col < left || h.col >= left+cols {
+		return
+	}
+	s := []rune(" " + describeCell(l, h.row, h.col) + " ")
+	s = s[:min(len(s), 2*cols)]
+	y := h.row - top + 1
+	if y >= rows {
+		y = h.row - top - 1
+	}
+	x := min(d.left+2*(h.col-left), d.left+2*cols-len(s))
+	d.repaint()
+	style := tcell.StyleDefault.Reverse(true)
+	for _, r := range s {
+		d.screen.SetContent(x, y, r, nil, style)
+		x++
+	}
+}
+
+// describeCell tells the coordinates, state, timing and live neighbours of
+// the cell of l at (x, y), for debugging rules.
+func describeCell(l *layer, x, y int) string {
+	e := l.e
+	info := e.Inspect(x, y)
+	parts := []string{fmt.Sprintf("%d,%d %s", x, y, stateName(l, info.State))}
+	if info.State.Alive() && !info.State.Wall {
+		parts[0] += fmt.Sprintf(" age %d", info.State.Age)
+	}
+	now := e.Now()
+	if info.LastUpdate > 0 {
+		parts = append(parts, fmt.Sprintf("updated %s ago", (now-info.LastUpdate).Round(time.Millisecond)))
+	} else {
+		parts = append(parts, "not updated yet")
+	}
+	switch {
+	case e.Paused():
+		parts = append(parts, "paused")
+	case info.NextUpdate > 0:
+		parts = append(parts, fmt.Sprintf("next in %s", max(info.NextUpdate-now, 0).Round(time.Millisecond)))
+	}
+	parts = append(parts, fmt.Sprintf("%d updates", info.Updates))
+	counts := []string{fmt.Sprintf("neighbours %d", info.Neighbors.Total)}
+	species := e.Species()
+	for id, n := range info.Neighbors.Counts {
+		if n > 0 && id != engine.Dead && id < len(species) {
+			counts = append(counts, fmt.Sprintf("%s %d", species[id].Name, n))
+		}
+	}
+	parts = append(parts, strings.Join(counts, " "))
+	return strings.Join(parts, " · ")
+}
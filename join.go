@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/netplay"
+)
+
+// runJoin implements the "join" subcommand: a client of a game served with
+// "serve", showing the shared grid and painting cells of the player's
+// species with the mouse.
+func runJoin(args []string) {
+	fs := flag.NewFlagSet("join", flag.ExitOnError)
+	name := fs.String("name", os.Getenv("USER"), "player name")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s join [flags] HOST:PORT\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	c := &client{addr: fs.Arg(0), name: *name}
+	if err := c.connect(); err != nil {
+		log.Fatalf("joining: %v", err)
+	}
+
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		log.Fatalf("creating screen: %v", err)
+	}
+	if err = screen.Init(); err != nil {
+		log.Fatalf("initializing screen: %v", err)
+	}
+	defer screen.Fini()
+	screen.EnableMouse()
+	go c.receive()
+	go func() {
+		for {
+			c.draw(screen)
+			screen.Show()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+
+	last := [2]int{-1, -1}
+	for {
+		switch ev := screen.PollEvent().(type) {
+		case *tcell.EventMouse:
+			if ev.Buttons()&tcell.ButtonPrimary == 0 {
+				last = [2]int{-1, -1}
+				continue
+			}
+			x, y := ev.Position()
+			if cell := [2]int{y, x / 2}; cell != last {
+				last = cell
+				c.paint(cell)
+			}
+		case *tcell.EventKey:
+			if ev.Key() == tcell.KeyEscape || ev.Key() == tcell.KeyCtrlC || ev.Rune() == 'q' {
+				return
+			}
+		}
+	}
+}
+
+// client is a connection to a game, redialed if it drops.
+type client struct {
+	addr, name string
+
+	mu      sync.Mutex
+	conn    *netplay.Conn
+	welcome netplay.Message
+	frame   netplay.Message // the latest frame or end message
+	err     error           // of the dropped connection while redialing
+}
+
+// connect dials the server and says hello, with the token of the previous
+// connection if there was one, so as to play the same species.
+func (c *client) connect() error {
+	conn, err := netplay.Dial(c.addr)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	token := c.welcome.Token
+	c.mu.Unlock()
+	if err := conn.Send(netplay.Message{Type: netplay.Hello, Name: c.name, Token: token}); err != nil {
+		conn.Close()
+		return err
+	}
+	m, err := conn.Receive()
+	if err == nil && m.Type != netplay.Welcome {
+		err = fmt.Errorf("%s", m.Text)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn, c.welcome, c.err = conn, m, nil
+	return nil
+}
+
+// receive keeps the latest frame until the game ends, redialing every
+// second while the connection is down.
+func (c *client) receive() {
+	for {
+		c.mu.Lock()
+		conn := c.conn
+		c.mu.Unlock()
+		m, err := conn.Receive()
+		c.mu.Lock()
+		switch {
+		case err != nil:
+			c.err = err
+		case m.Type == netplay.Frame || m.Type == netplay.End:
+			c.frame = m
+		}
+		c.mu.Unlock()
+		if m.Type == netplay.End {
+			return
+		}
+		if err != nil {
+			conn.Close()
+			for c.connect() != nil {
+				time.Sleep(time.Second)
+			}
+		}
+	}
+}
+
+// paint asks to paint the cell at row, column cell.
+func (c *client) paint(cell [2]int) {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	conn.Send(netplay.Message{Type: netplay.Paint, Cells: [][2]int{cell}})
+}
+
+// draw draws the latest frame with the game's status below it: the
+// player's color and ink, the population of each species and the time
+// left, then the players.
+func (c *client) draw(screen tcell.Screen) {
+	c.mu.Lock()
+	w, f, err := c.welcome, c.frame, c.err
+	c.mu.Unlock()
+	colors := make([]tcell.Color, len(w.Colors))
+	for i, name := range w.Colors {
+		colors[i] = tcell.GetColor(name)
+	}
+	color := func(id int) tcell.Color {
+		if id >= 0 && id < len(colors) {
+			return colors[id]
+		}
+		return tcell.ColorGray
+	}
+	screen.Clear()
+	for x, row := range f.Grid {
+		for y := range len(row) {
+			style := tcell.StyleDefault.Background(color(netplay.Cell(row[y])))
+			screen.SetContent(2*y, x, ' ', nil, style)
+			screen.SetContent(2*y+1, x, ' ', nil, style)
+		}
+	}
+
+	name := func(id int) string {
+		if id >= 0 && id < len(w.Names) {
+			return w.Names[id]
+		}
+		return fmt.Sprint(id)
+	}
+	var status []string
+	for _, p := range f.Players {
+		if p.Name == w.Name {
+			status = append(status, fmt.Sprintf("%s (%s): ink %d", p.Name, name(p.Species), p.Ink))
+		}
+	}
+	for id := 1; id < len(f.Population); id++ {
+		status = append(status, fmt.Sprintf("%s %d", name(id), f.Population[id]))
+	}
+	switch {
+	case f.Type == netplay.End:
+		status = append(status, name(f.Winner)+" wins [q]")
+	case err != nil:
+		status = append(status, "reconnecting: "+err.Error())
+	case f.Left > 0:
+		left := time.Duration(f.Left * float64(time.Second)).Round(time.Second)
+		status = append(status, left.String()+" left")
+	}
+	var players []string
+	for _, p := range f.Players {
+		s := fmt.Sprintf("%s (%s)", p.Name, name(p.Species))
+		if !p.Connected {
+			s += " away"
+		}
+		players = append(players, s)
+	}
+	for i, line := range []string{strings.Join(status, "  "), "players: " + strings.Join(players, ", ")} {
+		x := 0
+		for _, r := range line {
+			screen.SetContent(x, len(f.Grid)+i, r, nil, tcell.StyleDefault)
+			x++
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"app/engine"
+	"app/pattern"
+)
+
+// With -jsonrpc the simulation is controlled by JSON-RPC 2.0 requests read
+// from standard input, one per line, answered on standard output the same
+// way, for programs that run it as a child process. The methods are:
+//
+//	pause                       pause every layer
+//	resume                      resume every layer
+//	step {ticks}                pause, then run every layer ticks ticks (default 1)
+//	getRegion {layer, row, col, rows, cols}
+//	                            the species ids of a rectangle of cells, walls as -1
+//	                            (default the whole grid)
+//	setCells {layer, cells: [{row, col, species}]}
+//	                            set cells to a species, by name or id, dead or wall
+//	getStats {layer}            the tick, simulated time and population
+//	wipeOut {layer, species}    kill every cell of a species
+//	convert {layer, from, to, fraction}
+//	                            turn a fraction of the cells of a species into another
+//	inject {layer, species, density, row, col, rows, cols}
+//	                            make a fraction density of a rectangle of cells a species
+//	                            (default the whole grid)
+//
+// pause, resume, step and getStats answer with the stats of the layer, the
+// bottom one unless given, and wipeOut, convert and inject with the number
+// of cells changed. Layers are numbered bottom first, from those of
+// the first pane.
+type rpcServer struct {
+	layers []*layer // of every pane, the bottom layer of the first one first
+}
+
+// rpcRequest is a request or notification, which has no ID and gets no
+// response.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// The error codes of the specification; rpcFailed is ours, for methods
+// that could not do what they were asked.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcNoMethod       = -32601
+	rpcInvalidParams  = -32602
+	rpcFailed         = -32000
+)
+
+// rpcStats is the result of pause, resume, step and getStats.
+type rpcStats struct {
+	Layer      string         `json:"layer"`
+	Tick       int            `json:"tick"`
+	Time       float64        `json:"time"` // simulated, in seconds
+	Paused     bool           `json:"paused"`
+	Population map[string]int `json:"population"` // live cells by species name
+}
+
+// rpcRegion is the result of getRegion.
+type rpcRegion struct {
+	Row   int     `json:"row"`
+	Col   int     `json:"col"`
+	Cells [][]int `json:"cells"` // by row, species ids with walls as -1
+}
+
+// rpcPerturbed is the result of wipeOut, convert and inject.
+type rpcPerturbed struct {
+	Changed int `json:"changed"` // cells
+}
+
+func rpcChanged(changes []change) rpcPerturbed { return rpcPerturbed{len(changes)} }
+
+// rpcSpecies is a species given by name or by id.
+type rpcSpecies string
+
+func (s *rpcSpecies) UnmarshalJSON(b []byte) error {
+	var id int
+	if err := json.Unmarshal(b, &id); err == nil {
+		*s = rpcSpecies(strconv.Itoa(id))
+		return nil
+	}
+	return json.Unmarshal(b, (*string)(s))
+}
+
+// serveJSONRPC answers the requests read from r on w until r ends or ctx
+// is done.
+func serveJSONRPC(ctx context.Context, r io.Reader, w io.Writer, layers []*layer) error {
+	srv := &rpcServer{layers: layers}
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(nil, 4*pattern.MaxCells)
+		for sc.Scan() {
+			select {
+			case lines <- append([]byte(nil), sc.Bytes()...):
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- sc.Err()
+	}()
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case line := <-lines:
+			if resp := srv.handle(line); resp != nil {
+				if err := enc.Encode(resp); err != nil {
+					return err
+				}
+			}
+		case err := <-readErr:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// handle answers the request of line, or returns nil for a notification or
+// a blank line.
+func (srv *rpcServer) handle(line []byte) *rpcResponse {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"),
+			Error: &rpcError{rpcParseError, err.Error()}}
+	}
+	id := req.ID
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	resp := &rpcResponse{JSONRPC: "2.0", ID: id}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &rpcError{rpcInvalidRequest, `not a JSON-RPC 2.0 request`}
+		return resp
+	}
+	result, err := srv.call(req.Method, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	if err == nil {
+		resp.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		re, ok := err.(*rpcError)
+		if !ok {
+			re = &rpcError{rpcFailed, err.Error()}
+		}
+		resp.Result, resp.Error = nil, re
+	}
+	return resp
+}
+
+// call runs method with params.
+func (srv *rpcServer) call(method string, params json.RawMessage) (any, error) {
+	var p struct {
+		Layer int  `json:"layer"`
+		Ticks *int `json:"ticks"`
+		Row   int  `json:"row"`
+		Col   int  `json:"col"`
+		Rows  int  `json:"rows"`
+		Cols  int  `json:"cols"`
+		Cells []struct {
+			Row     int        `json:"row"`
+			Col     int        `json:"col"`
+			Species rpcSpecies `json:"species"`
+		} `json:"cells"`
+		Species  rpcSpecies `json:"species"`
+		From     rpcSpecies `json:"from"`
+		To       rpcSpecies `json:"to"`
+		Fraction float64    `json:"fraction"`
+		Density  float64    `json:"density"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+	}
+	if p.Layer < 0 || p.Layer >= len(srv.layers) {
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("no layer %d", p.Layer)}
+	}
+	l := srv.layers[p.Layer]
+	switch method {
+	case "pause":
+		for _, l := range srv.layers {
+			l.e.Pause()
+		}
+	case "resume":
+		for _, l := range srv.layers {
+			l.e.Resume()
+		}
+	case "step":
+		ticks := 1
+		if p.Ticks != nil {
+			ticks = *p.Ticks
+		}
+		if ticks < 0 {
+			return nil, &rpcError{rpcInvalidParams, "ticks must be non-negative"}
+		}
+		for _, l := range srv.layers {
+			l.e.Pause()
+		}
+		for range ticks {
+			for _, l := range srv.layers {
+				l.e.RunTicks(engine.Sequential, 1)
+			}
+		}
+	case "getStats":
+	case "getRegion":
+		return srv.region(l.e, p.Row, p.Col, p.Rows, p.Cols)
+	case "setCells":
+		states := make([]engine.State, len(p.Cells))
+		for i, c := range p.Cells {
+			if c.Row < 0 || c.Row >= l.e.Rows() || c.Col < 0 || c.Col >= l.e.Cols() {
+				return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("no cell %d, %d in the %dx%d grid", c.Row, c.Col, l.e.Rows(), l.e.Cols())}
+			}
+			s, err := parseState(l.e, string(c.Species))
+			if err != nil {
+				return nil, &rpcError{rpcInvalidParams, err.Error()}
+			}
+			states[i] = s
+		}
+		for i, c := range p.Cells {
+			l.e.SetCell(c.Row, c.Col, states[i])
+		}
+		return struct {
+			Set int `json:"set"`
+		}{len(p.Cells)}, nil
+	case "wipeOut":
+		id, err := srv.species(l.e, p.Species)
+		if err != nil {
+			return nil, err
+		}
+		return rpcChanged(wipeOut(l, id)), nil
+	case "convert":
+		from, err := srv.species(l.e, p.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := srv.species(l.e, p.To)
+		if err != nil {
+			return nil, err
+		}
+		if err := srv.fraction(p.Fraction); err != nil {
+			return nil, err
+		}
+		return rpcChanged(convertSpecies(l, from, to, p.Fraction)), nil
+	case "inject":
+		id, err := srv.species(l.e, p.Species)
+		if err != nil {
+			return nil, err
+		}
+		if err := srv.fraction(p.Density); err != nil {
+			return nil, err
+		}
+		reg, err := srv.region(l.e, p.Row, p.Col, p.Rows, p.Cols)
+		if err != nil {
+			return nil, err
+		}
+		rows, cols := len(reg.Cells), 0
+		if rows > 0 {
+			cols = len(reg.Cells[0])
+		}
+		return rpcChanged(inject(l, id, p.Row, p.Col, rows, cols, p.Density)), nil
+	default:
+		return nil, &rpcError{rpcNoMethod, fmt.Sprintf("no method %q", method)}
+	}
+	return rpcStatsOf(l), nil
+}
+
+// species returns the id on e of the species s, which must be given.
+func (srv *rpcServer) species(e *engine.Engine, s rpcSpecies) (int, error) {
+	if s == "" {
+		return 0, &rpcError{rpcInvalidParams, "no species"}
+	}
+	id, err := speciesID(e, string(s))
+	if err != nil {
+		return 0, &rpcError{rpcInvalidParams, err.Error()}
+	}
+	return id, nil
+}
+
+// fraction checks that f is a fraction from 0 to 1.
+func (srv *rpcServer) fraction(f float64) error {
+	if f < 0 || f > 1 {
+		return &rpcError{rpcInvalidParams, fmt.Sprintf("fraction %g is not between 0 and 1", f)}
+	}
+	return nil
+}
+
+// region returns the rows by cols cells of e from row, col on, all those
+// to the bottom right if rows or cols is 0.
+func (srv *rpcServer) region(e *engine.Engine, row, col, rows, cols int) (rpcRegion, error) {
+	if rows == 0 {
+		rows = e.Rows() - row
+	}
+	if cols == 0 {
+		cols = e.Cols() - col
+	}
+	if row < 0 || col < 0 || rows < 0 || cols < 0 || row+rows > e.Rows() || col+cols > e.Cols() {
+		return rpcRegion{}, &rpcError{rpcInvalidParams, fmt.Sprintf("region outside the %dx%d grid", e.Rows(), e.Cols())}
+	}
+	reg := rpcRegion{Row: row, Col: col, Cells: make([][]int, rows)}
+	for i := range rows {
+		reg.Cells[i] = make([]int, cols)
+		for j := range cols {
+			if s := e.Cell(row+i, col+j); s.Wall {
+				reg.Cells[i][j] = pattern.Wall
+			} else {
+				reg.Cells[i][j] = s.Species
+			}
+		}
+	}
+	return reg, nil
+}
+
+// rpcStatsOf returns the stats of l.
+func rpcStatsOf(l *layer) rpcStats {
+	species := l.e.Species()
+	st := rpcStats{
+		Layer:      l.name,
+		Tick:       l.e.Ticks(),
+		Time:       l.e.Now().Seconds(),
+		Paused:     l.e.Paused(),
+		Population: map[string]int{},
+	}
+	for _, sp := range species[1:] {
+		st.Population[sp.Name] = 0
+	}
+	for _, row := range l.e.Snapshot().Cells {
+		for _, s := range row {
+			if s.Alive() && !s.Wall && s.Species < len(species) {
+				st.Population[species[s.Species].Name]++
+			}
+		}
+	}
+	return st
+}
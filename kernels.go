@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"app/engine"
+)
+
+// kernels are the built-in neighbourhood kernels, by name. Those named for
+// a side count only the five cells on that side of the cell, so that cells
+// grow away from it: with "above", growth falls down the grid.
+var kernels = map[string]engine.Kernel{
+	"moore":       {{1, 1, 1}, {1, 0, 1}, {1, 1, 1}},
+	"von-neumann": {{0, 1, 0}, {1, 0, 1}, {0, 1, 0}},
+	"above":       {{1, 1, 1}, {1, 0, 1}, {0, 0, 0}},
+	"below":       {{0, 0, 0}, {1, 0, 1}, {1, 1, 1}},
+	"left":        {{1, 1, 0}, {1, 0, 0}, {1, 1, 0}},
+	"right":       {{0, 1, 1}, {0, 0, 1}, {0, 1, 1}},
+	"left-heavy":  {{2, 1, 0.5}, {2, 0, 0.5}, {2, 1, 0.5}},
+}
+
+// kernelNames returns the names of the built-in kernels, sorted.
+func kernelNames() []string {
+	var names []string
+	for name := range kernels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// kernel returns the kernel the rules count neighbours with, nil for the
+// plain Moore neighbourhood: the matrix of cfg.Kernel, the radius-2 kernel
+// of cfg.FarWeight, or the built-in kernel or kernel file named by
+// cfg.KernelName. Only one of them may be set.
+func (cfg *Config) kernel() (engine.Kernel, error) {
+	set := 0
+	for _, ok := range []bool{cfg.Kernel != nil, cfg.FarWeight != 0, cfg.KernelName != ""} {
+		if ok {
+			set++
+		}
+	}
+	switch {
+	case set > 1:
+		return nil, fmt.Errorf("set only one of kernel, far_weight and kernel_name")
+	case cfg.Kernel != nil:
+		return engine.Kernel(cfg.Kernel), nil
+	case cfg.FarWeight < 0:
+		return nil, fmt.Errorf("far_weight must not be negative")
+	case cfg.FarWeight > 0:
+		return engine.FarKernel(cfg.FarWeight), nil
+	case cfg.KernelName == "":
+		return nil, nil
+	}
+	if k, ok := kernels[cfg.KernelName]; ok {
+		return k, nil
+	}
+	return loadKernel(cfg.KernelName)
+}
+
+// loadKernel reads a kernel file: a row of weights per line, separated by
+// spaces or commas, skipping blank lines and those starting with #.
+func loadKernel(path string) (engine.Kernel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s: not a built-in kernel (%s) nor a kernel file", path, strings.Join(kernelNames(), ", "))
+		}
+		return nil, err
+	}
+	var k engine.Kernel
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var row []float64
+		for f := range strings.FieldsSeq(strings.ReplaceAll(line, ",", " ")) {
+			w, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+			}
+			row = append(row, w)
+		}
+		k = append(k, row)
+	}
+	return k, nil
+}
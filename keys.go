@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Actions that can be bound to keys.
+const (
+	actQuit          = "quit"
+	actPause         = "pause"
+	actBack          = "back"
+	actForward       = "forward"
+	actAge           = "age"
+	actTrails        = "trails"
+	actHeatmap       = "heatmap"
+	actStructures    = "structures"
+	actSparklines    = "sparklines"
+	actFPS           = "fps"
+	actEvents        = "events"
+	actRules         = "rules"
+	actHelp          = "help"
+	actExport        = "export"
+	actLayerDown     = "layer-down"
+	actLayerUp       = "layer-up"
+	actProjection    = "projection"
+	actEdit          = "edit"
+	actBrush         = "brush"
+	actUndo          = "undo"
+	actRedo          = "redo"
+	actSelect        = "select"
+	actCopy          = "copy"
+	actCut           = "cut"
+	actPaste         = "paste"
+	actPasteSystem   = "paste-system"
+	actInfectionDown = "infection-down"
+	actInfectionUp   = "infection-up"
+	actRecoveryDown  = "recovery-down"
+	actRecoveryUp    = "recovery-up"
+	actTuneNext      = "tune-next"
+	actTuneDown      = "tune-down"
+	actTuneUp        = "tune-up"
+	actWipe          = "wipe"
+	actConvert       = "convert"
+	actInject        = "inject"
+	actMark          = "mark"
+	actJump          = "jump"
+	actFork          = "fork"
+	actCamera        = "camera"
+	actContact       = "contact"
+	actOwners        = "owners"
+)
+
+var actions = []string{
+	actQuit, actPause, actBack, actForward, actAge, actTrails, actHeatmap, actStructures, actSparklines, actFPS, actEvents, actRules, actHelp, actExport,
+	actLayerDown, actLayerUp, actProjection, actEdit, actBrush, actUndo, actRedo,
+	actSelect, actCopy, actCut, actPaste, actPasteSystem,
+	actInfectionDown, actInfectionUp, actRecoveryDown, actRecoveryUp,
+	actTuneNext, actTuneDown, actTuneUp,
+	actWipe, actConvert, actInject,
+	actMark, actJump, actFork, actCamera, actContact, actOwners,
+}
+
+// editActions are the actions of edit mode. While editing their keys
+// shadow those of other actions, so that a key can serve both.
+var editActions = map[string]bool{
+	actBrush: true, actSelect: true, actCopy: true, actCut: true, actPaste: true,
+	actWipe: true, actConvert: true, actInject: true,
+}
+
+// actionHelp describes each action for the help overlay.
+var actionHelp = map[string]string{
+	actQuit:          "quit",
+	actPause:         "pause / resume",
+	actBack:          "while paused, step back through the history",
+	actForward:       "while paused, step forward through the history",
+	actAge:           "toggle age shading",
+	actTrails:        "toggle trails of dying cells",
+	actHeatmap:       "toggle the activity heatmap",
+	actStructures:    "toggle still life and oscillator marks",
+	actSparklines:    "toggle population sparklines",
+	actFPS:           "toggle the frame and update rates",
+	actEvents:        "toggle the event panel",
+	actRules:         "toggle the rule editor",
+	actHelp:          "toggle this help",
+	actExport:        "export the shown layer as a pattern file",
+	actLayerDown:     "show the layer or slice below",
+	actLayerUp:       "show the layer or slice above",
+	actProjection:    "toggle the projection of all layers",
+	actEdit:          "toggle edit mode",
+	actBrush:         "edit mode: next brush",
+	actUndo:          "undo the last edit",
+	actRedo:          "redo the last edit undone",
+	actSelect:        "edit mode: toggle selecting a rectangle",
+	actCopy:          "edit mode: copy the selection, also to the system clipboard as RLE",
+	actCut:           "edit mode: cut the selection",
+	actPaste:         "edit mode: paste at the next click, in any pane",
+	actPasteSystem:   "paste a pattern from the system clipboard at the next click",
+	actInfectionDown: "sir mode: lower the infection rate",
+	actInfectionUp:   "sir mode: raise the infection rate",
+	actRecoveryDown:  "sir mode: shorten the recovery time",
+	actRecoveryUp:    "sir mode: lengthen the recovery time",
+	actTuneNext:      "choose the next species to tune the reaction time of, or none",
+	actTuneDown:      "shorten the reaction time of the chosen species",
+	actTuneUp:        "lengthen the reaction time of the chosen species",
+	actWipe:          "edit mode: wipe out the brush species",
+	actConvert:       "edit mode: turn a quarter of the species chosen with T into the brush species",
+	actInject:        "edit mode: make a quarter of the selection the brush species",
+	actMark:          "create a named savepoint of every layer",
+	actJump:          "jump back to a savepoint",
+	actFork:          "with -fork or -ab, fork the right pane again from the left one",
+	actCamera:        "toggle the camera following the busiest region, or the cluster selected in edit mode",
+	actContact:       "toggle the species contact overlay",
+	actOwners:        "toggle coloring cells by the worker updating them, with their update rates",
+}
+
+func defaultKeys() map[string][]string {
+	return map[string][]string{
+		actQuit:    {"q", "Esc"},
+		actPause:   {" "},
+		actBack:    {"Left"},
+		actForward: {"Right"},
+		actAge:     {"a"},
+		actTrails:  {"t"},
+		actHeatmap: {"h"},
+		actContact: {"H"},
+
+		actStructures: {"s"},
+		actSparklines: {"g"},
+		actFPS:        {"f"},
+		actEvents:     {"E"},
+		actRules:      {"R"},
+		actHelp:       {"?"},
+		actExport:     {"x"},
+
+		actLayerDown:   {"l"},
+		actLayerUp:     {"L"},
+		actProjection:  {"p"},
+		actEdit:        {"e"},
+		actBrush:       {"b"},
+		actUndo:        {"u"},
+		actRedo:        {"U"},
+		actSelect:      {"m"},
+		actCopy:        {"c"},
+		actCut:         {"C"},
+		actPaste:       {"v"},
+		actPasteSystem: {"V"},
+
+		actInfectionDown: {"i"},
+		actInfectionUp:   {"I"},
+		actRecoveryDown:  {"o"},
+		actRecoveryUp:    {"O"},
+		actTuneNext:      {"T"},
+		actTuneDown:      {"["},
+		actTuneUp:        {"]"},
+
+		actWipe:    {"W"},
+		actConvert: {"K"},
+		actInject:  {"J"},
+
+		actMark: {"m"},
+		actJump: {"'"},
+		actFork: {"F"},
+
+		actCamera: {"z"},
+		actOwners: {"w"},
+	}
+}
+
+// keymap resolves key events to actions.
+type keymap struct {
+	runes map[rune]string
+	keys  map[tcell.Key]string
+	// editRunes and editKeys hold the bindings of editActions.
+	editRunes map[rune]string
+	editKeys  map[tcell.Key]string
+}
+
+// newKeymap builds a keymap from action -> key names. A key name is either a
+// single character or one of tcell's key names such as "Esc", "Enter",
+// "Left" or "Ctrl-C".
+func newKeymap(bindings map[string][]string) (keymap, error) {
+	byName := make(map[string]tcell.Key)
+	for k, name := range tcell.KeyNames {
+		byName[strings.ToLower(name)] = k
+	}
+
+	km := keymap{
+		runes:     make(map[rune]string),
+		keys:      make(map[tcell.Key]string),
+		editRunes: make(map[rune]string),
+		editKeys:  make(map[tcell.Key]string),
+	}
+	for action, names := range bindings {
+		known := false
+		for _, a := range actions {
+			known = known || a == action
+		}
+		if !known {
+			return km, fmt.Errorf("unknown action %q", action)
+		}
+		runes, keys := km.runes, km.keys
+		if editActions[action] {
+			runes, keys = km.editRunes, km.editKeys
+		}
+		for _, name := range names {
+			if r, size := utf8.DecodeRuneInString(name); size == len(name) && r != utf8.RuneError {
+				runes[r] = action
+				continue
+			}
+			k, ok := byName[strings.ToLower(name)]
+			if !ok {
+				return km, fmt.Errorf("action %q: unknown key %q", action, name)
+			}
+			keys[k] = action
+		}
+	}
+	return km, nil
+}
+
+// lookup returns the action of ev, preferring those of edit mode while
+// editing.
+func (km keymap) lookup(ev *tcell.EventKey, editing bool) string {
+	if ev.Key() == tcell.KeyRune {
+		if a, ok := km.editRunes[ev.Rune()]; ok && editing {
+			return a
+		}
+		if a, ok := km.runes[ev.Rune()]; ok {
+			return a
+		}
+		return km.editRunes[ev.Rune()]
+	}
+	if a, ok := km.editKeys[ev.Key()]; ok && editing {
+		return a
+	}
+	if a, ok := km.keys[ev.Key()]; ok {
+		return a
+	}
+	return km.editKeys[ev.Key()]
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+	"app/luarule"
+	"app/wasmrule"
+)
+
+// layer is one engine of the stack together with what the display needs to
+// draw it.
+type layer struct {
+	name      string
+	e         *engine.Engine
+	palette   []tcell.Color // indexed by species id, dead cells at 0
+	hybrids   hybridColors
+	wall      tcell.Color
+	intensity func(engine.State) float64
+	// plugin is the scripted or WASM rule, if any, whose errors are shown
+	// in the status line.
+	plugin   interface{ Err() error }
+	sir      *engine.SIR
+	activity *activity
+	trend    *trend
+	rewind   *rewind // nil unless history is kept
+	seams    []bool  // by cell, set on the seams of zones; see zoneSeams
+	probes   []*probe
+	night    *nightCycle // nil without night rules
+	// interval is the time between synchronous updates, 0 if the cells
+	// update asynchronously, on a goroutine per tile if tiled is set.
+	interval time.Duration
+	tiled    bool
+}
+
+// hybridColors are the colors of the hybrids of a layer, following its
+// palette.
+type hybridColors struct {
+	mu     sync.Mutex
+	colors []tcell.Color
+}
+
+// speciesColor returns the color of species id of l: its configured one,
+// or for a hybrid the mean of its parents' colors.
+func (l *layer) speciesColor(id int) tcell.Color {
+	if id < len(l.palette) {
+		return l.palette[id]
+	}
+	h := &l.hybrids
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	species := l.e.Species()
+	for n := len(l.palette) + len(h.colors); n <= id; n++ {
+		if n >= len(species) {
+			return l.palette[engine.Dead]
+		}
+		var r, g, b int32
+		parents := species[n].Parents
+		for _, p := range parents {
+			c := l.palette[engine.Dead]
+			switch {
+			case p < len(l.palette):
+				c = l.palette[p]
+			case p-len(l.palette) < len(h.colors):
+				c = h.colors[p-len(l.palette)]
+			}
+			pr, pg, pb := c.RGB()
+			r, g, b = r+max(pr, 0), g+max(pg, 0), b+max(pb, 0)
+		}
+		k := int32(max(len(parents), 1))
+		h.colors = append(h.colors, tcell.NewRGBColor(r/k, g/k, b/k))
+	}
+	return h.colors[id-len(l.palette)]
+}
+
+// start starts the layer's engine.
+func (l *layer) start() {
+	switch {
+	case l.interval > 0:
+		l.e.StartSynchronous(l.interval)
+	case l.tiled:
+		l.e.StartTiled()
+	default:
+		l.e.Start()
+	}
+}
+
+// stopLayers stops the engines of layers, waiting for the updates under
+// way to finish, and writes their grids through to their map files.
+func stopLayers(layers []*layer) {
+	for _, l := range layers {
+		l.e.Stop()
+		if err := l.e.Sync(); err != nil {
+			slog.Error("writing map file", "err", err)
+		}
+	}
+}
+
+// layerConfigs returns the configuration of every layer, bottom first. A
+// config without layers describes a single one; otherwise each layer
+// inherits everything but its mode and rule plugin from cfg, and turmites
+// are only placed on the bottom layer.
+func (cfg *Config) layerConfigs() ([]Config, error) {
+	if len(cfg.Layers) == 0 {
+		return []Config{*cfg}, nil
+	}
+	if cfg.Depth > 1 {
+		return nil, fmt.Errorf("depth and layers are mutually exclusive")
+	}
+	if cfg.MapFile != "" {
+		return nil, fmt.Errorf("a map file holds a single layer")
+	}
+	var lcs []Config
+	for i, l := range cfg.Layers {
+		lc := *cfg
+		lc.Layers = nil
+		lc.Mode, lc.RuleScript, lc.RuleWASM = l.Mode, l.RuleScript, l.RuleWASM
+		if lc.Mode == "" {
+			lc.Mode = "life"
+		}
+		if i > 0 {
+			lc.Agents.Count = 0
+		}
+		lcs = append(lcs, lc)
+	}
+	return lcs, nil
+}
+
+// buildLayers creates, seeds and stacks every layer of cfg, and starts
+// writing cfg.Stats for the bottom one. release releases the layers' rule
+// plugins.
+func buildLayers(cfg *Config) (layers []*layer, release func(), err error) {
+	lcs, err := cfg.layerConfigs()
+	if err != nil {
+		return nil, nil, fmt.Errorf("configuring layers: %w", err)
+	}
+	var releases []func()
+	releaseAll := func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	defer func() {
+		if err != nil {
+			releaseAll()
+		}
+	}()
+	for _, lc := range lcs {
+		ls, r, err := newLayers(&lc)
+		if err != nil {
+			return nil, nil, err
+		}
+		releases = append(releases, r)
+		layers = append(layers, ls...)
+	}
+	if cfg.Stats != "" {
+		if err := writeStats(cfg.Stats, layers[0].e, layers[0].probes); err != nil {
+			return nil, nil, fmt.Errorf("opening stats file: %w", err)
+		}
+	}
+	if cfg.StatsJSONL != "" {
+		if err := writeStatsJSONL(cfg.StatsJSONL, layers[0].e, layers[0].probes); err != nil {
+			return nil, nil, fmt.Errorf("opening stats file: %w", err)
+		}
+	}
+	if len(lcs) > 1 {
+		var engines []*engine.Engine
+		for _, l := range layers {
+			engines = append(engines, l.e)
+		}
+		if err := engine.Stack(engines...); err != nil {
+			return nil, nil, fmt.Errorf("stacking layers: %w", err)
+		}
+	}
+	return layers, releaseAll, nil
+}
+
+// newLayers creates and seeds the engine described by cfg, or the slices of
+// its volume when cfg.Depth is above 1, bottom first. release closes the
+// engines and releases the rule plugin once the layers are no longer used.
+func newLayers(cfg *Config) (layers []*layer, release func(), err error) {
+	release = func() {}
+	params, err := cfg.params()
+	if err != nil {
+		return nil, nil, fmt.Errorf("configuring engine: %w", err)
+	}
+	var plugin interface{ Err() error }
+	switch {
+	case cfg.RuleScript != "" && cfg.RuleWASM != "":
+		return nil, nil, fmt.Errorf("configuring engine: rule_script and rule_wasm are mutually exclusive")
+	case cfg.RuleScript != "":
+		script, err := luarule.Load(cfg.RuleScript, params.Species)
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading rule script: %w", err)
+		}
+		params.Transition, plugin = script, script
+		go script.Watch(time.Second)
+		release = func() { script.Close() }
+	case cfg.RuleWASM != "":
+		mod, err := wasmrule.Load(cfg.RuleWASM, len(params.Species))
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading rule plugin: %w", err)
+		}
+		params.Transition, plugin = mod, mod
+		release = func() { mod.Close() }
+	}
+	// The error returns below clear release, so keep it for the deferred
+	// call.
+	closePlugin := release
+	defer func() {
+		if err != nil {
+			closePlugin()
+		}
+	}()
+	sir, _ := params.Transition.(*engine.SIR)
+	palette, err := cfg.palette()
+	if err != nil {
+		return nil, nil, fmt.Errorf("configuring colors: %w", err)
+	}
+	wall, err := cfg.wallColor()
+	if err != nil {
+		return nil, nil, fmt.Errorf("configuring colors: %w", err)
+	}
+
+	params.Logger = slog.With("layer", cfg.Mode)
+	var engines []*engine.Engine
+	if cfg.Depth > 1 {
+		v, err := engine.NewVolume(params, cfg.Depth)
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating volume: %w", err)
+		}
+		for z := range v.Depth() {
+			engines = append(engines, v.Slice(z))
+		}
+	} else {
+		e, err := engine.New(params)
+		if err != nil {
+			return nil, nil, fmt.Errorf("creating engine: %w", err)
+		}
+		engines = append(engines, e)
+	}
+	closeEngines := func() {
+		for _, e := range engines {
+			e.Close()
+		}
+	}
+	defer func() {
+		if err != nil {
+			closeEngines()
+		}
+	}()
+
+	for z, e := range engines {
+		sc := *cfg
+		if z > 0 {
+			sc.Agents.Count = 0
+		}
+		if e.MapLoaded() {
+			slog.Info("grid loaded from map file", "path", cfg.MapFile)
+		} else if err := sc.seed(e); err != nil {
+			return nil, nil, fmt.Errorf("seeding: %w", err)
+		}
+		if err := startSchedules(cfg, e); err != nil {
+			return nil, nil, fmt.Errorf("configuring schedules: %w", err)
+		}
+		night, err := startNight(cfg, e, params.Transition == nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("configuring night rules: %w", err)
+		}
+		probes, err := cfg.probes(e)
+		if err != nil {
+			return nil, nil, fmt.Errorf("configuring probes: %w", err)
+		}
+		name := cfg.Mode
+		if len(engines) > 1 {
+			name = fmt.Sprintf("%s z=%d", cfg.Mode, z)
+		}
+		var rw *rewind
+		if cfg.Rewind > 0 {
+			rw = trackRewind(e, cfg.Rewind)
+		}
+		var interval time.Duration
+		if cfg.Update == "sync" {
+			interval = cmp.Or(cfg.SyncInterval, e.MeanReactionTime())
+		}
+		tiled := cfg.Update == "tiled"
+		layers = append(layers, &layer{
+			name:      name,
+			e:         e,
+			palette:   palette,
+			wall:      wall,
+			intensity: modes[cfg.Mode].intensity,
+			plugin:    plugin,
+			sir:       sir,
+			activity:  trackActivity(e),
+			trend:     trackTrend(e),
+			rewind:    rw,
+			seams:     zoneSeams(e),
+			probes:    probes,
+			night:     night,
+			interval:  interval,
+			tiled:     tiled,
+		})
+	}
+	return layers, func() {
+		closeEngines()
+		closePlugin()
+	}, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"sync/atomic"
+)
+
+// logOutput is where log records are written, switched off when a local
+// display takes over the terminal; see quietLogging.
+var logOutput switchWriter
+
+// switchWriter writes to the writer last stored in it.
+type switchWriter struct{ w atomic.Pointer[io.Writer] }
+
+func (sw *switchWriter) set(w io.Writer) { sw.w.Store(&w) }
+
+func (sw *switchWriter) Write(b []byte) (int, error) {
+	if w := sw.w.Load(); w != nil {
+		return (*w).Write(b)
+	}
+	return os.Stderr.Write(b)
+}
+
+// startLogging makes the default logger write records of cfg.Verbose's
+// level or above to cfg.LogFile, or standard error if it is empty, as text
+// or as JSON lines if cfg.LogJSON is set. The log package writes through
+// it too, at the error level, since only fatal errors go through it.
+// closeLog closes the file.
+func startLogging(cfg *Config) (closeLog func(), err error) {
+	closeLog = func() {}
+	logOutput.set(os.Stderr)
+	if cfg.LogFile != "" {
+		f, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		logOutput.set(f)
+		closeLog = func() { f.Close() }
+	}
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if cfg.Verbose {
+		opts.Level = slog.LevelDebug
+	}
+	var h slog.Handler = slog.NewTextHandler(&logOutput, opts)
+	if cfg.LogJSON {
+		h = slog.NewJSONHandler(&logOutput, opts)
+	}
+	slog.SetDefault(slog.New(h))
+	slog.SetLogLoggerLevel(slog.LevelError)
+	return closeLog, nil
+}
+
+// quietLogging drops log records while a local display owns the terminal,
+// unless they go to a file, until restore.
+func quietLogging(cfg *Config) (restore func()) {
+	if cfg.LogFile != "" {
+		return func() {}
+	}
+	logOutput.set(io.Discard)
+	return func() { logOutput.set(os.Stderr) }
+}
@@ -0,0 +1,235 @@
+// Package luarule provides an engine.Transition implemented by a Lua script.
+//
+// The script must define a global function
+//
+//	function nextState(self, neighbors) ... end
+//
+// where self has fields species (a species id, 0 for dead), name, alive,
+// age (updates survived as this species), energy (when the metabolism model
+// is enabled), value and refractory (a dead cell that cannot be born yet,
+// whatever the function returns), and neighbors has a field total plus the
+// live-neighbour count of every species, both by id (neighbors[1]) and by
+// name (neighbors.green). When the engine is one layer of a stack,
+// neighbors.below and neighbors.above describe the corresponding cell of
+// the adjacent layers with fields species, alive, value, u and v; they are
+// nil for the bottom and top layers. The function returns the next species
+// as an id or a name; nil, false or 0 mean the cell is dead. A global table
+// species maps names to ids.
+package luarule
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"app/engine"
+)
+
+// Rule runs nextState from a Lua script. Calls are serialized, since a Lua
+// state is not safe for concurrent use.
+type Rule struct {
+	path    string
+	species []engine.Species // indexed by species id, dead at 0
+
+	mu        sync.Mutex
+	L         *lua.LState
+	fn        lua.LValue
+	self      *lua.LTable
+	neighbors *lua.LTable
+	below     *lua.LTable
+	above     *lua.LTable
+	modTime   time.Time
+	err       error // of the last load
+	runErr    error // of the last call of nextState
+	done      chan struct{}
+	closed    bool
+}
+
+// Load compiles the script at path. species are the engine's species
+// definitions without the dead entry, as in engine.Params.
+func Load(path string, species []engine.Species) (*Rule, error) {
+	r := &Rule{
+		path:    path,
+		species: append([]engine.Species{{Name: "dead"}}, species...),
+		done:    make(chan struct{}),
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Rule) reload() error {
+	fi, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+
+	L := lua.NewState()
+	ids := L.NewTable()
+	for id, sp := range r.species {
+		ids.RawSetString(sp.Name, lua.LNumber(id))
+	}
+	L.SetGlobal("species", ids)
+	if err := L.DoFile(r.path); err != nil {
+		L.Close()
+		return err
+	}
+	fn := L.GetGlobal("nextState")
+	if fn.Type() != lua.LTFunction {
+		L.Close()
+		return fmt.Errorf("%s: nextState is not defined as a function", r.path)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		L.Close()
+		return nil
+	}
+	if r.L != nil {
+		r.L.Close()
+	}
+	r.L, r.fn = L, fn
+	r.self, r.neighbors = L.NewTable(), L.NewTable()
+	r.below, r.above = L.NewTable(), L.NewTable()
+	r.modTime = fi.ModTime()
+	r.err, r.runErr = nil, nil
+	return nil
+}
+
+// Watch polls the script every interval and reloads it when its
+// modification time changes. If the new version fails to load, the previous
+// one stays active and the error is reported by Err. Watch returns once the
+// rule is closed.
+func (r *Rule) Watch(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-t.C:
+		}
+		fi, err := os.Stat(r.path)
+		if err != nil {
+			r.setErr(err)
+			continue
+		}
+		r.mu.Lock()
+		changed := !fi.ModTime().Equal(r.modTime)
+		r.mu.Unlock()
+		if changed {
+			if err := r.reload(); err != nil {
+				r.setErr(err)
+				r.mu.Lock()
+				r.modTime = fi.ModTime() // don't retry until it changes again
+				r.mu.Unlock()
+			}
+		}
+	}
+}
+
+func (r *Rule) setErr(err error) {
+	r.mu.Lock()
+	r.err = err
+	r.mu.Unlock()
+}
+
+// Err returns the error of the last load, else that of the last call of
+// nextState, or nil.
+func (r *Rule) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	return r.runErr
+}
+
+// Close stops Watch and releases the Lua state. Next leaves cells as they
+// are once the rule is closed.
+func (r *Rule) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	close(r.done)
+	r.L.Close()
+	return nil
+}
+
+// setLayer fills t with the fields describing a cell of another layer.
+func (r *Rule) setLayer(t *lua.LTable, s engine.State) {
+	t.RawSetString("species", lua.LNumber(s.Species))
+	t.RawSetString("alive", lua.LBool(s.Alive()))
+	t.RawSetString("value", lua.LNumber(s.Value))
+	t.RawSetString("u", lua.LNumber(s.U))
+	t.RawSetString("v", lua.LNumber(s.V))
+}
+
+func (r *Rule) Next(self engine.State, n engine.Neighborhood) engine.State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return self
+	}
+
+	r.self.RawSetString("species", lua.LNumber(self.Species))
+	r.self.RawSetString("name", lua.LString(r.species[self.Species].Name))
+	r.self.RawSetString("alive", lua.LBool(self.Alive()))
+	r.self.RawSetString("age", lua.LNumber(self.Age))
+	r.self.RawSetString("energy", lua.LNumber(self.Energy))
+	r.self.RawSetString("value", lua.LNumber(self.Value))
+	r.self.RawSetString("refractory", lua.LBool(self.Refractory))
+	r.neighbors.RawSetString("total", lua.LNumber(n.Total))
+	for id := 1; id < len(n.Counts) && id < len(r.species); id++ {
+		r.neighbors.RawSetInt(id, lua.LNumber(n.Counts[id]))
+		r.neighbors.RawSetString(r.species[id].Name, lua.LNumber(n.Counts[id]))
+	}
+	r.neighbors.RawSetString("below", lua.LNil)
+	if n.HasBelow {
+		r.setLayer(r.below, n.Below)
+		r.neighbors.RawSetString("below", r.below)
+	}
+	r.neighbors.RawSetString("above", lua.LNil)
+	if n.HasAbove {
+		r.setLayer(r.above, n.Above)
+		r.neighbors.RawSetString("above", r.above)
+	}
+
+	err := r.L.CallByParam(lua.P{Fn: r.fn, NRet: 1, Protect: true}, r.self, r.neighbors)
+	if err != nil {
+		r.runErr = err
+		return self
+	}
+	ret := r.L.Get(-1)
+	r.L.Pop(1)
+
+	r.runErr = nil
+	switch v := ret.(type) {
+	case lua.LNumber:
+		if id := int(v); id >= 0 && id < len(r.species) {
+			return engine.State{Species: id}
+		}
+	case lua.LString:
+		for id, sp := range r.species {
+			if sp.Name == string(v) {
+				return engine.State{Species: id}
+			}
+		}
+	case *lua.LNilType:
+		return engine.State{}
+	case lua.LBool:
+		if !v {
+			return engine.State{}
+		}
+	}
+	r.runErr = fmt.Errorf("%s: nextState returned invalid species %s", r.path, ret)
+	return self
+}
@@ -1,241 +1,746 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"math/rand"
-	"sync"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
-)
 
-const (
-	rows = 50
-	cols = 50
+	"app/engine"
+	"app/pattern"
 )
 
-var invertColors bool
-
-type Cell struct {
-	x, y        int
-	alive       bool
-	species     int // 0 = dead, 1 = green, 2 = red, 3 = blue
-	next        bool
-	nextSpecies int
-	mu          sync.Mutex
-	grid        *[][]*Cell
-	gridMu      *sync.RWMutex
-	neighbour8  [][2]int
+// subcommands are the modes of the program other than the TUI, each with
+// its own flags, by the name it is invoked with: go run . NAME [flags].
+var subcommands = []struct {
+	name, summary string
+	run           func(args []string)
+}{
+	{"run", "run the simulation without a display and report its outcome", runHeadless},
+	{"bench", "measure the throughput of the update models", runBench},
+	{"sweep", "run every combination of a set of parameters and tabulate the outcomes", runSweep},
+	{"explore", "try random rules, score how interesting they stay and keep the best", runExplore},
+	{"evolve", "breed rules and parameters for a fitness by genetic search", runEvolve},
+	{"dump", "write the grid as text", runDump},
+	{"replay", "show a saved grid in the TUI, paused, and run on from it", runReplay},
+	{"convert", "convert a saved grid or pattern to another pattern format", runConvert},
+	{"fastforward", "move a saved grid or pattern billions of generations on with HashLife", runFastForward},
+	{"diff", "compare two saved grids", runDiff},
+	{"serve", "host a territory game for players to join", runServe},
+	{"join", "join a game hosted by serve", runJoin},
+	{"daemon", "run the simulation in the background for attach", runDaemon},
+	{"attach", "show a simulation run by daemon", runAttach},
+	{"ssh", "serve the TUI over SSH", runSSH},
+	{"shard", "run a band of a grid split across machines", runShard},
 }
 
-func (c *Cell) countAliveNeighbors() (green, red, blue int) {
-	c.gridMu.RLock()
-	defer c.gridMu.RUnlock()
-
-	for _, offset := range c.neighbour8 {
-		nx, ny := c.x+offset[0], c.y+offset[1]
-		if nx >= 0 && nx < rows && ny >= 0 && ny < cols {
-			neighbor := (*c.grid)[nx][ny]
-			neighbor.mu.Lock()
-			if neighbor.alive {
-				switch neighbor.species {
-				case 1:
-					green++
-				case 2:
-					red++
-				case 3:
-					blue++
-				}
+func main() {
+	if len(os.Args) > 1 {
+		for _, sc := range subcommands {
+			if sc.name == os.Args[1] {
+				sc.run(os.Args[2:])
+				return
 			}
-			neighbor.mu.Unlock()
 		}
 	}
-	return
+	flag.CommandLine.Usage = usage
+	runInteractive(flag.CommandLine, os.Args[1:], nil)
 }
 
-func (c *Cell) computeNextState() {
-	green, red, blue := c.countAliveNeighbors()
-	total := green + red + blue
-
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	switch {
-	case c.alive && c.species == 1 && (green == 2 || green == 3):
-		c.next = true
-		c.nextSpecies = 1
-	case c.alive && c.species == 2 && (red == 2 || red == 3):
-		c.next = true
-		c.nextSpecies = 2
-	case c.alive && c.species == 3 && (blue == 2 || blue == 3):
-		c.next = true
-		c.nextSpecies = 3
-	case !c.alive && total == 3:
-		c.next = true
-		// pick dominant or random on tie
-		counts := map[int]int{1: green, 2: red, 3: blue}
-
-		maxCount := 0
-		for _, count := range counts {
-			if count > maxCount {
-				maxCount = count
-			}
-		}
-
-		// If tie, choose randomly
-		var candidates []int
-		for s, count := range counts {
-			if count == maxCount {
-				candidates = append(candidates, s)
-			}
-		}
-		c.nextSpecies = candidates[rand.Intn(len(candidates))]
-	default:
-		c.next = false
-		c.nextSpecies = 0
+// usage prints the usage of the program, its subcommands and the flags of
+// the TUI.
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "usage: %s [flags]\n       %s COMMAND [flags]\n\n", os.Args[0], os.Args[0])
+	fmt.Fprintln(w, "Without a command the simulation is shown in the terminal. The commands, each")
+	fmt.Fprintf(w, "listing its own flags with -h, are:\n\n")
+	for _, sc := range subcommands {
+		fmt.Fprintf(w, "  %-11s %s\n", sc.name, sc.summary)
 	}
+	fmt.Fprintf(w, "\nThe flags of the TUI are:\n\n")
+	flag.PrintDefaults()
 }
 
-func (c *Cell) applyNextState() {
-	c.mu.Lock()
-	c.alive = c.next
-	c.species = c.nextSpecies
-	c.mu.Unlock()
-}
+// runInteractive shows the configured simulation in the TUI, taking its
+// flags from args parsed with fs. With replay, args end with a file that it
+// reads the grid to show from instead of seeding one; see runReplay.
+func runInteractive(fs *flag.FlagSet, args []string, replay func(path string) (savedState, error)) {
+	cfg := defaultConfig()
+	if path := configFlag(args); path != "" {
+		if err := cfg.load(path); err != nil {
+			log.Fatalf("loading config: %v", err)
+		}
+	}
+	fs.String("config", "", "load settings from a TOML or YAML file; other flags override it")
+	cfg.bindFlags(fs)
+	cfg.bindTUIFlags(fs)
+	cfg.bindCueFlags(fs)
+	pprofAddr := fs.String("pprof", "", "serve net/http/pprof on this address (e.g. :6060)")
+	jsonrpc := fs.Bool("jsonrpc", false, "take JSON-RPC 2.0 requests on standard input and answer them on standard output, one per line, instead of showing the TUI")
+	fresh := fs.Bool("fresh", false, "start without the display settings and reaction times adjusted in the last run")
+	dumpConfig := fs.Bool("dump-config", false, "print the configuration, defaults included, as a TOML config file and exit")
+	fs.Parse(args)
+	var saved *savedState
+	switch {
+	case replay != nil && fs.NArg() == 1:
+		s, err := replay(fs.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		saved = &s
+		if s.Config != "" && configFlag(args) == "" {
+			if err := cfg.underFlags([]byte(s.Config), fs); err != nil {
+				log.Fatalf("%s: loading its config: %v", fs.Arg(0), err)
+			}
+		}
+		cfg.Rows, cfg.Cols = len(s.Layers[0].Cells), len(s.Layers[0].Cells[0])
+	case replay != nil:
+		fs.Usage()
+		os.Exit(2)
+	case fs.NArg() > 0:
+		fmt.Fprintf(fs.Output(), "unknown command %q\n\n", fs.Arg(0))
+		fs.Usage()
+		os.Exit(2)
+	}
+	closeLog, err := startLogging(&cfg)
+	if err != nil {
+		log.Fatalf("opening log: %v", err)
+	}
+	defer closeLog()
+	var last session
+	if !*fresh && !*jsonrpc {
+		var ok bool
+		if last, ok, err = loadSession(); err != nil {
+			slog.Warn("loading session failed", "err", err)
+		} else if ok {
+			last.apply(&cfg, fs)
+		}
+	}
+	if *dumpConfig {
+		text, err := cfg.dump()
+		if err != nil {
+			log.Fatalf("dumping config: %v", err)
+		}
+		fmt.Print(text)
+		return
+	}
 
-func (c *Cell) reactionTime() time.Duration {
-	switch c.species {
-	case 1:
-		return 101 * time.Millisecond // Green
-	case 2:
-		return 102 * time.Millisecond // Red
-	case 3:
-		return 102 * time.Millisecond // Blue
-	default:
-		return 102 * time.Millisecond // Dead
+	keys, err := newKeymap(cfg.Keys)
+	if err != nil {
+		log.Fatalf("configuring keys: %v", err)
+	}
+	if _, err := pattern.FormatNamed(cfg.Export); err != nil {
+		log.Fatalf("configuring export: %v", err)
+	}
+	if _, err := cfg.theme(); err != nil {
+		log.Fatalf("configuring theme: %v", err)
+	}
+	if cfg.StatsJSONL == "-" {
+		log.Fatal("-stats-jsonl - would write over the display; give a file, or use run or daemon")
 	}
-}
 
-func (c *Cell) run(wg *sync.WaitGroup) {
-	defer wg.Done()
+	if *pprofAddr != "" {
+		ln, err := net.Listen("tcp", *pprofAddr)
+		if err != nil {
+			log.Fatalf("starting pprof: %v", err)
+		}
+		runtime.SetMutexProfileFraction(100)
+		runtime.SetBlockProfileRate(int(time.Millisecond))
+		go http.Serve(ln, nil)
+	}
 
-	for {
-		time.Sleep(c.reactionTime())
-		c.computeNextState()
-		c.applyNextState()
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	rand.Seed(time.Now().UnixNano())
+	pcs, err := cfg.paneConfigs()
+	if err != nil {
+		log.Fatalf("configuring panes: %v", err)
+	}
+	var paneLayers [][]*layer
+	var all []*layer
+	for _, pc := range pcs {
+		layers, release, err := buildLayers(&pc)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer release()
+		paneLayers = append(paneLayers, layers)
+		all = append(all, layers...)
+	}
+	configured := map[string]time.Duration{}
+	for _, sp := range all[0].e.Species() {
+		configured[sp.Name] = sp.ReactionTime
+	}
+	last.restoreReactionTimes(all)
+	switch {
+	case saved != nil:
+		if err := restoreSaved(*saved, fs.Arg(0), paneLayers[0]); err != nil {
+			log.Fatal(err)
+		}
+	case cfg.Autosave > 0 && !*jsonrpc: // it asks on standard input
+		if err := offerRecovery(all); err != nil {
+			slog.Warn("recovering autosave failed", "err", err)
+		}
+	}
+	if cfg.EventLog != "" {
+		if err := writeEventLog(cfg.EventLog, all); err != nil {
+			log.Fatalf("opening event log: %v", err)
+		}
+	}
+	if cfg.OSC != "" || cfg.MIDI != "" {
+		if err := startCues(&cfg, paneLayers[0][0].e); err != nil {
+			log.Fatalf("starting cues: %v", err)
+		}
+	}
+	if cfg.Sound != "" {
+		stop, err := startSound(&cfg, paneLayers[0][0].e)
+		if err != nil {
+			log.Fatalf("starting sound: %v", err)
+		}
+		defer func() {
+			if err := stop(); err != nil {
+				slog.Warn("playing sound failed", "err", err)
+			}
+		}()
 	}
-}
 
-var grid [][]*Cell
-var gridMu sync.RWMutex
-
-func initGrid() {
-	grid = make([][]*Cell, rows)
-	for i := range grid {
-		grid[i] = make([]*Cell, cols)
-		for j := range grid[i] {
-			var species int
-			alive := rand.Float32() < 0.3
-			if alive {
-				species = 1 + rand.Intn(3) // Random: 1, 2, or 3
-			}
-			grid[i][j] = &Cell{
-				x:       i,
-				y:       j,
-				alive:   alive,
-				species: species,
-				grid:    &grid,
-				gridMu:  &gridMu,
-				neighbour8: [][2]int{
-					{-1, -1}, {-1, 0}, {-1, 1},
-					{0, -1}, {0, 1},
-					{1, -1}, {1, 0}, {1, 1},
-				},
+	if cfg.Video != "" {
+		rec, err := startVideo(&cfg, paneLayers[0][0])
+		if err != nil {
+			log.Fatalf("starting video: %v", err)
+		}
+		stop := make(chan struct{})
+		go rec.every(time.Second*time.Duration(rec.blend)/time.Duration(cfg.VideoFPS), stop)
+		defer func() {
+			close(stop)
+			if err := rec.close(); err != nil {
+				slog.Warn("recording video failed", "err", err)
 			}
+		}()
+	}
+	if cfg.HTTP != "" {
+		if err := startHTTP(cfg.HTTP, paneLayers[0]); err != nil {
+			log.Fatalf("serving HTTP: %v", err)
 		}
 	}
-}
-
-func displayGrid(screen tcell.Screen) {
-	gridMu.RLock()
-	defer gridMu.RUnlock()
-
-	for i := range grid {
-		for j := range grid[i] {
-			cell := grid[i][j]
-			cell.mu.Lock()
-			alive := cell.alive
-			species := cell.species
-			cell.mu.Unlock()
-
-			var fg, bg tcell.Color
-			if alive {
-				switch species {
-				case 1:
-					fg, bg = tcell.ColorBlack, tcell.ColorGreen
-				case 2:
-					fg, bg = tcell.ColorBlack, tcell.ColorRed
-				case 3:
-					fg, bg = tcell.ColorBlack, tcell.ColorBlue
-				default:
-					fg, bg = tcell.ColorBlack, tcell.ColorWhite
-				}
-			} else {
-				fg, bg = tcell.ColorGreen, tcell.ColorBlack
+	if cfg.Panel != "" {
+		pn, err := startPanel(&cfg, paneLayers[0][0])
+		if err != nil {
+			log.Fatalf("opening panel: %v", err)
+		}
+		stop := make(chan struct{})
+		go pn.every(time.Second/time.Duration(cfg.PanelFPS), stop)
+		defer func() {
+			close(stop)
+			if err := pn.close(); err != nil {
+				slog.Warn("showing panel failed", "err", err)
 			}
-
-			if invertColors {
-				fg, bg = bg, fg
+		}()
+	}
+	if cfg.Record != "" {
+		recd, err := startRecording(&cfg, paneLayers[0][0])
+		if err != nil {
+			log.Fatalf("starting recording: %v", err)
+		}
+		defer func() {
+			if err := recd.close(); err != nil {
+				slog.Warn("recording failed", "err", err)
 			}
-
-			style := tcell.StyleDefault.Foreground(fg).Background(bg)
-			screen.SetContent(j*2, i, ' ', nil, style)
-			screen.SetContent(j*2+1, i, ' ', nil, style)
+		}()
+	}
+	if cfg.Montage != "" {
+		mont, err := startMontage(&cfg, paneLayers[0][0])
+		if err != nil {
+			log.Fatalf("starting montage: %v", err)
 		}
+		defer func() {
+			if err := mont.write(cfg.Montage); err != nil {
+				slog.Warn("writing montage failed", "err", err)
+			}
+		}()
+	}
+	if cfg.Report != "" {
+		rep := startReport(&cfg, paneLayers[0][0])
+		defer func() {
+			if err := rep.write(cfg.Report); err != nil {
+				slog.Warn("writing report failed", "err", err)
+			}
+		}()
 	}
-	screen.Show()
-}
 
-func main() {
-	flag.BoolVar(&invertColors, "invert", false, "invert foreground/background colors")
-	flag.Parse()
+	var screen tcell.Screen
+	if !*jsonrpc {
+		if screen, err = tcell.NewScreen(); err != nil {
+			log.Fatalf("creating screen: %v", err)
+		}
+		if err = screen.Init(); err != nil {
+			log.Fatalf("initializing screen: %v", err)
+		}
+		defer quietLogging(&cfg)()
+		defer screen.Fini()
 
-	rand.Seed(time.Now().UnixNano())
-	initGrid()
+		screen.Clear()
+	}
 
-	screen, err := tcell.NewScreen()
-	if err != nil {
-		log.Fatalf("creating screen: %v", err)
+	if cfg.AB || cfg.Fork != "" {
+		if err := fork(paneLayers[0], paneLayers[1], cfg.Fork); err != nil {
+			log.Fatalf("seeding a/b panes: %v", err)
+		}
 	}
-	if err = screen.Init(); err != nil {
-		log.Fatalf("initializing screen: %v", err)
+	for _, l := range all {
+		if saved != nil {
+			l.e.Pause()
+		}
+		l.start()
 	}
-	defer screen.Fini()
-
-	screen.Clear()
-
-	var wg sync.WaitGroup
-	wg.Add(rows * cols)
-	for i := range grid {
-		for j := range grid[i] {
-			go grid[i][j].run(&wg)
+	defer stopLayers(all)
+	if cfg.Autosave > 0 {
+		defer startAutosave(ctx, all, cfg.Autosave, cfg.dumped())()
+	}
+	if *jsonrpc {
+		if err := serveJSONRPC(ctx, os.Stdin, os.Stdout, all); err != nil {
+			slog.Error("reading json-rpc requests failed", "err", err)
 		}
+		return
+	}
+	s := runTUI(ctx, screen, &cfg, keys, pcs, paneLayers)
+	s.ReactionTimes = reactionTimesTuned(all[0], configured)
+	if err := s.save(); err != nil {
+		slog.Warn("saving session failed", "err", err)
 	}
+}
 
+// runTUI shows the panes of paneLayers, configured by pcs, on screen and
+// handles its events until the quit key or ctx is done. The layers are left
+// running; last holds the display settings of the first pane by then.
+func runTUI(ctx context.Context, screen tcell.Screen, cfg *Config, keys keymap, pcs []Config, paneLayers [][]*layer) (last session) {
+	var ed editor
+	var panes []*display
+	left := 0
+	for i := range pcs {
+		d := newDisplay(screen, &pcs[i], paneLayers[i], &ed)
+		defer d.close()
+		d.left = left
+		if i < len(pcs)-1 {
+			d.width = 2 * pcs[i].Cols
+		}
+		left += d.width + paneGap
+		panes = append(panes, d)
+	}
+	defer func() { last = sessionOf(panes[0]) }()
+	if cfg.AB || cfg.Fork != "" {
+		trackDivergence(panes[0], panes[1])
+	}
+	var all []*layer // of every pane, for commands
+	for _, layers := range paneLayers {
+		all = append(all, layers...)
+	}
+	if cfg.Commands != "" {
+		stop, err := serveCommands(cfg.Commands, &commander{all}, panes[0].flash)
+		if err != nil {
+			panes[0].flash("commands: " + err.Error())
+		} else {
+			defer stop()
+		}
+	}
+	if cfg.Timeline != "" {
+		if err := startTimeline(cfg.Timeline, all, panes[0].flash); err != nil {
+			panes[0].flash("timeline: " + err.Error())
+		}
+	}
+	if cfg.Summary > 0 {
+		none := ""
+		panes[0].summary.Store(&none)
+		startSummary(paneLayers[0], cfg.Summary, func(line string) { panes[0].summary.Store(&line) })
+	}
+	rules := newRuleEditor(cfg)
+	panes[0].status = append(panes[0].status, rules.status)
+	tu := &tuner{layers: all, keys: cfg.Keys}
+	panes[0].status = append(panes[0].status, tu.status)
+	sp := newSavepoints(all, cfg.Savepoints, cfg.Keys, cfg.dumped())
+	panes[0].status = append(panes[0].status, sp.status)
+	hp := &help{cfg: cfg}
+	if keys := cfg.Keys[actHelp]; len(keys) > 0 {
+		panes[0].status = append(panes[0].status, func() string { return "help [" + keys[0] + "]" })
+	}
+	drawn := make(chan struct{})
+	stop := make(chan struct{})
+	defer func() {
+		close(stop)
+		<-drawn
+	}()
+	if cfg.Attract > 0 {
+		go startAttract(cfg, panes, stop)
+	}
+	woken := make(wake, 1)
+	for _, d := range panes {
+		d.wake = woken
+	}
+	ticks := func() int { // of every layer, which tell the frames apart
+		n := 0
+		for _, l := range all {
+			n += l.e.Ticks()
+		}
+		return n
+	}
 	go func() {
+		defer close(drawn)
+		helped := false // the help overlay was drawn last frame
+		behind := false // frames are spaced out for the terminal
+		p := pacer{interval: time.Second / time.Duration(max(cfg.MaxFPS, 1))}
+		poll := time.NewTicker(p.interval)
+		defer poll.Stop()
 		for {
-			displayGrid(screen)
-			time.Sleep(50 * time.Millisecond)
+			start := time.Now()
+			seen := ticks()
+			if helped {
+				// it may have covered more than the panes
+				screen.Clear()
+				for _, d := range panes {
+					d.repaint()
+				}
+			}
+			for _, d := range panes {
+				d.draw()
+			}
+			ed.drawSelection()
+			ed.drawInspector()
+			rules.draw(panes[0])
+			helped = hp.draw(screen, panes[0])
+			screen.Show()
+			wait, slow := p.wait(time.Since(start))
+			if slow && !behind {
+				slog.Warn("drawing falls behind, spacing out frames", "render", p.render.Round(time.Millisecond))
+			}
+			behind = slow
+			for _, d := range panes {
+				d.throttled.Store(slow)
+			}
+			select {
+			case <-time.After(wait):
+			case <-stop:
+				return
+			}
+			// Draw again once a layer ticked or something else changed,
+			// rather than the same frame over and over.
+			idle := time.After(idleFrame - time.Since(start))
+		waiting:
+			for ticks() == seen {
+				select {
+				case <-poll.C:
+				case <-woken:
+					break waiting
+				case <-idle:
+					break waiting
+				case <-stop:
+					return
+				}
+			}
 		}
 	}()
 
+	defer context.AfterFunc(ctx, func() { screen.PostEvent(tcell.NewEventInterrupt(nil)) })()
+	screen.EnablePaste()
+	var pasted *strings.Builder // text pasted into the terminal, while it arrives
 	for {
-		ev := screen.PollEvent()
-		if keyEv, ok := ev.(*tcell.EventKey); ok {
-			if keyEv.Key() == tcell.KeyEscape || keyEv.Rune() == 'q' {
+		woken.signal() // to show what the last event did
+		switch ev := screen.PollEvent().(type) {
+		case *tcell.EventPaste:
+			if ev.Start() {
+				pasted = &strings.Builder{}
+			} else if pasted != nil {
+				ed.receive(panes[0], pasted.String())
+				pasted = nil
+			}
+		case *tcell.EventClipboard:
+			ed.receive(panes[0], string(ev.Data()))
+		case *tcell.EventError:
+			return // the terminal is gone
+		case *tcell.EventInterrupt:
+			if ctx.Err() != nil {
 				return
 			}
+		case *tcell.EventMouse:
+			x, _ := ev.Position()
+			for _, d := range panes {
+				if x >= d.left && x < d.right() {
+					ed.paint(d, ev)
+				}
+			}
+		case *tcell.EventKey:
+			if pasted != nil {
+				switch ev.Key() {
+				case tcell.KeyRune:
+					pasted.WriteRune(ev.Rune())
+				case tcell.KeyEnter, tcell.KeyCtrlJ:
+					pasted.WriteByte('\n')
+				}
+				continue
+			}
+			if rules.key(panes[0], ev) || sp.key(panes[0], ev) {
+				continue
+			}
+			switch action := keys.lookup(ev, ed.on.Load()); action {
+			case actQuit:
+				return
+			case actEdit:
+				ed.toggle(screen)
+			case actBrush:
+				ed.nextBrush(panes[0].layer())
+			case actUndo:
+				ed.undo()
+			case actRedo:
+				ed.redo()
+			case actSelect:
+				ed.toggleSelect()
+			case actCopy, actCut:
+				ed.copySelection(action == actCut)
+			case actPaste:
+				ed.armPaste()
+			case actPasteSystem:
+				screen.GetClipboard()
+			case actRules:
+				rules.on.Store(!rules.on.Load())
+			case actHelp:
+				hp.on.Store(!hp.on.Load())
+			case actTuneNext:
+				tu.next()
+			case actTuneDown:
+				tu.scale(1 / tuneFactor)
+			case actTuneUp:
+				tu.scale(tuneFactor)
+			case actWipe, actConvert, actInject:
+				ed.perturb(panes[0], action, tu.chosen())
+			case actMark, actJump:
+				sp.begin(action)
+			case actCamera:
+				if d, sel := ed.selected(); d != nil {
+					d.toggleCamera(sel)
+				} else {
+					for _, d := range panes {
+						d.toggleCamera(nil)
+					}
+				}
+			case actFork:
+				if !cfg.AB && cfg.Fork == "" {
+					panes[0].flash("fork needs -fork or -ab")
+				} else if err := fork(paneLayers[0], paneLayers[1], cfg.Fork); err != nil {
+					panes[1].flash(err.Error())
+				} else {
+					panes[1].flash("forked")
+				}
+			default:
+				for _, d := range panes {
+					d.apply(action)
+				}
+			}
+		}
+	}
+}
+
+// paneGap is the number of blank columns between panes.
+const paneGap = 2
+
+// newDisplay returns a display of layers configured by cfg, with the
+// status line parts that apply to it.
+func newDisplay(screen tcell.Screen, cfg *Config, layers []*layer, ed *editor) *display {
+	d := &display{
+		screen:      screen,
+		invert:      cfg.Invert,
+		layers:      layers,
+		ageFade:     cfg.AgeFade,
+		trailLength: cfg.TrailLength,
+		stillAfter:  cfg.StillAfter,
+		exportAs:    cfg.Export,
+		noExport:    cfg.remote,
+		patternDir:  cfg.PatternDir,
+		themeName:   cfg.Theme,
+		looks:       map[*layer][]cellLook{},
+		ghosts:      map[*layer][][]ghost{},
+		done:        make(chan struct{}),
+	}
+	if d.patternDir == "" && cfg.Pattern != "" {
+		d.patternDir = filepath.Dir(cfg.Pattern)
+	}
+	d.theme, _ = cfg.theme() // checked at startup
+	d.ageShading.Store(cfg.AgeShading)
+	d.trails.Store(cfg.Trails)
+	d.structures.Store(cfg.Structures)
+	d.sparklines.Store(cfg.Sparklines)
+	d.heatmap.Store(cfg.Heatmap)
+	d.contact.Store(cfg.Contact)
+	d.owners.Store(cfg.Owners)
+	d.projection.Store(cfg.Projection)
+	d.overlay.Store(cfg.FPS)
+	d.events.Store(cfg.Events)
+	if cfg.Notify {
+		d.notify(cfg.Bell)
+	}
+	go d.measureRates()
+	go d.measureWorkers()
+	if l := layers[0]; l.interval > 0 {
+		d.status = append(d.status, func() string {
+			return fmt.Sprintf("synchronous every %s", l.interval)
+		})
+	}
+	d.status = append(d.status, func() string {
+		if asleep, tiles := d.layer().e.Sleeping(); tiles > 0 {
+			return fmt.Sprintf("asleep %d/%d tiles", asleep, tiles)
+		}
+		return ""
+	})
+	d.status = append(d.status, func() string {
+		l := d.layer()
+		switch {
+		case !l.e.Paused():
+			return ""
+		case l.rewind == nil:
+			return "paused [space]"
+		}
+		back, recorded := l.rewind.position()
+		return fmt.Sprintf("paused [space]  %d of %d ticks back [←/→]", back, recorded)
+	})
+	if len(layers) > 1 {
+		d.status = append(d.status, func() string {
+			if d.projection.Load() {
+				return fmt.Sprintf("projection of %d layers [p]", len(layers))
+			}
+			return fmt.Sprintf("layer %d/%d (%s) [l/L]", d.current.Load()+1, len(layers), d.layer().name)
+		})
+	}
+	if cfg.Symmetry != "none" {
+		d.status = append(d.status, func() string {
+			return fmt.Sprintf("asymmetric cells %d", asymmetry(d.layer().e, cfg.Symmetry))
+		})
+	}
+	d.status = append(d.status, func() string {
+		if d.heatmap.Load() && !d.projection.Load() {
+			return fmt.Sprintf("activity over the last %s [h]", heatWindow)
+		}
+		return ""
+	})
+	d.status = append(d.status, func() string {
+		if d.contact.Load() && !d.projection.Load() {
+			return fmt.Sprintf("contact fronts %d cells [H]", d.fronts.Load())
+		}
+		return ""
+	})
+	d.status = append(d.status, func() string {
+		if s := d.workerRates.Load(); s != nil && d.owners.Load() && !d.projection.Load() {
+			return *s
+		}
+		return ""
+	})
+	d.status = append(d.status, func() string {
+		if d.structures.Load() && !d.projection.Load() {
+			return fmt.Sprintf("still life • %d  oscillating ◦ %d [s]", d.stills.Load(), d.oscillators.Load())
+		}
+		return ""
+	})
+	d.status = append(d.status, func() string {
+		if s := d.late.Load(); s != nil {
+			return *s
+		}
+		return ""
+	})
+	d.status = append(d.status, func() string { return teamTotals(d.layer()) })
+	d.status = append(d.status, func() string { return probeSummary(d.layer()) })
+	d.status = append(d.status, func() string { l := d.layer(); return l.night.status(l.e) })
+	d.camera.on.Store(cfg.Camera)
+	go d.every(cameraInterval, func(time.Time) { d.aim() })
+	d.status = append(d.status, d.viewStatus)
+	var complexity atomic.Pointer[engine.Complexity]
+	var clusters atomic.Pointer[string]
+	go d.every(statsInterval, func(time.Time) {
+		e := d.layer().e
+		c := e.Complexity()
+		complexity.Store(&c)
+		s := clusterSummary(e)
+		clusters.Store(&s)
+	})
+	d.status = append(d.status, func() string {
+		if c := complexity.Load(); c != nil {
+			return fmt.Sprintf("entropy %.2f bits/cell  compressed %.0f%%", c.Entropy, 100*c.Compression)
+		}
+		return ""
+	})
+	d.status = append(d.status, func() string {
+		if s := clusters.Load(); s != nil {
+			return *s
+		}
+		return ""
+	})
+	d.status = append(d.status, func() string { return ed.status(d.layer()) })
+	d.status = append(d.status, func() string {
+		if p := d.layer().plugin; p != nil {
+			if err := p.Err(); err != nil {
+				return "rule: " + err.Error()
+			}
+		}
+		return ""
+	})
+	d.status = append(d.status, func() string {
+		if sir := d.layer().sir; sir != nil {
+			return fmt.Sprintf("infection rate %.2f [i/I]  recovery %d updates [o/O]", sir.InfectionRate(), sir.Recovery())
+		}
+		return ""
+	})
+	return d
+}
+
+// apply performs a key action that concerns a single display.
+func (d *display) apply(action string) {
+	switch action {
+	case actPause:
+		d.togglePause()
+	case actBack:
+		d.stepHistory(-1)
+	case actForward:
+		d.stepHistory(1)
+	case actAge:
+		d.ageShading.Store(!d.ageShading.Load())
+	case actTrails:
+		d.trails.Store(!d.trails.Load())
+	case actHeatmap:
+		d.heatmap.Store(!d.heatmap.Load())
+	case actContact:
+		d.contact.Store(!d.contact.Load())
+	case actOwners:
+		d.owners.Store(!d.owners.Load())
+	case actStructures:
+		d.structures.Store(!d.structures.Load())
+	case actSparklines:
+		d.sparklines.Store(!d.sparklines.Load())
+	case actFPS:
+		d.overlay.Store(!d.overlay.Load())
+	case actEvents:
+		d.events.Store(!d.events.Load())
+	case actExport:
+		d.export()
+	case actLayerDown:
+		d.moveLayer(-1)
+	case actLayerUp:
+		d.moveLayer(1)
+	case actProjection:
+		d.projection.Store(!d.projection.Load())
+	case actInfectionDown, actInfectionUp, actRecoveryDown, actRecoveryUp:
+		if sir := d.layer().sir; sir != nil {
+			adjustSIR(sir, action)
 		}
 	}
 }
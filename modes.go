@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+
+	"app/engine"
+	"app/pattern"
+)
+
+// builtinMode is a rule family selectable with -mode. Every mode but life,
+// voter and majority brings its own species; species entries in the config
+// file with a matching name still override their color and reaction time.
+type builtinMode struct {
+	species []engine.Species
+	colors  []string
+	weights []float64 // seeding weights per species; nil means uniform
+	// seed, if set, replaces random seeding with density and weights.
+	seed func(e *engine.Engine)
+	// intensity, if set, selects the continuous renderer, which colors
+	// each cell by a gradient of intensity(state) in [0, 1] instead of by
+	// species.
+	intensity func(engine.State) float64
+}
+
+var modes = map[string]builtinMode{
+	"life": {},
+	"sir": {
+		species: engine.SIRSpecies(),
+		colors:  []string{"green", "red", "blue"},
+		weights: []float64{0.98, 0.02, 0},
+	},
+	"forestfire": {
+		species: engine.ForestFireSpecies(),
+		colors:  []string{"green", "orangered"},
+		weights: []float64{1, 0},
+	},
+	"sandpile": {
+		species: engine.SandpileSpecies(),
+		colors:  []string{"#2b4c7e", "#5fa8d3", "#f2c14e", "white"},
+		seed:    seedSandpile,
+	},
+	"grayscott": {
+		species:   engine.GrayScottSpecies(),
+		colors:    []string{"aqua"},
+		seed:      seedGrayScott,
+		intensity: func(s engine.State) float64 { return min(s.V*2.5, 1) },
+	},
+	"voter":    {seed: seedOpinions},
+	"majority": {seed: seedOpinions},
+	"schelling": {
+		species: engine.SchellingSpecies(),
+		colors:  []string{"red", "blue"},
+	},
+	"lenia": {
+		species:   engine.LeniaSpecies(),
+		colors:    []string{"aqua"},
+		seed:      seedLenia,
+		intensity: func(s engine.State) float64 { return s.U },
+	},
+}
+
+// seedSandpile fills the grid with a random stable configuration.
+func seedSandpile(e *engine.Engine) {
+	for i := 0; i < e.Rows(); i++ {
+		for j := 0; j < e.Cols(); j++ {
+			g := e.Rand().Intn(engine.SandpileThreshold)
+			e.SetCell(i, j, engine.State{Species: g, Value: g})
+		}
+	}
+}
+
+// seedOpinions gives every cell one of the species at random, as the
+// opinion dynamics start from.
+func seedOpinions(e *engine.Engine) {
+	n := len(e.Species()) - 1
+	for i := 0; i < e.Rows(); i++ {
+		for j := 0; j < e.Cols(); j++ {
+			e.SetCell(i, j, engine.State{Species: 1 + e.Rand().Intn(n)})
+		}
+	}
+}
+
+// seedGrayScott fills the grid with the U chemical and drops a few noisy
+// squares of V into it.
+func seedGrayScott(e *engine.Engine) {
+	for i := 0; i < e.Rows(); i++ {
+		for j := 0; j < e.Cols(); j++ {
+			e.SetCell(i, j, engine.State{U: 1})
+		}
+	}
+	const size = 6
+	for range max(1, e.Rows()*e.Cols()/400) {
+		x, y := e.Rand().Intn(max(1, e.Rows()-size)), e.Rand().Intn(max(1, e.Cols()-size))
+		for i := x; i < min(x+size, e.Rows()); i++ {
+			for j := y; j < min(y+size, e.Cols()); j++ {
+				e.SetCell(i, j, engine.State{Species: 1, U: 0.5, V: 0.25 + 0.05*e.Rand().Float64()})
+			}
+		}
+	}
+}
+
+// seedLenia scatters a few patches of uniform noise.
+func seedLenia(e *engine.Engine) {
+	size := max(4, min(e.Rows(), e.Cols())/4)
+	for range max(1, e.Rows()*e.Cols()/(size*size*4)) {
+		x, y := e.Rand().Intn(max(1, e.Rows()-size)), e.Rand().Intn(max(1, e.Cols()-size))
+		for i := x; i < min(x+size, e.Rows()); i++ {
+			for j := y; j < min(y+size, e.Cols()); j++ {
+				e.SetCell(i, j, engine.State{Species: 1, U: e.Rand().Float64()})
+			}
+		}
+	}
+}
+
+// seed initializes e from the configured pattern or image, or for the
+// configured mode if there is neither, mirrors it as configured, then adds
+// the configured walls and turmites.
+func (cfg *Config) seed(e *engine.Engine) error {
+	m := modes[cfg.Mode]
+	switch {
+	case cfg.Pattern != "" && cfg.Image != "":
+		return fmt.Errorf("a pattern and an image cannot both seed the grid")
+	case cfg.Image != "":
+		palette, err := cfg.palette()
+		if err != nil {
+			return err
+		}
+		if err := seedImage(e, cfg.Image, palette); err != nil {
+			return err
+		}
+	case cfg.Pattern != "":
+		p, err := pattern.Load(cfg.Pattern)
+		if err != nil {
+			return err
+		}
+		if err := place(e, p, false); err != nil {
+			return fmt.Errorf("%s: %w", cfg.Pattern, err)
+		}
+	case m.seed != nil:
+		if cfg.Init != "random" {
+			return fmt.Errorf("mode %s seeds itself and takes no -init preset", cfg.Mode)
+		}
+		m.seed(e)
+	default:
+		if err := cfg.seedPreset(e); err != nil {
+			return err
+		}
+	}
+	if err := symmetrize(e, cfg.Symmetry); err != nil {
+		return err
+	}
+	if cfg.Walls != "" {
+		p, err := pattern.Load(cfg.Walls)
+		if err != nil {
+			return err
+		}
+		if err := place(e, p, true); err != nil {
+			return fmt.Errorf("%s: %w", cfg.Walls, err)
+		}
+	}
+	for range cfg.Agents.Count {
+		t := engine.Turmite{
+			X:    e.Rand().Intn(e.Rows()),
+			Y:    e.Rand().Intn(e.Cols()),
+			Dir:  e.Rand().Intn(4),
+			Rule: cfg.Agents.Rule,
+		}
+		if err := e.AddTurmite(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// densities returns the seeding probability of each species of the
+// configured mode: its entry in cfg.Densities if it has one, and otherwise
+// its share of cfg.Density by the mode's weights.
+func (cfg *Config) densities() ([]float64, error) {
+	scs, err := cfg.speciesConfigs()
+	if err != nil {
+		return nil, err
+	}
+	weights := modes[cfg.Mode].weights
+	var total float64
+	for i := range scs {
+		if weights == nil {
+			total++
+		} else if i < len(weights) {
+			total += weights[i]
+		}
+	}
+	for name := range cfg.Densities {
+		if !slices.Contains(allSpeciesNames(cfg), name) {
+			return nil, fmt.Errorf("density of unknown species %q", name)
+		}
+	}
+
+	densities := make([]float64, len(scs))
+	var sum float64
+	for i, sc := range scs {
+		if d, ok := cfg.Densities[sc.Name]; ok {
+			densities[i] = d
+		} else if weights == nil {
+			densities[i] = cfg.Density / total
+		} else if i < len(weights) && total > 0 {
+			densities[i] = cfg.Density * weights[i] / total
+		}
+		if densities[i] < 0 {
+			return nil, fmt.Errorf("species %q: negative density", sc.Name)
+		}
+		sum += densities[i]
+	}
+	if sum > 1 {
+		return nil, fmt.Errorf("densities add up to %.2f, more than 1", sum)
+	}
+	return densities, nil
+}
+
+// allSpeciesNames returns the names of the species of cfg and of every
+// mode, each once.
+func allSpeciesNames(cfg *Config) []string {
+	var names []string
+	for _, sc := range cfg.Species {
+		names = append(names, sc.Name)
+	}
+	for _, name := range modeNames() {
+		for _, sp := range modes[name].species {
+			names = append(names, sp.Name)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+func modeNames() []string {
+	var names []string
+	for name := range modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// speciesConfigs returns the species for the configured mode.
+func (cfg *Config) speciesConfigs() ([]SpeciesConfig, error) {
+	m, ok := modes[cfg.Mode]
+	if !ok {
+		return nil, fmt.Errorf("unknown mode %q (want one of %v)", cfg.Mode, modeNames())
+	}
+	if m.species == nil {
+		return cfg.Species, nil
+	}
+	var scs []SpeciesConfig
+	for i, sp := range m.species {
+		sc := SpeciesConfig{Name: sp.Name, Color: m.colors[i], ReactionTime: sp.ReactionTime}
+		for _, o := range cfg.Species {
+			if o.Name != sp.Name {
+				continue
+			}
+			if o.Color != "" {
+				sc.Color = o.Color
+			}
+			if o.ReactionTime != 0 {
+				sc.ReactionTime = o.ReactionTime
+			}
+		}
+		scs = append(scs, sc)
+	}
+	return scs, nil
+}
+
+// adjustSIR applies one of the sir parameter actions.
+func adjustSIR(sir *engine.SIR, action string) {
+	switch action {
+	case actInfectionDown:
+		sir.SetInfectionRate(sir.InfectionRate() - 0.05)
+	case actInfectionUp:
+		sir.SetInfectionRate(sir.InfectionRate() + 0.05)
+	case actRecoveryDown:
+		sir.SetRecovery(sir.Recovery() - 1)
+	case actRecoveryUp:
+		sir.SetRecovery(sir.Recovery() + 1)
+	}
+	slog.Info("sir changed", "infection_rate", sir.InfectionRate(), "recovery", sir.Recovery())
+}
+
+// modeTransition returns the Transition for the configured mode, or nil to
+// use the species' own rules.
+func (cfg *Config) modeTransition() engine.Transition {
+	switch cfg.Mode {
+	case "sir":
+		return engine.NewSIR(cfg.SIR.InfectionRate, cfg.SIR.Recovery)
+	case "forestfire":
+		return engine.ForestFire{Growth: cfg.ForestFire.Growth, Lightning: cfg.ForestFire.Lightning}
+	case "sandpile":
+		return engine.Sandpile{Drop: cfg.Sandpile.Drop}
+	case "grayscott":
+		gs := engine.DefaultGrayScott
+		gs.Feed, gs.Kill = cfg.GrayScott.Feed, cfg.GrayScott.Kill
+		gs.Du, gs.Dv = cfg.GrayScott.Du, cfg.GrayScott.Dv
+		return gs
+	case "lenia":
+		l := cfg.Lenia
+		return engine.NewLenia(l.Radius, l.Mu, l.Sigma, l.Dt)
+	case "schelling":
+		return engine.Schelling{Tolerance: cfg.Schelling.Tolerance}
+	case "voter":
+		return engine.Voter{}
+	case "majority":
+		return engine.MajorityVote{}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"app/engine"
+)
+
+// montageGap is the width in pixels of the gray border around the
+// thumbnails of a montage.
+const montageGap = 2
+
+// montage collects thumbnails of a layer every so often of its simulated
+// time, to lay them out on a contact sheet at the end of the run: a visual
+// history of a long experiment at a glance.
+type montage struct {
+	l     *layer
+	every time.Duration
+	scale int
+
+	mu     sync.Mutex
+	next   time.Duration
+	thumbs []*image.RGBA
+}
+
+// startMontage takes a thumbnail of l now and after the first tick of every
+// period of cfg.MontageEvery of simulated time, each cell cfg.MontageScale
+// pixels wide.
+func startMontage(cfg *Config, l *layer) (*montage, error) {
+	if cfg.MontageEvery <= 0 || cfg.MontageScale <= 0 {
+		return nil, fmt.Errorf("montage_every and montage_scale must be positive")
+	}
+	m := &montage{l: l, every: cfg.MontageEvery, scale: cfg.MontageScale, next: cfg.MontageEvery}
+	m.capture()
+	l.e.OnTick(func(engine.Stats) {
+		m.mu.Lock()
+		due := l.e.Now() >= m.next
+		for m.next <= l.e.Now() {
+			m.next += m.every
+		}
+		m.mu.Unlock()
+		if due {
+			m.capture()
+		}
+	})
+	return m, nil
+}
+
+// capture adds a thumbnail of the layer as it is now.
+func (m *montage) capture() {
+	img := m.l.image(m.scale)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.thumbs = append(m.thumbs, img)
+}
+
+// image renders the layer as it is now in its plain cell colors, each
+// cell scale pixels square.
+func (l *layer) image(scale int) *image.RGBA {
+	cells := l.e.Snapshot().Cells
+	img := image.NewRGBA(image.Rect(0, 0, l.e.Cols()*scale, l.e.Rows()*scale))
+	for x, row := range cells {
+		for y, s := range row {
+			r, g, b := l.color(s).RGB()
+			draw.Draw(img, image.Rect(y*scale, x*scale, (y+1)*scale, (x+1)*scale),
+				image.NewUniform(color.RGBA{uint8(r), uint8(g), uint8(b), 255}), image.Point{}, draw.Src)
+		}
+	}
+	return img
+}
+
+// write lays the thumbnails out left to right, then top to bottom, in a
+// grid at least as wide as it is high, and writes it to path as a PNG.
+func (m *montage) write(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := m.thumbs[0].Bounds()
+	n := len(m.thumbs)
+	cols := max(int(math.Ceil(math.Sqrt(float64(n*b.Dy())/float64(b.Dx())))), 1)
+	cols = min(cols, n)
+	rows := (n + cols - 1) / cols
+	sheet := image.NewRGBA(image.Rect(0, 0, cols*(b.Dx()+montageGap)+montageGap, rows*(b.Dy()+montageGap)+montageGap))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(color.Gray{128}), image.Point{}, draw.Src)
+	for i, thumb := range m.thumbs {
+		at := image.Pt(montageGap+i%cols*(b.Dx()+montageGap), montageGap+i/cols*(b.Dy()+montageGap))
+		draw.Draw(sheet, b.Add(at), thumb, image.Point{}, draw.Src)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, sheet); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return f.Close()
+}
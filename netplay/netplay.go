@@ -0,0 +1,177 @@
+// Package netplay runs a shared simulation that remote players paint on, a
+// territory game: each player is given a species and seeds cells of it
+// while the automaton runs, and the species with the most cells wins.
+//
+// Client and server exchange JSON messages, one per line, over TCP, or
+// over a WebSocket connection from the page Server.Web serves. A
+// session starts with the client's hello, naming the player, which the
+// server answers with a welcome:
+//
+//	{"type":"hello","name":"ada"}
+//	{"type":"welcome","token":"3f9a…","species":2,"rows":40,"cols":80,"names":["dead","green","red"],"colors":["black","green","red"]}
+//
+// A client reconnecting after a dropped connection sends the token it was
+// welcomed with in its hello, and plays the same species again. Then the
+// client sends paint messages, listing cells as row, column pairs,
+//
+//	{"type":"paint","cells":[[10,12],[10,13]]}
+//
+// and the server broadcasts a frame of the grid a few times a second: a
+// string per row, with . dead, # walls and A to X species 1 to 24, the
+// population of each species and the players. A game with a time limit
+// ends with an end message naming the winning species.
+//
+// Painting is first come, first served: a cell can only be painted while
+// it is dead, so players cannot overwrite each other's cells, only outgrow
+// them. Each player has an ink reserve of cells that refills over time,
+// so that painting is a matter of choosing where rather than how fast.
+package netplay
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// Message types.
+const (
+	Hello   = "hello"
+	Welcome = "welcome"
+	Paint   = "paint"
+	Frame   = "frame"
+	End     = "end"
+	Error   = "error"
+)
+
+// Message is any message of the protocol; fields not used by its type are
+// left out.
+type Message struct {
+	Type string `json:"type"`
+
+	// hello and welcome
+	Name    string   `json:"name,omitempty"`
+	Token   string   `json:"token,omitempty"`
+	Species int      `json:"species,omitempty"` // of the player
+	Rows    int      `json:"rows,omitempty"`
+	Cols    int      `json:"cols,omitempty"`
+	Names   []string `json:"names,omitempty"`  // of the species by id, dead first
+	Colors  []string `json:"colors,omitempty"` // of the species by id, dead first
+
+	// paint
+	Cells [][2]int `json:"cells,omitempty"`
+
+	// frame and end
+	Tick       int      `json:"tick,omitempty"`
+	Grid       []string `json:"grid,omitempty"`
+	Population []int    `json:"population,omitempty"` // by species id
+	Players    []Player `json:"players,omitempty"`
+	Left       float64  `json:"left,omitempty"` // seconds until the end, if the game has a time limit
+	Winner     int      `json:"winner,omitempty"`
+
+	// error
+	Text string `json:"text,omitempty"`
+}
+
+// Player describes a player in a frame.
+type Player struct {
+	Name      string `json:"name"`
+	Species   int    `json:"species"`
+	Ink       int    `json:"ink"`
+	Connected bool   `json:"connected"`
+}
+
+// Conn sends and receives messages over a connection. Send may be called
+// from several goroutines at once.
+type Conn struct {
+	conn  net.Conn
+	in    limitReader
+	dec   *json.Decoder
+	limit int64 // of a message received, 0 for none; see SetLimit
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewConn returns a Conn over conn.
+func NewConn(conn net.Conn) *Conn {
+	c := &Conn{conn: conn, enc: json.NewEncoder(conn)}
+	c.in.r = bufio.NewReader(conn)
+	c.dec = json.NewDecoder(&c.in)
+	return c
+}
+
+// SetLimit makes Receive fail on a message that takes more than n bytes to
+// read, or lifts the limit if n is 0. A server reading untrusted clients
+// sets one; frames of large grids are long, so clients need not.
+func (c *Conn) SetLimit(n int64) { c.limit = n }
+
+// limitReader reads from r, failing once it has read left bytes.
+type limitReader struct {
+	r    io.Reader
+	left int64
+}
+
+// ErrTooLong is returned by Receive for a message over the limit of the
+// Conn.
+var ErrTooLong = errors.New("netplay: message too long")
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		return 0, ErrTooLong
+	}
+	n, err := l.r.Read(p[:min(int64(len(p)), l.left)])
+	l.left -= int64(n)
+	return n, err
+}
+
+// Dial connects to a server at addr, a host:port.
+func Dial(addr string) (*Conn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(conn), nil
+}
+
+// writeTimeout bounds how long Send waits for a slow peer.
+const writeTimeout = 5 * time.Second
+
+// Send sends m.
+func (c *Conn) Send(m Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return c.enc.Encode(m)
+}
+
+// Receive waits for the next message.
+func (c *Conn) Receive() (Message, error) {
+	c.in.left = math.MaxInt64
+	if c.limit > 0 {
+		c.in.left = c.limit
+	}
+	var m Message
+	err := c.dec.Decode(&m)
+	return m, err
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Cell returns the species id of a grid character of a frame, or -1 for a
+// wall.
+func Cell(ch byte) int {
+	switch {
+	case ch == '#':
+		return -1
+	case ch >= 'A' && ch <= 'X':
+		return int(ch-'A') + 1
+	}
+	return 0
+}
@@ -0,0 +1,358 @@
+package netplay
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"app/engine"
+)
+
+// Options configure a Server.
+type Options struct {
+	// Colors are the color names of the species by id, dead first, passed
+	// on to clients.
+	Colors []string
+	// Ink is the most cells a player can have in reserve, and Refill the
+	// time it takes to gain one.
+	Ink    int
+	Refill time.Duration
+	// Interval is the time between frames.
+	Interval time.Duration
+	// Duration is how long the game lasts; 0 for no limit.
+	Duration time.Duration
+	// MaxPlayers is the most players that may join, counting those who
+	// left; 0 for DefaultMaxPlayers.
+	MaxPlayers int
+	// Log, if set, is told about players joining and leaving.
+	Log func(format string, args ...any)
+}
+
+// DefaultMaxPlayers is the most players of a game unless Options say
+// otherwise.
+const DefaultMaxPlayers = 64
+
+// Limits on clients, against ones that would hold a session or take all
+// memory: the time to say hello in, and the size of a message.
+const (
+	helloTimeout = 10 * time.Second
+	maxMessage   = 64 << 10
+)
+
+// frameBacklog is the most frames queued for a client. One that falls
+// further behind is disconnected, and may rejoin with its token.
+const frameBacklog = 16
+
+// Server is a game over an engine, which it does not start.
+type Server struct {
+	e    *engine.Engine
+	opts Options
+
+	mu      sync.Mutex
+	players map[string]*player // by token
+	order   []*player          // in order of joining, for frames
+	start   time.Time
+	ended   bool
+	winner  int
+
+	writers sync.WaitGroup // of the frames queued for players; see deliver
+}
+
+type player struct {
+	name    string
+	species int
+	ink     int
+	filled  time.Time    // when ink was last topped up
+	conn    *Conn        // nil while disconnected
+	out     chan Message // frames queued for conn, nil until welcomed
+}
+
+// NewServer returns a server for a game over e.
+func NewServer(e *engine.Engine, opts Options) (*Server, error) {
+	if len(e.Species()) < 2 {
+		return nil, errors.New("netplay needs at least one species")
+	}
+	if len(e.Species()) > 25 {
+		return nil, errors.New("netplay supports at most 24 species")
+	}
+	if opts.Ink <= 0 || opts.Refill <= 0 || opts.Interval <= 0 {
+		return nil, errors.New("ink, refill and interval must be positive")
+	}
+	if opts.MaxPlayers < 0 {
+		return nil, errors.New("the most players must not be negative")
+	}
+	if opts.MaxPlayers == 0 {
+		opts.MaxPlayers = DefaultMaxPlayers
+	}
+	if opts.Log == nil {
+		opts.Log = func(string, ...any) {}
+	}
+	return &Server{e: e, opts: opts, players: map[string]*player{}}, nil
+}
+
+// Serve accepts players on ln and broadcasts frames until the game ends or
+// ln fails. It returns the winning species if the game ended.
+func (s *Server) Serve(ln net.Listener) (winner int, err error) {
+	s.start = time.Now()
+	done := make(chan struct{})
+	go s.broadcast(done)
+	go func() {
+		<-done
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.ended {
+				return s.winner, nil
+			}
+			return 0, err
+		}
+		go s.session(NewConn(conn))
+	}
+}
+
+// session plays with one client until it disconnects.
+func (s *Server) session(c *Conn) {
+	defer c.Close()
+	c.SetLimit(maxMessage)
+	c.conn.SetReadDeadline(time.Now().Add(helloTimeout))
+	hello, err := c.Receive()
+	if err != nil {
+		return
+	}
+	c.conn.SetReadDeadline(time.Time{})
+	if hello.Type != Hello {
+		c.Send(Message{Type: Error, Text: "expected hello"})
+		return
+	}
+	p, token, err := s.join(hello, c)
+	if err != nil {
+		c.Send(Message{Type: Error, Text: err.Error()})
+		return
+	}
+	defer s.leave(p, c)
+
+	var names []string
+	for _, sp := range s.e.Species() {
+		names = append(names, sp.Name)
+	}
+	if err := c.Send(Message{Type: Welcome, Name: p.name, Token: token, Species: p.species,
+		Rows: s.e.Rows(), Cols: s.e.Cols(), Names: names, Colors: s.opts.Colors}); err != nil {
+		return
+	}
+	s.deliver(p, c)
+	for {
+		m, err := c.Receive()
+		if err != nil {
+			return
+		}
+		switch m.Type {
+		case Paint:
+			s.paint(p, m.Cells)
+		default:
+			c.Send(Message{Type: Error, Text: fmt.Sprintf("unexpected %q message", m.Type)})
+		}
+	}
+}
+
+// join adds the player of hello, or takes back the one its token names,
+// and returns it with its token. A new player is given the species with
+// the fewest players.
+func (s *Server) join(hello Message, c *Conn) (*player, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return nil, "", errors.New("the game is over")
+	}
+	if p, ok := s.players[hello.Token]; ok && hello.Token != "" {
+		if p.conn != nil {
+			return nil, "", fmt.Errorf("%s is already connected", p.name)
+		}
+		p.conn = c
+		s.opts.Log("%s is back", p.name)
+		return p, hello.Token, nil
+	}
+	if len(s.order) >= s.opts.MaxPlayers {
+		return nil, "", errors.New("the game is full")
+	}
+	name := strings.TrimSpace(hello.Name)
+	if name == "" || len(name) > 20 {
+		return nil, "", errors.New("a name of 1 to 20 characters is needed")
+	}
+	for _, p := range s.order {
+		if p.name == name {
+			return nil, "", fmt.Errorf("the name %s is taken", name)
+		}
+	}
+	counts := make([]int, len(s.e.Species()))
+	for _, p := range s.order {
+		counts[p.species]++
+	}
+	species := 1
+	for id := 2; id < len(counts); id++ {
+		if counts[id] < counts[species] {
+			species = id
+		}
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	p := &player{name: name, species: species, ink: s.opts.Ink, filled: time.Now(), conn: c}
+	s.players[token] = p
+	s.order = append(s.order, p)
+	s.opts.Log("%s joined as %s", name, s.e.Species()[species].Name)
+	return p, token, nil
+}
+
+// deliver starts sending p, connected on c, the frames broadcast queues
+// for it, from a goroutine of its own, unless the game is over.
+func (s *Server) deliver(p *player, c *Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended || p.conn != c {
+		return
+	}
+	out := make(chan Message, frameBacklog)
+	p.out = out
+	s.writers.Add(1)
+	go func() {
+		defer s.writers.Done()
+		for m := range out {
+			if err := c.Send(m); err != nil {
+				c.Close()
+				return
+			}
+			if m.Type == End {
+				return
+			}
+		}
+	}()
+}
+
+// leave marks p disconnected, unless it has reconnected on another
+// connection meanwhile.
+func (s *Server) leave(p *player, c *Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.conn == c {
+		if p.out != nil {
+			close(p.out)
+		}
+		p.conn, p.out = nil, nil
+		s.opts.Log("%s left", p.name)
+	}
+}
+
+// refill tops up the ink of p for the time passed. Call with s.mu held.
+func (s *Server) refill(p *player) {
+	gained := int(time.Since(p.filled) / s.opts.Refill)
+	if gained == 0 {
+		return
+	}
+	p.filled = p.filled.Add(time.Duration(gained) * s.opts.Refill)
+	if p.ink = min(p.ink+gained, s.opts.Ink); p.ink == s.opts.Ink {
+		p.filled = time.Now()
+	}
+}
+
+// paint seeds cells of the species of p at the given cells, as far as its
+// ink goes, skipping cells that are off the grid, walls or alive.
+func (s *Server) paint(p *player, cells [][2]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.refill(p)
+	for _, c := range cells {
+		if p.ink == 0 {
+			return
+		}
+		x, y := c[0], c[1]
+		if x < 0 || x >= s.e.Rows() || y < 0 || y >= s.e.Cols() {
+			continue
+		}
+		if old := s.e.Cell(x, y); old.Wall || old.Alive() {
+			continue
+		}
+		s.e.SetCell(x, y, engine.State{Species: p.species})
+		p.ink--
+	}
+}
+
+// broadcast queues a frame for every player every interval until the
+// game ends, then the end message, and closes done once that is sent.
+// Frames never wait for a slow client: one with frameBacklog frames
+// queued already is disconnected instead.
+func (s *Server) broadcast(done chan<- struct{}) {
+	t := time.NewTicker(s.opts.Interval)
+	defer t.Stop()
+	for range t.C {
+		m := s.frame()
+		if s.opts.Duration > 0 && time.Since(s.start) >= s.opts.Duration {
+			m.Type, m.Left, m.Winner = End, 0, 1
+			for id := 2; id < len(m.Population); id++ {
+				if m.Population[id] > m.Population[m.Winner] {
+					m.Winner = id
+				}
+			}
+		}
+		s.mu.Lock()
+		s.ended, s.winner = m.Type == End, m.Winner
+		for _, p := range s.order {
+			if p.out == nil {
+				continue
+			}
+			select {
+			case p.out <- m:
+			default:
+				p.conn.Close()
+			}
+		}
+		s.mu.Unlock()
+		if m.Type == End {
+			s.writers.Wait()
+			close(done)
+			return
+		}
+	}
+}
+
+// frame returns a frame of the grid as it is now.
+func (s *Server) frame() Message {
+	snap := s.e.Snapshot()
+	m := Message{Type: Frame, Tick: s.e.Ticks(), Population: make([]int, len(s.e.Species()))}
+	for _, row := range snap.Cells {
+		line := make([]byte, len(row))
+		for i, c := range row {
+			switch {
+			case c.Wall:
+				line[i] = '#'
+			case c.Alive():
+				line[i] = byte('A' + c.Species - 1)
+			default:
+				line[i] = '.'
+			}
+			m.Population[c.Species]++
+		}
+		m.Grid = append(m.Grid, string(line))
+	}
+	if s.opts.Duration > 0 {
+		m.Left = max(s.opts.Duration-time.Since(s.start), 0).Seconds()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.order {
+		s.refill(p)
+		m.Players = append(m.Players, Player{p.name, p.species, p.ink, p.conn != nil})
+	}
+	return m
+}
@@ -0,0 +1,163 @@
+package netplay_test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"app/engine"
+	"app/netplay"
+)
+
+// serve starts a game of two players at most over a grid of dead cells,
+// which does not run, and returns its address.
+func serve(t *testing.T) string {
+	t.Helper()
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 6, 8
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := netplay.NewServer(e, netplay.Options{Ink: 5, Refill: time.Hour, Interval: 10 * time.Millisecond, MaxPlayers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go srv.Serve(ln)
+	return ln.Addr().String()
+}
+
+// hello joins the game at addr as name and returns the connection and the
+// server's answer.
+func hello(t *testing.T, addr, name string) (*netplay.Conn, netplay.Message) {
+	t.Helper()
+	c, err := netplay.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.Send(netplay.Message{Type: netplay.Hello, Name: name}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := c.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, m
+}
+
+// TestSession checks that two players are given species of their own, that
+// one sees the cells the other paints, as far as its ink goes, and that a
+// third is turned away from a game of two.
+func TestSession(t *testing.T) {
+	addr := serve(t)
+	a, wa := hello(t, addr, "ada")
+	b, wb := hello(t, addr, "bob")
+	if wa.Type != netplay.Welcome || wb.Type != netplay.Welcome || wa.Species == wb.Species {
+		t.Fatalf("welcomed as %+v and %+v, want two species", wa, wb)
+	}
+	if wa.Rows != 6 || wa.Cols != 8 || wa.Token == "" {
+		t.Errorf("welcome %+v, want a token and a 6x8 grid", wa)
+	}
+
+	cells := [][2]int{{9, 9}} // off the grid, and so free
+	for y := range 8 {
+		cells = append(cells, [2]int{1, y})
+	}
+	if err := a.Send(netplay.Message{Type: netplay.Paint, Cells: cells}); err != nil {
+		t.Fatal(err)
+	}
+	painted := strings.Repeat(string(rune('A'+wa.Species-1)), 5) + "..."
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		m, err := b.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Type == netplay.Frame && m.Grid[1] == painted {
+			if len(m.Players) != 2 || m.Players[0].Ink != 0 || m.Players[1].Ink != 5 {
+				t.Errorf("players %+v, want ada out of ink and bob with 5", m.Players)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("row 1 is %q, want %q", m.Grid[1], painted)
+		}
+	}
+
+	if _, m := hello(t, addr, "cy"); m.Type != netplay.Error || m.Text != "the game is full" {
+		t.Errorf("a third player got %+v", m)
+	}
+}
+
+// TestLongMessage checks that the server hangs up on a client sending a
+// message beyond its limit rather than reading on.
+func TestLongMessage(t *testing.T) {
+	addr := serve(t)
+	c, err := netplay.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	go c.Send(netplay.Message{Type: netplay.Hello, Name: strings.Repeat("a", 1<<20)})
+	if m, err := c.Receive(); err == nil {
+		t.Errorf("got %+v, want the connection closed", m)
+	}
+}
+
+// TestSlowClient checks that a client that stops reading neither holds up
+// the frames of the others nor stays connected.
+func TestSlowClient(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 200, 300 // frames that fill the socket buffers soon
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	left := make(chan string, 2)
+	srv, err := netplay.NewServer(e, netplay.Options{Ink: 5, Refill: time.Hour, Interval: 20 * time.Millisecond,
+		Log: func(format string, args ...any) {
+			if msg := fmt.Sprintf(format, args...); strings.HasSuffix(msg, " left") {
+				left <- msg
+			}
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go srv.Serve(ln)
+	addr := ln.Addr().String()
+
+	hello(t, addr, "ada") // and never reads again
+	fast, _ := hello(t, addr, "bob")
+	for deadline := time.Now().Add(20 * time.Second); ; {
+		select {
+		case msg := <-left:
+			if msg != "ada left" {
+				t.Errorf("%s, want ada", msg)
+			}
+			return
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("ada is still connected")
+		}
+		last := time.Now()
+		if _, err := fast.Receive(); err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Since(last); d > time.Second {
+			t.Fatalf("bob waited %v for a frame", d)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package netplay
+
+import (
+	_ "embed"
+	"net"
+	"net/http"
+)
+
+//go:embed web/index.html
+var page []byte
+
+// Play plays with a client connected over conn until it disconnects, as
+// Serve does with every connection it accepts.
+func (s *Server) Play(conn net.Conn) {
+	s.session(NewConn(conn))
+}
+
+// Web returns a handler serving the game to browsers: a page at / for
+// watching and painting the grid, with a scoreboard of the players by the
+// live cells of their species, which plays over a WebSocket connection to
+// /play speaking the same messages as over TCP, a message a line. Browsers
+// and terminal players share the same game. Serve must run for frames to
+// be sent.
+func (s *Server) Web() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+	mux.HandleFunc("GET /play", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrade(w, r)
+		if err != nil {
+			return
+		}
+		s.Play(conn)
+	})
+	return mux
+}
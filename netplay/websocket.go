@@ -0,0 +1,217 @@
+package netplay
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// WebSocket opcodes, of RFC 6455.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// wsGUID is appended to the client's key to accept a WebSocket handshake.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxFrame is the longest data frame accepted from a client, far beyond
+// any message of the protocol.
+const wsMaxFrame = 1 << 20
+
+// upgrade takes over the connection of a WebSocket handshake request and
+// returns it as a net.Conn carrying the payload of the messages: each
+// Write is sent as a text message, and Read reads the messages received
+// one after the other, as a stream, which suits the JSON lines of the
+// protocol. It answers a request that is not a handshake with an error,
+// and so one from a page of another origin than the server.
+func upgrade(w http.ResponseWriter, r *http.Request) (net.Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		http.Error(w, "a WebSocket handshake is expected", http.StatusBadRequest)
+		return nil, errors.New("not a WebSocket handshake")
+	}
+	// Browsers send the origin of the page, which for the page Web
+	// serves is the server itself; other clients send none.
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || !strings.EqualFold(u.Host, r.Host) {
+			http.Error(w, "WebSocket connections from other origins are refused", http.StatusForbidden)
+			return nil, fmt.Errorf("websocket: origin %s is not %s", origin, r.Host)
+		}
+	}
+	h, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "cannot take over the connection", http.StatusInternalServerError)
+		return nil, errors.New("connection cannot be hijacked")
+	}
+	conn, rw, err := h.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if _, err := fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:])); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{Conn: conn, r: rw.Reader}, nil
+}
+
+// headerHas reports whether a comma-separated header lists token, in any
+// case.
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for t := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsConn is the server side of a WebSocket connection; see upgrade.
+type wsConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	left int64   // bytes of the payload of the current frame still to read
+	mask [4]byte // of the current frame
+	pos  int     // into mask of the next byte
+
+	wmu    sync.Mutex // for whole frames
+	closed bool
+}
+
+// Read reads the payload of the data frames received, answering pings and
+// closes in between.
+func (c *wsConn) Read(p []byte) (int, error) {
+	for c.left == 0 {
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := c.r.Read(p[:min(int64(len(p)), c.left)])
+	for i := range p[:n] {
+		p[i] ^= c.mask[c.pos%4]
+		c.pos++
+	}
+	c.left -= int64(n)
+	return n, err
+}
+
+// next reads frame headers up to that of the next data frame, handling the
+// control frames on the way. Clients must mask their frames.
+func (c *wsConn) next() error {
+	var h [2]byte
+	if _, err := io.ReadFull(c.r, h[:]); err != nil {
+		return err
+	}
+	op, size := h[0]&0x0f, int64(h[1]&0x7f)
+	if h[1]&0x80 == 0 {
+		return errors.New("websocket: unmasked frame from a client")
+	}
+	switch size {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return err
+		}
+		size = int64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return err
+		}
+		size = int64(binary.BigEndian.Uint64(b[:]) & (1<<63 - 1))
+	}
+	if _, err := io.ReadFull(c.r, c.mask[:]); err != nil {
+		return err
+	}
+	c.pos = 0
+	switch op {
+	case wsContinuation, wsText, wsBinary:
+		if size > wsMaxFrame {
+			return fmt.Errorf("websocket: frame of %d bytes, over %d", size, wsMaxFrame)
+		}
+		c.left = size
+		return nil
+	case wsClose, wsPing, wsPong:
+		if size > 125 {
+			return errors.New("websocket: control frame too long")
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(c.r, payload); err != nil {
+			return err
+		}
+		for i := range payload {
+			payload[i] ^= c.mask[i%4]
+		}
+		switch op {
+		case wsClose:
+			c.Close()
+			return io.EOF
+		case wsPing:
+			return c.writeFrame(wsPong, payload)
+		}
+		return nil
+	}
+	return fmt.Errorf("websocket: unknown opcode %d", op)
+}
+
+// Write sends p as a text message.
+func (c *wsConn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(wsText, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeFrame sends a single frame, unmasked as from a server.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	frame := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	_, err := c.Conn.Write(append(frame, payload...))
+	return err
+}
+
+// Close sends a close frame, if it has not been, and closes the
+// connection.
+func (c *wsConn) Close() error {
+	c.writeFrame(wsClose, nil)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.Conn.Close()
+}
@@ -0,0 +1,171 @@
+package netplay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// frame returns a frame as a client sends it, masked, with the extended
+// length a payload of its size needs.
+func frame(fin bool, op byte, payload []byte) []byte {
+	b := []byte{op}
+	if fin {
+		b[0] |= 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		b = append(b, 0x80|byte(n))
+	case n <= 0xffff:
+		b = binary.BigEndian.AppendUint16(append(b, 0x80|126), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint64(append(b, 0x80|127), uint64(n))
+	}
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	b = append(b, mask[:]...)
+	for i, c := range payload {
+		b = append(b, c^mask[i%4])
+	}
+	return b
+}
+
+// pipe returns the server side of a WebSocket connection over a pipe, and
+// the client side, whose output is read into out until it closes.
+func pipe(t *testing.T) (ws *wsConn, client net.Conn, out <-chan []byte) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	received := make(chan []byte, 1)
+	go func() {
+		b, _ := io.ReadAll(client)
+		received <- b
+	}()
+	return &wsConn{Conn: server, r: bufio.NewReader(server)}, client, received
+}
+
+// TestWebSocketFrames checks that the payloads of data frames of every
+// length encoding, and of a message fragmented around a ping, read as one
+// stream, that the ping is answered and a close ends it.
+func TestWebSocketFrames(t *testing.T) {
+	ws, client, out := pipe(t)
+	long, longer := bytes.Repeat([]byte("x"), 300), bytes.Repeat([]byte("y"), 70000)
+	go func() {
+		for _, f := range [][]byte{
+			frame(true, wsText, []byte("hi")),
+			frame(true, wsText, long),
+			frame(true, wsBinary, longer),
+			frame(false, wsText, []byte("ab")),
+			frame(true, wsPing, []byte("p")),
+			frame(true, wsContinuation, []byte("cd")),
+			frame(true, wsClose, nil),
+		} {
+			if _, err := client.Write(f); err != nil {
+				return
+			}
+		}
+	}()
+	got, err := io.ReadAll(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "hi" + string(long) + string(longer) + "abcd"
+	if string(got) != want {
+		t.Errorf("read %d bytes, not the %d sent", len(got), len(want))
+	}
+	if sent, want := <-out, []byte{0x80 | wsPong, 1, 'p', 0x80 | wsClose, 0}; !bytes.Equal(sent, want) {
+		t.Errorf("server sent % x, want a pong and a close % x", sent, want)
+	}
+}
+
+// TestWebSocketWrite checks the length encodings of the frames sent.
+func TestWebSocketWrite(t *testing.T) {
+	for _, tc := range []struct {
+		n      int
+		header []byte
+	}{
+		{5, []byte{0x81, 5}},
+		{300, []byte{0x81, 126, 0x01, 0x2c}},
+		{70000, []byte{0x81, 127, 0, 0, 0, 0, 0, 0x01, 0x11, 0x70}},
+	} {
+		ws, _, out := pipe(t)
+		payload := bytes.Repeat([]byte("z"), tc.n)
+		go func() {
+			ws.Write(payload)
+			ws.Conn.Close()
+		}()
+		if sent := <-out; !bytes.Equal(sent, append(tc.header, payload...)) {
+			t.Errorf("%d bytes sent with header % x, want % x", tc.n, sent[:min(len(sent), len(tc.header))], tc.header)
+		}
+	}
+}
+
+// TestWebSocketRefused checks that frames a server must not accept fail
+// the read: unmasked, too long, or a control frame over 125 bytes.
+func TestWebSocketRefused(t *testing.T) {
+	unmasked := frame(true, wsText, []byte("hi"))
+	unmasked[1] &^= 0x80
+	huge := binary.BigEndian.AppendUint64([]byte{0x82, 0x80 | 127}, wsMaxFrame+1)
+	for name, f := range map[string][]byte{
+		"unmasked":     unmasked,
+		"too long":     append(huge, 1, 2, 3, 4),
+		"long control": frame(true, wsPing, bytes.Repeat([]byte("p"), 126)),
+	} {
+		ws, client, _ := pipe(t)
+		go client.Write(f)
+		if _, err := ws.Read(make([]byte, 16)); err == nil || err == io.EOF {
+			t.Errorf("%s frame: read error %v", name, err)
+		}
+	}
+}
+
+// TestUpgrade checks the handshake, answered with the key of RFC 6455's
+// example, and that it is refused to pages of other origins.
+func TestUpgrade(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, err := upgrade(w, r); err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	for _, tc := range []struct {
+		origin string
+		want   string
+	}{
+		{"", "101"},
+		{"http://" + host, "101"},
+		{"http://evil.example", "403"},
+	} {
+		conn, err := net.Dial("tcp", host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := "GET / HTTP/1.1\r\nHost: " + host + "\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+			"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"
+		if tc.origin != "" {
+			req += "Origin: " + tc.origin + "\r\n"
+		}
+		fmt.Fprint(conn, req+"\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		conn.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(resp.StatusCode); got != tc.want {
+			t.Errorf("origin %q: status %s, want %s", tc.origin, got, tc.want)
+		}
+		if accept := resp.Header.Get("Sec-WebSocket-Accept"); tc.want == "101" && accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+			t.Errorf("accepted with %q", accept)
+		}
+	}
+}
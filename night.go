@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"app/engine"
+)
+
+// NightConfig alternates the species' rules between day and night over
+// simulated time, such as Life by day and Seeds by night. Every Period
+// starts with a day and ends with a night Length of it long, during which
+// the species play Rule, or their entry of Rules, by name, instead of
+// their own; species in neither keep theirs.
+type NightConfig struct {
+	Rule   string            `toml:"rule" yaml:"rule"`
+	Rules  map[string]string `toml:"rules" yaml:"rules"`
+	Period time.Duration     `toml:"period" yaml:"period"`
+	Length float64           `toml:"length" yaml:"length"`
+}
+
+// nightCycle switches the rules of an engine at dusk and dawn.
+type nightCycle struct {
+	period time.Duration
+	dusk   time.Duration // into the period
+	dark   atomic.Bool
+}
+
+// startNight has the night rules of cfg.Night take over e at every dusk
+// and hand back at every dawn, checked once per tick, and returns the
+// cycle, or nil if no night rules are configured. own tells whether e
+// plays the species' own rules. The day rules restored at dawn are those
+// the species played at dusk, so that rules changed by day, as with the
+// rule editor, are kept, and those changed by night lost.
+func startNight(cfg *Config, e *engine.Engine, own bool) (*nightCycle, error) {
+	n := &cfg.Night
+	if n.Rule == "" && len(n.Rules) == 0 {
+		return nil, nil
+	}
+	if n.Period <= 0 || n.Length <= 0 || n.Length >= 1 {
+		return nil, fmt.Errorf("period %v must be positive and length %v in (0, 1)", n.Period, n.Length)
+	}
+	night := make([]*engine.Rule, len(e.Species())) // by species id, nil to keep its rule
+	if n.Rule != "" {
+		r, err := engine.ParseRule(n.Rule)
+		if err != nil {
+			return nil, err
+		}
+		for id := 1; id < len(night); id++ {
+			night[id] = &r
+		}
+	}
+	for name, rule := range n.Rules {
+		id, err := speciesID(e, name)
+		if err != nil || id == engine.Dead {
+			return nil, fmt.Errorf("unknown species %q", name)
+		}
+		r, err := engine.ParseRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("species %s: %w", name, err)
+		}
+		night[id] = &r
+	}
+	if !own {
+		return nil, fmt.Errorf("the rules of %s replace the species' own", cfg.Mode)
+	}
+
+	c := &nightCycle{period: n.Period, dusk: time.Duration(float64(n.Period) * (1 - n.Length))}
+	day := make([]engine.Rule, len(night))
+	e.OnTick(func(engine.Stats) {
+		dark := e.Now()%c.period >= c.dusk
+		if dark == c.dark.Load() {
+			return
+		}
+		for id, r := range night {
+			switch {
+			case r == nil:
+			case dark:
+				day[id] = e.Rule(id)
+				e.SetRule(id, *r)
+			default:
+				e.SetRule(id, day[id])
+			}
+		}
+		c.dark.Store(dark)
+	})
+	return c, nil
+}
+
+// status describes the phase of c on e and how long it has left, for the
+// status line.
+func (c *nightCycle) status(e *engine.Engine) string {
+	if c == nil {
+		return ""
+	}
+	t := e.Now() % c.period
+	if c.dark.Load() {
+		return fmt.Sprintf("night, dawn in %s", (c.period - t).Round(time.Second))
+	}
+	return fmt.Sprintf("day, dusk in %s", max(c.dusk-t, 0).Round(time.Second))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+)
+
+// bannerTime is how long a notification stays on screen.
+const bannerTime = 3 * time.Second
+
+// banner is the latest notification of a display.
+type banner struct {
+	mu    sync.Mutex
+	text  string
+	until time.Time
+}
+
+// notify shows a banner across the grid of d whenever a species of one of
+// its layers dies out or takes the majority share, ringing the terminal
+// bell too if bell is set.
+func (d *display) notify(bell bool) {
+	for _, l := range d.layers {
+		l.e.OnEvent(func(ev engine.Event) {
+			if ev.Kind != engine.Extinction && ev.Kind != engine.Majority {
+				return
+			}
+			select {
+			case <-d.done: // the hook outlives a display of shared layers
+				return
+			default:
+			}
+			text := ev.Text
+			if len(d.layers) > 1 {
+				text = l.name + ": " + text
+			}
+			d.flash(text)
+			if bell {
+				d.screen.Beep()
+			}
+		})
+	}
+}
+
+// flash shows text in the banner of d for bannerTime.
+func (d *display) flash(text string) {
+	d.banner.mu.Lock()
+	defer d.banner.mu.Unlock()
+	d.banner.text, d.banner.until = text, time.Now().Add(bannerTime)
+	d.wake.signal()
+}
+
+// drawBanner draws the current notification, if any, in the middle of the
+// rows by cols cells of the grid shown.
+func (d *display) drawBanner(rows, cols int) {
+	d.banner.mu.Lock()
+	text, until := d.banner.text, d.banner.until
+	d.banner.mu.Unlock()
+	if text == "" || time.Now().After(until) {
+		return
+	}
+	d.repaint()
+	s := "  " + text + "  "
+	width := 2 * cols
+	if utf8.RuneCountInString(s) > width {
+		s = string([]rune(s)[:width])
+	}
+	x := d.left + (width-utf8.RuneCountInString(s))/2
+	style := tcell.StyleDefault.Reverse(true).Bold(true)
+	for _, r := range s {
+		d.screen.SetContent(x, rows/2, r, nil, style)
+		x++
+	}
+}
@@ -0,0 +1,80 @@
+// Package osc sends Open Sound Control messages over UDP, for driving
+// synthesizers and visuals from a running automaton.
+package osc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+)
+
+// Client sends messages to one address.
+type Client struct {
+	conn net.Conn
+}
+
+// Dial returns a client sending to addr, a host:port.
+func Dial(addr string) (*Client, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Send sends a message to address, e.g. "/nnca/extinction", with args of
+// type int, int32, float32, float64 or string.
+func (c *Client) Send(address string, args ...any) error {
+	msg, err := Encode(address, args...)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(msg)
+	return err
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Encode returns the OSC packet of a message. Integers are sent as int32
+// and floats as float32, the types every OSC receiver understands.
+func Encode(address string, args ...any) ([]byte, error) {
+	tags := []byte{','}
+	var data []byte
+	for _, a := range args {
+		switch v := a.(type) {
+		case int:
+			if v < math.MinInt32 || v > math.MaxInt32 {
+				return nil, fmt.Errorf("osc: %d does not fit in an int32", v)
+			}
+			tags = append(tags, 'i')
+			data = binary.BigEndian.AppendUint32(data, uint32(int32(v)))
+		case int32:
+			tags = append(tags, 'i')
+			data = binary.BigEndian.AppendUint32(data, uint32(v))
+		case float32:
+			tags = append(tags, 'f')
+			data = binary.BigEndian.AppendUint32(data, math.Float32bits(v))
+		case float64:
+			tags = append(tags, 'f')
+			data = binary.BigEndian.AppendUint32(data, math.Float32bits(float32(v)))
+		case string:
+			tags = append(tags, 's')
+			data = appendString(data, v)
+		default:
+			return nil, fmt.Errorf("osc: unsupported argument type %T", a)
+		}
+	}
+	msg := appendString(nil, address)
+	msg = appendString(msg, string(tags))
+	return append(msg, data...), nil
+}
+
+// appendString appends s null-terminated and padded to a multiple of four
+// bytes.
+func appendString(b []byte, s string) []byte {
+	b = append(b, s...)
+	return append(b, make([]byte, 4-len(s)%4)...)
+}
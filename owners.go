@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+)
+
+// ownerColor is the color of the cells of worker i in the ownership view,
+// hues a golden turn apart so that neighbouring workers differ.
+func ownerColor(i int) tcell.Color {
+	return hsv(float64(i)*0.618034, 0.6, 0.9)
+}
+
+// measureWorkers refreshes d.workerRates every second with the update
+// rates of the workers of the layer shown, while the ownership view is on.
+func (d *display) measureWorkers() {
+	var (
+		last  []engine.Worker
+		shown *layer
+		at    time.Time
+	)
+	d.every(time.Second, func(now time.Time) {
+		if !d.owners.Load() {
+			last = nil
+			return
+		}
+		l := d.layer()
+		ws := l.e.Workers()
+		if ws == nil || l != shown || len(ws) != len(last) {
+			last, shown, at = ws, l, now
+			s := "measuring workers [w]"
+			if ws == nil {
+				s = "a goroutine per cell, or one for all [w]"
+			}
+			d.workerRates.Store(&s)
+			return
+		}
+		s := workerSummary(last, ws, now.Sub(at))
+		d.workerRates.Store(&s)
+		last, at = ws, now
+	})
+}
+
+// workerSummary describes the share of the work of every worker between
+// two readings of Workers taken span apart: the least, mean and most cell
+// updates a second of any, which worker does the most, and the largest
+// share of the time any was busy.
+func workerSummary(before, after []engine.Worker, span time.Duration) string {
+	secs := span.Seconds()
+	lo, hi, sum, busiest, busy := 0.0, 0.0, 0.0, 0, 0.0
+	for i, w := range after {
+		rate := float64(w.Updates-before[i].Updates) / secs
+		if i == 0 || rate < lo {
+			lo = rate
+		}
+		if i == 0 || rate > hi {
+			hi, busiest = rate, i
+		}
+		sum += rate
+		busy = max(busy, (w.Busy-before[i].Busy).Seconds()/secs)
+	}
+	w := after[busiest]
+	return fmt.Sprintf("%d workers  updates/s min %s mean %s max %s (at %d,%d)  busiest %.0f%% [w]",
+		len(after), siCount(lo), siCount(sum/float64(len(after))), siCount(hi), w.Top, w.Left, 100*busy)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ftPort is the default port of a Flaschen Taschen server.
+const ftPort = "1337"
+
+// panelOut is a physical display a panel shows frames on.
+type panelOut interface {
+	// size returns the width and height of the display in pixels, or 0, 0
+	// if it takes frames of any size.
+	size() (w, h int)
+	show(img *image.RGBA) error
+	close() error
+}
+
+// panel shows the plain cell colors of a layer on a physical display, such
+// as an LED matrix on a Raspberry Pi, every frame interval, scaled to fit
+// it.
+type panel struct {
+	l   *layer
+	out panelOut
+
+	mu  sync.Mutex
+	img *image.RGBA
+	err error // of the first failed frame, after which frames are dropped
+}
+
+// startPanel opens the display cfg.Panel names for l: a Linux framebuffer
+// device, such as /dev/fb0, or ft:HOST[:PORT] for a Flaschen Taschen
+// server, such as the ft-server of rpi-rgb-led-matrix driving an LED panel,
+// which takes one pixel per cell.
+func startPanel(cfg *Config, l *layer) (*panel, error) {
+	if cfg.PanelFPS <= 0 {
+		return nil, fmt.Errorf("panel_fps must be positive")
+	}
+	var out panelOut
+	var err error
+	if addr, ok := strings.CutPrefix(cfg.Panel, "ft:"); ok {
+		out, err = openFlaschenTaschen(addr)
+	} else {
+		out, err = openFramebuffer(cfg.Panel)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.Panel, err)
+	}
+	w, h := out.size()
+	if w == 0 {
+		w, h = l.e.Cols(), l.e.Rows()
+	}
+	return &panel{l: l, out: out, img: image.NewRGBA(image.Rect(0, 0, w, h))}, nil
+}
+
+// capture renders the layer as it is now, as large as fits the display in
+// whole or fractional cells and centered on black, and shows it.
+func (p *panel) capture() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return
+	}
+	cells := p.l.e.Snapshot().Cells
+	rows, cols := len(cells), len(cells[0])
+	b := p.img.Bounds()
+	scale := min(float64(b.Dx())/float64(cols), float64(b.Dy())/float64(rows))
+	w, h := int(scale*float64(cols)), int(scale*float64(rows))
+	left, top := (b.Dx()-w)/2, (b.Dy()-h)/2
+	clear(p.img.Pix)
+	for py := range h {
+		row := cells[py*rows/h]
+		k := p.img.PixOffset(left, top+py)
+		for px := range w {
+			r, g, bl := p.l.color(row[px*cols/w]).RGB()
+			p.img.Pix[k], p.img.Pix[k+1], p.img.Pix[k+2], p.img.Pix[k+3] = byte(r), byte(g), byte(bl), 255
+			k += 4
+		}
+	}
+	p.err = p.out.show(p.img)
+}
+
+// every captures a frame every interval until stop is closed.
+func (p *panel) every(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			p.capture()
+		}
+	}
+}
+
+// close blanks the display and closes it.
+func (p *panel) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clear(p.img.Pix)
+	p.out.show(p.img)
+	if err := p.out.close(); err != nil {
+		return err
+	}
+	return p.err
+}
+
+// framebuffer is a Linux framebuffer device, its geometry read from sysfs.
+type framebuffer struct {
+	f           *os.File
+	w, h        int
+	bpp, stride int
+	buf         []byte
+}
+
+// openFramebuffer opens the framebuffer device at path.
+func openFramebuffer(path string) (*framebuffer, error) {
+	sys := filepath.Join("/sys/class/graphics", filepath.Base(path))
+	read := func(name string) ([]int, error) {
+		b, err := os.ReadFile(filepath.Join(sys, name))
+		if err != nil {
+			return nil, err
+		}
+		var v []int
+		for _, f := range strings.Split(strings.TrimSpace(string(b)), ",") {
+			n, err := strconv.Atoi(f)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			v = append(v, n)
+		}
+		return v, nil
+	}
+	size, err := read("virtual_size")
+	if err != nil {
+		return nil, err
+	}
+	bpp, err := read("bits_per_pixel")
+	if err != nil {
+		return nil, err
+	}
+	stride, err := read("stride")
+	if err != nil {
+		return nil, err
+	}
+	if len(size) != 2 || len(bpp) != 1 || len(stride) != 1 {
+		return nil, fmt.Errorf("unexpected framebuffer geometry in %s", sys)
+	}
+	switch bpp[0] {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("%d bits per pixel, want 16, 24 or 32", bpp[0])
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	fb := &framebuffer{f: f, w: size[0], h: size[1], bpp: bpp[0], stride: stride[0]}
+	fb.buf = make([]byte, fb.stride*fb.h)
+	return fb, nil
+}
+
+func (fb *framebuffer) size() (int, int) { return fb.w, fb.h }
+
+// show writes img to the framebuffer as RGB565 at 16 bits per pixel, and
+// as BGR, the usual order, at 24 and 32.
+func (fb *framebuffer) show(img *image.RGBA) error {
+	for y := range fb.h {
+		k, o := img.PixOffset(0, y), y*fb.stride
+		for range fb.w {
+			r, g, b := img.Pix[k], img.Pix[k+1], img.Pix[k+2]
+			switch fb.bpp {
+			case 16:
+				v := uint16(r>>3)<<11 | uint16(g>>2)<<5 | uint16(b>>3)
+				fb.buf[o], fb.buf[o+1] = byte(v), byte(v>>8)
+			case 24:
+				fb.buf[o], fb.buf[o+1], fb.buf[o+2] = b, g, r
+			case 32:
+				fb.buf[o], fb.buf[o+1], fb.buf[o+2], fb.buf[o+3] = b, g, r, 255
+			}
+			k += 4
+			o += fb.bpp / 8
+		}
+	}
+	_, err := fb.f.WriteAt(fb.buf, 0)
+	return err
+}
+
+func (fb *framebuffer) close() error { return fb.f.Close() }
+
+// flaschenTaschen sends frames as PPM images in UDP datagrams to a Flaschen
+// Taschen server, which shows them at its top left corner.
+type flaschenTaschen struct {
+	conn net.Conn
+	buf  []byte
+}
+
+// openFlaschenTaschen connects to the server at addr, HOST or HOST:PORT.
+func openFlaschenTaschen(addr string) (*flaschenTaschen, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, ftPort)
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &flaschenTaschen{conn: conn}, nil
+}
+
+func (ft *flaschenTaschen) size() (int, int) { return 0, 0 }
+
+func (ft *flaschenTaschen) show(img *image.RGBA) error {
+	b := img.Bounds()
+	ft.buf = fmt.Appendf(ft.buf[:0], "P6\n%d %d\n255\n", b.Dx(), b.Dy())
+	for k := 0; k < len(img.Pix); k += 4 {
+		ft.buf = append(ft.buf, img.Pix[k], img.Pix[k+1], img.Pix[k+2])
+	}
+	if len(ft.buf) > 65507 {
+		return fmt.Errorf("a %dx%d frame is too large for a datagram", b.Dx(), b.Dy())
+	}
+	_, err := ft.conn.Write(ft.buf)
+	return err
+}
+
+func (ft *flaschenTaschen) close() error { return ft.conn.Close() }
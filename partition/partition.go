@@ -0,0 +1,210 @@
+// Package partition splits a grid too large for one machine into bands of
+// rows, each run by its own process, which exchange the rows along their
+// edges over TCP so that the cells there see their neighbours in the
+// adjacent bands.
+//
+// Every band is an engine made with engine.Params.Halo. Band i dials band
+// i-1, the one above it, and accepts band i+1 below; under a wrapping
+// boundary the first and last bands are linked too, making a ring. Each
+// exchange sends a band's edge rows to both neighbours and sets its halo
+// from theirs. Run does so before every tick, keeping the bands in lock
+// step so that a synchronous model runs exactly as on a single grid;
+// Exchange called on a timer beside the engine's own goroutines instead
+// keeps the bands asynchronous, each seeing its neighbours' edges as of
+// the last exchange.
+package partition
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"app/engine"
+)
+
+// Band returns the first row and the number of rows of band i of n of a
+// grid of rows rows, the first rows%n bands taking a row more than the
+// others.
+func Band(rows, n, i int) (first, count int) {
+	count = rows / n
+	first = i*count + min(i, rows%n)
+	if i < rows%n {
+		count++
+	}
+	return first, count
+}
+
+// dialTimeout is how long Connect keeps dialing the band above while its
+// process starts.
+const dialTimeout = 30 * time.Second
+
+// hello opens a link, so that a band can check it is talking to the right
+// neighbour.
+type hello struct {
+	Index, Cols int
+}
+
+// rows carries a band's edge rows in an exchange.
+type rows struct {
+	Rows [][]engine.State
+}
+
+// link is a connection to an adjacent band.
+type link struct {
+	conn net.Conn
+	enc  *gob.Encoder
+	dec  *gob.Decoder
+}
+
+func newLink(conn net.Conn) *link {
+	return &link{conn: conn, enc: gob.NewEncoder(conn), dec: gob.NewDecoder(conn)}
+}
+
+// Node is the band of one process.
+type Node struct {
+	e        *engine.Engine
+	boundary engine.Boundary
+	up, down *link // nil at the top and bottom of the grid
+}
+
+// Connect links e, band index of n under boundary, to the adjacent bands:
+// it dials the band above at up and accepts the one below on ln, as far as
+// there are such bands. It returns once both are linked.
+func Connect(e *engine.Engine, boundary engine.Boundary, index, n int, ln net.Listener, up string) (*Node, error) {
+	if n < 2 || index < 0 || index >= n {
+		return nil, fmt.Errorf("band %d of %d: want at least 2 bands", index, n)
+	}
+	wrap := boundary == engine.BoundaryWrap
+	nd := &Node{e: e, boundary: boundary}
+	var dialed, accepted chan error
+	if index > 0 || wrap {
+		if up == "" {
+			return nil, errors.New("the address of the band above is needed")
+		}
+		dialed = make(chan error, 1)
+		go func() { dialed <- nd.dial(up, index) }()
+	}
+	if index < n-1 || wrap {
+		accepted = make(chan error, 1)
+		go func() { accepted <- nd.accept(ln, (index+1)%n) }()
+	}
+	var errs []error
+	for _, ch := range []chan error{dialed, accepted} {
+		if ch != nil {
+			errs = append(errs, <-ch)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		nd.Close()
+		return nil, err
+	}
+	return nd, nil
+}
+
+// dial links the node to the band above at addr, retrying until it
+// listens.
+func (nd *Node) dial(addr string, index int) error {
+	deadline := time.Now().Add(dialTimeout)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("dialing the band above: %w", err)
+			}
+			time.Sleep(200 * time.Millisecond)
+			continue
+		}
+		l := newLink(conn)
+		if err := l.enc.Encode(hello{index, nd.e.Cols()}); err != nil {
+			conn.Close()
+			return err
+		}
+		nd.up = l
+		return nil
+	}
+}
+
+// accept links the node to the band below, which must be band index.
+func (nd *Node) accept(ln net.Listener, index int) error {
+	conn, err := ln.Accept()
+	if err != nil {
+		return err
+	}
+	l := newLink(conn)
+	var h hello
+	if err := l.dec.Decode(&h); err != nil {
+		conn.Close()
+		return fmt.Errorf("greeting the band below: %w", err)
+	}
+	if h.Index != index || h.Cols != nd.e.Cols() {
+		conn.Close()
+		return fmt.Errorf("band %d of %d columns connected, want band %d of %d", h.Index, h.Cols, index, nd.e.Cols())
+	}
+	nd.down = l
+	return nil
+}
+
+// Exchange sends the node's edge rows to the adjacent bands and sets its
+// halo from theirs. At the top and bottom of the grid a reflecting
+// boundary mirrors the band's own rows, and other boundaries leave the
+// halo empty, so that their edge cells are seen.
+func (nd *Node) Exchange() error {
+	top, bottom := nd.e.EdgeRows()
+	above, below := make(chan error, 1), make(chan error, 1)
+	var haloTop, haloBottom [][]engine.State
+	swap := func(l *link, send [][]engine.State, recv *[][]engine.State, done chan<- error) {
+		sent := make(chan error, 1)
+		go func() { sent <- l.enc.Encode(rows{send}) }()
+		var r rows
+		err := l.dec.Decode(&r)
+		*recv = r.Rows
+		done <- errors.Join(err, <-sent)
+	}
+	switch {
+	case nd.up != nil:
+		go swap(nd.up, top, &haloTop, above)
+	case nd.boundary == engine.BoundaryReflect:
+		haloTop = top
+		above <- nil
+	default:
+		above <- nil
+	}
+	switch {
+	case nd.down != nil:
+		go swap(nd.down, bottom, &haloBottom, below)
+	case nd.boundary == engine.BoundaryReflect:
+		haloBottom = bottom
+		below <- nil
+	default:
+		below <- nil
+	}
+	if err := errors.Join(<-above, <-below); err != nil {
+		return fmt.Errorf("exchanging halos: %w", err)
+	}
+	return nd.e.SetHalo(haloTop, haloBottom)
+}
+
+// Run runs ticks ticks of model m in lock step with the other bands,
+// exchanging halos before each.
+func (nd *Node) Run(m engine.Model, ticks int) error {
+	for range ticks {
+		if err := nd.Exchange(); err != nil {
+			return err
+		}
+		nd.e.RunTicks(m, 1)
+	}
+	return nil
+}
+
+// Close closes the links to the adjacent bands.
+func (nd *Node) Close() error {
+	var errs []error
+	for _, l := range []*link{nd.up, nd.down} {
+		if l != nil {
+			errs = append(errs, l.conn.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
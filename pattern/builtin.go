@@ -0,0 +1,38 @@
+package pattern
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// builtins are the classic Life patterns known by name, in RLE.
+var builtins = map[string]string{
+	"block":      "2o$2o!",
+	"blinker":    "3o!",
+	"glider":     "bo$2bo$3o!",
+	"lwss":       "bo2bo$o4b$o3bo$4o!",
+	"rpentomino": "b2o$2o$bo!",
+	"acorn":      "bo5b$3bo3b$2o2b3o!",
+	"diehard":    "6bob$2o6b$bo3b3o!",
+	"gun":        "24bo$22bobo$12b2o6b2o12b2o$11bo3bo4b2o12b2o$2o8bo5bo3b2o$2o8bo3bob2o4bobo$10bo5bo7bo$11bo3bo$12b2o!",
+}
+
+// Builtin returns the classic pattern called name, such as "glider" or
+// "gun" for Gosper's glider gun, with its live cells as species 1.
+func Builtin(name string) (*Pattern, bool) {
+	rle, ok := builtins[name]
+	if !ok {
+		return nil, false
+	}
+	p, err := ReadRLE(strings.NewReader(rle))
+	if err != nil {
+		panic("pattern: builtin " + name + ": " + err.Error())
+	}
+	return p, true
+}
+
+// Builtins returns the names Builtin knows, sorted.
+func Builtins() []string {
+	return slices.Sorted(maps.Keys(builtins))
+}
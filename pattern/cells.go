@@ -0,0 +1,71 @@
+package pattern
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadCells decodes a pattern in plaintext format. A "!Rule:" comment, which
+// some collections add, sets the rule.
+func ReadCells(r io.Reader) (*Pattern, error) {
+	p := &Pattern{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			if rule, ok := strings.CutPrefix(rest, "Rule:"); ok {
+				p.Rule = strings.TrimSpace(rule)
+			}
+			continue
+		}
+		if len(p.Cells) >= MaxCells || len(line) > MaxCells {
+			return nil, errTooLarge
+		}
+		row := make([]int, len(line))
+		for i := range len(line) {
+			switch line[i] {
+			case '.':
+			case 'O', '*':
+				row[i] = 1
+			default:
+				return nil, fmt.Errorf("row %d: unexpected %q", len(p.Cells)+1, line[i])
+			}
+		}
+		p.Cells = append(p.Cells, row)
+		p.Width = max(p.Width, len(row))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	p.Height = len(p.Cells)
+	if p.Width*p.Height > MaxCells {
+		return nil, errTooLarge
+	}
+	p.pad()
+	return p, nil
+}
+
+// WriteCells encodes p in plaintext format. It fails if p holds walls or a
+// species other than 1, which the format cannot express.
+func WriteCells(w io.Writer, p *Pattern) error {
+	if !twoState(p) {
+		return errMultiState
+	}
+	bw := bufio.NewWriter(w)
+	if p.Rule != "" {
+		fmt.Fprintf(bw, "!Rule: %s\n", p.Rule)
+	}
+	for _, row := range p.Cells {
+		line := make([]byte, len(row))
+		for i, v := range row {
+			line[i] = '.'
+			if v == 1 {
+				line[i] = 'O'
+			}
+		}
+		bw.Write(append(line, '\n'))
+	}
+	return bw.Flush()
+}
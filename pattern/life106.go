@@ -0,0 +1,89 @@
+package pattern
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// life106Header is the first line of a Life 1.06 file.
+const life106Header = "#Life 1.06"
+
+// ReadLife106 decodes a pattern in Life 1.06 format. Coordinates may be
+// negative; the pattern is the bounding box of the listed cells.
+func ReadLife106(r io.Reader) (*Pattern, error) {
+	type point struct{ x, y int }
+	var points []point
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want \"x y\", got %q", n, line)
+		}
+		x, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		y, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if len(points) >= MaxCells || abs(x) > MaxCells || abs(y) > MaxCells {
+			return nil, errTooLarge
+		}
+		points = append(points, point{x, y})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	p := &Pattern{}
+	if len(points) == 0 {
+		return p, nil
+	}
+	left, top, right, bottom := points[0].x, points[0].y, points[0].x, points[0].y
+	for _, pt := range points {
+		left, right = min(left, pt.x), max(right, pt.x)
+		top, bottom = min(top, pt.y), max(bottom, pt.y)
+	}
+	p.Width, p.Height = right-left+1, bottom-top+1
+	if p.Width*p.Height > MaxCells {
+		return nil, errTooLarge
+	}
+	p.pad()
+	for _, pt := range points {
+		p.Cells[pt.y-top][pt.x-left] = 1
+	}
+	return p, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// WriteLife106 encodes p in Life 1.06 format, with the top left corner of p
+// at the origin. It fails if p holds walls or a species other than 1, which
+// the format cannot express.
+func WriteLife106(w io.Writer, p *Pattern) error {
+	if !twoState(p) {
+		return errMultiState
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString(life106Header + "\n")
+	for y, row := range p.Cells {
+		for x, v := range row {
+			if v == 1 {
+				fmt.Fprintf(bw, "%d %d\n", x, y)
+			}
+		}
+	}
+	return bw.Flush()
+}
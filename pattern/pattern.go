@@ -0,0 +1,190 @@
+// Package pattern reads and writes cell patterns, for placing on a grid and
+// saving what grew on one.
+//
+// Three formats are supported, chosen by Load and Save from the file's
+// extension. Run-length encoded (RLE, .rle) files as written by Golly and
+// most Life software:
+//
+//	#C a glider
+//	x = 3, y = 3, rule = B3/S23
+//	bo$2bo$3o!
+//
+// where b and . are dead cells, o is species 1, and A to X are species 1 to
+// 24. Walls, which Golly has no notion of, are written as z. Plaintext
+// (.cells) files draw the pattern row by row:
+//
+//	!Name: Glider
+//	.O
+//	..O
+//	OOO
+//
+// with . dead and O or * alive. Life 1.06 (.lif, .life) files list the
+// coordinates of the live cells, one "x y" pair per line, after a #Life 1.06
+// header. Both only know one species, read as species 1, and no walls.
+package pattern
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Wall is the value of a wall in Pattern.Cells.
+const Wall = -1
+
+// MaxCells bounds the area of a pattern, well beyond any grid the engine can
+// run, so that a corrupt header or run count fails to read instead of
+// exhausting memory.
+const MaxCells = 1 << 20
+
+var errTooLarge = fmt.Errorf("pattern larger than %d cells", MaxCells)
+
+var errMultiState = errors.New("format holds a single species and no walls")
+
+// Pattern is a rectangular block of cells.
+type Pattern struct {
+	Width, Height int
+	Rule          string // from the file's header, if it has one
+	// Cells is indexed by row, then column. Each entry is 0 for dead, a
+	// species id, or Wall.
+	Cells [][]int
+}
+
+// Format is a pattern file format.
+type Format struct {
+	Name       string
+	Extensions []string // the first one is used by Save's callers to name files
+	Read       func(io.Reader) (*Pattern, error)
+	Write      func(io.Writer, *Pattern) error
+}
+
+// Formats are the supported formats.
+var Formats = []Format{
+	{"rle", []string{".rle"}, ReadRLE, WriteRLE},
+	{"cells", []string{".cells"}, ReadCells, WriteCells},
+	{"life106", []string{".lif", ".life"}, ReadLife106, WriteLife106},
+}
+
+// FormatNamed returns the format called name.
+func FormatNamed(name string) (Format, error) {
+	for _, f := range Formats {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return Format{}, fmt.Errorf("unknown pattern format %q", name)
+}
+
+// FormatOf returns the format of the file at path, by its extension.
+func FormatOf(path string) (Format, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	var exts []string
+	for _, f := range Formats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return f, nil
+			}
+			exts = append(exts, e)
+		}
+	}
+	return Format{}, fmt.Errorf("%s: unsupported pattern format %q (want one of %s)", path, ext, strings.Join(exts, ", "))
+}
+
+// Read decodes a pattern in any of the formats, recognizing it by its
+// content: a #Life 1.06 header, rows of only ., O and * with ! comments for
+// plaintext, and RLE otherwise. It is meant for text without a file name,
+// such as the clipboard's.
+func Read(r io.Reader) (*Pattern, error) {
+	data, err := io.ReadAll(io.LimitReader(r, 4*MaxCells))
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimSpace(string(data))
+	format, _ := FormatNamed("rle")
+	switch {
+	case strings.HasPrefix(text, life106Header):
+		format, _ = FormatNamed("life106")
+	case isCells(text):
+		format, _ = FormatNamed("cells")
+	}
+	return format.Read(strings.NewReader(text))
+}
+
+// isCells reports whether text looks like a plaintext pattern.
+func isCells(text string) bool {
+	for line := range strings.Lines(text) {
+		if strings.HasPrefix(line, "!") {
+			continue
+		}
+		if strings.Trim(line, ".O* \t\r\n") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Load reads the pattern file at path, choosing the format from its
+// extension.
+func Load(path string) (*Pattern, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := format.Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Save writes p to the file at path, choosing the format from its
+// extension.
+func Save(path string, p *Pattern) error {
+	format, err := FormatOf(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := format.Write(f, p); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// twoState reports whether p has no cells but dead ones and species 1.
+func twoState(p *Pattern) bool {
+	for _, row := range p.Cells {
+		for _, v := range row {
+			if v != 0 && v != 1 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pad makes every row of p.Cells p.Width long, adding rows up to p.Height,
+// with a single allocation for the cells.
+func (p *Pattern) pad() {
+	for len(p.Cells) < p.Height {
+		p.Cells = append(p.Cells, nil)
+	}
+	cells := make([]int, p.Width*p.Height)
+	for i := range p.Cells {
+		row := cells[i*p.Width : (i+1)*p.Width : (i+1)*p.Width]
+		copy(row, p.Cells[i])
+		p.Cells[i] = row
+	}
+}
@@ -0,0 +1,124 @@
+package pattern
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// seed adds the files matching globs to the corpus of f.
+func seed(f *testing.F, globs ...string) {
+	for _, glob := range globs {
+		paths, err := filepath.Glob(glob)
+		if err != nil {
+			f.Fatal(err)
+		}
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(string(data))
+		}
+	}
+}
+
+// roundTrip checks that format reads data, if it accepts it at all, as a
+// pattern as large as it claims, and writes it back as the same pattern.
+func roundTrip(t *testing.T, format Format, data string) {
+	p, err := format.Read(strings.NewReader(data))
+	if err != nil {
+		return
+	}
+	if len(p.Cells) != p.Height {
+		t.Fatalf("%d rows, want height %d", len(p.Cells), p.Height)
+	}
+	for i, row := range p.Cells {
+		if len(row) != p.Width {
+			t.Fatalf("row %d has %d cells, want width %d", i, len(row), p.Width)
+		}
+	}
+	var buf bytes.Buffer
+	if err := format.Write(&buf, p); err != nil {
+		t.Fatalf("writing %+v: %v", p, err)
+	}
+	again, err := format.Read(&buf)
+	if err != nil {
+		t.Fatalf("reading back %+v: %v", p, err)
+	}
+	if !reflect.DeepEqual(again, p) {
+		t.Fatalf("read back %+v as %+v", p, again)
+	}
+}
+
+// FuzzReadRLE checks that no input makes ReadRLE panic, that whatever it
+// accepts is as large as it claims and that WriteRLE writes it back as the
+// same pattern. The corpus is seeded with the patterns
+// in the repository.
+func FuzzReadRLE(f *testing.F) {
+	seed(f, "testdata/*.rle", "../engine/testdata/*.rle", "../examples/*.rle")
+	f.Add("x = 0, y = 0\n!")
+	f.Add("3o$$$2b3A!")
+	format, _ := FormatNamed("rle")
+	f.Fuzz(func(t *testing.T, data string) {
+		roundTrip(t, format, data)
+	})
+}
+
+// FuzzReadCells does the same for ReadCells and WriteCells.
+func FuzzReadCells(f *testing.F) {
+	seed(f, "testdata/*.cells")
+	f.Add("!comment\n\n*.*\n")
+	format, _ := FormatNamed("cells")
+	f.Fuzz(func(t *testing.T, data string) {
+		roundTrip(t, format, data)
+	})
+}
+
+// FuzzReadLife106 does the same for ReadLife106 and WriteLife106.
+func FuzzReadLife106(f *testing.F) {
+	seed(f, "testdata/*.lif")
+	f.Add("#Life 1.06\n-3 -2\n5 7\n")
+	format, _ := FormatNamed("life106")
+	f.Fuzz(func(t *testing.T, data string) {
+		roundTrip(t, format, data)
+	})
+}
+
+// TestFormats checks that the same pattern reads the same in every format.
+func TestFormats(t *testing.T) {
+	var want *Pattern
+	for _, path := range []string{"testdata/lwss.rle", "testdata/lwss.cells", "testdata/lwss.lif"} {
+		p, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Rule = ""
+		if want == nil {
+			want = p
+		} else if !reflect.DeepEqual(p, want) {
+			t.Errorf("%s: read %+v, want %+v", path, p, want)
+		}
+	}
+}
+
+// TestBuiltins checks that every builtin pattern reads, and that the
+// spaceship matches the one in testdata.
+func TestBuiltins(t *testing.T) {
+	for _, name := range Builtins() {
+		if p, _ := Builtin(name); p.Width == 0 || p.Height == 0 {
+			t.Errorf("%s is empty", name)
+		}
+	}
+	want, err := Load("testdata/lwss.cells")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Rule = ""
+	if p, _ := Builtin("lwss"); !reflect.DeepEqual(p, want) {
+		t.Errorf("lwss reads as %+v, want %+v", p, want)
+	}
+}
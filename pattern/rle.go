@@ -0,0 +1,201 @@
+package pattern
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadRLE decodes a pattern in RLE format.
+func ReadRLE(r io.Reader) (*Pattern, error) {
+	p := &Pattern{}
+	var body strings.Builder
+	sc := bufio.NewScanner(r)
+	header := false
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case !header && strings.HasPrefix(line, "x"):
+			header = true
+			if err := p.parseHeader(line); err != nil {
+				return nil, err
+			}
+		default:
+			body.WriteString(line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	row, col, run, width := 0, 0, 0, 0
+	set := func(v int) {
+		for len(p.Cells) <= row {
+			p.Cells = append(p.Cells, nil)
+		}
+		for len(p.Cells[row]) <= col {
+			p.Cells[row] = append(p.Cells[row], 0)
+		}
+		p.Cells[row][col] = v
+	}
+body:
+	for _, ch := range body.String() {
+		n := max(run, 1)
+		switch {
+		case ch >= '0' && ch <= '9':
+			run = run*10 + int(ch-'0')
+			if run > MaxCells {
+				return nil, fmt.Errorf("RLE run count above %d", MaxCells)
+			}
+			continue
+		case ch == '!':
+			break body
+		case ch == '$':
+			row, col = row+n, 0
+			if row > MaxCells {
+				return nil, errTooLarge
+			}
+		case ch == 'b' || ch == '.':
+			col += n
+			if col > MaxCells {
+				return nil, errTooLarge
+			}
+		case ch == 'o', ch >= 'A' && ch <= 'X', ch == 'z':
+			v := Wall
+			switch {
+			case ch == 'o':
+				v = 1
+			case ch != 'z':
+				v = int(ch-'A') + 1
+			}
+			width = max(width, col+n)
+			if (row+1)*width > MaxCells {
+				return nil, errTooLarge
+			}
+			for range n {
+				set(v)
+				col++
+			}
+		case ch == ' ' || ch == '\t':
+			continue
+		default:
+			return nil, fmt.Errorf("unexpected %q in RLE data", ch)
+		}
+		run = 0
+	}
+
+	// The header's size is a minimum; live cells beyond it extend it.
+	p.Height = max(p.Height, len(p.Cells))
+	for i := range p.Cells {
+		p.Width = max(p.Width, len(p.Cells[i]))
+	}
+	if p.Width*p.Height > MaxCells {
+		return nil, errTooLarge
+	}
+	p.pad()
+	return p, nil
+}
+
+// parseHeader reads the "x = m, y = n, rule = ..." line. The rule runs to
+// the end of the line, since rulestrings may hold commas.
+func (p *Pattern) parseHeader(line string) error {
+	if i := strings.Index(line, "rule"); i >= 0 {
+		_, rule, ok := strings.Cut(line[i:], "=")
+		if !ok {
+			return fmt.Errorf("bad RLE header %q", line)
+		}
+		p.Rule = strings.TrimSpace(rule)
+		line = strings.TrimRight(strings.TrimSpace(line[:i]), ",")
+	}
+	for _, field := range strings.Split(line, ",") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return fmt.Errorf("bad RLE header %q", line)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "x", "y":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > MaxCells {
+				return fmt.Errorf("bad RLE header %q", line)
+			}
+			if key == "x" {
+				p.Width = n
+			} else {
+				p.Height = n
+			}
+		}
+	}
+	return nil
+}
+
+// WriteRLE encodes p in RLE format, with p.Rule in the header if it is set.
+// A pattern of dead and species 1 cells only is written with b and o, as
+// two-state software expects; others use b, A to X and z. Lines are wrapped
+// at 70 characters.
+func WriteRLE(w io.Writer, p *Pattern) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "x = %d, y = %d", p.Width, p.Height)
+	if p.Rule != "" {
+		fmt.Fprintf(bw, ", rule = %s", p.Rule)
+	}
+	bw.WriteString("\n")
+
+	twoState := twoState(p)
+	symbol := func(v int) (byte, error) {
+		switch {
+		case v == 0:
+			return 'b', nil
+		case v == Wall:
+			return 'z', nil
+		case twoState:
+			return 'o', nil
+		case v >= 1 && v <= 24:
+			return byte('A' + v - 1), nil
+		}
+		return 0, fmt.Errorf("cell value %d has no RLE symbol", v)
+	}
+
+	var line []byte
+	emit := func(n int, ch byte) {
+		item := []byte{ch}
+		if n > 1 {
+			item = append(strconv.AppendInt(nil, int64(n), 10), ch)
+		}
+		if len(line)+len(item) > 70 {
+			bw.Write(append(line, '\n'))
+			line = line[:0]
+		}
+		line = append(line, item...)
+	}
+	rows := 0 // end of rows owed before the next live cell
+	for _, row := range p.Cells {
+		end := len(row)
+		for end > 0 && row[end-1] == 0 {
+			end--
+		}
+		if end > 0 && rows > 0 {
+			emit(rows, '$')
+			rows = 0
+		}
+		for j := 0; j < end; {
+			k := j
+			for k < end && row[k] == row[j] {
+				k++
+			}
+			ch, err := symbol(row[j])
+			if err != nil {
+				return err
+			}
+			emit(k-j, ch)
+			j = k
+		}
+		rows++
+	}
+	emit(1, '!')
+	bw.Write(append(line, '\n'))
+	return bw.Flush()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"app/engine"
+	"app/pattern"
+)
+
+// place copies p onto the middle of e. With wallsOnly, only the pattern's
+// walls are copied and the rest of the grid is left as it is.
+func place(e *engine.Engine, p *pattern.Pattern, wallsOnly bool) error {
+	if p.Height > e.Rows() || p.Width > e.Cols() {
+		return fmt.Errorf("pattern is %dx%d, larger than the %dx%d grid", p.Height, p.Width, e.Rows(), e.Cols())
+	}
+	x0, y0 := (e.Rows()-p.Height)/2, (e.Cols()-p.Width)/2
+	for i, row := range p.Cells {
+		for j, v := range row {
+			switch {
+			case v == pattern.Wall:
+				e.SetCell(x0+i, y0+j, engine.State{Wall: true})
+			case wallsOnly:
+			case v >= len(e.Species()):
+				return fmt.Errorf("pattern uses species %d, but there are only %d", v, len(e.Species())-1)
+			default:
+				e.SetCell(x0+i, y0+j, engine.State{Species: v})
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"app/engine"
+)
+
+// Perturbations change many cells of a layer at once, for testing how the
+// ecosystem recovers from a blow: wiping out a species, turning a fraction
+// of one species into another and injecting a burst of random cells of a
+// species into a region. The commands, the JSON-RPC methods and the keys
+// of edit mode run them; the keys as edits that can be undone.
+
+// perturbFraction is the fraction of cells the keys of edit mode convert
+// and inject.
+const perturbFraction = 0.25
+
+// wipeOut kills every cell of species id on l and returns the changes.
+func wipeOut(l *layer, id int) []change {
+	var changes []change
+	for x := range l.e.Rows() {
+		for y := range l.e.Cols() {
+			if s := l.e.Cell(x, y); s.Alive() && !s.Wall && s.Species == id {
+				changes = append(changes, set(l, x, y, s, engine.State{}))
+			}
+		}
+	}
+	return changes
+}
+
+// convertSpecies turns each cell of species from on l into species to with
+// probability frac and returns the changes.
+func convertSpecies(l *layer, from, to int, frac float64) []change {
+	r := l.e.Rand()
+	var changes []change
+	for x := range l.e.Rows() {
+		for y := range l.e.Cols() {
+			if s := l.e.Cell(x, y); !s.Wall && s.Species == from && r.Float64() < frac {
+				changes = append(changes, set(l, x, y, s, engine.State{Species: to}))
+			}
+		}
+	}
+	return changes
+}
+
+// inject makes each cell other than walls of the rows by cols cells of l
+// from row, col on species id with probability density, cut short at the
+// edges, and returns the changes.
+func inject(l *layer, id, row, col, rows, cols int, density float64) []change {
+	r := l.e.Rand()
+	var changes []change
+	for x := max(row, 0); x < min(row+rows, l.e.Rows()); x++ {
+		for y := max(col, 0); y < min(col+cols, l.e.Cols()); y++ {
+			if s := l.e.Cell(x, y); !s.Wall && s.Species != id && r.Float64() < density {
+				changes = append(changes, set(l, x, y, s, engine.State{Species: id}))
+			}
+		}
+	}
+	return changes
+}
+
+// set makes the cell at x, y of l, in state old, new and returns the
+// change.
+func set(l *layer, x, y int, old, new engine.State) change {
+	l.e.SetCell(x, y, new)
+	return change{l, x, y, old, new}
+}
+
+// parseFraction parses a fraction from 0 to 1, or a percentage such as
+// 30%.
+func parseFraction(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil {
+		return 0, err
+	}
+	if strings.HasSuffix(s, "%") {
+		v /= 100
+	}
+	if v < 0 || v > 1 {
+		return 0, fmt.Errorf("fraction %s is not between 0 and 1", s)
+	}
+	return v, nil
+}
+
+// perturb runs a perturbation of edit mode on the layer of d and records
+// it as an edit: wiping out the brush species, converting a quarter of the
+// species chosen to tune into the brush species, or injecting it into a
+// quarter of the cells of the selection. It flashes what it did.
+func (ed *editor) perturb(d *display, action, tuned string) {
+	if !ed.on.Load() {
+		return
+	}
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	if ed.stroking {
+		return
+	}
+	l := d.layer()
+	brush := ed.brushState(l)
+	if brush.Wall || !brush.Alive() {
+		d.flash("choose a species as the brush first [b]")
+		return
+	}
+	name := l.e.Species()[brush.Species].Name
+	var changes []change
+	var what string
+	switch action {
+	case actWipe:
+		changes, what = wipeOut(l, brush.Species), "wiped out %d cells "+name
+	case actConvert:
+		from, err := speciesID(l.e, tuned)
+		if tuned == "" || err != nil || from == brush.Species {
+			d.flash("choose the species to convert with T")
+			return
+		}
+		changes = convertSpecies(l, from, brush.Species, perturbFraction)
+		what = "converted %d cells " + tuned + " to " + name
+	case actInject:
+		if ed.sel == nil {
+			d.flash("select the region to inject into first [m]")
+			return
+		}
+		l = ed.sel.d.layer()
+		top, left, bottom, right := ed.sel.bounds()
+		changes = inject(l, brush.Species, top, left, bottom-top+1, right-left+1, perturbFraction)
+		what = "injected %d cells " + name
+	}
+	if len(changes) > 0 {
+		ed.push(edit{changes, fmt.Sprintf(what, len(changes))})
+	}
+	d.flash(fmt.Sprintf(what, len(changes)))
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"app/engine"
+)
+
+// Regions an initPreset can assign a cell to, besides a species id.
+const (
+	regionEmpty = -1 // stays dead
+	regionMixed = 0  // seeded at random like the random preset
+)
+
+// An initPreset lays out the starting grid: layout returns, for the given
+// parameters, a function assigning each cell to a region. A cell in a
+// species' region is alive as that species with the species' density.
+type initPreset struct {
+	params map[string]float64 // names and defaults, sizes given in cells
+	layout func(e *engine.Engine, p map[string]float64) func(x, y int) int
+}
+
+var initPresets = map[string]initPreset{
+	"random": {
+		layout: func(*engine.Engine, map[string]float64) func(x, y int) int {
+			return func(x, y int) int { return regionMixed }
+		},
+	},
+	"cluster": {
+		params: map[string]float64{"radius": 0}, // 0 means a quarter of the grid
+		layout: func(e *engine.Engine, p map[string]float64) func(x, y int) int {
+			r := p["radius"]
+			if r <= 0 {
+				r = float64(min(e.Rows(), e.Cols())) / 4
+			}
+			return func(x, y int) int {
+				if centreDistance(e, x, y) <= r {
+					return regionMixed
+				}
+				return regionEmpty
+			}
+		},
+	},
+	"ring": {
+		params: map[string]float64{"radius": 0, "width": 3}, // radius 0 means a third of the grid
+		layout: func(e *engine.Engine, p map[string]float64) func(x, y int) int {
+			r := p["radius"]
+			if r <= 0 {
+				r = float64(min(e.Rows(), e.Cols())) / 3
+			}
+			return func(x, y int) int {
+				if math.Abs(centreDistance(e, x, y)-r) <= p["width"]/2 {
+					return regionMixed
+				}
+				return regionEmpty
+			}
+		},
+	},
+	"halves": {
+		layout: func(e *engine.Engine, p map[string]float64) func(x, y int) int {
+			n := len(e.Species()) - 1
+			return func(x, y int) int { return 1 + y*n/e.Cols() }
+		},
+	},
+	"stripes": {
+		params: map[string]float64{"width": 4, "vertical": 0},
+		layout: func(e *engine.Engine, p map[string]float64) func(x, y int) int {
+			n, w := len(e.Species())-1, max(int(p["width"]), 1)
+			return func(x, y int) int {
+				if p["vertical"] != 0 {
+					x = y
+				}
+				return 1 + x/w%n
+			}
+		},
+	},
+	"checkerboard": {
+		params: map[string]float64{"size": 4},
+		layout: func(e *engine.Engine, p map[string]float64) func(x, y int) int {
+			n, s := len(e.Species())-1, max(int(p["size"]), 1)
+			return func(x, y int) int { return 1 + (x/s+y/s)%n }
+		},
+	},
+}
+
+func presetNames() []string {
+	var names []string
+	for name := range initPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// centreDistance is the distance of (x, y) from the middle of the grid.
+func centreDistance(e *engine.Engine, x, y int) float64 {
+	return math.Hypot(float64(x)-float64(e.Rows()-1)/2, float64(y)-float64(e.Cols()-1)/2)
+}
+
+// parseInit splits an -init value of the form name[:param=value,...] and
+// fills in the preset's defaults.
+func parseInit(s string) (initPreset, map[string]float64, error) {
+	name, args, _ := strings.Cut(s, ":")
+	preset, ok := initPresets[name]
+	if !ok {
+		return preset, nil, fmt.Errorf("unknown init preset %q (want one of %v)", name, presetNames())
+	}
+	p := make(map[string]float64)
+	for k, v := range preset.params {
+		p[k] = v
+	}
+	if args == "" {
+		return preset, p, nil
+	}
+	for _, arg := range strings.Split(args, ",") {
+		k, v, _ := strings.Cut(arg, "=")
+		if _, ok := preset.params[k]; !ok {
+			return preset, nil, fmt.Errorf("init preset %q has no parameter %q", name, k)
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return preset, nil, fmt.Errorf("init preset %q: %s: %w", name, k, err)
+		}
+		p[k] = f
+	}
+	return preset, p, nil
+}
+
+// seedPreset seeds e with the configured -init preset.
+func (cfg *Config) seedPreset(e *engine.Engine) error {
+	preset, p, err := parseInit(cfg.Init)
+	if err != nil {
+		return err
+	}
+	densities, err := cfg.densities()
+	if err != nil {
+		return err
+	}
+	scs, err := cfg.speciesConfigs()
+	if err != nil {
+		return err
+	}
+	// A cell in a species' region uses that species' own density, or the
+	// overall one.
+	own := make([]float64, len(scs)+1)
+	for i, sc := range scs {
+		own[i+1] = cfg.Density
+		if d, ok := cfg.Densities[sc.Name]; ok {
+			own[i+1] = d
+		}
+	}
+
+	region := preset.layout(e, p)
+	for i := 0; i < e.Rows(); i++ {
+		for j := 0; j < e.Cols(); j++ {
+			var s engine.State
+			switch r := region(i, j); r {
+			case regionEmpty:
+			case regionMixed:
+				v := e.Rand().Float64()
+				for k, d := range densities {
+					if v < d {
+						s.Species = k + 1
+						break
+					}
+					v -= d
+				}
+			default:
+				if e.Rand().Float64() < own[r] {
+					s.Species = r
+				}
+			}
+			if !e.Cell(i, j).Wall {
+				e.SetCell(i, j, s)
+			}
+		}
+	}
+	return nil
+}
+
+// Symmetries accepted by -symmetry.
+var symmetries = []string{"none", "horizontal", "vertical", "4-fold"}
+
+// symmetrize mirrors the seeded grid of e: horizontal copies the top half
+// onto the bottom, vertical the left half onto the right, and 4-fold does
+// both, so every quadrant mirrors the top-left one.
+func symmetrize(e *engine.Engine, symmetry string) error {
+	var horizontal, vertical bool
+	switch symmetry {
+	case "none":
+	case "horizontal":
+		horizontal = true
+	case "vertical":
+		vertical = true
+	case "4-fold":
+		horizontal, vertical = true, true
+	default:
+		return fmt.Errorf("unknown symmetry %q (want one of %v)", symmetry, symmetries)
+	}
+	rows, cols := e.Rows(), e.Cols()
+	if vertical {
+		for i := 0; i < rows; i++ {
+			for j := 0; j < cols/2; j++ {
+				e.SetCell(i, cols-1-j, e.Cell(i, j))
+			}
+		}
+	}
+	if horizontal {
+		for i := 0; i < rows/2; i++ {
+			for j := 0; j < cols; j++ {
+				e.SetCell(rows-1-i, j, e.Cell(i, j))
+			}
+		}
+	}
+	return nil
+}
+
+// asymmetry counts the cells of e whose species differs from one of their
+// mirror images under symmetry.
+func asymmetry(e *engine.Engine, symmetry string) int {
+	rows, cols := e.Rows(), e.Cols()
+	n := 0
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			s := e.Cell(i, j).Species
+			if (symmetry == "vertical" || symmetry == "4-fold") && e.Cell(i, cols-1-j).Species != s ||
+				(symmetry == "horizontal" || symmetry == "4-fold") && e.Cell(rows-1-i, j).Species != s {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"app/engine"
+)
+
+// ProbeConfig is a rectangle of the grid whose population and births of
+// each species are tracked on their own, to measure an invasion front or
+// the throughput of a gate. Rows and Cols of 0 reach the bottom and right
+// edges, and Species, if set, names the species tracked instead of all the
+// live ones.
+type ProbeConfig struct {
+	Name    string   `toml:"name" yaml:"name"`
+	Top     int      `toml:"top" yaml:"top"`
+	Left    int      `toml:"left" yaml:"left"`
+	Rows    int      `toml:"rows" yaml:"rows"`
+	Cols    int      `toml:"cols" yaml:"cols"`
+	Species []string `toml:"species" yaml:"species"`
+}
+
+// probe tracks the cells of one rectangle of an engine.
+type probe struct {
+	name    string
+	r       region
+	species []int          // the ids tracked
+	births  []atomic.Int64 // by index in species, since the start
+}
+
+// probes returns the probes of cfg.Probes on e, counting births from now.
+func (cfg *Config) probes(e *engine.Engine) ([]*probe, error) {
+	var probes []*probe
+	for i, pc := range cfg.Probes {
+		p := &probe{name: pc.Name, r: region{pc.Top, pc.Left, pc.Top + pc.Rows - 1, pc.Left + pc.Cols - 1}}
+		if p.name == "" {
+			p.name = fmt.Sprintf("probe%d", i+1)
+		}
+		for _, q := range probes {
+			if q.name == p.name {
+				return nil, fmt.Errorf("probe %s: named twice", p.name)
+			}
+		}
+		if pc.Rows == 0 {
+			p.r.bottom = e.Rows() - 1
+		}
+		if pc.Cols == 0 {
+			p.r.right = e.Cols() - 1
+		}
+		if p.r.top < 0 || p.r.left < 0 || p.r.bottom >= e.Rows() || p.r.right >= e.Cols() || p.r.top > p.r.bottom || p.r.left > p.r.right {
+			return nil, fmt.Errorf("probe %s: not a rectangle within the %dx%d grid", p.name, e.Rows(), e.Cols())
+		}
+		for _, name := range pc.Species {
+			id, err := speciesID(e, name)
+			if err != nil || id == engine.Dead {
+				return nil, fmt.Errorf("probe %s: unknown species %q", p.name, name)
+			}
+			p.species = append(p.species, id)
+		}
+		if len(pc.Species) == 0 {
+			for id := 1; id < len(e.Species()); id++ {
+				p.species = append(p.species, id)
+			}
+		}
+		p.births = make([]atomic.Int64, len(p.species))
+		index := make([]int, len(e.Species())) // by id, 1 + the index in species, 0 if untracked
+		for k, id := range p.species {
+			index[id] = k + 1
+		}
+		r := p.r
+		e.OnCellChanged(func(x, y int, old, new engine.State) {
+			if x < r.top || x > r.bottom || y < r.left || y > r.right || new.Wall || new.Species == old.Species || new.Species >= len(index) {
+				return
+			}
+			if k := index[new.Species]; k > 0 {
+				p.births[k-1].Add(1)
+			}
+		})
+		probes = append(probes, p)
+	}
+	return probes, nil
+}
+
+// population returns the live cells of each species tracked within p on e
+// now, by index in p.species.
+func (p *probe) population(e *engine.Engine) []int {
+	count := make([]int, len(e.Species()))
+	for x := p.r.top; x <= p.r.bottom; x++ {
+		for y := p.r.left; y <= p.r.right; y++ {
+			if s := e.Cell(x, y); s.Alive() && !s.Wall && s.Species < len(count) {
+				count[s.Species]++
+			}
+		}
+	}
+	pop := make([]int, len(p.species))
+	for k, id := range p.species {
+		pop[k] = count[id]
+	}
+	return pop
+}
+
+// probeSummary describes the probes of l for the status line: the live
+// cells of each species tracked within each and how many were born there.
+func probeSummary(l *layer) string {
+	species := l.e.Species()
+	var parts []string
+	for _, p := range l.probes {
+		var counts []string
+		for k, n := range p.population(l.e) {
+			counts = append(counts, fmt.Sprintf("%s %d (+%d)", species[p.species[k]].Name, n, p.births[k].Load()))
+		}
+		parts = append(parts, p.name+": "+strings.Join(counts, ", "))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "probes " + strings.Join(parts, "  ")
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// lateShare is the share of the updates of a second that must come late
+// for the display to warn of it.
+const lateShare = 0.01
+
+// measureRates refreshes d.rates every second with the frames drawn and
+// the cell updates applied by all layers over the past second, so that a
+// slow terminal can be told apart from a slow simulation, and d.late with
+// a warning if too many of the updates came late.
+func (d *display) measureRates() {
+	total := func() int64 {
+		var n int64
+		for _, l := range d.layers {
+			n += l.e.Updates()
+		}
+		return n
+	}
+	lateness := func() (late, measured int64) {
+		for _, l := range d.layers {
+			n, m, _ := l.e.Late()
+			late, measured = late+n, measured+m
+		}
+		return late, measured
+	}
+	frames, updates, last := d.frames.Load(), total(), time.Now()
+	late, measured := lateness()
+	d.every(time.Second, func(now time.Time) {
+		n, m := lateness()
+		warning := ""
+		if share := float64(n-late) / float64(max(m-measured, 1)); share > lateShare {
+			warning = fmt.Sprintf("⚠ %.0f%% of updates late: the machine cannot keep up", 100*share)
+		}
+		d.late.Store(&warning)
+		late, measured = n, m
+		f, u := d.frames.Load(), total()
+		secs := now.Sub(last).Seconds()
+		fps := fmt.Sprintf("%.0f fps", float64(f-frames)/secs)
+		if d.throttled.Load() {
+			fps += " (slow terminal)"
+		}
+		s := fmt.Sprintf(" %s  %s updates/s ", fps, siCount(float64(u-updates)/secs))
+		d.rates.Store(&s)
+		frames, updates, last = f, u, now
+	})
+}
+
+// idleFrame is the longest the display goes without a frame, for what
+// changes on screen with neither a tick nor a wake-up, such as a
+// notification timing out or a command run while paused.
+const idleFrame = time.Second
+
+// pacer spaces out frames so that drawing them, and getting them through
+// the terminal, takes at most half the time: over a slow link or in a slow
+// terminal frames are skipped rather than queued up, while the simulation
+// keeps its own pace.
+type pacer struct {
+	interval time.Duration // between frames when the terminal keeps up; see -max-fps
+	render   time.Duration // smoothed time to draw a frame
+}
+
+// wait returns how long to wait before the next frame, given that the last
+// one took spent to draw, and whether that is longer than p.interval calls
+// for.
+func (p *pacer) wait(spent time.Duration) (time.Duration, bool) {
+	if p.render == 0 {
+		p.render = spent
+	} else {
+		p.render = (3*p.render + spent) / 4
+	}
+	interval := max(p.interval, 2*p.render)
+	return max(interval-spent, 0), interval > p.interval
+}
+
+// wake tells the renderer that something it shows may have changed other
+// than by a tick, such as an input event or a notification, so that it
+// draws a frame without waiting for the next tick.
+type wake chan struct{}
+
+// signal wakes the renderer, unless a wake-up is already pending.
+func (w wake) signal() {
+	select {
+	case w <- struct{}{}:
+	default:
+	}
+}
+
+// siCount formats n with a k, M or G suffix.
+func siCount(n float64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1fG", n/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1fM", n/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1fk", n/1e3)
+	}
+	return fmt.Sprintf("%.0f", n)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"app/engine"
+	"app/pattern"
+	"app/recording"
+)
+
+// recordedWall is the byte a wall is recorded as; species are their ids.
+const recordedWall = 255
+
+// isRecording reports whether path names a recording.
+func isRecording(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".nncr")
+}
+
+// gridRecording records every tick of a layer into cfg.Record; see
+// package recording.
+type gridRecording struct {
+	e *engine.Engine
+	f *os.File
+	b *bufio.Writer
+
+	mu    sync.Mutex
+	w     *recording.Writer
+	cells []byte
+	err   error // of the first failed write, after which frames are dropped
+}
+
+// startRecording starts recording l into cfg.Record at every tick, with a
+// keyframe every cfg.RecordKeyframes ticks.
+func startRecording(cfg *Config, l *layer) (*gridRecording, error) {
+	f, err := os.Create(cfg.Record)
+	if err != nil {
+		return nil, err
+	}
+	r := &gridRecording{e: l.e, f: f, b: bufio.NewWriter(f), cells: make([]byte, l.e.Rows()*l.e.Cols())}
+	if r.w, err = recording.NewWriter(r.b, l.e.Rows(), l.e.Cols(), cfg.RecordKeyframes, []byte(cfg.dumped())); err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.capture()
+	l.e.OnTick(func(engine.Stats) { r.capture() })
+	return r, nil
+}
+
+// capture records the grid as it is now. It may be called from tick hooks.
+func (r *gridRecording) capture() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil || r.w == nil {
+		return
+	}
+	cols := r.e.Cols()
+	for x, row := range r.e.Snapshot().Cells {
+		for y, s := range row {
+			b := byte(min(s.Species, recordedWall-1))
+			if s.Wall {
+				b = recordedWall
+			}
+			r.cells[x*cols+y] = b
+		}
+	}
+	r.err = r.w.Add(r.e.Ticks(), r.e.Now(), r.cells)
+}
+
+// close ends the recording, writing its index.
+func (r *gridRecording) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.err
+	if err == nil {
+		err = r.w.Close()
+	}
+	r.w = nil
+	return errors.Join(err, r.b.Flush(), r.f.Close())
+}
+
+// readRecording reads the frame of the recording at path at the simulated
+// time at, or the last one before it, as a single layer state.
+func readRecording(path string, at time.Duration) (savedState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return savedState{}, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return savedState{}, err
+	}
+	rd, err := recording.Open(f, fi.Size())
+	if err != nil {
+		return savedState{}, fmt.Errorf("%s: %w", path, err)
+	}
+	frame, err := rd.Seek(at)
+	if err != nil {
+		return savedState{}, fmt.Errorf("%s: %w", path, err)
+	}
+	g := make([][]int, rd.Rows())
+	for x := range g {
+		g[x] = make([]int, rd.Cols())
+		for y := range g[x] {
+			id := int(frame.Cells[x*rd.Cols()+y])
+			if id == recordedWall {
+				id = pattern.Wall
+			}
+			g[x][y] = id
+		}
+	}
+	s := gridState(g)
+	s.Config = string(rd.Meta())
+	return s, nil
+}
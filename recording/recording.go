@@ -0,0 +1,383 @@
+// Package recording writes and reads long recordings of a grid compactly,
+// for runs of hours whose every tick is kept and can be jumped to.
+//
+// A frame is the state of every cell as a byte, row by row, at a tick. Most
+// frames are stored as the cells that changed since the previous one; every
+// so often a keyframe stores them all. A keyframe and the frames up to the
+// next one make a chunk, compressed with gzip on its own, so that seeking
+// decompresses a single chunk. A file is
+//
+//	"NNCR" version rows cols meta
+//	chunk...
+//	index "NNCX"
+//
+// where meta is the length and bytes of whatever the writer describes the
+// recording with, missing before version 2, each chunk is its length, the
+// tick and time of its keyframe and
+// its gzip member, and the index, written by Close, is the number of
+// chunks, the offset, tick and time of each and, last, its own offset as 8
+// bytes. Numbers are uvarints and times nanoseconds. A file cut short, by a
+// crash say, has no index: Open walks the chunks instead and drops the
+// last one if it is incomplete.
+//
+// Inside a chunk a frame is its tick, its time and either K and the cells,
+// or D, the number of cells changed and, for each, the step from the
+// previous one changed (from -1 for the first) and its byte.
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+const (
+	magic      = "NNCR"
+	indexMagic = "NNCX"
+	version    = 2
+	// maxCells is the largest grid Open accepts.
+	maxCells = 1 << 30
+)
+
+// A Frame is the state of every cell of the grid, a byte each by row, at a
+// tick and simulated time.
+type Frame struct {
+	Tick  int
+	At    time.Duration
+	Cells []byte
+}
+
+// chunk is an entry of the index.
+type chunk struct {
+	offset int64 // of its length
+	tick   int
+	at     time.Duration
+}
+
+// A Writer writes a recording.
+type Writer struct {
+	w          io.Writer
+	cells      int
+	keyEvery   int
+	offset     int64
+	index      []chunk
+	buf        bytes.Buffer // frames of the chunk under way
+	frames     int          // in buf
+	prev       []byte
+	err        error
+	compressed bytes.Buffer
+}
+
+// NewWriter writes the header of a recording of a grid of rows by cols
+// cells, described by meta, to w and returns a Writer adding frames to it,
+// with a keyframe every keyEvery frames. Nothing more reaches w until the
+// first chunk is complete.
+func NewWriter(w io.Writer, rows, cols, keyEvery int, meta []byte) (*Writer, error) {
+	if rows <= 0 || cols <= 0 {
+		return nil, fmt.Errorf("grid size %dx%d must be positive", rows, cols)
+	}
+	if keyEvery <= 0 {
+		return nil, fmt.Errorf("keyframe interval %d must be positive", keyEvery)
+	}
+	rw := &Writer{w: w, cells: rows * cols, keyEvery: keyEvery}
+	header := []byte(magic)
+	header = binary.AppendUvarint(header, version)
+	header = binary.AppendUvarint(header, uint64(rows))
+	header = binary.AppendUvarint(header, uint64(cols))
+	header = binary.AppendUvarint(header, uint64(len(meta)))
+	rw.write(append(header, meta...))
+	return rw, rw.err
+}
+
+// write writes p to the underlying writer, unless an earlier write failed.
+func (w *Writer) write(p []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	w.err = err
+}
+
+// Add adds the frame of cells at tick and at, writing the chunk under way
+// out if it is full.
+func (w *Writer) Add(tick int, at time.Duration, cells []byte) error {
+	if len(cells) != w.cells {
+		return fmt.Errorf("frame of %d cells, want %d", len(cells), w.cells)
+	}
+	if w.frames == w.keyEvery {
+		w.flush()
+	}
+	var p []byte
+	p = binary.AppendUvarint(p, uint64(tick))
+	p = binary.AppendUvarint(p, uint64(at))
+	if w.frames == 0 {
+		w.index = append(w.index, chunk{w.offset, tick, at})
+		p = append(append(p, 'K'), cells...)
+	} else {
+		var changes []byte
+		n, last := 0, -1
+		for k, b := range cells {
+			if b != w.prev[k] {
+				changes = binary.AppendUvarint(changes, uint64(k-last))
+				changes = append(changes, b)
+				n, last = n+1, k
+			}
+		}
+		p = binary.AppendUvarint(append(p, 'D'), uint64(n))
+		p = append(p, changes...)
+	}
+	w.buf.Write(p)
+	w.frames++
+	w.prev = append(w.prev[:0], cells...)
+	return w.err
+}
+
+// flush writes out the chunk under way, if any.
+func (w *Writer) flush() {
+	if w.frames == 0 {
+		return
+	}
+	w.compressed.Reset()
+	zw := gzip.NewWriter(&w.compressed)
+	zw.Write(w.buf.Bytes())
+	zw.Close()
+	c := w.index[len(w.index)-1]
+	var p []byte
+	p = binary.AppendUvarint(p, uint64(w.compressed.Len()))
+	p = binary.AppendUvarint(p, uint64(c.tick))
+	p = binary.AppendUvarint(p, uint64(c.at))
+	w.write(p)
+	w.write(w.compressed.Bytes())
+	w.buf.Reset()
+	w.frames = 0
+}
+
+// Close writes out the chunk under way and the index. It does not close
+// the underlying writer.
+func (w *Writer) Close() error {
+	w.flush()
+	start := w.offset
+	p := binary.AppendUvarint(nil, uint64(len(w.index)))
+	for _, c := range w.index {
+		p = binary.AppendUvarint(p, uint64(c.offset))
+		p = binary.AppendUvarint(p, uint64(c.tick))
+		p = binary.AppendUvarint(p, uint64(c.at))
+	}
+	p = binary.BigEndian.AppendUint64(p, uint64(start))
+	w.write(append(p, indexMagic...))
+	return w.err
+}
+
+// A Reader reads the frames of a recording.
+type Reader struct {
+	r          io.ReaderAt
+	rows, cols int
+	meta       []byte
+	chunks     []chunk
+}
+
+// Open reads the header and index of the recording of size bytes in r.
+func Open(r io.ReaderAt, size int64) (*Reader, error) {
+	br := bufio.NewReader(io.NewSectionReader(r, 0, size))
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, head); err != nil || string(head) != magic {
+		return nil, errors.New("not a recording")
+	}
+	var fields [3]uint64
+	for i := range fields {
+		v, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading header: %w", err)
+		}
+		fields[i] = v
+	}
+	if fields[0] == 0 || fields[0] > version {
+		return nil, fmt.Errorf("recording of version %d, want at most %d", fields[0], version)
+	}
+	if rows, cols := fields[1], fields[2]; rows == 0 || cols == 0 || rows > maxCells || cols > maxCells/rows {
+		return nil, fmt.Errorf("bad grid size %dx%d", rows, cols)
+	}
+	rd := &Reader{r: r, rows: int(fields[1]), cols: int(fields[2])}
+	start := int64(len(magic) + uvarintLen(fields[0]) + uvarintLen(fields[1]) + uvarintLen(fields[2]))
+	if fields[0] >= 2 {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("reading header: %w", err)
+		}
+		if n > uint64(size-start) {
+			return nil, errors.New("reading header: metadata beyond the end")
+		}
+		rd.meta = make([]byte, n)
+		if _, err := io.ReadFull(br, rd.meta); err != nil {
+			return nil, fmt.Errorf("reading header: %w", err)
+		}
+		start += int64(uvarintLen(n)) + int64(n)
+	}
+	if rd.readIndex(size) != nil {
+		rd.scan(start, size)
+	}
+	if len(rd.chunks) == 0 {
+		return nil, errors.New("empty recording")
+	}
+	return rd, nil
+}
+
+func uvarintLen(v uint64) int { return len(binary.AppendUvarint(nil, v)) }
+
+// readIndex reads the index at the end of the size bytes of rd.r.
+func (rd *Reader) readIndex(size int64) error {
+	trailer := make([]byte, 8+len(indexMagic))
+	if size < int64(len(trailer)) {
+		return errors.New("no index")
+	}
+	if _, err := rd.r.ReadAt(trailer, size-int64(len(trailer))); err != nil || string(trailer[8:]) != indexMagic {
+		return errors.New("no index")
+	}
+	start := int64(binary.BigEndian.Uint64(trailer))
+	if start < 0 || start > size-int64(len(trailer)) {
+		return errors.New("bad index offset")
+	}
+	br := bufio.NewReader(io.NewSectionReader(rd.r, start, size-int64(len(trailer))-start))
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return err
+	}
+	var chunks []chunk
+	for range n {
+		var v [3]uint64
+		for i := range v {
+			if v[i], err = binary.ReadUvarint(br); err != nil {
+				return err
+			}
+		}
+		chunks = append(chunks, chunk{int64(v[0]), int(v[1]), time.Duration(v[2])})
+	}
+	rd.chunks = chunks
+	return nil
+}
+
+// scan rebuilds the index by walking the chunks from offset to size,
+// stopping at the first incomplete one.
+func (rd *Reader) scan(offset, size int64) {
+	for offset < size {
+		br := bufio.NewReader(io.NewSectionReader(rd.r, offset, min(size-offset, 3*binary.MaxVarintLen64)))
+		var v [3]uint64
+		n := 0
+		for i := range v {
+			var err error
+			if v[i], err = binary.ReadUvarint(br); err != nil {
+				return
+			}
+			n += uvarintLen(v[i])
+		}
+		if v[0] > uint64(size-offset-int64(n)) {
+			return
+		}
+		rd.chunks = append(rd.chunks, chunk{offset, int(v[1]), time.Duration(v[2])})
+		offset += int64(n) + int64(v[0])
+	}
+}
+
+// Rows and Cols return the size of the grid recorded.
+func (rd *Reader) Rows() int { return rd.rows }
+func (rd *Reader) Cols() int { return rd.cols }
+
+// Meta returns what the writer described the recording with, nil for a
+// recording of version 1.
+func (rd *Reader) Meta() []byte { return rd.meta }
+
+// Seek returns the last frame recorded at or before the simulated time at,
+// or the first one if at is before it. Only the chunk holding it is read.
+func (rd *Reader) Seek(at time.Duration) (Frame, error) {
+	i := sort.Search(len(rd.chunks), func(i int) bool { return rd.chunks[i].at > at })
+	frames, err := rd.chunk(max(i-1, 0))
+	if err != nil {
+		return Frame{}, err
+	}
+	j := sort.Search(len(frames), func(j int) bool { return frames[j].At > at })
+	return frames[max(j-1, 0)], nil
+}
+
+// chunk returns the frames of chunk i.
+func (rd *Reader) chunk(i int) ([]Frame, error) {
+	c := rd.chunks[i]
+	br := bufio.NewReader(io.NewSectionReader(rd.r, c.offset, 1<<62))
+	length, err := binary.ReadUvarint(br)
+	if err == nil {
+		_, err = binary.ReadUvarint(br)
+	}
+	if err == nil {
+		_, err = binary.ReadUvarint(br)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("chunk %d: %w", i+1, err)
+	}
+	zr, err := gzip.NewReader(io.LimitReader(br, int64(length)))
+	if err != nil {
+		return nil, fmt.Errorf("chunk %d: %w", i+1, err)
+	}
+	frames, err := rd.decode(bufio.NewReader(zr))
+	if err != nil {
+		return nil, fmt.Errorf("chunk %d: %w", i+1, err)
+	}
+	return frames, nil
+}
+
+// decode reads the frames of a decompressed chunk from br.
+func (rd *Reader) decode(br *bufio.Reader) ([]Frame, error) {
+	var frames []Frame
+	var cells []byte
+	for {
+		tick, err := binary.ReadUvarint(br)
+		if err == io.EOF && len(frames) > 0 {
+			return frames, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		at, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+		kind, err := br.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case kind == 'K':
+			cells = make([]byte, rd.rows*rd.cols)
+			if _, err := io.ReadFull(br, cells); err != nil {
+				return nil, err
+			}
+		case kind == 'D' && cells != nil:
+			cells = append([]byte(nil), cells...)
+			n, err := binary.ReadUvarint(br)
+			if err != nil {
+				return nil, err
+			}
+			k := -1
+			for range n {
+				step, err := binary.ReadUvarint(br)
+				if err != nil {
+					return nil, err
+				}
+				if k += int(step); step == 0 || k >= len(cells) {
+					return nil, errors.New("change out of the grid")
+				}
+				if cells[k], err = br.ReadByte(); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, fmt.Errorf("bad frame kind %q", kind)
+		}
+		frames = append(frames, Frame{int(tick), time.Duration(at), cells})
+	}
+}
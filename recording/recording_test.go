@@ -0,0 +1,72 @@
+package recording
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// TestSeek checks that every frame of a recording is found again by Seek,
+// with its index and without, as when a run crashes before Close.
+func TestSeek(t *testing.T) {
+	const rows, cols, n = 8, 12, 250
+	var buf bytes.Buffer
+	meta := []byte("rows = 8")
+	w, err := NewWriter(&buf, rows, cols, 100, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewPCG(1, 2))
+	cells := make([]byte, rows*cols)
+	var frames []Frame
+	for i := range n {
+		for range r.IntN(10) {
+			cells[r.IntN(len(cells))] = byte(r.IntN(4))
+		}
+		at := time.Duration(i) * 100 * time.Millisecond
+		if err := w.Add(i, at, cells); err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, Frame{i, at, append([]byte(nil), cells...)})
+	}
+	written := buf.Len() // the last chunk is still buffered
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	for _, c := range []struct {
+		name   string
+		data   []byte
+		frames int
+	}{
+		{"indexed", data, n},
+		{"cut short", data[:written+5], 200},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			rd, err := Open(bytes.NewReader(c.data), int64(len(c.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rd.Rows() != rows || rd.Cols() != cols {
+				t.Fatalf("size %dx%d, want %dx%d", rd.Rows(), rd.Cols(), rows, cols)
+			}
+			if !bytes.Equal(rd.Meta(), meta) {
+				t.Fatalf("metadata %q, want %q", rd.Meta(), meta)
+			}
+			for _, want := range frames[:c.frames] {
+				got, err := rd.Seek(want.At + time.Millisecond)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Tick != want.Tick || got.At != want.At || !bytes.Equal(got.Cells, want.Cells) {
+					t.Fatalf("frame at %v: got tick %d at %v", want.At, got.Tick, got.At)
+				}
+			}
+			if last, _ := rd.Seek(time.Hour); last.Tick != c.frames-1 {
+				t.Errorf("seeking past the end found tick %d, want %d", last.Tick, c.frames-1)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runReplay implements the "replay" subcommand: it shows the grid saved in
+// an autosave or state file in the TUI, paused, with the ages and energy of its cells, so
+// that the state a crashed run or a member of an ensemble reached can be
+// looked at and run on from, or the frame of a recording at -at. The grid
+// takes the size of the file, and the other settings those saved with it,
+// unless -config or other flags say otherwise; a file saved without its
+// configuration needs the settings, the species first, of the run that
+// saved it.
+func runReplay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s replay [flags] FILE\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "FILE is an autosave (.gob), a state file (.json), a map file (.nnmap) or a recording (.nncr). The flags are those of the TUI.")
+		fs.PrintDefaults()
+	}
+	at := fs.Duration("at", 0, "simulated time of the frame of a recording to show")
+	runInteractive(fs, args, func(path string) (savedState, error) {
+		if isRecording(path) {
+			return readRecording(path, *at)
+		}
+		return readSaved(path)
+	})
+}
+
+// restoreSaved restores the layers of s, read from path, into layers.
+func restoreSaved(s savedState, path string, layers []*layer) error {
+	if len(s.Layers) != len(layers) {
+		return fmt.Errorf("%s has %d layers, the configuration %d", path, len(s.Layers), len(layers))
+	}
+	for i, l := range layers {
+		n := len(l.e.Species())
+		for x, row := range s.Layers[i].Cells {
+			for y, st := range row {
+				if st.Species >= n {
+					return fmt.Errorf("%s: layer %d: cell %d, %d is of species %d, of %d configured", path, i, x, y, st.Species, n-1)
+				}
+			}
+		}
+		if err := l.e.Restore(s.Layers[i]); err != nil {
+			return fmt.Errorf("%s: layer %d: %w", path, i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image/png"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"app/engine"
+)
+
+// reportPoints is the most samples of the populations a report keeps:
+// once full, every other one is dropped and samples are taken half as
+// often, so that a run of any length charts evenly.
+const reportPoints = 1024
+
+// Size of the population chart of a report, and the widest the final grid
+// is drawn, in pixels.
+const (
+	reportWidth  = 800
+	reportHeight = 300
+)
+
+// report follows a layer through a run to write a self-contained HTML page
+// at its end documenting the experiment: the populations over time, the
+// events, the final grid and the configuration it ran with.
+type report struct {
+	l      *layer
+	config string
+	start  time.Time
+
+	mu      sync.Mutex
+	every   int // ticks between samples
+	samples []reportSample
+}
+
+// reportSample is the population of every species at a tick.
+type reportSample struct {
+	tick       int
+	population []int
+}
+
+// startReport samples the populations of l at every tick from now on.
+func startReport(cfg *Config, l *layer) *report {
+	r := &report{l: l, config: cfg.dumped(), start: time.Now(), every: 1}
+	r.samples = append(r.samples, reportSample{l.e.Ticks(), census([]*layer{l})})
+	l.e.OnTick(func(s engine.Stats) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if s.Tick%r.every != 0 {
+			return
+		}
+		if len(r.samples) == reportPoints {
+			for i := range reportPoints / 2 {
+				r.samples[i] = r.samples[2*i]
+			}
+			r.samples = r.samples[:reportPoints/2]
+			r.every *= 2
+			if s.Tick%r.every != 0 {
+				return
+			}
+		}
+		r.samples = append(r.samples, reportSample{s.Tick, s.Population})
+	})
+	return r
+}
+
+// write writes the report to path.
+func (r *report) write(path string) error {
+	r.mu.Lock()
+	samples, every := append([]reportSample(nil), r.samples...), r.every
+	r.mu.Unlock()
+	e := r.l.e
+	species := e.Species()
+
+	var grid bytes.Buffer
+	if err := png.Encode(&grid, r.l.image(max(1, min(8, reportWidth/e.Cols())))); err != nil {
+		return err
+	}
+	type series struct {
+		Name, Color, Points string
+		Final               int
+	}
+	var chart []series
+	most := 1
+	for _, s := range samples {
+		for _, n := range s.population[1:] {
+			most = max(most, n)
+		}
+	}
+	first, last := samples[0].tick, samples[len(samples)-1].tick
+	for id := 1; id < len(species); id++ {
+		var points []string
+		for _, s := range samples {
+			n := 0
+			if id < len(s.population) {
+				n = s.population[id]
+			}
+			x := 0.0
+			if last > first {
+				x = float64(s.tick-first) / float64(last-first) * reportWidth
+			}
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x, reportHeight-float64(n)/float64(most)*reportHeight))
+		}
+		final := 0
+		if pop := samples[len(samples)-1].population; id < len(pop) {
+			final = pop[id]
+		}
+		rd, g, b := r.l.color(engine.State{Species: id}).RGB()
+		chart = append(chart, series{species[id].Name, fmt.Sprintf("#%02x%02x%02x", rd, g, b), strings.Join(points, " "), final})
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = reportPage.Execute(f, map[string]any{
+		"Date":    r.start.Format(time.DateTime),
+		"Took":    time.Since(r.start).Round(time.Second),
+		"Ticks":   e.Ticks(),
+		"Rows":    e.Rows(),
+		"Cols":    e.Cols(),
+		"Width":   reportWidth,
+		"Height":  reportHeight,
+		"Most":    most,
+		"First":   first,
+		"Last":    last,
+		"Series":  chart,
+		"Events":  e.Events(),
+		"Grid":    template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(grid.Bytes())),
+		"Config":  r.config,
+		"Sampled": every,
+	})
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return f.Close()
+}
+
+var reportPage = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Experiment of {{.Date}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 60em; color: #222; }
+svg { background: #111; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 0.8em; text-align: left; }
+tr:nth-child(even) { background: #f0f0f0; }
+pre { background: #f6f6f6; padding: 1em; overflow: auto; }
+.swatch { display: inline-block; width: 0.8em; height: 0.8em; margin-right: 0.4em; }
+img { image-rendering: pixelated; }
+</style>
+</head>
+<body>
+<h1>Experiment of {{.Date}}</h1>
+<p>{{.Rows}}×{{.Cols}} grid, {{.Ticks}} ticks in {{.Took}}.</p>
+
+<h2>Populations</h2>
+<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}">
+{{range .Series}}<polyline fill="none" stroke="{{.Color}}" stroke-width="1.5" points="{{.Points}}"/>
+{{end}}</svg>
+<p>Ticks {{.First}} to {{.Last}}{{if gt .Sampled 1}}, every {{.Sampled}} ticks{{end}}; the chart tops at {{.Most}} cells.</p>
+<table>
+<tr><th>Species</th><th>Final population</th></tr>
+{{range .Series}}<tr><td><span class="swatch" style="background: {{.Color}}"></span>{{.Name}}</td><td>{{.Final}}</td></tr>
+{{end}}</table>
+
+<h2>Events</h2>
+{{if .Events}}<table>
+<tr><th>Tick</th><th>Time</th><th>Event</th></tr>
+{{range .Events}}<tr><td>{{.Tick}}</td><td>{{.Elapsed.Round 100000000}}</td><td>{{.Text}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+
+<h2>Final state</h2>
+<img src="{{.Grid}}" alt="the grid at the end of the run">
+
+<h2>Configuration</h2>
+<pre>{{.Config}}</pre>
+</body>
+</html>
+`))
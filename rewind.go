@@ -0,0 +1,106 @@
+package main
+
+import (
+	"sync"
+
+	"app/engine"
+)
+
+// rewind keeps a snapshot of a layer's engine at each of its latest ticks,
+// so that while paused the user can step back through recent history.
+type rewind struct {
+	e     *engine.Engine
+	limit int
+
+	mu    sync.Mutex
+	snaps []engine.Snapshot // oldest first
+	back  int               // how many snapshots back from the newest is shown
+}
+
+// trackRewind starts recording the last limit ticks of e.
+func trackRewind(e *engine.Engine, limit int) *rewind {
+	r := &rewind{e: e, limit: limit}
+	e.OnTick(func(engine.Stats) {
+		s := e.Snapshot()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.push(s)
+	})
+	return r
+}
+
+// push records s as the newest snapshot. r.mu must be held.
+func (r *rewind) push(s engine.Snapshot) {
+	r.snaps = append(r.snaps, s)
+	if len(r.snaps) > r.limit {
+		r.snaps = append(r.snaps[:0], r.snaps[len(r.snaps)-r.limit:]...)
+	}
+}
+
+// pause pauses the engine and records its exact state, which becomes the
+// newest snapshot.
+func (r *rewind) pause() {
+	r.e.Pause()
+	s := r.e.Snapshot()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.push(s)
+	r.back = 0
+}
+
+// resume continues from the snapshot shown, forgetting the ones after it.
+func (r *rewind) resume() {
+	r.mu.Lock()
+	r.snaps = r.snaps[:len(r.snaps)-r.back]
+	r.back = 0
+	r.mu.Unlock()
+	r.e.Resume()
+}
+
+// step shows the snapshot delta ticks later, or earlier for a negative
+// delta, staying within the ones recorded.
+func (r *rewind) step(delta int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	back := min(max(r.back-delta, 0), len(r.snaps)-1)
+	if back == r.back || back < 0 {
+		return
+	}
+	r.back = back
+	r.e.Restore(r.snaps[len(r.snaps)-1-back])
+}
+
+// position returns how many ticks back the state shown is and how many
+// can be stepped back through.
+func (r *rewind) position() (back, recorded int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.back, max(len(r.snaps)-1, 0)
+}
+
+// togglePause pauses or resumes every layer.
+func (d *display) togglePause() {
+	paused := d.layers[0].e.Paused()
+	for _, l := range d.layers {
+		switch {
+		case l.rewind == nil && paused:
+			l.e.Resume()
+		case l.rewind == nil:
+			l.e.Pause()
+		case paused:
+			l.rewind.resume()
+		default:
+			l.rewind.pause()
+		}
+	}
+}
+
+// stepHistory steps every layer delta ticks through its recorded history;
+// it does nothing unless paused.
+func (d *display) stepHistory(delta int) {
+	for _, l := range d.layers {
+		if l.rewind != nil && l.e.Paused() {
+			l.rewind.step(delta)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+)
+
+// ruleEditor is the panel listing the birth and survival counts of every
+// species of the first pane's shown layer, or of all its slices for a 3D
+// volume, so they can be toggled while the simulation runs. While it is
+// open the arrow keys move its cursor and Space or Enter toggle the count
+// under it.
+type ruleEditor struct {
+	on     atomic.Bool
+	counts int  // highest neighbour count, 8 or 26 in 3D
+	volume bool // edits apply to every layer of the pane
+
+	mu       sync.Mutex
+	row, col int // row 2*(species-1) is its birth counts, the next one its survival counts
+	err      error
+}
+
+func newRuleEditor(cfg *Config) *ruleEditor {
+	re := &ruleEditor{counts: 8, volume: cfg.Depth > 1}
+	if re.volume {
+		re.counts = engine.MaxNeighbors
+	}
+	return re
+}
+
+// key handles a key event while the panel is open and reports whether it
+// was used.
+func (re *ruleEditor) key(d *display, ev *tcell.EventKey) bool {
+	if !re.on.Load() {
+		return false
+	}
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	rows := 2 * (len(d.layer().e.Species()) - 1)
+	switch {
+	case ev.Key() == tcell.KeyUp:
+		re.row = (re.row + rows - 1) % rows
+	case ev.Key() == tcell.KeyDown:
+		re.row = (re.row + 1) % rows
+	case ev.Key() == tcell.KeyLeft:
+		re.col = (re.col + re.counts) % (re.counts + 1)
+	case ev.Key() == tcell.KeyRight:
+		re.col = (re.col + 1) % (re.counts + 1)
+	case ev.Key() == tcell.KeyEnter, ev.Key() == tcell.KeyRune && ev.Rune() == ' ':
+		re.err = re.toggle(d)
+	default:
+		return false
+	}
+	return true
+}
+
+// toggle flips the count under the cursor.
+func (re *ruleEditor) toggle(d *display) error {
+	layers := []*layer{d.layer()}
+	if re.volume {
+		layers = d.layers
+	}
+	species := re.row/2 + 1
+	rule := layers[0].e.Rule(species)
+	if re.row%2 == 0 {
+		rule.Birth[re.col] = !rule.Birth[re.col]
+	} else {
+		rule.Survive[re.col] = !rule.Survive[re.col]
+	}
+	for _, l := range layers {
+		if err := l.e.SetRule(species, rule); err != nil {
+			return err
+		}
+	}
+	e := layers[0].e
+	e.LogEvent(engine.Edit, species, fmt.Sprintf("%s now plays %v", e.Species()[species].Name, rule))
+	return nil
+}
+
+func (re *ruleEditor) status() string {
+	if !re.on.Load() {
+		return ""
+	}
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if re.err != nil {
+		return fmt.Sprintf("rules: %v [R]", re.err)
+	}
+	return "rules: ←↑↓→ move, space toggles [R]"
+}
+
+// draw draws the panel over the top left corner of the grid of d.
+func (re *ruleEditor) draw(d *display) {
+	if !re.on.Load() {
+		return
+	}
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	d.repaint()
+	l := d.layer()
+	species := l.e.Species()
+	name := 0
+	for _, sp := range species[1:] {
+		name = max(name, len(sp.Name))
+	}
+	plain := tcell.StyleDefault.Reverse(true)
+	for row := range 2 * (len(species) - 1) {
+		id := row/2 + 1
+		rule, label := l.e.Rule(id), "S"
+		counts := rule.Survive
+		if row%2 == 0 {
+			label, counts = "B", rule.Birth
+		}
+		head := fmt.Sprintf(" %-*s %s ", name, species[id].Name, label)
+		if row%2 == 1 {
+			head = fmt.Sprintf(" %-*s %s ", name, "", label)
+		}
+		x := d.left
+		for _, r := range head {
+			d.screen.SetContent(x, row, r, nil, plain)
+			x++
+		}
+		for n := 0; n <= re.counts; n++ {
+			style := plain
+			if counts[n] {
+				style = tcell.StyleDefault.Background(l.speciesColor(id)).Foreground(tcell.ColorBlack)
+			}
+			if row == re.row && n == re.col {
+				style = style.Underline(true).Bold(true)
+			}
+			for _, r := range fmt.Sprintf("%2d ", n) {
+				d.screen.SetContent(x, row, r, nil, style)
+				x++
+			}
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"app/engine"
+)
+
+// runHeadless implements the "run" subcommand: it drives the configured
+// layers without a display, tick by tick, until the grid reaches a steady
+// state, a fixed point or a cycle of at most -cycle ticks, and reports the
+// time to fixation. With -replicas it runs several independent replicas
+// and summarizes them.
+func runHeadless(args []string) {
+	cfg := defaultConfig()
+	if path := configFlag(args); path != "" {
+		if err := cfg.load(path); err != nil {
+			log.Fatalf("loading config: %v", err)
+		}
+	}
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.String("config", "", "load settings from a TOML or YAML file; other flags override it")
+	cfg.bindFlags(fs)
+	ticks := fs.Int("ticks", 10000, "give up after this many ticks")
+	cycle := fs.Int("cycle", 8, "longest period of a cycle counted as a steady state")
+	model := fs.String("model", "timed", fmt.Sprintf("how cells are updated, one of %v", engine.Models))
+	replicas := fs.Int("replicas", 1, "independent runs of the configuration, summarized together")
+	parallel := fs.Int("parallel", 1, "replicas to run at once")
+	hashes := fs.String("hashes", "", "write a hash of the grids, chained to the ticks before, after every tick to this file, with -seed")
+	verify := fs.String("verify", "", "check the hash of every tick against this file written by -hashes, with the same seed and settings")
+	fs.Parse(args)
+	closeLog, err := startLogging(&cfg)
+	if err != nil {
+		log.Fatalf("opening log: %v", err)
+	}
+	defer closeLog()
+
+	m, err := engine.ParseModel(*model)
+	if err != nil {
+		log.Fatalf("parsing model: %v", err)
+	}
+	if *cycle < 1 || *replicas < 1 || *parallel < 1 {
+		log.Fatalf("cycle, replicas and parallel must be at least 1")
+	}
+	if *hashes != "" || *verify != "" {
+		switch {
+		case cfg.Seed == 0:
+			log.Fatalf("-hashes and -verify need -seed")
+		case m != engine.Sequential && m != engine.Timed:
+			log.Fatalf("-hashes and -verify need the sequential or timed model, which are deterministic")
+		case *replicas > 1:
+			log.Fatalf("-hashes and -verify take a single run")
+		}
+	}
+	if *replicas > 1 && cfg.MapFile != "" {
+		log.Fatalf("-map-file takes a single run: replicas cannot share the file")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	rand.Seed(time.Now().UnixNano())
+	if *replicas > 1 {
+		cfg.Stats, cfg.StatsJSONL, cfg.Autosave, cfg.EventLog, cfg.MergeSize, cfg.Video, cfg.Summary, cfg.Montage = "", "", 0, "", 0, "", 0, ""
+		cfg.Record, cfg.Report = "", ""
+		runs := make([]Config, *replicas)
+		for i := range runs {
+			runs[i] = cfg.clone()
+			if cfg.Seed != 0 {
+				runs[i].Seed = cfg.Seed + int64(i)
+			}
+		}
+		results, err := simulateAll(ctx, runs, m, *ticks, *cycle, *parallel)
+		if err != nil {
+			log.Fatal(err)
+		}
+		summarize(os.Stdout, results, *ticks)
+		return
+	}
+	if cfg.EventLog == "" && cfg.StatsJSONL == "" && cfg.Report == "" {
+		cfg.MergeSize = 0 // no one would see the merges
+	}
+	layers, release, err := buildLayers(&cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer release()
+	if cfg.EventLog != "" {
+		if err := writeEventLog(cfg.EventLog, layers); err != nil {
+			log.Fatalf("opening event log: %v", err)
+		}
+	}
+	if cfg.Timeline != "" {
+		if err := startTimeline(cfg.Timeline, layers, func(msg string) { slog.Warn("timeline command failed", "err", msg) }); err != nil {
+			log.Fatalf("loading timeline: %v", err)
+		}
+	}
+	if cfg.Summary > 0 {
+		startSummary(layers, cfg.Summary, func(line string) { fmt.Println(line) })
+	}
+
+	var rec *recorder
+	if cfg.Video != "" {
+		if rec, err = startVideo(&cfg, layers[0]); err != nil {
+			log.Fatalf("starting video: %v", err)
+		}
+		rec.capture()
+		layers[0].e.OnTick(func(engine.Stats) { rec.capture() })
+	}
+	var recd *gridRecording
+	if cfg.Record != "" {
+		if recd, err = startRecording(&cfg, layers[0]); err != nil {
+			log.Fatalf("starting recording: %v", err)
+		}
+	}
+	var mont *montage
+	if cfg.Montage != "" {
+		if mont, err = startMontage(&cfg, layers[0]); err != nil {
+			log.Fatalf("starting montage: %v", err)
+		}
+	}
+	var rep *report
+	if cfg.Report != "" {
+		rep = startReport(&cfg, layers[0])
+	}
+	var closeHashes func() error
+	if *hashes != "" {
+		f, err := os.Create(*hashes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := bufio.NewWriter(f)
+		writeHashes(w, layers)
+		closeHashes = func() error { return errors.Join(w.Flush(), f.Close()) }
+	}
+	var check *hashCheck
+	if *verify != "" {
+		if check, err = verifyHashes(*verify, layers); err != nil {
+			log.Fatalf("reading hashes: %v", err)
+		}
+	}
+
+	start := time.Now()
+	fixation, period := steady(ctx, layers, m, *ticks, *cycle)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if rec != nil {
+		if err := rec.close(); err != nil {
+			slog.Warn("recording video failed", "err", err)
+		}
+	}
+	if recd != nil {
+		if err := recd.close(); err != nil {
+			slog.Warn("recording failed", "err", err)
+		}
+	}
+	if mont != nil {
+		if err := mont.write(cfg.Montage); err != nil {
+			slog.Warn("writing montage failed", "err", err)
+		}
+	}
+	if rep != nil {
+		if err := rep.write(cfg.Report); err != nil {
+			slog.Warn("writing report failed", "err", err)
+		}
+	}
+	if closeHashes != nil {
+		if err := closeHashes(); err != nil {
+			log.Fatalf("writing hashes: %v", err)
+		}
+	}
+	out := os.Stdout
+	if cfg.StatsJSONL == "-" {
+		out = os.Stderr // keep standard output to JSON lines
+	}
+	failed := false
+	if check != nil {
+		msg, ok := check.result()
+		fmt.Fprintln(out, msg)
+		failed = !ok
+	}
+	result := populations(layers)
+	if cfg.Mode == "schelling" {
+		result += fmt.Sprintf(", similarity %.3f", layers[0].e.Similarity())
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintf(out, "interrupted after %d ticks (%s): %s\n", layers[0].e.Ticks(), elapsed, result)
+		os.Exit(1)
+	}
+	if period == 0 {
+		fmt.Fprintf(out, "no steady state after %d ticks (%s): %s\n", *ticks, elapsed, result)
+		os.Exit(1)
+	}
+	fmt.Fprintf(out, "steady state after %d ticks (period %d, %s): %s\n", fixation, period, elapsed, result)
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// settleTicks is how many ticks in a row a grid must repeat before it
+// counts as steady, so that a tick without changes, or with changes that
+// only ages will reveal, is not taken for one.
+const settleTicks = 20
+
+// steady updates layers tick by tick using model m until they reach a
+// steady state, a grid repeating with a period of at most cycle ticks for
+// settleTicks ticks, ticks have passed or ctx is done. It returns the tick
+// from which the state repeats and its period, or a period of 0 if there is
+// no steady state yet.
+func steady(ctx context.Context, layers []*layer, m engine.Model, ticks, cycle int) (fixation, period int) {
+	recent := make([]uint64, cycle) // grid hashes of the last ticks, by tick modulo cycle
+	repeats := make([]int, cycle+1) // by period, ticks in a row the grid has repeated
+	for tick := 1; tick <= ticks && ctx.Err() == nil; tick++ {
+		var h uint64
+		for _, l := range layers {
+			l.e.RunTicks(m, 1)
+			h = h*31 + l.e.Hash()
+		}
+		for period := 1; period <= cycle && period < tick; period++ {
+			if recent[(tick-period)%cycle] != h {
+				repeats[period] = 0
+				continue
+			}
+			if repeats[period]++; repeats[period] >= settleTicks {
+				return tick - repeats[period] - period + 1, period
+			}
+		}
+		recent[tick%cycle] = h
+	}
+	return 0, 0
+}
+
+// census returns the number of cells of every species id across layers.
+func census(layers []*layer) []int {
+	var pop []int
+	for _, l := range layers {
+		for len(pop) < len(l.e.Species()) {
+			pop = append(pop, 0)
+		}
+		for i := 0; i < l.e.Rows(); i++ {
+			for j := 0; j < l.e.Cols(); j++ {
+				s := l.e.Cell(i, j).Species
+				for len(pop) <= s { // a hybrid bred during the count
+					pop = append(pop, 0)
+				}
+				pop[s]++
+			}
+		}
+	}
+	return pop
+}
+
+// populations lists the live cells of every species across layers.
+func populations(layers []*layer) string {
+	pop := census(layers)
+	species := layers[0].e.Species()
+	var parts []string
+	for id, n := range pop[1:min(len(pop), len(species))] {
+		parts = append(parts, fmt.Sprintf("%s %d", species[id+1].Name, n))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// summarize writes the distribution over replica runs of the time to
+// fixation and of the survival time and final population of each species.
+func summarize(w io.Writer, results []sweepResult, ticks int) {
+	n := len(results)
+	var fixations []float64
+	for _, r := range results {
+		if r.period > 0 {
+			fixations = append(fixations, float64(r.fixation))
+		}
+	}
+	mean, variance := meanVariance(fixations)
+	fmt.Fprintf(w, "%d replicas, %d settled within %d ticks", n, len(fixations), ticks)
+	if len(fixations) > 0 {
+		fmt.Fprintf(w, ": fixation after mean %.1f, variance %.1f ticks", mean, variance)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "species\tsurvived\tdied out after (mean)\t(variance)\tfinal population (mean)\t(variance)")
+	for id := 1; id < len(results[0].species); id++ {
+		var deaths, pops []float64
+		for _, r := range results {
+			if r.extinct[id] > 0 {
+				deaths = append(deaths, float64(r.extinct[id]))
+			}
+			pops = append(pops, float64(r.population[id]))
+		}
+		dm, dv := meanVariance(deaths)
+		pm, pv := meanVariance(pops)
+		died := "-\t-"
+		if len(deaths) > 0 {
+			died = fmt.Sprintf("%.1f\t%.1f", dm, dv)
+		}
+		fmt.Fprintf(tw, "%s\t%d/%d\t%s\t%.1f\t%.1f\n", results[0].species[id], n-len(deaths), n, died, pm, pv)
+	}
+	tw.Flush()
+}
+
+// meanVariance returns the mean and unbiased sample variance of xs.
+func meanVariance(xs []float64) (mean, variance float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	if len(xs) < 2 {
+		return mean, 0
+	}
+	for _, x := range xs {
+		variance += (x - mean) * (x - mean)
+	}
+	return mean, variance / float64(len(xs)-1)
+}
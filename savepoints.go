@@ -0,0 +1,183 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+)
+
+// savepoint is a named copy of the state of every layer.
+type savepoint struct {
+	name  string
+	state savedState
+}
+
+// savepoints keeps savepoints of every layer made with the mark key, so
+// that the user can jump back to one and try something else from there,
+// forking the timeline. If dir is set they are also saved there as
+// autosaves named after them, which replay opens, and those found there at
+// startup can be jumped to.
+type savepoints struct {
+	layers []*layer // of every pane
+	dir    string
+	keys   map[string][]string
+	config string // see savedState.Config
+
+	mu     sync.Mutex
+	saved  []savepoint // oldest first
+	action string      // actMark or actJump while a name is typed, else ""
+	name   []rune
+}
+
+// newSavepoints returns the savepoints of layers, with those saved in dir,
+// if set, by a previous run. They are saved with config.
+func newSavepoints(layers []*layer, dir string, keys map[string][]string, config string) *savepoints {
+	sp := &savepoints{layers: layers, dir: dir, keys: keys, config: config}
+	if dir == "" {
+		return sp
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.gob"))
+	for _, path := range paths {
+		s, err := readSaved(path)
+		if err != nil {
+			continue
+		}
+		sp.saved = append(sp.saved, savepoint{strings.TrimSuffix(filepath.Base(path), ".gob"), s})
+	}
+	slices.SortStableFunc(sp.saved, func(a, b savepoint) int { return a.state.Saved.Compare(b.state.Saved) })
+	return sp
+}
+
+// begin starts typing the name of a savepoint to make or jump to.
+func (sp *savepoints) begin(action string) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.action, sp.name = action, nil
+}
+
+// key handles a key event while a name is typed and reports whether it
+// was used: letters, digits, - and _ make up the name, Enter confirms it
+// and Esc cancels. A blank name is the next number for a new savepoint and
+// the latest savepoint to jump to.
+func (sp *savepoints) key(d *display, ev *tcell.EventKey) bool {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if sp.action == "" {
+		return false
+	}
+	switch ev.Key() {
+	case tcell.KeyEnter:
+		var err error
+		if sp.action == actMark {
+			err = sp.mark(d, string(sp.name))
+		} else {
+			err = sp.jump(d, string(sp.name))
+		}
+		if err != nil {
+			d.flash("savepoint: " + err.Error())
+		}
+		sp.action = ""
+	case tcell.KeyEsc:
+		sp.action = ""
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if len(sp.name) > 0 {
+			sp.name = sp.name[:len(sp.name)-1]
+		}
+	case tcell.KeyRune:
+		if r := ev.Rune(); unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			sp.name = append(sp.name, r)
+		}
+	}
+	return true
+}
+
+// mark makes the savepoint name, replacing any of that name. sp.mu must be
+// held.
+func (sp *savepoints) mark(d *display, name string) error {
+	for n := len(sp.saved) + 1; name == ""; n++ {
+		if sp.find(fmt.Sprint(n)) < 0 {
+			name = fmt.Sprint(n)
+		}
+	}
+	s := savedState{Saved: time.Now(), Config: sp.config}
+	for _, l := range sp.layers {
+		paused := l.e.Paused()
+		if !paused {
+			l.e.Pause()
+		}
+		s.Layers = append(s.Layers, l.e.Snapshot())
+		if !paused {
+			l.e.Resume()
+		}
+	}
+	if i := sp.find(name); i >= 0 {
+		sp.saved = slices.Delete(sp.saved, i, i+1)
+	}
+	sp.saved = append(sp.saved, savepoint{name, s})
+	if sp.dir != "" {
+		if err := os.MkdirAll(sp.dir, 0o755); err != nil {
+			return err
+		}
+		if err := saveState(filepath.Join(sp.dir, name+".gob"), s); err != nil {
+			return err
+		}
+	}
+	sp.layers[0].e.LogEvent(engine.Edit, 0, "savepoint "+name)
+	d.flash("savepoint " + name)
+	return nil
+}
+
+// jump restores the savepoint name, or the latest one if name is blank.
+// sp.mu must be held.
+func (sp *savepoints) jump(d *display, name string) error {
+	i := len(sp.saved) - 1
+	if name != "" {
+		i = sp.find(name)
+	}
+	if i < 0 {
+		return fmt.Errorf("no savepoint %q", name)
+	}
+	p := sp.saved[i]
+	if err := restoreSaved(p.state, p.name, sp.layers); err != nil {
+		return err
+	}
+	sp.layers[0].e.LogEvent(engine.Edit, 0, "back to savepoint "+p.name)
+	d.flash("back to savepoint " + p.name)
+	return nil
+}
+
+// find returns the index of the savepoint name, or -1.
+func (sp *savepoints) find(name string) int {
+	return slices.IndexFunc(sp.saved, func(p savepoint) bool { return p.name == name })
+}
+
+// status prompts for the name being typed, or lists the savepoints.
+func (sp *savepoints) status() string {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	var names []string
+	for _, p := range sp.saved {
+		names = append(names, p.name)
+	}
+	switch sp.action {
+	case actMark:
+		return fmt.Sprintf("savepoint name: %s_ (enter, esc)", string(sp.name))
+	case actJump:
+		return fmt.Sprintf("jump to savepoint: %s_ of %s (enter for the latest, esc)", string(sp.name), strings.Join(names, " "))
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	key := func(action string) string { return cmp.Or(strings.Join(sp.keys[action], "/"), "unbound") }
+	return fmt.Sprintf("savepoints %s [%s/%s]", strings.Join(names, " "), key(actMark), key(actJump))
+}
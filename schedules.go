@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"app/engine"
+)
+
+// ScheduleConfig drives a parameter over simulated time, for studying
+// resonance between the driving and the automaton's own timescales.
+type ScheduleConfig struct {
+	// Param is speed, the factor dividing the reaction times of Species,
+	// or of every species and dead cells if it is empty; or decay, the
+	// probability of engine.Params.Decay.
+	Param   string `toml:"param" yaml:"param"`
+	Species string `toml:"species" yaml:"species"`
+	// Shape is ramp, from From to To over Period and then To for good, or
+	// sine, from From to To and back every Period. Either holds From
+	// until Start.
+	Shape  string        `toml:"shape" yaml:"shape"`
+	From   float64       `toml:"from" yaml:"from"`
+	To     float64       `toml:"to" yaml:"to"`
+	Start  time.Duration `toml:"start" yaml:"start"`
+	Period time.Duration `toml:"period" yaml:"period"`
+}
+
+// at returns the value of s at the simulated time t.
+func (s *ScheduleConfig) at(t time.Duration) float64 {
+	t -= s.Start
+	if t <= 0 {
+		return s.From
+	}
+	f := float64(t) / float64(s.Period)
+	if s.Shape == "ramp" {
+		f = min(f, 1)
+	} else {
+		f = (1 - math.Cos(2*math.Pi*f)) / 2
+	}
+	return s.From + (s.To-s.From)*f
+}
+
+// check reports whether s is well formed.
+func (s *ScheduleConfig) check() error {
+	switch {
+	case s.Shape != "ramp" && s.Shape != "sine":
+		return fmt.Errorf("unknown shape %q, want ramp or sine", s.Shape)
+	case s.Period <= 0:
+		return fmt.Errorf("period %v must be positive", s.Period)
+	case s.Start < 0:
+		return fmt.Errorf("start %v must not be negative", s.Start)
+	}
+	switch s.Param {
+	case "speed":
+		if s.From <= 0 || s.To <= 0 {
+			return fmt.Errorf("speeds %v and %v must be positive", s.From, s.To)
+		}
+	case "decay":
+		if s.Species != "" {
+			return fmt.Errorf("decay is not by species")
+		}
+		if s.From < 0 || s.From > 1 || s.To < 0 || s.To > 1 {
+			return fmt.Errorf("decay probabilities %v and %v must be in [0, 1]", s.From, s.To)
+		}
+	default:
+		return fmt.Errorf("unknown param %q, want speed or decay", s.Param)
+	}
+	return nil
+}
+
+// startSchedules has the schedules of cfg.Schedules drive e from now on,
+// once per tick. The speeds of the schedules of a species multiply, and of
+// several decays the last listed wins. The reaction times of the species
+// driven are those e has now divided by the speeds, rounded to the
+// millisecond, so that the tuner's changes to them are undone at the next
+// tick.
+func startSchedules(cfg *Config, e *engine.Engine) error {
+	if len(cfg.Schedules) == 0 {
+		return nil
+	}
+	var base []time.Duration
+	for _, sp := range e.Species() {
+		base = append(base, sp.ReactionTime)
+	}
+	ids := make([]int, len(cfg.Schedules)) // -1 for every species
+	driven := make([]bool, len(base))      // by species, whether a speed drives it
+	for i := range cfg.Schedules {
+		s := &cfg.Schedules[i]
+		if err := s.check(); err != nil {
+			return fmt.Errorf("schedule %d: %w", i+1, err)
+		}
+		ids[i] = -1
+		if s.Species != "" {
+			id, err := speciesID(e, s.Species)
+			if err != nil {
+				return fmt.Errorf("schedule %d: %w", i+1, err)
+			}
+			ids[i] = id
+		}
+		for id := range driven {
+			driven[id] = driven[id] || s.Param == "speed" && (ids[i] < 0 || ids[i] == id)
+		}
+	}
+
+	decay := -1.0
+	apply := func() {
+		now := e.Now()
+		speed := make([]float64, len(base))
+		for id := range speed {
+			speed[id] = 1
+		}
+		d := -1.0
+		for i := range cfg.Schedules {
+			s := &cfg.Schedules[i]
+			v := s.at(now)
+			switch {
+			case s.Param == "decay":
+				d = v
+			case ids[i] < 0:
+				for id := range speed {
+					speed[id] *= v
+				}
+			default:
+				speed[ids[i]] *= v
+			}
+		}
+		for id, sp := range e.Species() {
+			if !driven[id] {
+				continue
+			}
+			rt := time.Duration(float64(base[id]) / speed[id]).Round(time.Millisecond)
+			if rt = max(rt, time.Millisecond); rt != sp.ReactionTime {
+				e.SetReactionTime(id, rt)
+			}
+		}
+		if d >= 0 && d != decay {
+			decay = d
+			e.SetDecay(d)
+		}
+	}
+	apply()
+	e.OnTick(func(engine.Stats) { apply() })
+	return nil
+}
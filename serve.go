@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"app/netplay"
+)
+
+// runServe implements the "serve" subcommand: it runs the configured
+// simulation headless as a territory game that players join with "join";
+// see package netplay.
+func runServe(args []string) {
+	cfg := defaultConfig()
+	if path := configFlag(args); path != "" {
+		if err := cfg.load(path); err != nil {
+			log.Fatalf("loading config: %v", err)
+		}
+	}
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.String("config", "", "load settings from a TOML or YAML file; other flags override it")
+	cfg.bindFlags(fs)
+	addr := fs.String("listen", ":7777", "address to accept players on")
+	web := fs.String("web", "", "address to also serve the game to browsers on, such as :8080")
+	ink := fs.Int("ink", 50, "most cells a player can hold in reserve to paint")
+	refill := fs.Duration("refill", 200*time.Millisecond, "time for a player to gain a cell of ink")
+	interval := fs.Duration("frame-interval", 200*time.Millisecond, "time between frames sent to players")
+	players := fs.Int("max-players", netplay.DefaultMaxPlayers, "most players that may join the game, counting those who left")
+	game := fs.Duration("game", 0, "length of the game, after which the species with the most cells wins (0 for no end)")
+	fs.Parse(args)
+	closeLog, err := startLogging(&cfg)
+	if err != nil {
+		log.Fatalf("opening log: %v", err)
+	}
+	defer closeLog()
+
+	if cfg.Hybrids > 0 {
+		log.Fatal("hybrids are not supported in a game: each player owns a species")
+	}
+	rand.Seed(time.Now().UnixNano())
+	cfg.Autosave, cfg.Video = 0, ""
+	layers, release, err := buildLayers(&cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer release()
+	e := layers[0].e
+	scs, err := cfg.speciesConfigs()
+	if err != nil {
+		log.Fatal(err)
+	}
+	colors := []string{cfg.Dead.Color}
+	for _, sc := range scs {
+		colors = append(colors, sc.Color)
+	}
+	srv, err := netplay.NewServer(e, netplay.Options{
+		Colors: colors, Ink: *ink, Refill: *refill, Interval: *interval, Duration: *game, MaxPlayers: *players,
+		Log: func(format string, args ...any) { slog.Info(fmt.Sprintf(format, args...)) },
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("serving a game", "rows", e.Rows(), "cols", e.Cols(), "mode", cfg.Mode, "addr", ln.Addr())
+	if *web != "" {
+		wl, err := net.Listen("tcp", *web)
+		if err != nil {
+			log.Fatal(err)
+		}
+		slog.Info("serving the game to browsers", "url", "http://"+wl.Addr().String())
+		go func() {
+			if err := http.Serve(wl, srv.Web()); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+	for _, l := range layers {
+		l.start()
+	}
+	winner, err := srv.Serve(ln)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s wins\n", e.Species()[winner].Name)
+}
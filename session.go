@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// session is what the user adjusted in the TUI, remembered in sessionPath
+// from one run to the next.
+type session struct {
+	AgeShading bool `toml:"age_shading"`
+	Trails     bool `toml:"trails"`
+	Heatmap    bool `toml:"heatmap"`
+	Contact    bool `toml:"contact"`
+	Structures bool `toml:"structures"`
+	Sparklines bool `toml:"sparklines"`
+	FPS        bool `toml:"fps"`
+	Events     bool `toml:"events"`
+	Projection bool `toml:"projection"`
+	// TimeScale, Theme and PatternDir are the speed the first layer ran
+	// at, the theme it was drawn in and the directory exports went to.
+	TimeScale  float64 `toml:"time_scale"`
+	Theme      string  `toml:"theme"`
+	PatternDir string  `toml:"pattern_dir"`
+	// ReactionTimes holds the reaction times tuned away from the
+	// configured ones, by species name.
+	ReactionTimes map[string]time.Duration `toml:"reaction_times"`
+}
+
+// sessionPath returns where the session is kept, in the user's
+// configuration directory.
+func sessionPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "nnca", "session.toml"), nil
+}
+
+// loadSession reads the session of the last run, if there is one.
+func loadSession() (s session, ok bool, err error) {
+	path, err := sessionPath()
+	if err != nil {
+		return s, false, err
+	}
+	if _, err := toml.DecodeFile(path, &s); os.IsNotExist(err) {
+		return s, false, nil
+	} else if err != nil {
+		return s, false, err
+	}
+	return s, true, nil
+}
+
+// save writes s for the next run.
+func (s session) save() error {
+	path, err := sessionPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := toml.NewEncoder(f).Encode(s); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// apply restores the display settings of s to cfg, but for those given as
+// flags of fs or set by a config file, that of -config or of a saved
+// state. A theme that no longer loads is left out.
+func (s session) apply(cfg *Config, fs *flag.FlagSet) {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	keep := func(name string) bool {
+		return set[name] || cfg.loaded[strings.ReplaceAll(name, "-", "_")]
+	}
+	for name, field := range map[string]struct {
+		v   *bool
+		was bool
+	}{
+		"age-shading": {&cfg.AgeShading, s.AgeShading},
+		"trails":      {&cfg.Trails, s.Trails},
+		"heatmap":     {&cfg.Heatmap, s.Heatmap},
+		"contact":     {&cfg.Contact, s.Contact},
+		"structures":  {&cfg.Structures, s.Structures},
+		"sparklines":  {&cfg.Sparklines, s.Sparklines},
+		"fps":         {&cfg.FPS, s.FPS},
+		"events":      {&cfg.Events, s.Events},
+		"projection":  {&cfg.Projection, s.Projection},
+	} {
+		if !keep(name) {
+			*field.v = field.was
+		}
+	}
+	if !keep("time-scale") && s.TimeScale > 0 {
+		cfg.TimeScale = s.TimeScale
+	}
+	if !keep("pattern-dir") {
+		cfg.PatternDir = s.PatternDir
+	}
+	if !keep("theme") && s.Theme != "" {
+		configured := cfg.Theme
+		cfg.Theme = s.Theme
+		if _, err := cfg.theme(); err != nil {
+			cfg.Theme = configured
+		}
+	}
+}
+
+// restoreReactionTimes sets the reaction times of s on every layer that
+// has the species.
+func (s session) restoreReactionTimes(layers []*layer) {
+	for name, d := range s.ReactionTimes {
+		for _, l := range layers {
+			if id, err := speciesID(l.e, name); err == nil && id > 0 {
+				l.e.SetReactionTime(id, d)
+			}
+		}
+	}
+}
+
+// reactionTimesTuned returns the reaction times of the species of l that
+// differ from configured, by name.
+func reactionTimesTuned(l *layer, configured map[string]time.Duration) map[string]time.Duration {
+	tuned := map[string]time.Duration{}
+	for _, sp := range l.e.Species()[1:] {
+		if d, ok := configured[sp.Name]; ok && d != sp.ReactionTime {
+			tuned[sp.Name] = sp.ReactionTime
+		}
+	}
+	return tuned
+}
+
+// sessionOf returns the display settings of d.
+func sessionOf(d *display) session {
+	return session{
+		AgeShading: d.ageShading.Load(),
+		Trails:     d.trails.Load(),
+		Heatmap:    d.heatmap.Load(),
+		Contact:    d.contact.Load(),
+		Structures: d.structures.Load(),
+		Sparklines: d.sparklines.Load(),
+		FPS:        d.overlay.Load(),
+		Events:     d.events.Load(),
+		Projection: d.projection.Load(),
+		TimeScale:  d.layers[0].e.TimeScale(),
+		Theme:      d.themeName,
+		PatternDir: d.patternDir,
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"math/rand"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"app/engine"
+	"app/partition"
+)
+
+// runShard implements the "shard" subcommand: it runs one band of rows of
+// a grid split across processes, possibly on several machines, that
+// exchange the rows along their edges; see package partition. The grid
+// flags give the size of the whole grid.
+func runShard(args []string) {
+	cfg := defaultConfig()
+	if path := configFlag(args); path != "" {
+		if err := cfg.load(path); err != nil {
+			log.Fatalf("loading config: %v", err)
+		}
+	}
+	fs := flag.NewFlagSet("shard", flag.ExitOnError)
+	fs.String("config", "", "load settings from a TOML or YAML file; other flags override it")
+	cfg.bindFlags(fs)
+	index := fs.Int("shard", 0, "index of this band, from 0 at the top")
+	n := fs.Int("shards", 2, "number of bands the grid is split into")
+	listen := fs.String("listen", ":7100", "address the band below connects to")
+	up := fs.String("up", "", "address of the band above (of the last band for the first one under a wrapping boundary)")
+	ticks := fs.Int("ticks", 0, "ticks to run in lock step with the other bands; 0 runs asynchronously in real time until interrupted")
+	model := fs.String("model", "sequential", fmt.Sprintf("how cells are updated with -ticks, one of %v", engine.Models))
+	exchange := fs.Duration("exchange", 100*time.Millisecond, "time between halo exchanges without -ticks")
+	out := fs.String("out", "", "write the band as text to this file at the end")
+	fs.Parse(args)
+	closeLog, err := startLogging(&cfg)
+	if err != nil {
+		log.Fatalf("opening log: %v", err)
+	}
+	defer closeLog()
+
+	m, err := engine.ParseModel(*model)
+	if err != nil {
+		log.Fatalf("parsing model: %v", err)
+	}
+	if *n < 2 || *index < 0 || *index >= *n || *n > cfg.Rows {
+		log.Fatalf("shard %d of %d: want 2 to %d bands", *index, *n, cfg.Rows)
+	}
+	if cfg.Depth > 1 || len(cfg.Layers) > 0 || cfg.Pattern != "" || cfg.Image != "" {
+		log.Fatalf("a shard runs a single 2D layer seeded at random")
+	}
+	first, rows := partition.Band(cfg.Rows, *n, *index)
+	cfg.Rows, cfg.halo = rows, true
+	if cfg.Seed != 0 {
+		cfg.Seed += int64(*index)
+	}
+	cfg.Autosave, cfg.Video = 0, ""
+	rand.Seed(time.Now().UnixNano())
+	layers, release, err := buildLayers(&cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer release()
+	l := layers[0]
+	b, _ := engine.ParseBoundary(cfg.Boundary)
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("waiting for the neighbouring bands", "band", *index, "bands", *n, "first_row", first, "last_row", first+rows-1)
+	node, err := partition.Connect(l.e, b, *index, *n, ln, *up)
+	ln.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer node.Close()
+
+	start := time.Now()
+	if *ticks > 0 {
+		if err := node.Run(m, *ticks); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+		if err := node.Exchange(); err != nil {
+			log.Fatal(err)
+		}
+		l.start()
+		t := time.NewTicker(*exchange)
+	run:
+		for {
+			select {
+			case <-ctx.Done():
+				break run
+			case <-t.C:
+				if err := node.Exchange(); err != nil {
+					slog.Error("band failed, stopping", "err", err)
+					break run
+				}
+			}
+		}
+		t.Stop()
+		l.e.Stop()
+	}
+	fmt.Printf("band %d of %d after %d ticks (%s): %s\n", *index, *n, l.e.Ticks(), time.Since(start).Round(time.Millisecond), populations(layers))
+	if *out != "" {
+		if err := os.WriteFile(*out, []byte(l.e.Text()), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// Package sound turns a running automaton into audio, so that it can be
+// listened to as well as watched.
+//
+// A Synth collects births and deaths, or population levels, from the
+// engine's hooks and renders them as 16-bit mono PCM at SampleRate. In
+// Events mode every species has a pitch on a pentatonic scale; the births
+// of a step sound as a short note at that pitch, louder the more cells were
+// born, and deaths as a softer note an octave below, so the bursts of
+// asynchronous updates come out as rhythms. In Population mode every
+// species is a continuous tone whose loudness follows its share of the
+// grid.
+//
+// The package has no audio driver of its own: Play writes the samples to
+// any io.Writer, such as the standard input of a player like aplay, or a
+// WAV file started with WriteWAVHeader.
+package sound
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SampleRate is the number of samples per second written by Play.
+const SampleRate = 44100
+
+// Step is how often the births and deaths counted so far become notes.
+const Step = time.Second / 16
+
+// maxVoices bounds the notes sounding at once; older ones are dropped.
+const maxVoices = 64
+
+// Mode selects what the synth listens to.
+type Mode int
+
+const (
+	// Events plays births and deaths as notes.
+	Events Mode = iota
+	// Population plays each species as a tone as loud as it is numerous.
+	Population
+)
+
+var Modes = []Mode{Events, Population}
+
+func (m Mode) String() string {
+	switch m {
+	case Events:
+		return "events"
+	case Population:
+		return "population"
+	default:
+		return fmt.Sprintf("Mode(%d)", int(m))
+	}
+}
+
+// ParseMode returns the mode with the given name.
+func ParseMode(name string) (Mode, error) {
+	for _, m := range Modes {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown sound mode %q", name)
+}
+
+// Synth renders the events reported to it as audio. Its methods are safe
+// for concurrent use; Birth and Death are cheap enough to call from
+// engine.OnCellChanged.
+type Synth struct {
+	mode   Mode
+	births []atomic.Int64 // indexed by species id, since the last step
+	deaths []atomic.Int64
+
+	mu     sync.Mutex
+	shares []float64 // Population mode, by species id
+	voices []voice
+	drones []float64 // phase of each species' tone
+	gains  []float64 // current loudness of each tone, easing to shares
+}
+
+// voice is a decaying sine note.
+type voice struct {
+	freq, amp, phase float64
+	left             int // samples
+}
+
+// New returns a synth for species 1 to species.
+func New(mode Mode, species int) *Synth {
+	return &Synth{
+		mode:   mode,
+		births: make([]atomic.Int64, species+1),
+		deaths: make([]atomic.Int64, species+1),
+		shares: make([]float64, species+1),
+		drones: make([]float64, species+1),
+		gains:  make([]float64, species+1),
+	}
+}
+
+// Birth reports that a cell of species was born.
+func (s *Synth) Birth(species int) {
+	if species > 0 && species < len(s.births) {
+		s.births[species].Add(1)
+	}
+}
+
+// Death reports that a cell of species died.
+func (s *Synth) Death(species int) {
+	if species > 0 && species < len(s.deaths) {
+		s.deaths[species].Add(1)
+	}
+}
+
+// Populations reports the population of every species, indexed by species
+// id with the dead cells at 0.
+func (s *Synth) Populations(pop []int) {
+	total := 0
+	for _, n := range pop {
+		total += n
+	}
+	if total == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := 1; id < len(pop) && id < len(s.shares); id++ {
+		s.shares[id] = float64(pop[id]) / float64(total)
+	}
+}
+
+// Pitch returns the frequency in Hz of species: the notes of a major
+// pentatonic scale upwards from A3.
+func Pitch(species int) float64 {
+	degrees := [...]int{0, 2, 4, 7, 9}
+	d := max(species-1, 0)
+	semitones := 12*(d/len(degrees)) + degrees[d%len(degrees)]
+	return 220 * math.Pow(2, float64(semitones)/12)
+}
+
+// Play writes audio to w in real time, one Step at a time, until stop is
+// closed or a write fails.
+func (s *Synth) Play(w io.Writer, stop <-chan struct{}) error {
+	buf := make([]byte, 2*SampleRate*Step/time.Second)
+	t := time.NewTicker(Step)
+	defer t.Stop()
+	for {
+		s.render(buf)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// render fills buf with the next step of samples.
+func (s *Synth) render(buf []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mode == Events {
+		for id := 1; id < len(s.births); id++ {
+			s.note(Pitch(id), s.births[id].Swap(0), 0.3, Step*3/2)
+			s.note(Pitch(id)/2, s.deaths[id].Swap(0), 0.15, Step)
+		}
+	}
+	for i := 0; i < len(buf); i += 2 {
+		var v float64
+		for j := range s.voices {
+			vc := &s.voices[j]
+			if vc.left <= 0 {
+				continue
+			}
+			v += vc.amp * math.Sin(vc.phase)
+			vc.phase += 2 * math.Pi * vc.freq / SampleRate
+			vc.amp *= 0.9998
+			vc.left--
+		}
+		if s.mode == Population {
+			for id := 1; id < len(s.drones); id++ {
+				s.gains[id] += (s.shares[id] - s.gains[id]) / 2000
+				v += 0.3 * s.gains[id] * math.Sin(s.drones[id])
+				s.drones[id] = math.Mod(s.drones[id]+2*math.Pi*Pitch(id)/SampleRate, 2*math.Pi)
+			}
+		}
+		v = math.Tanh(v) // soft clipping when many notes coincide
+		binary.LittleEndian.PutUint16(buf[i:], uint16(int16(v*math.MaxInt16)))
+	}
+	live := s.voices[:0]
+	for _, vc := range s.voices {
+		if vc.left > 0 {
+			live = append(live, vc)
+		}
+	}
+	s.voices = live
+}
+
+// note starts a note for n events, louder the more there were, up to amp.
+func (s *Synth) note(freq float64, n int64, amp float64, d time.Duration) {
+	if n == 0 {
+		return
+	}
+	if len(s.voices) == maxVoices {
+		s.voices = s.voices[1:]
+	}
+	s.voices = append(s.voices, voice{
+		freq: freq,
+		amp:  amp * math.Min(1, math.Log1p(float64(n))/math.Log(100)),
+		left: int(d * SampleRate / time.Second),
+	})
+}
+
+// WriteWAVHeader writes the header of a WAV file holding the samples Play
+// writes. size is the number of bytes of samples that follow; a stream of
+// unknown length may be given the largest size, or the header rewritten
+// once the samples are all written.
+func WriteWAVHeader(w io.Writer, size uint32) error {
+	h := struct {
+		Riff          [4]byte
+		RiffSize      uint32
+		Wave, Fmt     [4]byte
+		FmtSize       uint32
+		Format        uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		Riff: [4]byte{'R', 'I', 'F', 'F'}, RiffSize: min(size, math.MaxUint32-36) + 36,
+		Wave: [4]byte{'W', 'A', 'V', 'E'}, Fmt: [4]byte{'f', 'm', 't', ' '},
+		FmtSize: 16, Format: 1, Channels: 1,
+		SampleRate: SampleRate, ByteRate: 2 * SampleRate, BlockAlign: 2, BitsPerSample: 16,
+		Data: [4]byte{'d', 'a', 't', 'a'}, DataSize: size,
+	}
+	return binary.Write(w, binary.LittleEndian, &h)
+}
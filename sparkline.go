@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+)
+
+// trendLength is how many ticks of population history are kept for the
+// sparklines, enough to fill a wide terminal.
+const trendLength = 256
+
+// trend records the population of every species at each tick.
+type trend struct {
+	mu      sync.Mutex
+	samples [][]int // ring of Stats.Population, oldest at next once full
+	next    int
+}
+
+// trackTrend starts recording the populations of e.
+func trackTrend(e *engine.Engine) *trend {
+	t := &trend{}
+	e.OnTick(func(s engine.Stats) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if len(t.samples) < trendLength {
+			t.samples = append(t.samples, s.Population)
+			return
+		}
+		t.samples[t.next] = s.Population
+		t.next = (t.next + 1) % trendLength
+	})
+	return t
+}
+
+// series returns the population of species at each of the last n ticks
+// recorded, oldest first.
+func (t *trend) series(species, n int) []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n = min(n, len(t.samples))
+	s := make([]int, n)
+	for k := range s {
+		if pop := t.samples[(t.next+len(t.samples)-n+k)%len(t.samples)]; species < len(pop) {
+			s[k] = pop[species] // else a hybrid not bred yet
+		}
+	}
+	return s
+}
+
+// latest returns the population of every species at the last tick
+// recorded, or nil before the first.
+func (t *trend) latest() []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.samples) == 0 {
+		return nil
+	}
+	return t.samples[(t.next+len(t.samples)-1)%len(t.samples)]
+}
+
+// sparkGlyphs are the eighths of a block, lowest first.
+var sparkGlyphs = []rune(" ▁▂▃▄▅▆▇█")
+
+// drawSparklines draws one line per live species of l from row y down: its
+// name in its color, its population over the recent ticks scaled to the
+// largest value in view, and its current population. Nothing is drawn
+// when off, but the lines are still cleared.
+func (d *display) drawSparklines(l *layer, y int, on bool) {
+	w := d.right() - d.left
+	const label = 10
+	species := l.e.Species()
+	for id := 1; id < len(species); id++ {
+		row := y + id - 1
+		if !on {
+			d.drawText(0, row, "")
+			continue
+		}
+		series := l.trend.series(id, max(w-2*label, 0))
+		peak := 0
+		for _, n := range series {
+			peak = max(peak, n)
+		}
+		style := tcell.StyleDefault.Foreground(d.speciesColor(l, id))
+		x := d.left
+		for _, r := range fmt.Sprintf("%-*.*s", label, label-1, species[id].Name) {
+			d.screen.SetContent(x, row, r, nil, style)
+			x++
+		}
+		for _, n := range series {
+			g := 0
+			if peak > 0 {
+				g = (n*(len(sparkGlyphs)-1) + peak - 1) / peak
+			}
+			d.screen.SetContent(x, row, sparkGlyphs[g], nil, style)
+			x++
+		}
+		cur := 0
+		if len(series) > 0 {
+			cur = series[len(series)-1]
+		}
+		d.drawText(x-d.left, row, fmt.Sprintf(" %d", cur))
+	}
+}
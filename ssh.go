@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math/rand"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/crypto/ssh"
+
+	"app/pattern"
+)
+
+// runSSH implements the "ssh" subcommand: an SSH server that drops every
+// user who connects straight into the automaton, with no binary to install.
+// Each session gets a simulation of its own, built from the configuration,
+// unless -shared is set, in which case they all watch and edit the same
+// one. Only the keys of -authorized-keys may connect; without it the server
+// asks for no password or key, and so only listens on the loopback
+// interface. Sessions cannot write files on the server.
+func runSSH(args []string) {
+	cfg := defaultConfig()
+	if path := configFlag(args); path != "" {
+		if err := cfg.load(path); err != nil {
+			log.Fatalf("loading config: %v", err)
+		}
+	}
+	fs := flag.NewFlagSet("ssh", flag.ExitOnError)
+	fs.String("config", "", "load settings from a TOML or YAML file; other flags override it")
+	cfg.bindFlags(fs)
+	cfg.bindTUIFlags(fs)
+	addr := fs.String("listen", "localhost:2222", "address to accept SSH connections on, on the loopback interface unless -authorized-keys is given")
+	authorized := fs.String("authorized-keys", "", "file of the public keys allowed to connect, in the form of ~/.ssh/authorized_keys (default anyone, from this machine only)")
+	hostKey := fs.String("host-key", "", "file holding the server's private key in PEM form (default a new key for every run)")
+	shared := fs.Bool("shared", false, "show every session the same simulation instead of one of its own")
+	maxSessions := fs.Int("max-sessions", 8, "most sessions at once")
+	fs.Parse(args)
+	closeLog, err := startLogging(&cfg)
+	if err != nil {
+		log.Fatalf("opening log: %v", err)
+	}
+	defer closeLog()
+
+	keys, err := newKeymap(cfg.Keys)
+	if err != nil {
+		log.Fatalf("configuring keys: %v", err)
+	}
+	if _, err := pattern.FormatNamed(cfg.Export); err != nil {
+		log.Fatalf("configuring export: %v", err)
+	}
+	if _, err := cfg.theme(); err != nil {
+		log.Fatalf("configuring theme: %v", err)
+	}
+	if cfg.StatsJSONL == "-" {
+		log.Fatal("-stats-jsonl - would write over the log; give a file")
+	}
+	signer, err := loadHostKey(*hostKey)
+	if err != nil {
+		log.Fatalf("loading host key: %v", err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	if *authorized != "" {
+		keys, err := loadAuthorizedKeys(*authorized)
+		if err != nil {
+			log.Fatalf("loading authorized keys: %v", err)
+		}
+		config = &ssh.ServerConfig{PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if keys[string(key.Marshal())] {
+				return nil, nil
+			}
+			return nil, errors.New("unknown public key")
+		}}
+	} else if !loopback(*addr) {
+		log.Fatalf("-listen %s accepts anyone from other machines; give -authorized-keys", *addr)
+	}
+	config.AddHostKey(signer)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	rand.Seed(time.Now().UnixNano())
+	cfg.Autosave, cfg.Video, cfg.Montage, cfg.Commands, cfg.Timeline, cfg.Panel = 0, "", "", "", "", ""
+	cfg.Savepoints, cfg.remote = "", true // users may not write files here
+	if !*shared {
+		cfg.Stats, cfg.StatsJSONL = "", "" // every session would write them
+	} else {
+		cfg.Summary = 0 // every session would add a hook to the same layers
+	}
+	pcs, err := cfg.paneConfigs()
+	if err != nil {
+		log.Fatalf("configuring panes: %v", err)
+	}
+	for _, pc := range pcs {
+		if pc.MapFile != "" && !*shared {
+			log.Fatal("-map-file needs -shared: sessions of their own cannot share the file")
+		}
+	}
+	// sim returns the panes of a session and a function to call once it
+	// is over.
+	sim := func() ([][]*layer, func(), error) {
+		paneLayers, release, err := startPanes(&cfg, pcs)
+		if err != nil {
+			return nil, nil, err
+		}
+		return paneLayers, func() {
+			for _, layers := range paneLayers {
+				stopLayers(layers)
+			}
+			release()
+		}, nil
+	}
+	if *shared {
+		paneLayers, release, err := startPanes(&cfg, pcs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			for _, layers := range paneLayers {
+				stopLayers(layers)
+			}
+			release()
+		}()
+		sim = func() ([][]*layer, func(), error) { return paneLayers, func() {}, nil }
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("serving over ssh", "addr", ln.Addr(), "host_key", ssh.FingerprintSHA256(signer.PublicKey()))
+	defer context.AfterFunc(ctx, func() { ln.Close() })()
+	sessions := make(chan struct{}, *maxSessions)
+	var wg sync.WaitGroup // of the sessions under way
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if ctx.Err() != nil {
+			slog.Info("shutting down", "sessions", len(sessions))
+			return
+		} else if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			defer conn.Close()
+			sc, chans, reqs, err := ssh.NewServerConn(conn, config)
+			if err != nil {
+				slog.Warn("ssh handshake failed", "remote", conn.RemoteAddr(), "err", err)
+				return
+			}
+			defer sc.Close()
+			defer context.AfterFunc(ctx, func() { sc.Close() })()
+			go ssh.DiscardRequests(reqs)
+			slog.Info("user connected", "user", sc.User(), "remote", sc.RemoteAddr())
+			defer slog.Info("user left", "user", sc.User())
+			for nc := range chans {
+				if nc.ChannelType() != "session" {
+					nc.Reject(ssh.UnknownChannelType, "only sessions are served")
+					continue
+				}
+				select {
+				case sessions <- struct{}{}:
+				default:
+					nc.Reject(ssh.ResourceShortage, "too many sessions, try again later")
+					continue
+				}
+				ch, requests, err := nc.Accept()
+				if err != nil {
+					<-sessions
+					continue
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-sessions }()
+					serveSession(ctx, ch, requests, &cfg, keys, pcs, sim)
+				}()
+			}
+		}()
+	}
+}
+
+// loadHostKey reads the private key at path, or makes a new one if path is
+// empty.
+func loadHostKey(path string) (ssh.Signer, error) {
+	if path == "" {
+		_, key, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return nil, err
+		}
+		return ssh.NewSignerFromKey(key)
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(pem)
+}
+
+// loadAuthorizedKeys reads the public keys in the authorized_keys file at
+// path, by their wire form.
+func loadAuthorizedKeys(path string) (map[string]bool, error) {
+	rest, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	for len(bytes.TrimSpace(rest)) > 0 {
+		var key ssh.PublicKey
+		if key, _, _, rest, err = ssh.ParseAuthorizedKey(rest); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		keys[string(key.Marshal())] = true
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s holds no keys", path)
+	}
+	return keys, nil
+}
+
+// loopback reports whether addr only accepts connections from this
+// machine.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return host == "localhost" || ip != nil && ip.IsLoopback()
+}
+
+// startPanes builds and starts the layers of every pane of pcs. release
+// releases them all.
+func startPanes(cfg *Config, pcs []Config) (paneLayers [][]*layer, release func(), err error) {
+	var releases []func()
+	release = func() {
+		for _, r := range releases {
+			r()
+		}
+	}
+	for i := range pcs {
+		layers, r, err := buildLayers(&pcs[i])
+		if err != nil {
+			release()
+			return nil, nil, err
+		}
+		releases = append(releases, r)
+		paneLayers = append(paneLayers, layers)
+	}
+	if cfg.AB || cfg.Fork != "" {
+		if err := fork(paneLayers[0], paneLayers[1], cfg.Fork); err != nil {
+			release()
+			return nil, nil, fmt.Errorf("seeding a/b panes: %w", err)
+		}
+	}
+	for _, layers := range paneLayers {
+		for _, l := range layers {
+			l.start()
+		}
+	}
+	return paneLayers, release, nil
+}
+
+// serveSession runs the TUI in an SSH session once the client asks for a
+// shell, on the terminal it asked for beforehand.
+func serveSession(ctx context.Context, ch ssh.Channel, requests <-chan *ssh.Request, cfg *Config, keys keymap, pcs []Config, sim func() ([][]*layer, func(), error)) {
+	defer ch.Close()
+	tty := newSSHTty(ch)
+	shell := make(chan string, 1) // the terminal type, once a shell is asked for
+	go func() {
+		defer close(shell)
+		term := ""
+		for req := range requests {
+			ok := false
+			switch req.Type {
+			case "pty-req":
+				var pty struct {
+					Term                         string
+					Columns, Rows, Width, Height uint32
+					Modes                        string
+				}
+				if ok = ssh.Unmarshal(req.Payload, &pty) == nil; ok {
+					term = pty.Term
+					tty.resize(pty.Columns, pty.Rows)
+				}
+			case "window-change":
+				var size struct{ Columns, Rows, Width, Height uint32 }
+				if ok = ssh.Unmarshal(req.Payload, &size) == nil; ok {
+					tty.resize(size.Columns, size.Rows)
+				}
+			case "shell":
+				ok = true
+			}
+			if req.WantReply {
+				req.Reply(ok, nil)
+			}
+			if req.Type == "shell" {
+				select {
+				case shell <- term:
+				default: // asked twice
+				}
+			}
+		}
+	}()
+	term, ok := <-shell
+	switch {
+	case !ok:
+		return
+	case term == "":
+		fmt.Fprint(ch.Stderr(), "a terminal is needed: connect with ssh -t\r\n")
+		exit(ch, 1)
+		return
+	}
+	if err := showSession(ctx, tty, term, cfg, keys, pcs, sim); err != nil {
+		fmt.Fprintf(ch.Stderr(), "%v\r\n", err)
+		exit(ch, 1)
+		return
+	}
+	exit(ch, 0)
+}
+
+// showSession shows the panes returned by sim on tty until the user quits
+// or ctx is done.
+func showSession(ctx context.Context, tty *sshTty, term string, cfg *Config, keys keymap, pcs []Config, sim func() ([][]*layer, func(), error)) error {
+	ti, err := tcell.LookupTerminfo(term)
+	if err != nil {
+		if ti, err = tcell.LookupTerminfo("xterm-256color"); err != nil {
+			return err
+		}
+	}
+	paneLayers, done, err := sim()
+	if err != nil {
+		return err
+	}
+	defer done()
+	screen, err := tcell.NewTerminfoScreenFromTtyTerminfo(tty, ti)
+	if err != nil {
+		return err
+	}
+	if err := screen.Init(); err != nil {
+		return err
+	}
+	defer screen.Fini()
+	screen.Clear()
+	runTUI(ctx, screen, cfg, keys, pcs, paneLayers)
+	return nil
+}
+
+// exit tells the client the session is over with status.
+func exit(ch ssh.Channel, status uint32) {
+	ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+}
+
+// errDrained ends a read of an sshTty cut short by Drain.
+var errDrained = errors.New("tty drained")
+
+// sshTty is the terminal of an SSH session, for tcell.
+type sshTty struct {
+	ch    ssh.Channel
+	input chan []byte // read from ch, closed when it is
+	rest  []byte      // of the last input, not read yet
+
+	mu       sync.Mutex
+	size     tcell.WindowSize
+	onResize func()
+	drained  chan struct{}
+}
+
+func newSSHTty(ch ssh.Channel) *sshTty {
+	t := &sshTty{ch: ch, input: make(chan []byte), drained: make(chan struct{})}
+	go func() {
+		defer close(t.input)
+		for {
+			buf := make([]byte, 256)
+			n, err := ch.Read(buf)
+			if n > 0 {
+				t.input <- buf[:n]
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return t
+}
+
+// resize records the size of the client's terminal.
+func (t *sshTty) resize(cols, rows uint32) {
+	t.mu.Lock()
+	t.size = tcell.WindowSize{Width: int(cols), Height: int(rows)}
+	f := t.onResize
+	t.mu.Unlock()
+	if f != nil {
+		f()
+	}
+}
+
+func (t *sshTty) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.drained = make(chan struct{})
+	return nil
+}
+
+func (t *sshTty) Stop() error { return nil }
+
+func (t *sshTty) Drain() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	close(t.drained)
+	return nil
+}
+
+func (t *sshTty) NotifyResize(f func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onResize = f
+}
+
+func (t *sshTty) WindowSize() (tcell.WindowSize, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.size, nil
+}
+
+func (t *sshTty) Read(b []byte) (int, error) {
+	if len(t.rest) == 0 {
+		t.mu.Lock()
+		drained := t.drained
+		t.mu.Unlock()
+		select {
+		case in, ok := <-t.input:
+			if !ok {
+				return 0, io.EOF
+			}
+			t.rest = in
+		case <-drained:
+			return 0, errDrained
+		}
+	}
+	n := copy(b, t.rest)
+	t.rest = t.rest[n:]
+	return n, nil
+}
+
+func (t *sshTty) Write(b []byte) (int, error) { return t.ch.Write(b) }
+
+// Close leaves the channel open for the exit status; serveSession closes it.
+func (t *sshTty) Close() error { return nil }
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"app/engine"
+	"app/pattern"
+)
+
+// stateFile is the JSON form of an autosave, a state file (.json), for
+// other programs and for editing by hand:
+//
+//	{"saved": "2026-01-02T15:04:05Z", "layers": [{"cells": [[0, 1], [2, -1]], "ages": [[0, 12], [3, 0]]}]}
+//
+// Cells are the species ids of every cell by row, walls -1. The ages,
+// energy, values and concentrations of the cells, laid out the same way,
+// are left out when all zero. The configuration of the run that saved
+// it, if known, is in config as a TOML config file.
+type stateFile struct {
+	Saved  time.Time    `json:"saved"`
+	Layers []stateLayer `json:"layers"` // bottom first
+	Config string       `json:"config,omitempty"`
+}
+
+type stateLayer struct {
+	Cells    [][]int        `json:"cells"`
+	Ages     [][]int        `json:"ages,omitempty"`
+	Energy   [][]float64    `json:"energy,omitempty"`
+	Values   [][]int        `json:"values,omitempty"`
+	U        [][]float64    `json:"u,omitempty"`
+	V        [][]float64    `json:"v,omitempty"`
+	Turmites []stateTurmite `json:"turmites,omitempty"`
+}
+
+type stateTurmite struct {
+	Row  int    `json:"row"`
+	Col  int    `json:"col"`
+	Dir  int    `json:"dir"`
+	Rule string `json:"rule"`
+}
+
+// isState reports whether path names an autosave, a state file or a map
+// file.
+func isState(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".gob" || ext == ".json" || ext == ".nnmap"
+}
+
+// readSaved reads the autosave or state file at path.
+func readSaved(path string) (savedState, error) {
+	var s savedState
+	if !isState(path) {
+		return s, fmt.Errorf("%s: not an autosave (.gob), a state file (.json) nor a map file (.nnmap)", path)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".nnmap") {
+		return readMap(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return s, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		s, err = readState(f)
+	} else {
+		err = gob.NewDecoder(f).Decode(&s)
+	}
+	if err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(s.Layers) == 0 || len(s.Layers[0].Cells) == 0 || len(s.Layers[0].Cells[0]) == 0 {
+		return s, fmt.Errorf("%s: empty grid", path)
+	}
+	return s, nil
+}
+
+// saveState writes s to path as an autosave, a state file or a map file,
+// by its extension.
+func saveState(path string, s savedState) error {
+	if strings.EqualFold(filepath.Ext(path), ".nnmap") {
+		if len(s.Layers) != 1 {
+			return fmt.Errorf("%s: a map file holds a single layer, not %d", path, len(s.Layers))
+		}
+		return engine.WriteMap(path, s.Layers[0])
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = writeState(f, s)
+	} else {
+		err = gob.NewEncoder(f).Encode(s)
+	}
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// readMap reads the map file (see -map-file) at path as a saved state of
+// one layer, saved when the file was last written.
+func readMap(path string) (savedState, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return savedState{}, err
+	}
+	snap, err := engine.ReadMap(path)
+	if err != nil {
+		return savedState{}, err
+	}
+	if len(snap.Cells) == 0 || len(snap.Cells[0]) == 0 {
+		return savedState{}, fmt.Errorf("%s: empty grid", path)
+	}
+	return savedState{Saved: fi.ModTime(), Layers: []engine.Snapshot{snap}}, nil
+}
+
+// writeState writes s to w as a state file.
+func writeState(w io.Writer, s savedState) error {
+	sf := stateFile{Saved: s.Saved, Config: s.Config}
+	for _, snap := range s.Layers {
+		l := stateLayer{
+			Cells: field(snap.Cells, func(st engine.State) int {
+				if st.Wall {
+					return -1
+				}
+				return st.Species
+			}),
+			Ages:   nonzero(field(snap.Cells, func(st engine.State) int { return st.Age })),
+			Energy: nonzero(field(snap.Cells, func(st engine.State) float64 { return st.Energy })),
+			Values: nonzero(field(snap.Cells, func(st engine.State) int { return st.Value })),
+			U:      nonzero(field(snap.Cells, func(st engine.State) float64 { return st.U })),
+			V:      nonzero(field(snap.Cells, func(st engine.State) float64 { return st.V })),
+		}
+		for _, t := range snap.Turmites {
+			l.Turmites = append(l.Turmites, stateTurmite{t.X, t.Y, t.Dir, t.Rule})
+		}
+		sf.Layers = append(sf.Layers, l)
+	}
+	return json.NewEncoder(w).Encode(sf)
+}
+
+// readState reads a state file from r.
+func readState(r io.Reader) (savedState, error) {
+	var sf stateFile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sf); err != nil {
+		return savedState{}, err
+	}
+	s := savedState{Saved: sf.Saved, Config: sf.Config}
+	for i, l := range sf.Layers {
+		rows := len(l.Cells)
+		if rows == 0 {
+			return s, fmt.Errorf("layer %d: no cells", i)
+		}
+		cols := len(l.Cells[0])
+		snap := engine.Snapshot{Cells: make([][]engine.State, rows)}
+		for x, row := range l.Cells {
+			if len(row) != cols {
+				return s, fmt.Errorf("layer %d: row %d has %d cells, not %d", i, x, len(row), cols)
+			}
+			snap.Cells[x] = make([]engine.State, cols)
+			for y, id := range row {
+				switch {
+				case id == -1:
+					snap.Cells[x][y].Wall = true
+				case id < 0:
+					return s, fmt.Errorf("layer %d: cell %d, %d: species %d", i, x, y, id)
+				default:
+					snap.Cells[x][y].Species = id
+				}
+			}
+		}
+		for _, f := range []struct {
+			name string
+			err  error
+		}{
+			{"ages", unpack(l.Ages, snap.Cells, func(st *engine.State, v int) { st.Age = v })},
+			{"energy", unpack(l.Energy, snap.Cells, func(st *engine.State, v float64) { st.Energy = v })},
+			{"values", unpack(l.Values, snap.Cells, func(st *engine.State, v int) { st.Value = v })},
+			{"u", unpack(l.U, snap.Cells, func(st *engine.State, v float64) { st.U = v })},
+			{"v", unpack(l.V, snap.Cells, func(st *engine.State, v float64) { st.V = v })},
+		} {
+			if f.err != nil {
+				return s, fmt.Errorf("layer %d: %s: %w", i, f.name, f.err)
+			}
+		}
+		for _, t := range l.Turmites {
+			snap.Turmites = append(snap.Turmites, engine.Turmite{X: t.Row, Y: t.Col, Dir: t.Dir, Rule: t.Rule})
+		}
+		s.Layers = append(s.Layers, snap)
+	}
+	return s, nil
+}
+
+// field returns f of every cell of cells, laid out the same way.
+func field[T any](cells [][]engine.State, f func(engine.State) T) [][]T {
+	out := make([][]T, len(cells))
+	for x, row := range cells {
+		out[x] = make([]T, len(row))
+		for y, st := range row {
+			out[x][y] = f(st)
+		}
+	}
+	return out
+}
+
+// nonzero returns m, or nil if all its values are zero.
+func nonzero[T comparable](m [][]T) [][]T {
+	var zero T
+	for _, row := range m {
+		for _, v := range row {
+			if v != zero {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// unpack sets the values of m, if any, in cells with set; m must have
+// their layout.
+func unpack[T any](m [][]T, cells [][]engine.State, set func(*engine.State, T)) error {
+	if m == nil {
+		return nil
+	}
+	if len(m) != len(cells) {
+		return fmt.Errorf("%d rows, not %d", len(m), len(cells))
+	}
+	for x, row := range m {
+		if len(row) != len(cells[x]) {
+			return fmt.Errorf("row %d has %d cells, not %d", x, len(row), len(cells[x]))
+		}
+		for y, v := range row {
+			set(&cells[x][y], v)
+		}
+	}
+	return nil
+}
+
+// gridState returns a single layer state of g, species ids by row with
+// walls as pattern.Wall, padded with dead cells to a rectangle.
+func gridState(g [][]int) savedState {
+	cols := 0
+	for _, row := range g {
+		cols = max(cols, len(row))
+	}
+	snap := engine.Snapshot{Cells: make([][]engine.State, len(g))}
+	for x, row := range g {
+		snap.Cells[x] = make([]engine.State, cols)
+		for y, id := range row {
+			if id == pattern.Wall {
+				snap.Cells[x][y].Wall = true
+			} else {
+				snap.Cells[x][y].Species = id
+			}
+		}
+	}
+	return savedState{Saved: time.Now(), Layers: []engine.Snapshot{snap}}
+}
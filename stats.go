@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"app/engine"
+)
+
+// statsInterval is how often the stats files gain a row, in simulated
+// time, and the status line's complexity and cluster measures are
+// refreshed.
+const statsInterval = time.Second
+
+// writeStats appends a CSV row describing e to the file at path every
+// statsInterval: the time, tick, complexity measures, the population of
+// every species, the clusters of every live species, their size
+// distribution written as semicolon-separated counts of clusters of 1,
+// 2-3, 4-7, ... cells, and the mean, median and 99th percentile of the
+// intervals between the updates of every species in milliseconds, left
+// empty until measured (see engine.Latency), then how many updates came
+// late and how many were measured (see engine.Engine.Late). Each of probes
+// adds the population and the births so far of every species it tracks. A
+// header is written first if the file is empty. Hybrids bred after it
+// starts get no columns.
+func writeStats(path string, e *engine.Engine, probes []*probe) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if fi.Size() == 0 {
+		cols := []string{"time", "tick", "entropy", "compression"}
+		for _, sp := range e.Species() {
+			cols = append(cols, sp.Name)
+		}
+		for _, sp := range e.Species()[1:] {
+			for _, col := range []string{"clusters", "largest", "mean", "sizes"} {
+				cols = append(cols, sp.Name+"_"+col)
+			}
+		}
+		for _, sp := range e.Species() {
+			for _, col := range []string{"interval_mean", "interval_p50", "interval_p99"} {
+				cols = append(cols, sp.Name+"_"+col)
+			}
+		}
+		cols = append(cols, "late", "measured")
+		for _, p := range probes {
+			for _, id := range p.species {
+				name := p.name + "_" + e.Species()[id].Name
+				cols = append(cols, name, name+"_births")
+			}
+		}
+		fmt.Fprintln(w, strings.Join(cols, ","))
+	}
+
+	columns := len(e.Species())
+	var last time.Duration
+	e.OnTick(func(s engine.Stats) {
+		if last != 0 && s.Elapsed-last < statsInterval {
+			return
+		}
+		last = s.Elapsed
+		c := e.Complexity()
+		fmt.Fprintf(w, "%.1f,%d,%.4f,%.4f", s.Elapsed.Seconds(), s.Tick, c.Entropy, c.Compression)
+		for _, n := range s.Population[:columns] {
+			fmt.Fprintf(w, ",%d", n)
+		}
+		for _, cl := range e.Clusters()[1:columns] {
+			sizes := make([]string, len(cl.Sizes))
+			for i, n := range cl.Sizes {
+				sizes[i] = strconv.Itoa(n)
+			}
+			fmt.Fprintf(w, ",%d,%d,%.1f,%s", cl.Count, cl.Largest, cl.Mean, strings.Join(sizes, ";"))
+		}
+		for _, l := range e.Latencies()[:columns] {
+			if l.Count == 0 {
+				fmt.Fprint(w, ",,,")
+				continue
+			}
+			fmt.Fprintf(w, ",%.2f,%.2f,%.2f", ms(l.Mean), ms(l.P50), ms(l.P99))
+		}
+		late, measured, _ := e.Late()
+		fmt.Fprintf(w, ",%d,%d", late, measured)
+		for _, p := range probes {
+			for k, n := range p.population(e) {
+				fmt.Fprintf(w, ",%d,%d", n, p.births[k].Load())
+			}
+		}
+		fmt.Fprintln(w)
+		if err := w.Flush(); err != nil {
+			slog.Warn("writing stats failed", "err", err)
+		}
+	})
+	return nil
+}
+
+// writeStatsJSONL writes a JSON object describing e to the file at path,
+// or standard output if it is "-", every statsInterval, one per line: the
+// time, tick, complexity measures and population of every species, as in
+// writeStats, the intervals between the updates of every species measured
+// so far and how many updates came late, the populations and births
+// within every one of probes, and the events logged since the line before.
+func writeStatsJSONL(path string, e *engine.Engine, probes []*probe) error {
+	out := os.Stdout
+	if path != "-" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return err
+		}
+		out = f
+	}
+	type event struct {
+		Tick    int     `json:"tick"`
+		Time    float64 `json:"time"`
+		Kind    string  `json:"kind"`
+		Species string  `json:"species,omitempty"`
+		Text    string  `json:"text"`
+	}
+	type interval struct { // in milliseconds
+		Count   int     `json:"count"`
+		Nominal float64 `json:"nominal"`
+		Mean    float64 `json:"mean"`
+		P50     float64 `json:"p50"`
+		P90     float64 `json:"p90"`
+		P99     float64 `json:"p99"`
+		Min     float64 `json:"min"`
+		Max     float64 `json:"max"`
+	}
+	type lateLine struct {
+		Count    int64   `json:"count"`
+		Measured int64   `json:"measured"`
+		Worst    float64 `json:"worst"` // in milliseconds
+	}
+	type probeLine struct { // by species name
+		Population map[string]int   `json:"population"`
+		Births     map[string]int64 `json:"births"`
+	}
+	type line struct {
+		Timestamp   time.Time      `json:"timestamp"`
+		Time        float64        `json:"time"` // since the engine was created, in seconds
+		Tick        int            `json:"tick"`
+		Entropy     float64        `json:"entropy"`
+		Compression float64        `json:"compression"`
+		Population  map[string]int `json:"population"` // by species name, dead cells included
+		// Intervals are those between updates, by species name, dead cells
+		// included, for those measured.
+		Intervals map[string]interval  `json:"intervals,omitempty"`
+		Late      *lateLine            `json:"late,omitempty"`   // updates over the tolerance so far
+		Probes    map[string]probeLine `json:"probes,omitempty"` // by probe name
+		Events    []event              `json:"events"`
+	}
+	var mu sync.Mutex
+	events := []event{}
+	e.OnEvent(func(ev engine.Event) {
+		species := ""
+		if sp := e.Species(); ev.Species != engine.Dead && ev.Species < len(sp) {
+			species = sp[ev.Species].Name
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event{ev.Tick, ev.Elapsed.Seconds(), ev.Kind.String(), species, ev.Text})
+	})
+
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	var last time.Duration
+	e.OnTick(func(s engine.Stats) {
+		if last != 0 && s.Elapsed-last < statsInterval {
+			return
+		}
+		last = s.Elapsed
+		c := e.Complexity()
+		l := line{
+			Timestamp:   time.Now(),
+			Time:        s.Elapsed.Seconds(),
+			Tick:        s.Tick,
+			Entropy:     c.Entropy,
+			Compression: c.Compression,
+			Population:  map[string]int{},
+		}
+		species := e.Species()
+		for id, n := range s.Population[:min(len(s.Population), len(species))] {
+			l.Population[species[id].Name] = n
+		}
+		for id, lt := range e.Latencies() {
+			if lt.Count == 0 || id >= len(species) {
+				continue
+			}
+			if l.Intervals == nil {
+				l.Intervals = map[string]interval{}
+			}
+			l.Intervals[species[id].Name] = interval{lt.Count, ms(lt.Nominal), ms(lt.Mean), ms(lt.P50), ms(lt.P90), ms(lt.P99), ms(lt.Min), ms(lt.Max)}
+		}
+		if late, measured, worst := e.Late(); measured > 0 {
+			l.Late = &lateLine{late, measured, ms(worst)}
+		}
+		for _, p := range probes {
+			pl := probeLine{map[string]int{}, map[string]int64{}}
+			for k, n := range p.population(e) {
+				name := species[p.species[k]].Name
+				pl.Population[name], pl.Births[name] = n, p.births[k].Load()
+			}
+			if l.Probes == nil {
+				l.Probes = map[string]probeLine{}
+			}
+			l.Probes[p.name] = pl
+		}
+		mu.Lock()
+		l.Events, events = events, []event{}
+		mu.Unlock()
+		enc.Encode(l)
+		if err := w.Flush(); err != nil {
+			slog.Warn("writing stats failed", "err", err)
+		}
+	})
+	return nil
+}
+
+// ms returns d in milliseconds.
+func ms(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+// teamTotals describes the live cells of every team of l's species for the
+// status line, as of the latest tick.
+func teamTotals(l *layer) string {
+	pop := l.trend.latest()
+	var names [][]string
+	var totals []int
+	for id, sp := range l.e.Species() {
+		if sp.Team == 0 || id >= len(pop) {
+			continue
+		}
+		for len(totals) < sp.Team {
+			names, totals = append(names, nil), append(totals, 0)
+		}
+		names[sp.Team-1] = append(names[sp.Team-1], sp.Name)
+		totals[sp.Team-1] += pop[id]
+	}
+	var parts []string
+	for i, n := range totals {
+		if names[i] != nil {
+			parts = append(parts, fmt.Sprintf("%s %d", strings.Join(names[i], "+"), n))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "teams " + strings.Join(parts, "  ")
+}
+
+// clusterSummary describes the clusters of every live species of e for the
+// status line, as their count and the size of the largest.
+func clusterSummary(e *engine.Engine) string {
+	var parts []string
+	for s, c := range e.Clusters() {
+		if s != engine.Dead && c.Count > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d (max %d)", e.Species()[s].Name, c.Count, c.Largest))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "clusters " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,49 @@
+package main
+
+import "app/engine"
+
+// maxPeriod is the longest oscillator period looked for. Every cell
+// remembers its last historyLength updates, enough to see three cycles.
+const (
+	maxPeriod     = 8
+	historyLength = 3 * maxPeriod
+)
+
+// A structure is what a cell seems to be part of.
+type structure int
+
+const (
+	unsettled structure = iota
+	stillLife
+	oscillator
+)
+
+// classify tells whether a cell is part of a still life, having stayed
+// alive as the same species for stillAfter updates, or of an oscillator,
+// its last historyLength species repeating with a period of 2 to maxPeriod
+// updates. hist is the cell's history from engine.History.
+func classify(cell engine.State, hist []int, stillAfter int) structure {
+	if cell.Alive() && cell.Age >= stillAfter {
+		return stillLife
+	}
+	if len(hist) < historyLength {
+		return unsettled
+	}
+	constant := true
+	for _, s := range hist {
+		constant = constant && s == hist[0]
+	}
+	if constant {
+		return unsettled
+	}
+period:
+	for p := 2; p <= maxPeriod; p++ {
+		for i := p; i < len(hist); i++ {
+			if hist[i] != hist[i-p] {
+				continue period
+			}
+		}
+		return oscillator
+	}
+	return unsettled
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"app/engine"
+)
+
+// summary writes a line about the populations of layers every so often,
+// for screen readers and other programs:
+//
+//	t=40s: green 412 ↑, red 198 ↓, blue extinct at t=34s
+//
+// The arrows compare with the line before.
+type summary struct {
+	layers []*layer
+	every  time.Duration
+	emit   func(line string)
+
+	mu      sync.Mutex
+	pops    [][]int // the latest of each layer, by species id
+	next    time.Duration
+	last    []int                 // the populations of the last line
+	seen    []bool                // species that have been alive
+	extinct map[int]time.Duration // when species died out, by id
+}
+
+// startSummary calls emit with a summary of layers after the first tick
+// of the top layer of every period of simulated time every.
+func startSummary(layers []*layer, every time.Duration, emit func(line string)) {
+	sm := &summary{
+		layers:  layers,
+		every:   every,
+		emit:    emit,
+		pops:    make([][]int, len(layers)),
+		next:    every,
+		extinct: map[int]time.Duration{},
+	}
+	for i, l := range layers {
+		l.e.OnTick(func(s engine.Stats) {
+			sm.mu.Lock()
+			defer sm.mu.Unlock()
+			sm.pops[i] = s.Population
+			if i == len(layers)-1 { // layers tick bottom first
+				sm.tick(layers[0].e.Now())
+			}
+		})
+	}
+}
+
+// tick records extinctions and writes a line if it is time to. sm.mu is
+// held.
+func (sm *summary) tick(now time.Duration) {
+	var pop []int
+	for _, p := range sm.pops {
+		for len(pop) < len(p) {
+			pop = append(pop, 0)
+		}
+		for id, n := range p {
+			pop[id] += n
+		}
+	}
+	for len(sm.seen) < len(pop) {
+		sm.seen = append(sm.seen, false)
+	}
+	for id := 1; id < len(pop); id++ {
+		_, gone := sm.extinct[id]
+		switch {
+		case pop[id] > 0:
+			sm.seen[id] = true
+			delete(sm.extinct, id) // painted back
+		case sm.seen[id] && !gone:
+			sm.extinct[id] = now
+		}
+	}
+	if now < sm.next {
+		return
+	}
+	for sm.next <= now {
+		sm.next += sm.every
+	}
+	sm.emit(sm.line(now, pop))
+	sm.last = pop
+}
+
+// line returns the summary of pop at now.
+func (sm *summary) line(now time.Duration, pop []int) string {
+	species := sm.layers[0].e.Species()
+	var parts []string
+	for id := 1; id < min(len(pop), len(species)); id++ {
+		name := species[id].Name
+		if at, ok := sm.extinct[id]; ok {
+			parts = append(parts, fmt.Sprintf("%s extinct at t=%s", name, sm.round(at)))
+			continue
+		}
+		part := fmt.Sprintf("%s %d", name, pop[id])
+		if id < len(sm.last) {
+			switch {
+			case pop[id] > sm.last[id]:
+				part += " ↑"
+			case pop[id] < sm.last[id]:
+				part += " ↓"
+			}
+		}
+		parts = append(parts, part)
+	}
+	return fmt.Sprintf("t=%s: %s", sm.round(now), strings.Join(parts, ", "))
+}
+
+// round rounds d to whole seconds, or tenths of a second if the summary
+// comes more often.
+func (sm *summary) round(d time.Duration) time.Duration {
+	if sm.every < time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Second)
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"app/engine"
+)
+
+// runSweep implements the "sweep" subcommand: it runs the configured
+// simulation headless for every combination of the parameter values listed
+// in a sweep file and writes the averaged outcome of each combination.
+//
+// A sweep file maps config keys, dotted for nested tables, to the values
+// to try. species.NAME.KEY sets KEY of the species called NAME:
+//
+//	density = [0.1, 0.3, 0.5]
+//	mutation = [0, 0.01]
+//	"dead.reaction_time" = ["50ms", "100ms"]
+//	"species.red.reaction_time" = ["100ms", "300ms"]
+func runSweep(args []string) {
+	cfg := defaultConfig()
+	if path := configFlag(args); path != "" {
+		if err := cfg.load(path); err != nil {
+			log.Fatalf("loading config: %v", err)
+		}
+	}
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	fs.String("config", "", "load settings from a TOML or YAML file; other flags and the sweep override it")
+	cfg.bindFlags(fs)
+	ticks := fs.Int("ticks", 1000, "ticks to run each simulation for, unless it settles first")
+	cycle := fs.Int("cycle", 8, "longest period of a cycle counted as a steady state")
+	repeats := fs.Int("repeats", 1, "simulations per combination, averaged")
+	parallel := fs.Int("parallel", 1, "simulations to run at once")
+	model := fs.String("model", "timed", fmt.Sprintf("how cells are updated, one of %v", engine.Models))
+	out := fs.String("out", "", "write the results to this .csv or .json file instead of CSV on stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s sweep [flags] SWEEPFILE\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	closeLog, err := startLogging(&cfg)
+	if err != nil {
+		log.Fatalf("opening log: %v", err)
+	}
+	defer closeLog()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	m, err := engine.ParseModel(*model)
+	if err != nil {
+		log.Fatalf("parsing model: %v", err)
+	}
+	if *cycle < 1 || *repeats < 1 || *parallel < 1 {
+		log.Fatalf("cycle, repeats and parallel must be at least 1")
+	}
+	axes, err := loadSweep(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("loading sweep: %v", err)
+	}
+	cfg.Stats, cfg.StatsJSONL, cfg.Autosave, cfg.EventLog, cfg.MergeSize, cfg.Video, cfg.Montage = "", "", 0, "", 0, "", ""
+	combos := combinations(axes)
+	var runs []Config // repeats runs of each combination in turn
+	for i, combo := range combos {
+		rc, err := cfg.with(axes, combo)
+		if err != nil {
+			log.Fatalf("combination %d: %v", i+1, err)
+		}
+		if rc.MapFile != "" {
+			log.Fatalf("combination %d: a sweep cannot keep its simulations in one map file", i+1)
+		}
+		for k := range *repeats {
+			if cfg.Seed != 0 {
+				rc.Seed = cfg.Seed + int64(k)
+			}
+			runs = append(runs, rc)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	rand.Seed(time.Now().UnixNano())
+	results, err := simulateAll(ctx, runs, m, *ticks, *cycle, *parallel)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var rows []sweepRow
+	for i, combo := range combos {
+		rows = append(rows, average(combo, results[i**repeats:(i+1)**repeats]))
+	}
+	w := io.Writer(os.Stdout)
+	asJSON := false
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("writing results: %v", err)
+		}
+		defer f.Close()
+		w, asJSON = f, strings.ToLower(filepath.Ext(*out)) == ".json"
+	}
+	species := results[0].species[1:]
+	if asJSON {
+		err = writeSweepJSON(w, axes, species, rows)
+	} else {
+		err = writeSweepCSV(w, axes, species, rows)
+	}
+	if err != nil {
+		log.Fatalf("writing results: %v", err)
+	}
+}
+
+// A sweepAxis is a config key and the values it takes in a sweep.
+type sweepAxis struct {
+	key    string
+	values []any
+}
+
+// loadSweep reads a TOML or YAML sweep file, returning its axes sorted by
+// key. A key given a single value rather than a list keeps that value.
+func loadSweep(path string) ([]sweepAxis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".toml":
+		_, err = toml.Decode(string(data), &doc)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("%s: unsupported sweep format %q (want .toml, .yaml or .yml)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var axes []sweepAxis
+	var flatten func(prefix string, m map[string]any)
+	flatten = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			switch v := v.(type) {
+			case map[string]any:
+				flatten(prefix+k+".", v)
+			case []any:
+				axes = append(axes, sweepAxis{prefix + k, v})
+			default:
+				axes = append(axes, sweepAxis{prefix + k, []any{v}})
+			}
+		}
+	}
+	flatten("", doc)
+	for _, a := range axes {
+		if len(a.values) == 0 {
+			return nil, fmt.Errorf("%s: %s has no values", path, a.key)
+		}
+	}
+	slices.SortFunc(axes, func(a, b sweepAxis) int { return strings.Compare(a.key, b.key) })
+	return axes, nil
+}
+
+// combinations returns every combination of one value per axis, the last
+// axis varying fastest.
+func combinations(axes []sweepAxis) [][]any {
+	combos := [][]any{nil}
+	for _, a := range axes {
+		var next [][]any
+		for _, c := range combos {
+			for _, v := range a.values {
+				next = append(next, append(slices.Clip(c), v))
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// with returns a copy of cfg with each axis key set to the value of combo
+// at the same index.
+func (cfg *Config) with(axes []sweepAxis, combo []any) (Config, error) {
+	c := cfg.clone()
+	for i, a := range axes {
+		target, key := any(&c), a.key
+		if rest, ok := strings.CutPrefix(key, "species."); ok {
+			name, field, ok := strings.Cut(rest, ".")
+			k := slices.IndexFunc(c.Species, func(sc SpeciesConfig) bool { return sc.Name == name })
+			if !ok || k < 0 {
+				return c, fmt.Errorf("%s: no species %q", a.key, name)
+			}
+			target, key = &c.Species[k], field
+		}
+		doc := map[string]any{}
+		parts := strings.Split(key, ".")
+		m := doc
+		for _, p := range parts[:len(parts)-1] {
+			sub := map[string]any{}
+			m[p] = sub
+			m = sub
+		}
+		m[parts[len(parts)-1]] = combo[i]
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(doc); err != nil {
+			return c, fmt.Errorf("%s: %w", a.key, err)
+		}
+		md, err := toml.Decode(buf.String(), target)
+		if err != nil {
+			return c, fmt.Errorf("%s: %w", a.key, err)
+		}
+		if len(md.Undecoded()) > 0 {
+			return c, fmt.Errorf("unknown key %q", a.key)
+		}
+	}
+	return c, nil
+}
+
+// sweepResult is the outcome of one headless simulation.
+type sweepResult struct {
+	fixation, period int // period 0 if it never settled
+	species          []string
+	population       []int // by species id
+	// extinct is the first tick at whose end each species id had no
+	// cells left, or 0 if it had some at the end of the run.
+	extinct    []int
+	complexity engine.Complexity
+}
+
+// simulate runs the simulation described by cfg like the "run" subcommand,
+// unless ctx is done first.
+func simulate(ctx context.Context, cfg *Config, m engine.Model, ticks, cycle int) (sweepResult, error) {
+	layers, release, err := buildLayers(cfg)
+	if err != nil {
+		return sweepResult{}, err
+	}
+	defer release()
+	if cfg.Timeline != "" {
+		if err := startTimeline(cfg.Timeline, layers, func(msg string) { slog.Warn("timeline command failed", "err", msg) }); err != nil {
+			return sweepResult{}, err
+		}
+	}
+	var r sweepResult
+	for _, sp := range layers[0].e.Species() {
+		r.species = append(r.species, sp.Name)
+	}
+	r.extinct = make([]int, len(r.species))
+	pops := make([][]int, len(layers))
+	for k, l := range layers {
+		l.e.OnTick(func(s engine.Stats) {
+			pops[k] = s.Population
+			if k < len(layers)-1 {
+				return // layers tick bottom first, so the totals are complete at the top
+			}
+			for id := 1; id < len(r.extinct); id++ {
+				total := 0
+				for _, pop := range pops {
+					if id < len(pop) {
+						total += pop[id]
+					}
+				}
+				if total == 0 && r.extinct[id] == 0 {
+					r.extinct[id] = s.Tick
+				}
+			}
+		})
+	}
+	r.fixation, r.period = steady(ctx, layers, m, ticks, cycle)
+	if err := ctx.Err(); err != nil {
+		return sweepResult{}, err
+	}
+	r.population = census(layers)
+	for id := 1; id < len(r.extinct); id++ {
+		if r.population[id] > 0 {
+			r.extinct[id] = 0 // died out on one tick's census but came back
+		}
+	}
+	r.complexity = layers[0].e.Complexity()
+	return r, nil
+}
+
+// simulateAll simulates every configuration of runs, parallel at once,
+// giving up if ctx is done first.
+func simulateAll(ctx context.Context, runs []Config, m engine.Model, ticks, cycle, parallel int) ([]sweepResult, error) {
+	results := make([]sweepResult, len(runs))
+	errs := make([]error, len(runs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range parallel {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = simulate(ctx, &runs[i], m, ticks, cycle)
+			}
+		}()
+	}
+feed:
+	for i := range runs {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("interrupted: %w", err)
+	}
+	return results, errors.Join(errs...)
+}
+
+// sweepRow is the average outcome of the runs of one combination.
+type sweepRow struct {
+	values   []any
+	runs     int
+	settled  float64  // fraction of runs that reached a steady state
+	fixation *float64 // mean ticks to fixation of those, nil if none did
+	// population is the mean final population of each live species.
+	population           []float64
+	entropy, compression float64
+}
+
+func average(combo []any, runs []sweepResult) sweepRow {
+	row := sweepRow{values: combo, runs: len(runs), population: make([]float64, len(runs[0].population)-1)}
+	n := float64(len(runs))
+	settled, fixation := 0, 0
+	for _, r := range runs {
+		if r.period > 0 {
+			settled++
+			fixation += r.fixation
+		}
+		for id, p := range r.population[1:] {
+			row.population[id] += float64(p) / n
+		}
+		row.entropy += r.complexity.Entropy / n
+		row.compression += r.complexity.Compression / n
+	}
+	row.settled = float64(settled) / n
+	if settled > 0 {
+		f := float64(fixation) / float64(settled)
+		row.fixation = &f
+	}
+	return row
+}
+
+func writeSweepCSV(w io.Writer, axes []sweepAxis, species []string, rows []sweepRow) error {
+	cw := csv.NewWriter(w)
+	var header []string
+	for _, a := range axes {
+		header = append(header, a.key)
+	}
+	header = append(header, "runs", "settled", "fixation")
+	header = append(header, species...)
+	header = append(header, "entropy", "compression")
+	cw.Write(header)
+	for _, row := range rows {
+		var rec []string
+		for _, v := range row.values {
+			rec = append(rec, fmt.Sprint(v))
+		}
+		fixation := ""
+		if row.fixation != nil {
+			fixation = strconv.FormatFloat(*row.fixation, 'f', 1, 64)
+		}
+		rec = append(rec, strconv.Itoa(row.runs), strconv.FormatFloat(row.settled, 'f', 2, 64), fixation)
+		for _, p := range row.population {
+			rec = append(rec, strconv.FormatFloat(p, 'f', 1, 64))
+		}
+		rec = append(rec, strconv.FormatFloat(row.entropy, 'f', 4, 64), strconv.FormatFloat(row.compression, 'f', 4, 64))
+		cw.Write(rec)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeSweepJSON(w io.Writer, axes []sweepAxis, species []string, rows []sweepRow) error {
+	type result struct {
+		Params      map[string]any     `json:"params"`
+		Runs        int                `json:"runs"`
+		Settled     float64            `json:"settled"`
+		Fixation    *float64           `json:"fixation"`
+		Population  map[string]float64 `json:"population"`
+		Entropy     float64            `json:"entropy"`
+		Compression float64            `json:"compression"`
+	}
+	var results []result
+	for _, row := range rows {
+		r := result{
+			Params:      make(map[string]any),
+			Runs:        row.runs,
+			Settled:     row.settled,
+			Fixation:    row.fixation,
+			Population:  make(map[string]float64),
+			Entropy:     row.entropy,
+			Compression: row.compression,
+		}
+		for i, a := range axes {
+			r.Params[a.key] = row.values[i]
+		}
+		for id, p := range row.population {
+			r.Population[species[id]] = p
+		}
+		results = append(results, r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gdamore/tcell/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// cellStyle is how a theme draws a kind of cell: the characters of its two
+// screen columns, their color and attributes, and its background. Empty
+// fields keep the default: spaces, and the colors of the configuration.
+type cellStyle struct {
+	Glyph string `toml:"glyph" yaml:"glyph"`
+	Fg    string `toml:"fg" yaml:"fg"`
+	Bg    string `toml:"bg" yaml:"bg"`
+	Bold  bool   `toml:"bold" yaml:"bold"`
+	Dim   bool   `toml:"dim" yaml:"dim"`
+}
+
+// theme is the look of the grid, read from a theme file or built in. Live
+// cells take the style of their species, by name or id, over that of Live,
+// field by field.
+type theme struct {
+	Dead       cellStyle            `toml:"dead" yaml:"dead"`
+	Wall       cellStyle            `toml:"wall" yaml:"wall"`
+	Live       cellStyle            `toml:"live" yaml:"live"`
+	Species    map[string]cellStyle `toml:"species" yaml:"species"`
+	Still      cellStyle            `toml:"still" yaml:"still"` // the marks of -structures
+	Oscillator cellStyle            `toml:"oscillator" yaml:"oscillator"`
+	Turmite    cellStyle            `toml:"turmite" yaml:"turmite"` // its glyph is its heading
+}
+
+// themes are the built-in themes, by name.
+var themes = map[string]theme{
+	"default": {
+		Dead:       cellStyle{Fg: "green"},
+		Live:       cellStyle{Fg: "black"},
+		Still:      cellStyle{Glyph: "•", Fg: "white"},
+		Oscillator: cellStyle{Glyph: "◦", Fg: "white"},
+		Turmite:    cellStyle{Fg: "white", Bold: true},
+	},
+	// high-contrast tells the species apart by their characters as well as
+	// by bright backgrounds on black.
+	"high-contrast": {
+		Dead: cellStyle{Fg: "white", Bg: "black"},
+		Wall: cellStyle{Glyph: "▒▒", Fg: "white", Bg: "black"},
+		Live: cellStyle{Fg: "black", Bold: true},
+		Species: map[string]cellStyle{
+			"1": {Glyph: "++", Bg: "yellow"},
+			"2": {Glyph: "xx", Bg: "aqua"},
+			"3": {Glyph: "oo", Bg: "fuchsia"},
+			"4": {Glyph: "##", Bg: "white"},
+			"5": {Glyph: "==", Bg: "lime"},
+			"6": {Glyph: "//", Bg: "orange"},
+		},
+		Still:      cellStyle{Glyph: "■", Fg: "black"},
+		Oscillator: cellStyle{Glyph: "□", Fg: "black"},
+		Turmite:    cellStyle{Fg: "red", Bg: "white", Bold: true},
+	},
+}
+
+// themeNames returns the names of the built-in themes.
+func themeNames() []string { return []string{"default", "high-contrast"} }
+
+// cellLook is a cellStyle resolved for drawing. Colors left to the
+// configuration are tcell.ColorDefault.
+type cellLook struct {
+	glyphs    [2]rune
+	fg, bg    tcell.Color
+	bold, dim bool
+}
+
+// colors returns the colors of lk, with fg and bg for those it leaves to
+// the configuration.
+func (lk cellLook) colors(fg, bg tcell.Color) (tcell.Color, tcell.Color) {
+	if lk.fg != tcell.ColorDefault {
+		fg = lk.fg
+	}
+	if lk.bg != tcell.ColorDefault {
+		bg = lk.bg
+	}
+	return fg, bg
+}
+
+// style returns the style of lk drawn in fg on bg.
+func (lk cellLook) style(fg, bg tcell.Color) tcell.Style {
+	return tcell.StyleDefault.Foreground(fg).Background(bg).Bold(lk.bold).Dim(lk.dim)
+}
+
+// resolve checks s and returns its look, with the fields it leaves empty
+// taken from under.
+func (s cellStyle) resolve(under cellLook) (cellLook, error) {
+	lk := under
+	if s.Glyph != "" {
+		r := []rune(s.Glyph)
+		if len(r) > 2 {
+			return lk, fmt.Errorf("glyph %q has more than two characters", s.Glyph)
+		}
+		lk.glyphs = [2]rune{r[0], ' '}
+		if len(r) == 2 {
+			lk.glyphs[1] = r[1]
+		}
+	}
+	for _, c := range []struct {
+		name string
+		to   *tcell.Color
+	}{{s.Fg, &lk.fg}, {s.Bg, &lk.bg}} {
+		if c.name == "" {
+			continue
+		}
+		if *c.to = tcell.GetColor(c.name); *c.to == tcell.ColorDefault {
+			return lk, fmt.Errorf("unknown color %q", c.name)
+		}
+	}
+	lk.bold, lk.dim = lk.bold || s.Bold, lk.dim || s.Dim
+	return lk, nil
+}
+
+// themeLooks is a theme resolved for drawing.
+type themeLooks struct {
+	dead, wall, live, still, oscillator, turmite cellLook
+	species                                      map[string]cellLook // by name or id
+	// mono draws every cell in the terminal's default colors, species
+	// the theme gives no glyph as letters; see monoGlyph.
+	mono bool
+}
+
+// of returns the look of species id of a layer whose species are names,
+// dead first.
+func (tl *themeLooks) of(id int, names []string) cellLook {
+	lk, ok := tl.live, false
+	if id < len(names) {
+		lk, ok = tl.species[names[id]]
+	}
+	if !ok {
+		if lk, ok = tl.species[strconv.Itoa(id)]; !ok {
+			lk = tl.live
+		}
+	}
+	if tl.mono && lk.glyphs == blankGlyphs {
+		lk.glyphs = [2]rune{monoGlyph(id, names), ' '}
+	}
+	return lk
+}
+
+// blankGlyphs are those of a style that sets none.
+var blankGlyphs = [2]rune{' ', ' '}
+
+// monoGlyph returns the letter species id is drawn as in mono: the first
+// of its name, capitalized, unless another species' name starts with it
+// too, else the letter of its id as in engine.Engine.Text.
+func monoGlyph(id int, names []string) rune {
+	if id < len(names) && names[id] != "" {
+		r := unicode.ToUpper([]rune(names[id])[0])
+		unique := true
+		for k, name := range names[1:] {
+			if k+1 != id && name != "" && unicode.ToUpper([]rune(name)[0]) == r {
+				unique = false
+			}
+		}
+		if unique {
+			return r
+		}
+	}
+	if id <= 24 {
+		return rune('A' + id - 1)
+	}
+	return '?'
+}
+
+// theme returns the looks of cfg.Theme, a built-in theme or a TOML or YAML
+// theme file whose styles are laid over the default theme, drawn in mono if
+// cfg.Mono is set. On error it returns those of the default theme.
+func (cfg *Config) theme() (*themeLooks, error) {
+	tl, err := themes["default"].resolve()
+	if err != nil {
+		panic(err)
+	}
+	if cfg.Theme != "" && cfg.Theme != "default" {
+		t, ok := themes[cfg.Theme]
+		if !ok {
+			if t, err = loadTheme(cfg.Theme); err != nil {
+				return tl, err
+			}
+		}
+		looks, err := t.resolve()
+		if err != nil {
+			return tl, fmt.Errorf("%s: %w", cfg.Theme, err)
+		}
+		tl = looks
+	}
+	if cfg.Mono {
+		tl.mono = true
+		for _, c := range []struct {
+			lk    *cellLook
+			glyph rune
+		}{{&tl.dead, '.'}, {&tl.wall, '#'}} {
+			if c.lk.glyphs == blankGlyphs {
+				c.lk.glyphs = [2]rune{c.glyph, ' '}
+			}
+		}
+	}
+	return tl, nil
+}
+
+// loadTheme reads the theme file at path.
+func loadTheme(path string) (theme, error) {
+	t := themes["default"]
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".toml" && ext != ".yaml" && ext != ".yml" {
+		return t, fmt.Errorf("%s: not a built-in theme (%s) nor a theme file (.toml, .yaml or .yml)", path, strings.Join(themeNames(), ", "))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return t, err
+	}
+	switch ext {
+	case ".toml":
+		md, err := toml.Decode(string(data), &t)
+		if err != nil {
+			return t, fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return t, fmt.Errorf("%s: unknown key %q", path, undecoded[0].String())
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&t); err != nil {
+			return t, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return t, nil
+}
+
+// resolve checks t and returns its looks.
+func (t theme) resolve() (*themeLooks, error) {
+	blank := cellLook{glyphs: blankGlyphs, fg: tcell.ColorDefault, bg: tcell.ColorDefault}
+	tl := &themeLooks{species: map[string]cellLook{}}
+	for _, s := range []struct {
+		name  string
+		style cellStyle
+		to    *cellLook
+	}{
+		{"dead", t.Dead, &tl.dead},
+		{"wall", t.Wall, &tl.wall},
+		{"live", t.Live, &tl.live},
+		{"still", t.Still, &tl.still},
+		{"oscillator", t.Oscillator, &tl.oscillator},
+		{"turmite", t.Turmite, &tl.turmite},
+	} {
+		lk, err := s.style.resolve(blank)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.name, err)
+		}
+		*s.to = lk
+	}
+	for name, s := range t.Species {
+		lk, err := s.resolve(tl.live)
+		if err != nil {
+			return nil, fmt.Errorf("species %s: %w", name, err)
+		}
+		tl.species[name] = lk
+	}
+	return tl, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"app/engine"
+)
+
+// timelineEntry is a command of a timeline, to run at a simulated time.
+type timelineEntry struct {
+	at      time.Duration
+	command string
+	line    int
+}
+
+// loadTimeline reads the timeline file at path: a command of commander per
+// line, prefixed with the simulated time to run it at, such as
+//
+//	t=10s: stamp gun at 5,5
+//
+// Blank lines and lines starting with # are skipped. The entries are
+// returned in the order of their times, those at the same time in the
+// order of the file.
+func loadTimeline(path string) ([]timelineEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []timelineEntry
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		at, command, ok := strings.Cut(line, ":")
+		at, isTime := strings.CutPrefix(strings.TrimSpace(at), "t=")
+		d, err := time.ParseDuration(at)
+		if !ok || !isTime || err != nil || d < 0 {
+			return nil, fmt.Errorf("%s:%d: want t=<time>: <command>, not %q", path, n, line)
+		}
+		entries = append(entries, timelineEntry{d, strings.TrimSpace(command), n})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(entries, func(a, b timelineEntry) int { return cmp.Compare(a.at, b.at) })
+	return entries, nil
+}
+
+// startTimeline runs the commands of the timeline file at path on layers as
+// the simulated time of the bottom one reaches theirs, at the end of a
+// tick, and passes report the errors of those that fail.
+func startTimeline(path string, layers []*layer, report func(string)) error {
+	entries, err := loadTimeline(path)
+	if err != nil {
+		return err
+	}
+	cm := &commander{layers}
+	var mu sync.Mutex
+	e := layers[0].e
+	e.OnTick(func(engine.Stats) {
+		mu.Lock()
+		defer mu.Unlock()
+		for len(entries) > 0 && entries[0].at <= e.Now() {
+			en := entries[0]
+			entries = entries[1:]
+			run := func() {
+				if err := cm.run(en.command); err != nil {
+					report(fmt.Sprintf("%s:%d: %v", path, en.line, err))
+				}
+			}
+			// Pause, which fast-forwarding a running engine takes, waits
+			// for the tick hooks to return.
+			if f := strings.Fields(en.command); len(f) > 0 && (f[0] == "pause" || f[0] == "fastforward" && e.Started()) {
+				go run()
+				continue
+			}
+			run()
+		}
+	})
+	return nil
+}
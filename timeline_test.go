@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"app/engine"
+)
+
+// TestTimelineFastForward checks that a timeline fast-forwards a running
+// engine, which then ticks on.
+func TestTimelineFastForward(t *testing.T) {
+	params := engine.DefaultParams()
+	params.Rows, params.Cols = 20, 20
+	params.Boundary = engine.BoundaryDead
+	params.TickInterval = 10 * time.Millisecond
+	params.Rand = engine.NewRand(1)
+	e, err := engine.New(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Seed(0.3)
+	path := filepath.Join(t.TempDir(), "timeline.txt")
+	if err := os.WriteFile(path, []byte("t=0s: fastforward 100\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	failed := make(chan string, 1)
+	if err := startTimeline(path, []*layer{{e: e}}, func(msg string) { failed <- msg }); err != nil {
+		t.Fatal(err)
+	}
+	forwarded := make(chan struct{})
+	e.OnEvent(func(ev engine.Event) {
+		if strings.HasPrefix(ev.Text, "fast-forwarded") {
+			close(forwarded)
+		}
+	})
+	e.Start()
+	defer e.Stop()
+
+	select {
+	case <-forwarded:
+	case msg := <-failed:
+		t.Fatal(msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the timeline did not fast-forward")
+	}
+	for ticks, deadline := e.Ticks(), time.Now().Add(5*time.Second); e.Ticks() <= ticks+2; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("stuck at tick %d after fast-forwarding", ticks)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tuneFactor is how much a key press lengthens or shortens a reaction time.
+const tuneFactor = 1.25
+
+// tuner changes the reaction time of a species on every layer from the
+// keyboard while the simulation runs, so that the effect of one species
+// updating faster than another can be felt. Its status shows the reaction
+// times once a species is chosen.
+type tuner struct {
+	layers []*layer // of every pane, the bottom layer of the first one first
+	keys   map[string][]string
+
+	mu      sync.Mutex
+	species int // the one tuned, 0 for none
+}
+
+// next chooses the next species, or none after the last one.
+func (tu *tuner) next() {
+	tu.mu.Lock()
+	defer tu.mu.Unlock()
+	tu.species = (tu.species + 1) % len(tu.layers[0].e.Species())
+}
+
+// chosen returns the name of the species chosen, "" for none.
+func (tu *tuner) chosen() string {
+	tu.mu.Lock()
+	defer tu.mu.Unlock()
+	if tu.species == 0 {
+		return ""
+	}
+	return tu.layers[0].e.Species()[tu.species].Name
+}
+
+// scale multiplies the reaction time of the chosen species by f, choosing
+// the first one if there is none, on every layer that has it.
+func (tu *tuner) scale(f float64) {
+	tu.mu.Lock()
+	defer tu.mu.Unlock()
+	if tu.species == 0 {
+		tu.species = 1
+	}
+	name := tu.layers[0].e.Species()[tu.species].Name
+	for _, l := range tu.layers {
+		id, err := speciesID(l.e, name)
+		if err != nil {
+			continue
+		}
+		d := time.Duration(float64(l.e.Species()[id].ReactionTime) * f).Round(time.Millisecond)
+		l.e.SetReactionTime(id, max(d, time.Millisecond))
+	}
+}
+
+// status lists the reaction times of the bottom layer, the chosen species
+// marked, while one is.
+func (tu *tuner) status() string {
+	tu.mu.Lock()
+	defer tu.mu.Unlock()
+	if tu.species == 0 {
+		return ""
+	}
+	var parts []string
+	for id, sp := range tu.layers[0].e.Species() {
+		if id == 0 {
+			continue
+		}
+		part := fmt.Sprintf("%s %v", sp.Name, sp.ReactionTime)
+		if id == tu.species {
+			part = "‹" + part + "›"
+		}
+		parts = append(parts, part)
+	}
+	return fmt.Sprintf("reaction times %s [%s %s/%s]", strings.Join(parts, " "),
+		firstKey(tu.keys, actTuneNext), firstKey(tu.keys, actTuneDown), firstKey(tu.keys, actTuneUp))
+}
+
+// firstKey returns the first key bound to action, or "" if none is.
+func firstKey(keys map[string][]string, action string) string {
+	if len(keys[action]) == 0 {
+		return ""
+	}
+	return keys[action][0]
+}
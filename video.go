@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+)
+
+// recorder pipes frames of a layer into an ffmpeg subprocess as raw RGB,
+// each cell a square of scale pixels, to encode them as the video file
+// cfg.Video. With blend above 1, every capture is written as blend frames
+// cross-fading from the previous one to it.
+type recorder struct {
+	l      *layer
+	scale  int
+	blend  int
+	cmd    *exec.Cmd
+	in     io.WriteCloser
+	stderr bytes.Buffer
+
+	mu    sync.Mutex
+	frame []byte
+	last  []byte // the previous capture when blending, nil before the first
+	faded []byte // a frame between last and frame
+	err   error  // of the first failed write, after which frames are dropped
+}
+
+// startVideo starts ffmpeg encoding frames of l at cfg.VideoFPS frames per
+// second of video into cfg.Video.
+func startVideo(cfg *Config, l *layer) (*recorder, error) {
+	if cfg.VideoFPS <= 0 || cfg.VideoScale <= 0 {
+		return nil, fmt.Errorf("video_fps and video_scale must be positive")
+	}
+	if cfg.VideoBlend < 0 {
+		return nil, fmt.Errorf("video_blend must not be negative")
+	}
+	r := &recorder{l: l, scale: cfg.VideoScale, blend: max(cfg.VideoBlend, 1)}
+	w, h := l.e.Cols()*r.scale, l.e.Rows()*r.scale
+	r.frame = make([]byte, 3*w*h)
+	r.cmd = exec.Command("ffmpeg", "-y", "-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "rgb24", "-s", fmt.Sprintf("%dx%d", w, h), "-r", fmt.Sprint(cfg.VideoFPS), "-i", "-",
+		// Most encoders want even dimensions for yuv420p, which players
+		// expect.
+		"-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2", "-pix_fmt", "yuv420p", cfg.Video)
+	r.cmd.Stderr = &r.stderr
+	in, err := r.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting ffmpeg: %w", err)
+	}
+	r.in = in
+	return r, nil
+}
+
+// capture renders the layer as it is now and sends it to ffmpeg, as blend
+// frames when blending. It may be called from tick hooks.
+func (r *recorder) capture() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	cells := r.l.e.Snapshot().Cells
+	w := len(r.frame) / 3 / max(len(cells)*r.scale, 1)
+	for x, row := range cells {
+		for y, s := range row {
+			cr, cg, cb := r.l.color(s).RGB()
+			for i := range r.scale {
+				p := 3 * ((x*r.scale+i)*w + y*r.scale)
+				for range r.scale {
+					r.frame[p], r.frame[p+1], r.frame[p+2] = byte(cr), byte(cg), byte(cb)
+					p += 3
+				}
+			}
+		}
+	}
+	if r.blend == 1 || r.last == nil {
+		_, r.err = r.in.Write(r.frame)
+	} else {
+		r.fade()
+	}
+	if r.blend > 1 {
+		if r.last == nil {
+			r.last, r.faded = make([]byte, len(r.frame)), make([]byte, len(r.frame))
+		}
+		copy(r.last, r.frame)
+	}
+}
+
+// fade writes the frames from r.last to r.frame, the last of them r.frame
+// itself, each channel of every pixel moving in even steps: in truecolor,
+// a cell flickering between two states between captures shows as a shade
+// between them rather than a flash.
+func (r *recorder) fade() {
+	for i := 1; i < r.blend && r.err == nil; i++ {
+		for p, to := range r.frame {
+			from := int(r.last[p])
+			r.faded[p] = byte(from + (int(to)-from)*i/r.blend)
+		}
+		_, r.err = r.in.Write(r.faded)
+	}
+	if r.err == nil {
+		_, r.err = r.in.Write(r.frame)
+	}
+}
+
+// every captures a frame every interval until stop is closed.
+func (r *recorder) every(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			r.capture()
+		}
+	}
+}
+
+// close finishes the video and waits for ffmpeg to write it.
+func (r *recorder) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.in.Close()
+	err := r.cmd.Wait()
+	if msg := strings.TrimSpace(r.stderr.String()); err != nil && msg != "" {
+		return fmt.Errorf("ffmpeg: %s", msg)
+	}
+	if err == nil {
+		err = r.err
+	}
+	return err
+}
+
+// color returns the plain color of a cell of l, without the display's
+// shading, trails and overlays.
+func (l *layer) color(s engine.State) tcell.Color {
+	switch {
+	case s.Wall:
+		return l.wall
+	case l.intensity != nil:
+		return gradient(l.intensity(s))
+	}
+	return l.speciesColor(s.Species)
+}
@@ -0,0 +1,144 @@
+// Package wasmrule provides an engine.Transition implemented by a
+// WebAssembly module, so rules can be written in any language that compiles
+// to WASM and run sandboxed.
+//
+// # Host ABI
+//
+// The module must export:
+//
+//	memory                                     its linear memory
+//	counts_ptr() -> i32                        address of an i32 array with
+//	                                           room for nspecies+1 entries
+//	next_state(self, total, nspecies i32) -> i32
+//
+// Before each call to next_state the host writes the live-neighbour count of
+// every species id into the counts array (index 0, dead, is always 0). self
+// is the cell's species id (0 for dead), total the number of live
+// neighbours, and nspecies the number of live species. next_state returns
+// the next species id, 0 for dead.
+//
+// The host provides one optional import, env.random() -> f64, returning a
+// uniform random number in [0, 1). WASI preview 1 is also available, so
+// modules built with GOOS=wasip1 or wasi-libc work; a module exporting
+// _initialize is treated as a reactor and initialized once.
+package wasmrule
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"app/engine"
+)
+
+// Rule runs next_state from a WASM module. Calls are serialized, since a
+// module instance is not safe for concurrent use.
+type Rule struct {
+	nspecies int
+
+	mu        sync.Mutex
+	rt        wazero.Runtime
+	mod       api.Module
+	nextState api.Function
+	counts    uint32
+	buf       []byte
+	err       error
+}
+
+// Load instantiates the module at path for an engine with the given number
+// of live species.
+func Load(path string, nspecies int) (*Rule, error) {
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	rt := wazero.NewRuntime(ctx)
+	r := &Rule{nspecies: nspecies, rt: rt, buf: make([]byte, 4*(nspecies+1))}
+	fail := func(err error) (*Rule, error) {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+	_, err = rt.NewHostModuleBuilder("env").
+		NewFunctionBuilder().
+		WithFunc(func() float64 { return rand.Float64() }).
+		Export("random").
+		Instantiate(ctx)
+	if err != nil {
+		return fail(err)
+	}
+
+	compiled, err := rt.CompileModule(ctx, wasm)
+	if err != nil {
+		return fail(err)
+	}
+	cfg := wazero.NewModuleConfig().WithStartFunctions("_initialize")
+	r.mod, err = rt.InstantiateModule(ctx, compiled, cfg)
+	if err != nil {
+		return fail(err)
+	}
+
+	r.nextState = r.mod.ExportedFunction("next_state")
+	countsPtr := r.mod.ExportedFunction("counts_ptr")
+	if r.nextState == nil || countsPtr == nil {
+		return fail(fmt.Errorf("module must export next_state and counts_ptr"))
+	}
+	if r.mod.Memory() == nil {
+		return fail(fmt.Errorf("module must export its memory"))
+	}
+	res, err := countsPtr.Call(ctx)
+	if err != nil {
+		return fail(fmt.Errorf("counts_ptr: %w", err))
+	}
+	r.counts = api.DecodeU32(res[0])
+	if _, ok := r.mod.Memory().Read(r.counts, uint32(len(r.buf))); !ok {
+		return fail(fmt.Errorf("counts buffer at %#x is out of bounds", r.counts))
+	}
+	return r, nil
+}
+
+// Err returns the most recent runtime error, or nil.
+func (r *Rule) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close releases the WASM runtime.
+func (r *Rule) Close() error {
+	return r.rt.Close(context.Background())
+}
+
+func (r *Rule) Next(self engine.State, n engine.Neighborhood) engine.State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clear(r.buf)
+	for id := 1; id < len(n.Counts) && id <= r.nspecies; id++ {
+		binary.LittleEndian.PutUint32(r.buf[4*id:], uint32(n.Counts[id]))
+	}
+	r.mod.Memory().Write(r.counts, r.buf)
+
+	res, err := r.nextState.Call(context.Background(),
+		api.EncodeI32(int32(self.Species)), api.EncodeI32(int32(n.Total)), api.EncodeI32(int32(r.nspecies)))
+	if err != nil {
+		r.err = err
+		return self
+	}
+	next := int(api.DecodeI32(res[0]))
+	if next < 0 || next > r.nspecies {
+		r.err = fmt.Errorf("next_state returned invalid species %d", next)
+		return self
+	}
+	return engine.State{Species: next}
+}
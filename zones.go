@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+
+	"app/engine"
+)
+
+// ZoneConfig is a rectangle of the grid with parameters of its own; see
+// engine.Zone. Rows and Cols of 0 reach the bottom and right edges, and
+// Rule, if set, is a rulestring.
+type ZoneConfig struct {
+	Top   int     `toml:"top" yaml:"top"`
+	Left  int     `toml:"left" yaml:"left"`
+	Rows  int     `toml:"rows" yaml:"rows"`
+	Cols  int     `toml:"cols" yaml:"cols"`
+	Speed float64 `toml:"speed" yaml:"speed"`
+	Rule  string  `toml:"rule" yaml:"rule"`
+	Decay float64 `toml:"decay" yaml:"decay"`
+}
+
+// zones returns the zones of cfg.Zones.
+func (cfg *Config) zones() ([]engine.Zone, error) {
+	var zones []engine.Zone
+	for i, zc := range cfg.Zones {
+		z := engine.Zone{
+			Top:   zc.Top,
+			Left:  zc.Left,
+			Rows:  zc.Rows,
+			Cols:  zc.Cols,
+			Speed: zc.Speed,
+			Decay: zc.Decay,
+		}
+		if z.Rows == 0 {
+			z.Rows = cfg.Rows - z.Top
+		}
+		if z.Cols == 0 {
+			z.Cols = cfg.Cols - z.Left
+		}
+		if zc.Rule != "" {
+			rule, err := engine.ParseRule(zc.Rule)
+			if err != nil {
+				return nil, fmt.Errorf("zone %d: %w", i+1, err)
+			}
+			z.Rule = &rule
+		}
+		zones = append(zones, z)
+	}
+	return zones, nil
+}
+
+// seamTint is the color the seams of zones are tinted with, and seamShare
+// how much.
+var seamTint = tcell.NewRGBColor(255, 255, 255)
+
+const seamShare = 0.15
+
+// zoneSeams returns, by cell, whether it lies on the seam of a zone of e:
+// whether an orthogonal neighbour is in an earlier zone or none, so that a
+// seam is drawn inside the zone that wins there. It returns nil if e has
+// no zones.
+func zoneSeams(e *engine.Engine) []bool {
+	zones := e.Zones()
+	if len(zones) == 0 {
+		return nil
+	}
+	rows, cols := e.Rows(), e.Cols()
+	zone := make([]int, rows*cols) // by cell, 1 + the index of its zone, 0 for none
+	for i, z := range zones {
+		for x := max(z.Top, 0); x < min(z.Top+z.Rows, rows); x++ {
+			for y := max(z.Left, 0); y < min(z.Left+z.Cols, cols); y++ {
+				zone[x*cols+y] = i + 1
+			}
+		}
+	}
+	seams := make([]bool, rows*cols)
+	for x := range rows {
+		for y := range cols {
+			for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				i, j := x+d[0], y+d[1]
+				if i >= 0 && i < rows && j >= 0 && j < cols && zone[i*cols+j] < zone[x*cols+y] {
+					seams[x*cols+y] = true
+				}
+			}
+		}
+	}
+	return seams
+}